@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/waelbendhia/music-streaming/wms/config"
+	"github.com/waelbendhia/music-streaming/wms/metadata"
+	"github.com/waelbendhia/music-streaming/wms/models"
+	"github.com/waelbendhia/music-streaming/wms/server"
+	"github.com/waelbendhia/music-streaming/wms/store"
+	"gopkg.in/mgo.v2/bson"
+)
+
+//backend is what commands that work either against the storage or against
+// a running server use
+type backend interface {
+	search(query string) ([]models.Release, error)
+	downloads(states []models.JobState) ([]models.Job, error)
+	cancelDownload(id string) error
+	topTracks(days, limit int) ([]store.TopTrack, error)
+	Close() error
+}
+
+//remoteFlags are the flags of commands that can talk to a running server
+type remoteFlags struct {
+	server, token *string
+}
+
+func addRemoteFlags(flags *flag.FlagSet) remoteFlags {
+	return remoteFlags{
+		server: flags.String(
+			"server",
+			os.Getenv("WMS_SERVER"),
+			"URL of a server to use instead of the storage ($WMS_SERVER)",
+		),
+		token: flags.String(
+			"token",
+			os.Getenv("WMS_TOKEN"),
+			"API token sent to the server ($WMS_TOKEN)",
+		),
+	}
+}
+
+//openBackend returns a client of the server if one was given, the
+// configured storage otherwise
+func (f remoteFlags) openBackend(cfg *config.Config) (backend, error) {
+	if *f.server != "" {
+		return newAPIClient(*f.server, *f.token), nil
+	}
+	database, err := openStore(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &localBackend{db: database, cfg: cfg}, nil
+}
+
+//client returns a client of the given server or, if there is none, of the
+// configured one
+func (f remoteFlags) client(cfg *config.Config) *apiClient {
+	if *f.server != "" {
+		return newAPIClient(*f.server, *f.token)
+	}
+	host, port, err := net.SplitHostPort(cfg.HTTP.Addr)
+	if err != nil {
+		return newAPIClient("http://"+cfg.HTTP.Addr, *f.token)
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+	return newAPIClient("http://"+net.JoinHostPort(host, port), *f.token)
+}
+
+//localBackend works directly against the storage
+type localBackend struct {
+	db  store.Store
+	cfg *config.Config
+}
+
+//search returns the library's releases matching query followed by the
+// provider's, the provider is skipped if it isn't configured
+func (b *localBackend) search(query string) ([]models.Release, error) {
+	rels, err := b.db.SearchReleases(query)
+	if err != nil {
+		return nil, err
+	}
+	for i := range rels {
+		if !bson.IsObjectIdHex(rels[i].AlbumArtistID) {
+			continue
+		}
+		artist, err := b.db.Artist(bson.ObjectIdHex(rels[i].AlbumArtistID))
+		if err != nil && err != store.ErrNotFound {
+			return nil, err
+		}
+		rels[i].AlbumArtist = artist
+	}
+	provider, err := metadata.New(b.cfg.MetadataConfig())
+	if err != nil {
+		log.Printf("Not searching the metadata provider: %v", err)
+		return rels, nil
+	}
+	remote, err := provider.SearchReleases(context.Background(), query)
+	if err != nil {
+		return nil, err
+	}
+	return append(rels, remote...), nil
+}
+
+func (b *localBackend) downloads(
+	states []models.JobState,
+) ([]models.Job, error) {
+	return b.db.Jobs(states...)
+}
+
+//cancelDownload marks the job cancelled, it is only meant for when the
+// server is stopped: jobs that are downloading are refused since only the
+// server can drop their torrent
+func (b *localBackend) cancelDownload(id string) error {
+	if !bson.IsObjectIdHex(id) {
+		return fmt.Errorf("invalid ID: %s", id)
+	}
+	job, err := b.db.Job(bson.ObjectIdHex(id))
+	if err != nil {
+		return err
+	}
+	if job.State.Done() {
+		return fmt.Errorf("download is already %s", job.State)
+	}
+	if job.State == models.JobDownloading {
+		return errors.New(
+			"download has started, cancel it through the server with -server",
+		)
+	}
+	job.State = models.JobCancelled
+	job.UpdatedAt = time.Now()
+	return b.db.UpdateJob(job)
+}
+
+func (b *localBackend) topTracks(days, limit int) ([]store.TopTrack, error) {
+	return store.FullTopTracks(b.db, time.Now().AddDate(0, 0, -days), limit)
+}
+
+func (b *localBackend) Close() error {
+	return b.db.Close()
+}
+
+//apiClient talks to a running server over its HTTP API
+type apiClient struct {
+	base, token string
+	http        *http.Client
+}
+
+func newAPIClient(base, token string) *apiClient {
+	return &apiClient{
+		base:  strings.TrimSuffix(base, "/"),
+		token: token,
+		http:  &http.Client{Timeout: time.Minute},
+	}
+}
+
+func (c *apiClient) search(query string) ([]models.Release, error) {
+	var rels []models.Release
+	err := c.do("GET", "/albums?name="+url.QueryEscape(query), nil, &rels)
+	return rels, err
+}
+
+func (c *apiClient) downloads(states []models.JobState) ([]models.Job, error) {
+	path := "/downloads"
+	if len(states) > 0 {
+		names := make([]string, len(states))
+		for i, state := range states {
+			names[i] = string(state)
+		}
+		path += "?state=" + url.QueryEscape(strings.Join(names, ","))
+	}
+	var jobs []models.Job
+	err := c.do("GET", path, nil, &jobs)
+	return jobs, err
+}
+
+func (c *apiClient) cancelDownload(id string) error {
+	return c.do("DELETE", "/downloads/"+url.PathEscape(id), nil, nil)
+}
+
+func (c *apiClient) topTracks(days, limit int) ([]store.TopTrack, error) {
+	var top []store.TopTrack
+	err := c.do(
+		"GET",
+		fmt.Sprintf("/stats/top?days=%d&limit=%d", days, limit),
+		nil,
+		&top,
+	)
+	return top, err
+}
+
+func (c *apiClient) Close() error {
+	return nil
+}
+
+//do sends a request with in as its JSON body, unless it is nil, and decodes
+// the JSON response into out, unless it is nil
+func (c *apiClient) do(method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, c.base+path, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		var problem server.APIError
+		if json.Unmarshal(msg, &problem) == nil && problem.Message != "" {
+			msg = []byte(problem.Message)
+		}
+		return fmt.Errorf(
+			"%s %s: %s: %s",
+			method,
+			path,
+			resp.Status,
+			strings.TrimSpace(string(msg)),
+		)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
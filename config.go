@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/waelbendhia/music-streaming/wms/config"
+)
+
+const configUsage = `usage: music-streaming config print [flags]
+
+  print       show the effective value of every setting and where it
+              comes from, then validate them
+`
+
+func runConfig(args []string) int {
+	if len(args) < 1 || args[0] != "print" {
+		fmt.Fprint(os.Stderr, configUsage)
+		return 2
+	}
+	flags := flag.NewFlagSet("config print", flag.ExitOnError)
+	showSecrets := flags.Bool("show-secrets", false, "don't mask secrets")
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, configUsage)
+		flags.PrintDefaults()
+	}
+	cfg, err := config.Load(flags, args[1:])
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+	if err := cfg.Print(os.Stdout, *showSecrets); err != nil {
+		log.Println(err)
+		return 1
+	}
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintln(os.Stdout)
+		fmt.Fprintln(os.Stdout, err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/waelbendhia/music-streaming/gopirate"
+	"github.com/waelbendhia/music-streaming/wms/config"
+	"github.com/waelbendhia/music-streaming/wms/models"
+)
+
+const downloadUsage = `usage: music-streaming download [flags] <artist> <release>
+
+Ask the server to download a release, -server defaults to http.addr.
+
+`
+
+func runDownload(args []string) int {
+	flags := newFlagSet("download", downloadUsage)
+	remote := addRemoteFlags(flags)
+	cfg, err := config.Load(flags, args)
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return 2
+	}
+	var (
+		rel = models.Release{
+			Name:        flags.Arg(1),
+			AlbumArtist: &models.Artist{Name: flags.Arg(0)},
+		}
+		torrents []gopirate.Torrent
+	)
+	err = remote.client(cfg).do("POST", "/album", rel, &torrents)
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+	if len(torrents) > 0 {
+		fmt.Printf(
+			"Queued %s (%d seeders)\n",
+			torrents[0].Name,
+			torrents[0].Seeders,
+		)
+	}
+	return 0
+}
+
+const downloadsUsage = `usage: music-streaming downloads ls|cancel [flags] [args]
+
+  ls [state...]   list downloads, only those in the given states if any
+  cancel <id>     cancel a download, without -server only downloads that
+                  haven't started can be cancelled and the server must be
+                  stopped
+
+`
+
+func runDownloads(args []string) int {
+	action, args := splitAction(args)
+	flags := newFlagSet("downloads", downloadsUsage)
+	remote := addRemoteFlags(flags)
+	cfg, err := config.Load(flags, args)
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+	if action != "ls" && action != "cancel" ||
+		action == "cancel" && flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+	b, err := remote.openBackend(cfg)
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+	defer b.Close()
+	if action == "cancel" {
+		if err := b.cancelDownload(flags.Arg(0)); err != nil {
+			log.Println(err)
+			return 1
+		}
+		return 0
+	}
+	var states []models.JobState
+	for _, state := range flags.Args() {
+		states = append(states, models.JobState(strings.ToLower(state)))
+	}
+	jobs, err := b.downloads(states)
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+	w := newTable("ID", "NAME", "STATE", "USER", "CREATED", "ERROR")
+	for _, job := range jobs {
+		fmt.Fprintf(
+			w,
+			"%s\t%s\t%s\t%s\t%s\t%s\n",
+			job.ID.Hex(),
+			job.Name,
+			job.State,
+			job.User,
+			job.CreatedAt.Format("2006-01-02 15:04:05"),
+			job.Error,
+		)
+	}
+	w.Flush()
+	return 0
+}
@@ -0,0 +1,131 @@
+package lastfm
+
+import (
+	"context"
+	"net/url"
+)
+
+//Album from last FM api
+type Album struct {
+	Name       string    `json:"name"`
+	Artist     ArtistRef `json:"artist"`
+	URL        string    `json:"url"`
+	Image      Images    `json:"image"`
+	Tracks     TrackList `json:"tracks"`
+	Tags       TagList   `json:"tags"`
+	Wiki       Wiki      `json:"wiki"`
+	Listeners  Number    `json:"listeners"`
+	Playcount  Number    `json:"playcount"`
+	Streamable string    `json:"streamable"`
+	MBID       string    `json:"mbid"`
+}
+
+//Track from last FM api
+type Track struct {
+	Name     string    `json:"name"`
+	URL      string    `json:"url"`
+	Duration Number    `json:"duration"`
+	Artist   ArtistRef `json:"artist"`
+	Attr     struct {
+		Rank Number `json:"rank"`
+	} `json:"@attr"`
+}
+
+//AlbumResponse from last FM api
+type AlbumResponse struct {
+	Album Album `json:"album"`
+}
+
+//SearchResults of a last FM api search
+type SearchResults struct {
+	Results struct {
+		SearchInfo
+		AlbumMatches AlbumList `json:"albummatches"`
+	} `json:"results"`
+}
+
+//TopTags are the tags most applied to an album, artist or track
+type TopTags struct {
+	Tags TagList
+	Attr struct {
+		Artist string `json:"artist"`
+		Album  string `json:"album"`
+		Track  string `json:"track"`
+	}
+}
+
+//UnmarshalJSON decodes the tag list and its attributes
+func (tt *TopTags) UnmarshalJSON(data []byte) error {
+	return unmarshalPagedList(data, "tag", (*[]Tag)(&tt.Tags), &tt.Attr)
+}
+
+//TopTagsResponse from last FM api
+type TopTagsResponse struct {
+	TopTags TopTags `json:"toptags"`
+}
+
+//TopAlbums is a page of an artist's or tag's most listened albums
+type TopAlbums struct {
+	Albums AlbumList
+	Attr   struct {
+		PageInfo
+		Artist string `json:"artist"`
+		Tag    string `json:"tag"`
+	}
+}
+
+//UnmarshalJSON decodes the album list and its paging information
+func (ta *TopAlbums) UnmarshalJSON(data []byte) error {
+	return unmarshalPagedList(data, "album", (*[]Album)(&ta.Albums), &ta.Attr)
+}
+
+//GetAlbumInfo gets best match for artistName-albumName
+func (cli *Client) GetAlbumInfo(
+	ctx context.Context,
+	artistName, albumName string,
+) (*AlbumResponse, error) {
+	var lfmalbum AlbumResponse
+	err := cli.call(ctx, "album.getinfo", url.Values{
+		"artist":      {artistName},
+		"album":       {albumName},
+		"autocorrect": {"1"},
+	}, &lfmalbum)
+	return &lfmalbum, err
+}
+
+//GetAlbumInfoByMBID gets the album with the given musicbrainz ID
+func (cli *Client) GetAlbumInfoByMBID(
+	ctx context.Context,
+	mbid string,
+) (*AlbumResponse, error) {
+	var lfmalbum AlbumResponse
+	err := cli.call(ctx, "album.getinfo", url.Values{"mbid": {mbid}}, &lfmalbum)
+	return &lfmalbum, err
+}
+
+//SearchAlbums searches for albums
+func (cli *Client) SearchAlbums(
+	ctx context.Context,
+	searchTerm string,
+	page Page,
+) (*SearchResults, error) {
+	params := url.Values{"album": {searchTerm}}
+	page.apply(params)
+	var results SearchResults
+	err := cli.call(ctx, "album.search", params, &results)
+	return &results, err
+}
+
+//GetAlbumTopTags gets the tags most applied to an album
+func (cli *Client) GetAlbumTopTags(
+	ctx context.Context,
+	artistName, albumName string,
+) (*TopTagsResponse, error) {
+	var tags TopTagsResponse
+	err := cli.call(ctx, "album.gettoptags", url.Values{
+		"artist":      {artistName},
+		"album":       {albumName},
+		"autocorrect": {"1"},
+	}, &tags)
+	return &tags, err
+}
@@ -0,0 +1,116 @@
+package lastfm
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+//Artist from last FM api
+type Artist struct {
+	Name       string `json:"name"`
+	MBID       string `json:"mbid"`
+	URL        string `json:"url"`
+	Image      Images `json:"image"`
+	Streamable string `json:"streamable"`
+	Listeners  Number `json:"listeners"`
+	Match      Float  `json:"match"`
+	Stats      struct {
+		Listeners Number `json:"listeners"`
+		Playcount Number `json:"playcount"`
+	} `json:"stats"`
+	Similar ArtistList `json:"similar"`
+	Tags    TagList    `json:"tags"`
+	Bio     Wiki       `json:"bio"`
+}
+
+//ArtistResponse from last FM api
+type ArtistResponse struct {
+	Artist Artist `json:"artist"`
+}
+
+//SimilarArtists are artists similar to Attr.Artist ordered by similarity
+type SimilarArtists struct {
+	Artists ArtistList
+	Attr    struct {
+		Artist string `json:"artist"`
+	}
+}
+
+//UnmarshalJSON decodes the artist list and its attributes
+func (sa *SimilarArtists) UnmarshalJSON(data []byte) error {
+	return unmarshalPagedList(data, "artist", (*[]Artist)(&sa.Artists), &sa.Attr)
+}
+
+//SimilarArtistsResponse from last FM api
+type SimilarArtistsResponse struct {
+	SimilarArtists SimilarArtists `json:"similarartists"`
+}
+
+//TopAlbumsResponse from last FM api
+type TopAlbumsResponse struct {
+	TopAlbums TopAlbums `json:"topalbums"`
+}
+
+//ArtistSearchResults of a last FM artist search
+type ArtistSearchResults struct {
+	Results struct {
+		SearchInfo
+		ArtistMatches ArtistList `json:"artistmatches"`
+	} `json:"results"`
+}
+
+//GetArtistInfo gets the artist's metadata including bio and similar artists
+func (cli *Client) GetArtistInfo(
+	ctx context.Context,
+	artistName string,
+) (*ArtistResponse, error) {
+	var artist ArtistResponse
+	err := cli.call(ctx, "artist.getinfo", url.Values{
+		"artist":      {artistName},
+		"autocorrect": {"1"},
+	}, &artist)
+	return &artist, err
+}
+
+//GetSimilarArtists gets up to limit artists similar to artistName, a limit
+// of 0 uses lastFM's default
+func (cli *Client) GetSimilarArtists(
+	ctx context.Context,
+	artistName string,
+	limit int,
+) (*SimilarArtistsResponse, error) {
+	params := url.Values{"artist": {artistName}, "autocorrect": {"1"}}
+	if limit > 0 {
+		params.Set("limit", fmt.Sprint(limit))
+	}
+	var similar SimilarArtistsResponse
+	err := cli.call(ctx, "artist.getsimilar", params, &similar)
+	return &similar, err
+}
+
+//GetArtistTopAlbums gets the artist's most listened albums
+func (cli *Client) GetArtistTopAlbums(
+	ctx context.Context,
+	artistName string,
+	page Page,
+) (*TopAlbumsResponse, error) {
+	params := url.Values{"artist": {artistName}, "autocorrect": {"1"}}
+	page.apply(params)
+	var albums TopAlbumsResponse
+	err := cli.call(ctx, "artist.gettopalbums", params, &albums)
+	return &albums, err
+}
+
+//SearchArtists searches for artists
+func (cli *Client) SearchArtists(
+	ctx context.Context,
+	searchTerm string,
+	page Page,
+) (*ArtistSearchResults, error) {
+	params := url.Values{"artist": {searchTerm}}
+	page.apply(params)
+	var results ArtistSearchResults
+	err := cli.call(ctx, "artist.search", params, &results)
+	return &results, err
+}
@@ -1,161 +1,117 @@
 package lastfm
 
 import (
+	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 )
 
-//SearchResults of a last FM api search
-type SearchResults struct {
-	Results struct {
-		OpensearchQuery struct {
-			Text        string `json:"#text"`
-			Role        string `json:"role"`
-			SearchTerms string `json:"searchTerms"`
-			StartPage   string `json:"startPage"`
-		} `json:"opensearch:Query"`
-		OpensearchTotalResults string `json:"opensearch:totalResults"`
-		OpensearchStartIndex   string `json:"opensearch:startIndex"`
-		OpensearchItemsPerPage string `json:"opensearch:itemsPerPage"`
-		AlbumMatches           struct {
-			Album []Album `json:"album"`
-		} `json:"albummatches"`
-	} `json:"results"`
-}
-
 const root = "http://ws.audioscrobbler.com/2.0"
 
-//Error from last FM api
-type Error struct {
-	Code    int    `json:"error"`
-	Message string `json:"message"`
+//Client allows you to make queries to lastFM api
+type Client struct {
+	key        string
+	root       string
+	httpClient *http.Client
 }
 
-//Album from last FM api
-type Album struct {
-	Name   string `json:"name"`
-	Artist string `json:"artist"`
-	URL    string `json:"url"`
-	Image  []struct {
-		Text string `json:"#text"`
-		Size string `json:"size"`
-	} `json:"image"`
-	Tracks struct {
-		Tracks []Track `json:"track"`
-	} `json:"tracks"`
-	Streamable string `json:"streamable"`
-	MBID       string `json:"mbid"`
+//Page selects a page of results, zero values use lastFM's defaults
+type Page struct {
+	Page  int
+	Limit int
 }
 
-//Track from last FM api
-type Track struct {
-	Name     string `json:"name"`
-	Duration string `json:"duration"`
+func (p Page) apply(params url.Values) {
+	if p.Page > 0 {
+		params.Set("page", fmt.Sprint(p.Page))
+	}
+	if p.Limit > 0 {
+		params.Set("limit", fmt.Sprint(p.Limit))
+	}
 }
 
-//AlbumResponse from last FM api
-type AlbumResponse struct {
-	Album Album `json:"album"`
+//PageInfo describes the page of a paged lastFM response
+type PageInfo struct {
+	Page       Number `json:"page"`
+	PerPage    Number `json:"perPage"`
+	TotalPages Number `json:"totalPages"`
+	Total      Number `json:"total"`
 }
 
-//Client allows you to make queries to lastFM api
-type Client string
+//SearchInfo describes the page of a lastFM search response
+type SearchInfo struct {
+	OpensearchQuery struct {
+		Text        string `json:"#text"`
+		Role        string `json:"role"`
+		SearchTerms string `json:"searchTerms"`
+		StartPage   Number `json:"startPage"`
+	} `json:"opensearch:Query"`
+	OpensearchTotalResults Number `json:"opensearch:totalResults"`
+	OpensearchStartIndex   Number `json:"opensearch:startIndex"`
+	OpensearchItemsPerPage Number `json:"opensearch:itemsPerPage"`
+}
 
 func readBody(r *http.Response) ([]byte, error) {
 	return ioutil.ReadAll(io.LimitReader(r.Body, 1048576))
 }
 
-//CreateLastFmClient from lastFM api key
-func CreateLastFmClient(key string) (Client, error) {
-	resp, err := http.Get(fmt.Sprintf(
-		root+"/?method=album.getinfo"+
-			"&api_key=%s"+
-			"&artist=Cher"+
-			"&album=Believe"+
-			"&format=json",
-		key,
-	))
-	if err != nil {
-		return "", err
-	}
-	body, err := readBody(resp)
-	if err != nil {
-		return "", err
-	}
-	var errResp Error
-	if err := json.Unmarshal(body, &errResp); err != nil {
-		return "", err
-	}
-	if errResp.Message != "" {
-		return "", errors.New(errResp.Message)
+//NewClient creates a lastFM client for the api key, if httpClient is nil
+// http.DefaultClient is used
+func NewClient(key string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
 	}
-	return Client(key), err
+	return &Client{key: key, root: root, httpClient: httpClient}
 }
 
-//GetAlbumInfo gets best match for artistName-albumName
-func (cli Client) GetAlbumInfo(artistName, albumName string) (*AlbumResponse, error) {
-	query := root + fmt.Sprintf(
-		"/?method=album.getinfo&api_key=%s&artist=%s&album=%s&format=json",
-		cli,
-		artistName,
-		albumName,
-	)
-	resp, err := http.Get(query)
-	if err != nil {
-		return nil, err
+//WithRoot returns a copy of the client that sends its queries to root
+// instead of the public lastFM api
+func (cli *Client) WithRoot(root string) *Client {
+	cp := *cli
+	cp.root = root
+	return &cp
+}
+
+//call queries method with params and decodes the response into v
+func (cli *Client) call(
+	ctx context.Context,
+	method string,
+	params url.Values,
+	v interface{},
+) error {
+	if params == nil {
+		params = url.Values{}
 	}
-	body, err := readBody(resp)
+	params.Set("method", method)
+	params.Set("api_key", cli.key)
+	params.Set("format", "json")
+	req, err := http.NewRequest("GET", cli.root+"/?"+params.Encode(), nil)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	var lfmalbum AlbumResponse
-	err = json.Unmarshal(body, &lfmalbum)
-	return &lfmalbum, err
-}
-
-//SearchAlbums searches for albums
-func (cli Client) SearchAlbums(searchTerm string) (*SearchResults, error) {
-	query := root + fmt.Sprintf(
-		"/?method=album.search&api_key=%s&album=%s&format=json",
-		cli,
-		searchTerm,
-	)
-	resp, err := http.Get(query)
+	resp, err := cli.httpClient.Do(req.WithContext(ctx))
 	if err != nil {
-		return nil, err
+		return err
 	}
+	defer resp.Body.Close()
 	body, err := readBody(resp)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	var errResp Error
+	if err := json.Unmarshal(body, &errResp); err == nil && errResp.Code != 0 {
+		return &errResp
+	}
+	if resp.StatusCode != http.StatusOK {
+		return &StatusError{
+			Method:     method,
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+		}
 	}
-	var results SearchResults
-	err = json.Unmarshal(body, &results)
-	return &results, err
+	return json.Unmarshal(body, v)
 }
-
-// func (lfmAlbum *Album) toWMSAlbum() models.Release {
-// 	var album models.Release
-// 	album.AlbumArtist = &models.Artist{Name: lfmAlbum.Artist}
-// 	album.Name = lfmAlbum.Name
-// 	for _, image := range lfmAlbum.Image {
-// 		if image.Size == "extralarge" {
-// 			album.CoverURL = image.Text
-// 			break
-// 		}
-// 	}
-// 	for _, track := range lfmAlbum.Tracks.Tracks {
-// 		var newTrack models.Track
-// 		duration, convErr := strconv.Atoi(track.Duration)
-// 		if convErr != nil {
-// 			duration = 0
-// 		}
-// 		newTrack.Length = time.Duration(duration) * time.Second
-// 		newTrack.Name = track.Name
-// 		album.Tracks = append(album.Tracks, newTrack)
-// 	}
-// 	return album
-// }
@@ -0,0 +1,549 @@
+package lastfm
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+)
+
+//fixture is a recorded response served for a lastFM method
+type fixture struct {
+	file   string
+	status int
+}
+
+//newTestClient returns a client whose queries are answered by fixtures, by
+// method, and a function returning the query of the last request
+func newTestClient(
+	t *testing.T,
+	fixtures map[string]fixture,
+) (*Client, func() url.Values) {
+	var last url.Values
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			last = r.URL.Query()
+			f, ok := fixtures[last.Get("method")]
+			if !ok {
+				http.Error(w, "no fixture", http.StatusInternalServerError)
+				return
+			}
+			data, err := ioutil.ReadFile(filepath.Join("testdata", f.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			if f.status != 0 {
+				w.WriteHeader(f.status)
+			}
+			w.Write(data)
+		},
+	))
+	t.Cleanup(srv.Close)
+	cli := NewClient("key", srv.Client()).WithRoot(srv.URL)
+	return cli, func() url.Values { return last }
+}
+
+//checkParams fails t if query doesn't hold the common parameters and want
+func checkParams(
+	t *testing.T,
+	query url.Values,
+	method string,
+	want map[string]string,
+) {
+	t.Helper()
+	common := map[string]string{
+		"method":  method,
+		"api_key": "key",
+		"format":  "json",
+	}
+	for _, params := range []map[string]string{common, want} {
+		for key, value := range params {
+			if got := query.Get(key); got != value {
+				t.Errorf("param %s = %q, want %q", key, got, value)
+			}
+		}
+	}
+	for key := range query {
+		if _, ok := common[key]; !ok {
+			if _, ok := want[key]; !ok {
+				t.Errorf("unexpected param %s = %q", key, query.Get(key))
+			}
+		}
+	}
+}
+
+func TestGetArtistInfo(t *testing.T) {
+	cli, query := newTestClient(t, map[string]fixture{
+		"artist.getinfo": {file: "artist.getinfo.json"},
+	})
+	resp, err := cli.GetArtistInfo(context.Background(), "Radiohead")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkParams(t, query(), "artist.getinfo", map[string]string{
+		"artist":      "Radiohead",
+		"autocorrect": "1",
+	})
+	artist := resp.Artist
+	if artist.Name != "Radiohead" ||
+		artist.MBID != "a74b1b7f-71a5-4011-9441-d0b5e4122711" {
+		t.Errorf("artist = %q %q", artist.Name, artist.MBID)
+	}
+	if artist.Stats.Listeners != 4950348 ||
+		artist.Stats.Playcount != 529632140 {
+		t.Errorf("stats = %+v", artist.Stats)
+	}
+	if len(artist.Similar) != 2 || artist.Similar[1].Name != "Atoms for Peace" {
+		t.Errorf("similar = %+v", artist.Similar)
+	}
+	tags := artist.Tags.Names()
+	if len(tags) != 3 || tags[0] != "alternative" {
+		t.Errorf("tags = %v", tags)
+	}
+	if artist.Bio.Content == "" || artist.Bio.Published == "" {
+		t.Errorf("bio = %+v", artist.Bio)
+	}
+	want := "https://lastfm.freetls.fastly.net/i/u/300x300/" +
+		"2a96cbd8b46e442fc41c2b86b821562f.png"
+	if got := artist.Image.Largest(); got != want {
+		t.Errorf("largest image = %q, want %q", got, want)
+	}
+}
+
+func TestGetSimilarArtists(t *testing.T) {
+	cli, query := newTestClient(t, map[string]fixture{
+		"artist.getsimilar": {file: "artist.getsimilar.json"},
+	})
+	for _, test := range []struct {
+		limit  int
+		params map[string]string
+	}{
+		{0, map[string]string{}},
+		{5, map[string]string{"limit": "5"}},
+	} {
+		resp, err := cli.GetSimilarArtists(
+			context.Background(),
+			"Atoms for Peace",
+			test.limit,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		test.params["artist"] = "Atoms for Peace"
+		test.params["autocorrect"] = "1"
+		checkParams(t, query(), "artist.getsimilar", test.params)
+		// A single similar artist is sent as an object instead of a list
+		similar := resp.SimilarArtists
+		if len(similar.Artists) != 1 ||
+			similar.Artists[0].Name != "Thom Yorke" ||
+			similar.Artists[0].Match != 1 {
+			t.Errorf("similar artists = %+v", similar.Artists)
+		}
+		if similar.Attr.Artist != "Atoms for Peace" {
+			t.Errorf("attr artist = %q", similar.Attr.Artist)
+		}
+	}
+}
+
+func TestGetArtistTopAlbums(t *testing.T) {
+	cli, query := newTestClient(t, map[string]fixture{
+		"artist.gettopalbums": {file: "artist.gettopalbums.json"},
+	})
+	resp, err := cli.GetArtistTopAlbums(
+		context.Background(),
+		"Radiohead",
+		Page{Page: 2, Limit: 2},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkParams(t, query(), "artist.gettopalbums", map[string]string{
+		"artist":      "Radiohead",
+		"autocorrect": "1",
+		"page":        "2",
+		"limit":       "2",
+	})
+	top := resp.TopAlbums
+	if len(top.Albums) != 2 || top.Albums[0].Name != "In Rainbows" ||
+		top.Albums[0].Playcount != 84718474 ||
+		top.Albums[1].Artist.Name != "Radiohead" {
+		t.Errorf("albums = %+v", top.Albums)
+	}
+	if top.Attr.Page != 2 || top.Attr.PerPage != 2 ||
+		top.Attr.TotalPages != 1517 || top.Attr.Total != 3033 ||
+		top.Attr.Artist != "Radiohead" {
+		t.Errorf("attr = %+v", top.Attr)
+	}
+}
+
+func TestSearchArtists(t *testing.T) {
+	cli, query := newTestClient(t, map[string]fixture{
+		"artist.search": {file: "artist.search.json"},
+	})
+	resp, err := cli.SearchArtists(
+		context.Background(),
+		"simon & garfunkel",
+		Page{Page: 3, Limit: 10},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkParams(t, query(), "artist.search", map[string]string{
+		"artist": "simon & garfunkel",
+		"page":   "3",
+		"limit":  "10",
+	})
+	results := resp.Results
+	if len(results.ArtistMatches) != 2 ||
+		results.ArtistMatches[0].Name != "Simon & Garfunkel" ||
+		results.ArtistMatches[0].Listeners != 1730551 {
+		t.Errorf("matches = %+v", results.ArtistMatches)
+	}
+	if results.OpensearchTotalResults != 412 ||
+		results.OpensearchStartIndex != 20 ||
+		results.OpensearchItemsPerPage != 10 ||
+		results.OpensearchQuery.StartPage != 3 {
+		t.Errorf("search info = %+v", results.SearchInfo)
+	}
+}
+
+func TestSearchEscaping(t *testing.T) {
+	var rawQuery string
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			rawQuery = r.URL.RawQuery
+			w.Write([]byte(`{"results":{"albummatches":{"album":[]}}}`))
+		},
+	))
+	defer srv.Close()
+	cli := NewClient("key", srv.Client()).WithRoot(srv.URL)
+	_, err := cli.SearchAlbums(
+		context.Background(),
+		"Simon & Garfunkel method=x",
+		Page{},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := query.Get("album"); got != "Simon & Garfunkel method=x" {
+		t.Errorf("album = %q in %s", got, rawQuery)
+	}
+	if got := query["method"]; len(got) != 1 || got[0] != "album.search" {
+		t.Errorf("method = %q in %s", got, rawQuery)
+	}
+}
+
+func TestGetTrackInfo(t *testing.T) {
+	cli, query := newTestClient(t, map[string]fixture{
+		"track.getinfo": {file: "track.getinfo.json"},
+	})
+	resp, err := cli.GetTrackInfo(context.Background(), "Radiohead", "Reckoner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkParams(t, query(), "track.getinfo", map[string]string{
+		"artist":      "Radiohead",
+		"track":       "Reckoner",
+		"autocorrect": "1",
+	})
+	track := resp.Track
+	if track.Name != "Reckoner" || track.Duration != 290000 ||
+		track.Artist.Name != "Radiohead" {
+		t.Errorf(
+			"track = %q %d %q",
+			track.Name,
+			track.Duration,
+			track.Artist.Name,
+		)
+	}
+	if track.Album.Title != "In Rainbows" ||
+		track.Album.Position.Position != 7 {
+		t.Errorf("album = %+v", track.Album)
+	}
+	if tags := track.TopTags.Names(); len(tags) != 2 || tags[1] != "radiohead" {
+		t.Errorf("tags = %v", tags)
+	}
+}
+
+func TestSearchTracks(t *testing.T) {
+	cli, query := newTestClient(t, map[string]fixture{
+		"track.search": {file: "track.search.json"},
+	})
+	for _, test := range []struct {
+		artist string
+		page   Page
+		params map[string]string
+	}{
+		{"", Page{}, map[string]string{}},
+		{
+			"Sigur Rós",
+			Page{Page: 1, Limit: 5},
+			map[string]string{
+				"artist": "Sigur Rós",
+				"page":   "1",
+				"limit":  "5",
+			},
+		},
+	} {
+		resp, err := cli.SearchTracks(
+			context.Background(),
+			"Hoppípolla",
+			test.artist,
+			test.page,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		test.params["track"] = "Hoppípolla"
+		checkParams(t, query(), "track.search", test.params)
+		// A single match is sent as an object instead of a list
+		matches := resp.Results.TrackMatches
+		if len(matches) != 1 || matches[0].Name != "Hoppípolla" ||
+			matches[0].Artist.Name != "Sigur Rós" ||
+			matches[0].Listeners != 1059325 {
+			t.Errorf("matches = %+v", matches)
+		}
+	}
+}
+
+func TestGetAlbumInfo(t *testing.T) {
+	cli, query := newTestClient(t, map[string]fixture{
+		"album.getinfo": {file: "album.getinfo.json"},
+	})
+	resp, err := cli.GetAlbumInfo(context.Background(), "Radiohead", "Kid A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkParams(t, query(), "album.getinfo", map[string]string{
+		"artist":      "Radiohead",
+		"album":       "Kid A",
+		"autocorrect": "1",
+	})
+	album := resp.Album
+	if album.Artist.Name != "Radiohead" || album.Listeners != 1543402 {
+		t.Errorf("album = %q %d", album.Artist.Name, album.Listeners)
+	}
+	if len(album.Tracks) != 2 || album.Tracks[1].Name != "Kid A" ||
+		album.Tracks[1].Duration != 284 || album.Tracks[1].Attr.Rank != 2 {
+		t.Errorf("tracks = %+v", album.Tracks)
+	}
+	// Albums without tags have "" instead of a list
+	if len(album.Tags) != 0 {
+		t.Errorf("tags = %+v", album.Tags)
+	}
+	if _, err := cli.GetAlbumInfoByMBID(
+		context.Background(),
+		"b3a8f4d3-95fa-3a70-9e6f-b6b7b0a3b68e",
+	); err != nil {
+		t.Fatal(err)
+	}
+	checkParams(t, query(), "album.getinfo", map[string]string{
+		"mbid": "b3a8f4d3-95fa-3a70-9e6f-b6b7b0a3b68e",
+	})
+}
+
+func TestGetAlbumTopTags(t *testing.T) {
+	cli, query := newTestClient(t, map[string]fixture{
+		"album.gettoptags": {file: "album.gettoptags.json"},
+	})
+	resp, err := cli.GetAlbumTopTags(
+		context.Background(),
+		"My Bloody Valentine",
+		"Loveless",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkParams(t, query(), "album.gettoptags", map[string]string{
+		"artist":      "My Bloody Valentine",
+		"album":       "Loveless",
+		"autocorrect": "1",
+	})
+	top := resp.TopTags
+	if len(top.Tags) != 1 || top.Tags[0].Name != "shoegaze" ||
+		top.Tags[0].Count != 100 {
+		t.Errorf("tags = %+v", top.Tags)
+	}
+	if top.Attr.Artist != "My Bloody Valentine" ||
+		top.Attr.Album != "Loveless" {
+		t.Errorf("attr = %+v", top.Attr)
+	}
+}
+
+func TestGetTagTopAlbums(t *testing.T) {
+	cli, query := newTestClient(t, map[string]fixture{
+		"tag.gettopalbums": {file: "tag.gettopalbums.json"},
+	})
+	resp, err := cli.GetTagTopAlbums(
+		context.Background(),
+		"shoegaze",
+		Page{Page: 3, Limit: 1},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkParams(t, query(), "tag.gettopalbums", map[string]string{
+		"tag":   "shoegaze",
+		"page":  "3",
+		"limit": "1",
+	})
+	top := resp.Albums
+	if len(top.Albums) != 1 || top.Albums[0].Name != "Loveless" ||
+		top.Albums[0].Artist.Name != "My Bloody Valentine" {
+		t.Errorf("albums = %+v", top.Albums)
+	}
+	if top.Attr.Tag != "shoegaze" || top.Attr.Page != 3 ||
+		top.Attr.Total != 40327 {
+		t.Errorf("attr = %+v", top.Attr)
+	}
+}
+
+func TestUnmarshalList(t *testing.T) {
+	for _, test := range []struct {
+		data  string
+		names []string
+	}{
+		{`""`, nil},
+		{`null`, nil},
+		{`{}`, nil},
+		{`{"tag":[]}`, []string{}},
+		{`{"tag":{"name":"rock"}}`, []string{"rock"}},
+		{`{"tag":[{"name":"rock"},{"name":"pop"}]}`, []string{"rock", "pop"}},
+	} {
+		var tags TagList
+		if err := tags.UnmarshalJSON([]byte(test.data)); err != nil {
+			t.Errorf("%s: %v", test.data, err)
+			continue
+		}
+		names := tags.Names()
+		equal := len(names) == len(test.names)
+		for i := 0; equal && i < len(names); i++ {
+			equal = names[i] == test.names[i]
+		}
+		if !equal {
+			t.Errorf("%s: names = %v, want %v", test.data, names, test.names)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	cli, _ := newTestClient(t, map[string]fixture{
+		"artist.getinfo":   {file: "error.notfound.json"},
+		"track.getinfo":    {file: "error.notfound.json", status: 404},
+		"artist.search":    {file: "error.ratelimit.json", status: 429},
+		"album.gettoptags": {file: "album.gettoptags.json", status: 502},
+		"artist.getsimilar": {
+			file:   "artist.getsimilar.json",
+			status: 429,
+		},
+		"artist.gettopalbums": {
+			file:   "artist.gettopalbums.json",
+			status: 404,
+		},
+	})
+	ctx := context.Background()
+	for _, test := range []struct {
+		name      string
+		call      func() error
+		code      ErrorCode
+		status    int
+		notFound  bool
+		temporary bool
+	}{
+		{
+			name: "not found",
+			call: func() error {
+				_, err := cli.GetArtistInfo(ctx, "Nobody")
+				return err
+			},
+			code:     CodeInvalidParameters,
+			notFound: true,
+		},
+		{
+			name: "not found with status",
+			call: func() error {
+				_, err := cli.GetTrackInfo(ctx, "Nobody", "Nothing")
+				return err
+			},
+			code:     CodeInvalidParameters,
+			notFound: true,
+		},
+		{
+			name: "rate limited",
+			call: func() error {
+				_, err := cli.SearchArtists(ctx, "anyone", Page{})
+				return err
+			},
+			code:      CodeRateLimitExceeded,
+			temporary: true,
+		},
+		{
+			name: "bad gateway",
+			call: func() error {
+				_, err := cli.GetAlbumTopTags(ctx, "Anyone", "Anything")
+				return err
+			},
+			status:    502,
+			temporary: true,
+		},
+		{
+			name: "server error",
+			call: func() error {
+				_, err := cli.GetTagTopAlbums(ctx, "rock", Page{})
+				return err
+			},
+			status:    500,
+			temporary: true,
+		},
+		{
+			name: "too many requests",
+			call: func() error {
+				_, err := cli.GetSimilarArtists(ctx, "Anyone", 0)
+				return err
+			},
+			status:    429,
+			temporary: true,
+		},
+		{
+			name: "status not found",
+			call: func() error {
+				_, err := cli.GetArtistTopAlbums(ctx, "Anyone", Page{})
+				return err
+			},
+			status: 404,
+		},
+	} {
+		err := test.call()
+		if err == nil {
+			t.Errorf("%s: no error", test.name)
+			continue
+		}
+		lfmErr, ok := err.(*Error)
+		if test.code != 0 && (!ok || lfmErr.Code != test.code) {
+			t.Errorf("%s: err = %#v, want code %d", test.name, err, test.code)
+		}
+		statusErr, ok := err.(*StatusError)
+		if test.status != 0 && (!ok || statusErr.StatusCode != test.status) {
+			t.Errorf(
+				"%s: err = %#v, want status %d",
+				test.name,
+				err,
+				test.status,
+			)
+		}
+		if IsNotFound(err) != test.notFound {
+			t.Errorf("%s: IsNotFound = %t", test.name, !test.notFound)
+		}
+		if IsTemporary(err) != test.temporary {
+			t.Errorf("%s: IsTemporary = %t", test.name, !test.temporary)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package lastfm
+
+import (
+	"fmt"
+	"net/http"
+)
+
+//ErrorCode identifies the kind of error returned by the lastFM api
+type ErrorCode int
+
+//Error codes documented by the lastFM api
+const (
+	CodeInvalidService         ErrorCode = 2
+	CodeInvalidMethod          ErrorCode = 3
+	CodeAuthenticationFailed   ErrorCode = 4
+	CodeInvalidFormat          ErrorCode = 5
+	CodeInvalidParameters      ErrorCode = 6
+	CodeInvalidResource        ErrorCode = 7
+	CodeOperationFailed        ErrorCode = 8
+	CodeInvalidSessionKey      ErrorCode = 9
+	CodeInvalidAPIKey          ErrorCode = 10
+	CodeServiceOffline         ErrorCode = 11
+	CodeInvalidMethodSignature ErrorCode = 13
+	CodeTemporaryError         ErrorCode = 16
+	CodeSuspendedAPIKey        ErrorCode = 26
+	CodeRateLimitExceeded      ErrorCode = 29
+)
+
+//Error from last FM api
+type Error struct {
+	Code    ErrorCode `json:"error"`
+	Message string    `json:"message"`
+}
+
+func (err *Error) Error() string {
+	return fmt.Sprintf("lastfm: %s (error %d)", err.Message, err.Code)
+}
+
+//Temporary returns true if the request may succeed if retried later
+func (err *Error) Temporary() bool {
+	switch err.Code {
+	case CodeOperationFailed,
+		CodeServiceOffline,
+		CodeTemporaryError,
+		CodeRateLimitExceeded:
+		return true
+	}
+	return false
+}
+
+//StatusError is returned when lastFM answers with a status other than 200
+// and no error body
+type StatusError struct {
+	Method     string
+	StatusCode int
+	Status     string
+}
+
+func (err *StatusError) Error() string {
+	return fmt.Sprintf("lastfm: %s returned status %s", err.Method, err.Status)
+}
+
+//Temporary returns true for server errors and rate limiting
+func (err *StatusError) Temporary() bool {
+	return err.StatusCode == http.StatusTooManyRequests ||
+		err.StatusCode >= 500
+}
+
+//IsNotFound returns true if err is lastFM reporting that the requested
+// artist, album, track or tag does not exist
+func IsNotFound(err error) bool {
+	lfmErr, ok := err.(*Error)
+	return ok && lfmErr.Code == CodeInvalidParameters
+}
+
+//IsTemporary returns true if err is a lastFM error or status that may go
+// away if the request is retried later
+func IsTemporary(err error) bool {
+	switch err := err.(type) {
+	case *Error:
+		return err.Temporary()
+	case *StatusError:
+		return err.Temporary()
+	}
+	return false
+}
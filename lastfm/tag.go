@@ -0,0 +1,24 @@
+package lastfm
+
+import (
+	"context"
+	"net/url"
+)
+
+//TagTopAlbumsResponse from last FM api
+type TagTopAlbumsResponse struct {
+	Albums TopAlbums `json:"albums"`
+}
+
+//GetTagTopAlbums gets the albums most tagged with tag
+func (cli *Client) GetTagTopAlbums(
+	ctx context.Context,
+	tag string,
+	page Page,
+) (*TagTopAlbumsResponse, error) {
+	params := url.Values{"tag": {tag}}
+	page.apply(params)
+	var albums TagTopAlbumsResponse
+	err := cli.call(ctx, "tag.gettopalbums", params, &albums)
+	return &albums, err
+}
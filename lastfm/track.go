@@ -0,0 +1,82 @@
+package lastfm
+
+import (
+	"context"
+	"net/url"
+)
+
+//TrackInfo from last FM api, unlike Track its duration is in milliseconds
+type TrackInfo struct {
+	Name       string     `json:"name"`
+	MBID       string     `json:"mbid"`
+	URL        string     `json:"url"`
+	Duration   Number     `json:"duration"`
+	Listeners  Number     `json:"listeners"`
+	Playcount  Number     `json:"playcount"`
+	Artist     ArtistRef  `json:"artist"`
+	Image      Images     `json:"image"`
+	Streamable Streamable `json:"streamable"`
+	Album      struct {
+		Artist   string `json:"artist"`
+		Title    string `json:"title"`
+		MBID     string `json:"mbid"`
+		URL      string `json:"url"`
+		Image    Images `json:"image"`
+		Position struct {
+			Position Number `json:"position"`
+		} `json:"@attr"`
+	} `json:"album"`
+	TopTags TagList `json:"toptags"`
+	Wiki    Wiki    `json:"wiki"`
+}
+
+//TrackResponse from last FM api
+type TrackResponse struct {
+	Track TrackInfo `json:"track"`
+}
+
+//TrackInfoList is a list of tracks as found in track searches
+type TrackInfoList []TrackInfo
+
+//UnmarshalJSON decodes a {"track": [...]} list
+func (l *TrackInfoList) UnmarshalJSON(data []byte) error {
+	return unmarshalList(data, "track", (*[]TrackInfo)(l))
+}
+
+//TrackSearchResults of a last FM track search
+type TrackSearchResults struct {
+	Results struct {
+		SearchInfo
+		TrackMatches TrackInfoList `json:"trackmatches"`
+	} `json:"results"`
+}
+
+//GetTrackInfo gets best match for artistName-trackName
+func (cli *Client) GetTrackInfo(
+	ctx context.Context,
+	artistName, trackName string,
+) (*TrackResponse, error) {
+	var track TrackResponse
+	err := cli.call(ctx, "track.getinfo", url.Values{
+		"artist":      {artistName},
+		"track":       {trackName},
+		"autocorrect": {"1"},
+	}, &track)
+	return &track, err
+}
+
+//SearchTracks searches for tracks, artistName may be empty
+func (cli *Client) SearchTracks(
+	ctx context.Context,
+	searchTerm, artistName string,
+	page Page,
+) (*TrackSearchResults, error) {
+	params := url.Values{"track": {searchTerm}}
+	if artistName != "" {
+		params.Set("artist", artistName)
+	}
+	page.apply(params)
+	var results TrackSearchResults
+	err := cli.call(ctx, "track.search", params, &results)
+	return &results, err
+}
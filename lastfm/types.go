@@ -0,0 +1,202 @@
+package lastfm
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+//Number is an integer that lastFM sends either as a JSON number or string
+type Number int64
+
+//UnmarshalJSON accepts numbers, numeric strings and empty strings
+func (n *Number) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "" || s == "null" {
+		*n = 0
+		return nil
+	}
+	v, err := strconv.ParseInt(s, 10, 64)
+	*n = Number(v)
+	return err
+}
+
+//Float is a decimal number that lastFM sends either as a JSON number or
+// string
+type Float float64
+
+//UnmarshalJSON accepts numbers, numeric strings and empty strings
+func (f *Float) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "" || s == "null" {
+		*f = 0
+		return nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	*f = Float(v)
+	return err
+}
+
+//Image from last FM api
+type Image struct {
+	Text string `json:"#text"`
+	Size string `json:"size"`
+}
+
+//Images is a set of the same image in different sizes
+type Images []Image
+
+var imageSizes = []string{"mega", "extralarge", "large", "medium", "small"}
+
+//Size returns the url of the image with the given size or an empty string
+func (imgs Images) Size(size string) string {
+	for _, img := range imgs {
+		if img.Size == size {
+			return img.Text
+		}
+	}
+	return ""
+}
+
+//Largest returns the url of the largest image available or an empty string
+func (imgs Images) Largest() string {
+	for _, size := range imageSizes {
+		if url := imgs.Size(size); url != "" {
+			return url
+		}
+	}
+	return ""
+}
+
+//ArtistRef is a reference to an artist, lastFM sends it either as a plain
+// name or as an object
+type ArtistRef struct {
+	Name string `json:"name"`
+	MBID string `json:"mbid"`
+	URL  string `json:"url"`
+}
+
+//UnmarshalJSON accepts both a name string and an artist object
+func (ref *ArtistRef) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		*ref = ArtistRef{}
+		return json.Unmarshal(data, &ref.Name)
+	}
+	type plain ArtistRef
+	return json.Unmarshal(data, (*plain)(ref))
+}
+
+//Streamable tells whether a track can be streamed from lastFM, it is sent
+// either as an object or, in searches, as a plain string
+type Streamable struct {
+	Text      string `json:"#text"`
+	FullTrack string `json:"fulltrack"`
+}
+
+//UnmarshalJSON accepts both a string and a streamable object
+func (s *Streamable) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		*s = Streamable{}
+		return json.Unmarshal(data, &s.Text)
+	}
+	type plain Streamable
+	return json.Unmarshal(data, (*plain)(s))
+}
+
+//Wiki is the descriptive text attached to artists, albums and tracks
+type Wiki struct {
+	Published string `json:"published"`
+	Summary   string `json:"summary"`
+	Content   string `json:"content"`
+}
+
+//Tag from last FM api
+type Tag struct {
+	Name  string `json:"name"`
+	URL   string `json:"url"`
+	Count Number `json:"count"`
+}
+
+//TagList is a list of tags
+type TagList []Tag
+
+//UnmarshalJSON decodes a {"tag": [...]} list
+func (l *TagList) UnmarshalJSON(data []byte) error {
+	return unmarshalList(data, "tag", (*[]Tag)(l))
+}
+
+//Names of the tags in the list
+func (l TagList) Names() []string {
+	names := make([]string, len(l))
+	for i, tag := range l {
+		names[i] = tag.Name
+	}
+	return names
+}
+
+//TrackList is a list of tracks
+type TrackList []Track
+
+//UnmarshalJSON decodes a {"track": [...]} list
+func (l *TrackList) UnmarshalJSON(data []byte) error {
+	return unmarshalList(data, "track", (*[]Track)(l))
+}
+
+//AlbumList is a list of albums
+type AlbumList []Album
+
+//UnmarshalJSON decodes an {"album": [...]} list
+func (l *AlbumList) UnmarshalJSON(data []byte) error {
+	return unmarshalList(data, "album", (*[]Album)(l))
+}
+
+//ArtistList is a list of artists
+type ArtistList []Artist
+
+//UnmarshalJSON decodes an {"artist": [...]} list
+func (l *ArtistList) UnmarshalJSON(data []byte) error {
+	return unmarshalList(data, "artist", (*[]Artist)(l))
+}
+
+//unmarshalList decodes lastFM's {key: [...]} lists which are sent as a
+// single object when they have one element and as "" when they are empty
+func unmarshalList(data []byte, key string, v interface{}) error {
+	if len(data) == 0 || data[0] == '"' || string(data) == "null" {
+		return nil
+	}
+	var wrapper map[string]json.RawMessage
+	if err := json.Unmarshal(data, &wrapper); err != nil {
+		return err
+	}
+	raw, ok := wrapper[key]
+	if !ok || len(raw) == 0 {
+		return nil
+	}
+	if raw[0] == '{' {
+		raw = append(append([]byte{'['}, raw...), ']')
+	}
+	return json.Unmarshal(raw, v)
+}
+
+//unmarshalPagedList decodes a list like unmarshalList and its "@attr"
+// object, which holds paging information, into attr
+func unmarshalPagedList(
+	data []byte,
+	key string,
+	v, attr interface{},
+) error {
+	if err := unmarshalList(data, key, v); err != nil {
+		return err
+	}
+	if len(data) == 0 || data[0] != '{' {
+		return nil
+	}
+	var wrapper struct {
+		Attr json.RawMessage `json:"@attr"`
+	}
+	if err := json.Unmarshal(data, &wrapper); err != nil ||
+		len(wrapper.Attr) == 0 {
+		return err
+	}
+	return json.Unmarshal(wrapper.Attr, attr)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/waelbendhia/music-streaming/wms/config"
+	"github.com/waelbendhia/music-streaming/wms/library"
+)
+
+const libraryUsage = `usage: music-streaming library scan [flags] [path...]
+
+  scan        import the audio files under the given paths, or under
+              library.paths, laid out as Artist/Release/01 - Track.ext
+
+`
+
+func runLibrary(args []string) int {
+	action, args := splitAction(args)
+	flags := newFlagSet("library", libraryUsage)
+	cfg, err := config.Load(flags, args)
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+	if action != "scan" {
+		flags.Usage()
+		return 2
+	}
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = cfg.Library.Paths
+	}
+	if len(paths) == 0 {
+		log.Println("no paths to scan, set library.paths or give some")
+		return 2
+	}
+	database, err := openStore(cfg)
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+	defer database.Close()
+	res, err := library.Scan(database, paths)
+	for _, path := range res.Skipped {
+		log.Println("Skipped", path)
+	}
+	fmt.Printf("Scanned %d releases and %d tracks\n", res.Releases, res.Tracks)
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+	return 0
+}
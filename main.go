@@ -1,24 +1,101 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"log"
 	"os"
+	"strings"
+	"text/tabwriter"
 
-	"github.com/waelbendhia/music-streaming/wms/server"
+	"github.com/waelbendhia/music-streaming/wms/config"
+	"github.com/waelbendhia/music-streaming/wms/db"
+	"github.com/waelbendhia/music-streaming/wms/store"
 )
 
+var commands = []struct {
+	name, summary string
+	run           func(args []string) int
+}{
+	{"serve", "start the server, the default", runServe},
+	{"search", "search releases in the library and the provider", runSearch},
+	{"download", "download a release", runDownload},
+	{"downloads", "list or cancel downloads", runDownloads},
+	{"library", "import music files already on disk", runLibrary},
+	{"stats", "show listening statistics", runStats},
+	{"user", "manage API users", runUser},
+	{"migrate", "apply or revert schema migrations", runMigrate},
+	{"config", "show the effective configuration", runConfig},
+}
+
 func main() {
-	server, err := server.NewServer(
-		os.Stdout,
-		os.Stderr,
-		"localhost",
-		"wmsDB",
-		os.Getenv("LASTFM_API_KEY"),
-		"/home/wael/third-world-streams/",
-		"0.0.0.0:12345",
+	log.SetFlags(0)
+	if len(os.Args) < 2 || strings.HasPrefix(os.Args[1], "-") {
+		os.Exit(runServe(os.Args[1:]))
+	}
+	for _, cmd := range commands {
+		if cmd.name == os.Args[1] {
+			os.Exit(cmd.run(os.Args[2:]))
+		}
+	}
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: music-streaming [command] [flags] [args]")
+	fmt.Fprintln(os.Stderr)
+	w := tabwriter.NewWriter(os.Stderr, 0, 4, 2, ' ', 0)
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %s\t%s\n", cmd.name, cmd.summary)
+	}
+	w.Flush()
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Run 'music-streaming <command> -h' for its flags.")
+}
+
+//newFlagSet returns the flag set of a command, usageText is printed before
+// its flags
+func newFlagSet(name, usageText string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, usageText)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+//splitAction returns the action args start with, if any, and the rest of
+// args, commands with actions take their flags after it
+func splitAction(args []string) (string, []string) {
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		return args[0], args[1:]
+	}
+	return "", args
+}
+
+//openStore opens the configured storage and applies pending migrations
+func openStore(cfg *config.Config) (store.Store, error) {
+	database, err := db.Open(
+		cfg.Storage.URL,
+		log.New(os.Stderr, "", log.LstdFlags),
 	)
 	if err != nil {
-		log.Fatal(err)
+		return nil, fmt.Errorf("could not open database: %v", err)
+	}
+	if migrator, ok := database.(store.Migrator); ok {
+		if _, err := migrator.MigrateUp(); err != nil {
+			database.Close()
+			return nil, err
+		}
 	}
-	log.Println("server exited with value:", <-server.Start(":8082"))
+	return database, nil
+}
+
+//newTable returns a writer aligning tab separated columns, it must be
+// flushed
+func newTable(header ...string) *tabwriter.Writer {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	return w
 }
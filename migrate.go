@@ -0,0 +1,93 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/waelbendhia/music-streaming/wms/config"
+	"github.com/waelbendhia/music-streaming/wms/db"
+	"github.com/waelbendhia/music-streaming/wms/store"
+)
+
+const migrateUsage = `usage: music-streaming migrate [flags] up|down [steps]|status
+
+  up          apply all pending migrations
+  down        revert the last applied migration, or the last <steps>
+  status      list migrations and whether they are applied
+`
+
+func runMigrate(args []string) int {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, migrateUsage)
+		flags.PrintDefaults()
+	}
+	cfg, err := config.Load(flags, args)
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+	if flags.NArg() < 1 {
+		flags.Usage()
+		return 2
+	}
+	database, err := db.Open(
+		cfg.Storage.URL,
+		log.New(os.Stdout, "", log.LstdFlags),
+	)
+	if err != nil {
+		log.Println("could not open database:", err)
+		return 1
+	}
+	defer database.Close()
+	migrator, ok := database.(store.Migrator)
+	if !ok {
+		log.Println("database does not support migrations")
+		return 1
+	}
+	switch flags.Arg(0) {
+	case "up":
+		applied, err := migrator.MigrateUp()
+		if err != nil {
+			log.Println(err)
+			return 1
+		}
+		fmt.Printf("Applied %d migrations\n", applied)
+	case "down":
+		steps := 1
+		if flags.NArg() > 1 {
+			if steps, err = strconv.Atoi(flags.Arg(1)); err != nil || steps < 1 {
+				flags.Usage()
+				return 2
+			}
+		}
+		if err := migrator.MigrateDown(steps); err != nil {
+			log.Println(err)
+			return 1
+		}
+	case "status":
+		statuses, err := migrator.MigrationStatus()
+		if err != nil {
+			log.Println(err)
+			return 1
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+		for _, st := range statuses {
+			applied := "pending"
+			if st.Applied {
+				applied = st.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", st.Version, st.Name, applied)
+		}
+		w.Flush()
+	default:
+		flags.Usage()
+		return 2
+	}
+	return 0
+}
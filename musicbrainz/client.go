@@ -0,0 +1,151 @@
+package musicbrainz
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const root = "https://musicbrainz.org/ws/2"
+
+const coverArtRoot = "https://coverartarchive.org"
+
+//CoverArtURL returns the URL of the front cover of the release with the
+// given MBID on the Cover Art Archive
+func CoverArtURL(mbid string) string {
+	return coverArtRoot + "/release/" + mbid + "/front"
+}
+
+//DefaultUserAgent identifies this application to MusicBrainz which requires
+// every client to send a meaningful user agent
+const DefaultUserAgent = "music-streaming/0.1 " +
+	"( https://github.com/waelbendhia/music-streaming )"
+
+//Client allows you to make queries to the MusicBrainz api, it never sends
+// more than one request per second as required by MusicBrainz
+type Client struct {
+	root       string
+	userAgent  string
+	httpClient *http.Client
+	limiter    *rate.Limiter
+}
+
+//NewClient creates a MusicBrainz client, if userAgent is empty
+// DefaultUserAgent is used and if httpClient is nil http.DefaultClient is
+func NewClient(userAgent string, httpClient *http.Client) *Client {
+	if userAgent == "" {
+		userAgent = DefaultUserAgent
+	}
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{
+		root:       root,
+		userAgent:  userAgent,
+		httpClient: httpClient,
+		limiter:    rate.NewLimiter(rate.Every(time.Second), 1),
+	}
+}
+
+//WithRoot returns a copy of the client that sends its queries to root
+// instead of musicbrainz.org, the copy shares the rate limit of cli
+func (cli *Client) WithRoot(root string) *Client {
+	cp := *cli
+	cp.root = root
+	return &cp
+}
+
+func readBody(r *http.Response) ([]byte, error) {
+	return ioutil.ReadAll(io.LimitReader(r.Body, 4*1048576))
+}
+
+//get queries the resource at path with params and decodes the response
+// into v
+func (cli *Client) get(
+	ctx context.Context,
+	path string,
+	params url.Values,
+	v interface{},
+) error {
+	if err := cli.limiter.Wait(ctx); err != nil {
+		return err
+	}
+	if params == nil {
+		params = url.Values{}
+	}
+	params.Set("fmt", "json")
+	req, err := http.NewRequest("GET", cli.root+path+"?"+params.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", cli.userAgent)
+	req.Header.Set("Accept", "application/json")
+	resp, err := cli.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := readBody(resp)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		mbErr := &Error{StatusCode: resp.StatusCode}
+		if json.Unmarshal(body, mbErr) != nil || mbErr.Message == "" {
+			mbErr.Message = resp.Status
+		}
+		return mbErr
+	}
+	return json.Unmarshal(body, v)
+}
+
+func lookupParams(inc []string) url.Values {
+	params := url.Values{}
+	if len(inc) > 0 {
+		params.Set("inc", strings.Join(inc, " "))
+	}
+	return params
+}
+
+func searchParams(query string, limit, offset int) url.Values {
+	params := url.Values{"query": {query}}
+	if limit > 0 {
+		params.Set("limit", fmt.Sprint(limit))
+	}
+	if offset > 0 {
+		params.Set("offset", fmt.Sprint(offset))
+	}
+	return params
+}
+
+//Error from MusicBrainz api
+type Error struct {
+	StatusCode int    `json:"-"`
+	Message    string `json:"error"`
+}
+
+func (err *Error) Error() string {
+	return fmt.Sprintf("musicbrainz: %s (status %d)", err.Message, err.StatusCode)
+}
+
+//Temporary returns true if the request may succeed if retried later
+func (err *Error) Temporary() bool {
+	return err.StatusCode == http.StatusServiceUnavailable ||
+		err.StatusCode == http.StatusTooManyRequests ||
+		err.StatusCode >= 500
+}
+
+//IsNotFound returns true if err is MusicBrainz reporting that the requested
+// entity does not exist
+func IsNotFound(err error) bool {
+	mbErr, ok := err.(*Error)
+	return ok && mbErr.StatusCode == http.StatusNotFound
+}
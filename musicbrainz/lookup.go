@@ -0,0 +1,106 @@
+package musicbrainz
+
+import (
+	"context"
+	"net/url"
+)
+
+//ReleaseGroupSearchResults of a MusicBrainz release group search
+type ReleaseGroupSearchResults struct {
+	Count         int            `json:"count"`
+	Offset        int            `json:"offset"`
+	ReleaseGroups []ReleaseGroup `json:"release-groups"`
+}
+
+//ReleaseSearchResults of a MusicBrainz release search
+type ReleaseSearchResults struct {
+	Count    int       `json:"count"`
+	Offset   int       `json:"offset"`
+	Releases []Release `json:"releases"`
+}
+
+//ArtistSearchResults of a MusicBrainz artist search
+type ArtistSearchResults struct {
+	Count   int      `json:"count"`
+	Offset  int      `json:"offset"`
+	Artists []Artist `json:"artists"`
+}
+
+//SearchReleaseGroups using a lucene query, see Phrase to build clauses
+func (cli *Client) SearchReleaseGroups(
+	ctx context.Context,
+	query string,
+	limit, offset int,
+) (*ReleaseGroupSearchResults, error) {
+	var results ReleaseGroupSearchResults
+	err := cli.get(
+		ctx,
+		"/release-group",
+		searchParams(query, limit, offset),
+		&results,
+	)
+	return &results, err
+}
+
+//SearchReleases using a lucene query, see Phrase to build clauses
+func (cli *Client) SearchReleases(
+	ctx context.Context,
+	query string,
+	limit, offset int,
+) (*ReleaseSearchResults, error) {
+	var results ReleaseSearchResults
+	err := cli.get(ctx, "/release", searchParams(query, limit, offset), &results)
+	return &results, err
+}
+
+//SearchArtists using a lucene query, see Phrase to build clauses
+func (cli *Client) SearchArtists(
+	ctx context.Context,
+	query string,
+	limit, offset int,
+) (*ArtistSearchResults, error) {
+	var results ArtistSearchResults
+	err := cli.get(ctx, "/artist", searchParams(query, limit, offset), &results)
+	return &results, err
+}
+
+//LookupRelease gets a release by MBID, inc selects the related entities to
+// include e.g. "recordings", "artist-credits", "isrcs", "labels"
+func (cli *Client) LookupRelease(
+	ctx context.Context,
+	mbid string,
+	inc ...string,
+) (*Release, error) {
+	var rel Release
+	err := cli.get(ctx, "/release/"+url.PathEscape(mbid), lookupParams(inc), &rel)
+	return &rel, err
+}
+
+//LookupReleaseGroup gets a release group by MBID, inc selects the related
+// entities to include e.g. "releases", "artist-credits", "genres"
+func (cli *Client) LookupReleaseGroup(
+	ctx context.Context,
+	mbid string,
+	inc ...string,
+) (*ReleaseGroup, error) {
+	var rg ReleaseGroup
+	err := cli.get(
+		ctx,
+		"/release-group/"+url.PathEscape(mbid),
+		lookupParams(inc),
+		&rg,
+	)
+	return &rg, err
+}
+
+//LookupArtist gets an artist by MBID, inc selects the related entities to
+// include e.g. "tags", "genres"
+func (cli *Client) LookupArtist(
+	ctx context.Context,
+	mbid string,
+	inc ...string,
+) (*Artist, error) {
+	var artist Artist
+	err := cli.get(ctx, "/artist/"+url.PathEscape(mbid), lookupParams(inc), &artist)
+	return &artist, err
+}
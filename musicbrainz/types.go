@@ -0,0 +1,179 @@
+package musicbrainz
+
+import (
+	"strings"
+	"time"
+)
+
+//NameCredit is one of the artists credited for a release or track
+type NameCredit struct {
+	Name       string `json:"name"`
+	JoinPhrase string `json:"joinphrase"`
+	Artist     Artist `json:"artist"`
+}
+
+//ArtistCredit lists the artists credited for a release or track
+type ArtistCredit []NameCredit
+
+//String returns the credit as it is printed on the release
+func (ac ArtistCredit) String() string {
+	var b strings.Builder
+	for _, credit := range ac {
+		name := credit.Name
+		if name == "" {
+			name = credit.Artist.Name
+		}
+		b.WriteString(name)
+		b.WriteString(credit.JoinPhrase)
+	}
+	return b.String()
+}
+
+//MainArtist returns the first credited artist
+func (ac ArtistCredit) MainArtist() Artist {
+	if len(ac) == 0 {
+		return Artist{}
+	}
+	return ac[0].Artist
+}
+
+//Tag is a user applied tag or genre
+type Tag struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+//Artist from MusicBrainz api
+type Artist struct {
+	ID             string `json:"id"`
+	Name           string `json:"name"`
+	SortName       string `json:"sort-name"`
+	Disambiguation string `json:"disambiguation"`
+	Type           string `json:"type"`
+	Country        string `json:"country"`
+	Score          int    `json:"score"`
+	Tags           []Tag  `json:"tags"`
+	Genres         []Tag  `json:"genres"`
+}
+
+//ReleaseGroup from MusicBrainz api, it groups the different releases of an
+// album
+type ReleaseGroup struct {
+	ID               string       `json:"id"`
+	Title            string       `json:"title"`
+	PrimaryType      string       `json:"primary-type"`
+	SecondaryTypes   []string     `json:"secondary-types"`
+	FirstReleaseDate string       `json:"first-release-date"`
+	Disambiguation   string       `json:"disambiguation"`
+	ArtistCredit     ArtistCredit `json:"artist-credit"`
+	Releases         []Release    `json:"releases"`
+	Score            int          `json:"score"`
+	Tags             []Tag        `json:"tags"`
+	Genres           []Tag        `json:"genres"`
+}
+
+//LabelInfo is the label and catalogue number a release was issued under
+type LabelInfo struct {
+	CatalogNumber string `json:"catalog-number"`
+	Label         *struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"label"`
+}
+
+//Release from MusicBrainz api
+type Release struct {
+	ID             string        `json:"id"`
+	Title          string        `json:"title"`
+	Status         string        `json:"status"`
+	Date           string        `json:"date"`
+	Country        string        `json:"country"`
+	Disambiguation string        `json:"disambiguation"`
+	Barcode        string        `json:"barcode"`
+	ArtistCredit   ArtistCredit  `json:"artist-credit"`
+	ReleaseGroup   *ReleaseGroup `json:"release-group"`
+	LabelInfo      []LabelInfo   `json:"label-info"`
+	Media          []Medium      `json:"media"`
+	TrackCount     int           `json:"track-count"`
+	Score          int           `json:"score"`
+	Tags           []Tag         `json:"tags"`
+	Genres         []Tag         `json:"genres"`
+	//CoverArt tells which images of the release the Cover Art Archive has
+	CoverArt CoverArt `json:"cover-art-archive"`
+}
+
+//CoverArt of a release on the Cover Art Archive
+type CoverArt struct {
+	Artwork bool `json:"artwork"`
+	Front   bool `json:"front"`
+	Count   int  `json:"count"`
+}
+
+//Medium is a disc, side or other physical or digital part of a release
+type Medium struct {
+	Position   int     `json:"position"`
+	Title      string  `json:"title"`
+	Format     string  `json:"format"`
+	TrackCount int     `json:"track-count"`
+	Tracks     []Track `json:"tracks"`
+}
+
+//Track is a recording as it appears on a medium
+type Track struct {
+	ID           string       `json:"id"`
+	Number       string       `json:"number"`
+	Position     int          `json:"position"`
+	Title        string       `json:"title"`
+	Length       int          `json:"length"`
+	ArtistCredit ArtistCredit `json:"artist-credit"`
+	Recording    Recording    `json:"recording"`
+}
+
+//Duration of the track
+func (t Track) Duration() time.Duration {
+	length := t.Length
+	if length == 0 {
+		length = t.Recording.Length
+	}
+	return time.Duration(length) * time.Millisecond
+}
+
+//Recording is a distinct audio recording which may appear on many releases
+type Recording struct {
+	ID           string       `json:"id"`
+	Title        string       `json:"title"`
+	Length       int          `json:"length"`
+	ISRCs        []string     `json:"isrcs"`
+	ArtistCredit ArtistCredit `json:"artist-credit"`
+}
+
+//ParseDate parses MusicBrainz's partial dates, YYYY, YYYY-MM and
+// YYYY-MM-DD, returning false if the date is empty or malformed
+func ParseDate(date string) (time.Time, bool) {
+	for _, layout := range []string{"2006-01-02", "2006-01", "2006"} {
+		if t, err := time.Parse(layout, date); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+var (
+	phraseEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	termsEscaper  = strings.NewReplacer(
+		`\`, `\\`, `+`, `\+`, `-`, `\-`, `&`, `\&`, `|`, `\|`,
+		`!`, `\!`, `(`, `\(`, `)`, `\)`, `{`, `\{`, `}`, `\}`,
+		`[`, `\[`, `]`, `\]`, `^`, `\^`, `"`, `\"`, `~`, `\~`,
+		`*`, `\*`, `?`, `\?`, `:`, `\:`, `/`, `\/`,
+	)
+)
+
+//Phrase builds a search clause matching value exactly in field
+func Phrase(field, value string) string {
+	return field + `:"` + phraseEscaper.Replace(value) + `"`
+}
+
+//Escape user input so it can be used as free text terms in a query
+func Escape(terms string) string {
+	return termsEscaper.Replace(terms)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/waelbendhia/music-streaming/wms/config"
+)
+
+const searchUsage = `usage: music-streaming search [flags] <query>
+
+Search releases in the library and with the metadata provider.
+
+`
+
+func runSearch(args []string) int {
+	flags := newFlagSet("search", searchUsage)
+	remote := addRemoteFlags(flags)
+	cfg, err := config.Load(flags, args)
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+	if flags.NArg() < 1 {
+		flags.Usage()
+		return 2
+	}
+	b, err := remote.openBackend(cfg)
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+	defer b.Close()
+	rels, err := b.search(strings.Join(flags.Args(), " "))
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+	w := newTable("ARTIST", "RELEASE", "YEAR", "ID")
+	for _, rel := range rels {
+		var artist, year string
+		if rel.AlbumArtist != nil {
+			artist = rel.AlbumArtist.Name
+		}
+		if !rel.ReleaseDate.IsZero() {
+			year = fmt.Sprint(rel.ReleaseDate.Year())
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", artist, rel.Name, year, rel.ID.Hex())
+	}
+	w.Flush()
+	return 0
+}
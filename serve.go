@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/waelbendhia/music-streaming/wms/config"
+	"github.com/waelbendhia/music-streaming/wms/logging"
+	"github.com/waelbendhia/music-streaming/wms/server"
+)
+
+const serveUsage = `usage: music-streaming [serve] [flags]
+
+Start the server. On SIGINT or SIGTERM requests in flight and downloads
+are given http.shutdown_timeout to finish, a second signal exits at once.
+
+`
+
+func runServe(args []string) int {
+	flags := newFlagSet("serve", serveUsage)
+	cfg, err := config.Load(flags, args)
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Println(err)
+		return 1
+	}
+	logger, err := cfg.Logger(os.Stdout)
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+	// Libraries log through the standard logger
+	log.SetOutput(logging.Writer(logger.Subsystem("lib"), logging.Info))
+	defer log.SetOutput(os.Stderr)
+	server, err := server.NewServer(logger, cfg)
+	logger = logger.Subsystem("main")
+	if err != nil {
+		logger.Error("Could not create server", "err", err)
+		return 1
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	var exitVal int
+	select {
+	case exitVal = <-server.Start(cfg.HTTP.Addr):
+	case sig := <-signals:
+		logger.Info("Shutting down", "signal", sig)
+		go func() {
+			sig := <-signals
+			logger.Warn("Exiting now", "signal", sig)
+			os.Exit(1)
+		}()
+	}
+	ctx, cancel := context.WithTimeout(
+		context.Background(),
+		cfg.HTTP.ShutdownTimeout.Duration,
+	)
+	defer cancel()
+	if err := server.Stop(ctx); err != nil {
+		logger.Error("Could not stop cleanly", "err", err)
+		exitVal = 1
+	}
+	logger.Info("Server exited", "exit_value", exitVal)
+	return exitVal
+}
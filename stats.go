@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/waelbendhia/music-streaming/wms/config"
+)
+
+const statsUsage = `usage: music-streaming stats top [flags]
+
+  top         list the most listened tracks
+
+`
+
+func runStats(args []string) int {
+	action, args := splitAction(args)
+	flags := newFlagSet("stats", statsUsage)
+	remote := addRemoteFlags(flags)
+	days := flags.Int("days", 7, "only count listens of the last days")
+	limit := flags.Int("limit", 10, "number of tracks listed")
+	cfg, err := config.Load(flags, args)
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+	if action != "top" || flags.NArg() != 0 || *days < 1 || *limit < 1 {
+		flags.Usage()
+		return 2
+	}
+	b, err := remote.openBackend(cfg)
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+	defer b.Close()
+	top, err := b.topTracks(*days, *limit)
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+	w := newTable("LISTENS", "TRACK", "ID")
+	for _, t := range top {
+		fmt.Fprintf(w, "%d\t%s\t%s\n", t.Count, t.Track.Name, t.Track.ID.Hex())
+	}
+	w.Flush()
+	return 0
+}
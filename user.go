@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/waelbendhia/music-streaming/wms/config"
+	"github.com/waelbendhia/music-streaming/wms/models"
+	"github.com/waelbendhia/music-streaming/wms/store"
+)
+
+const userUsage = `usage: music-streaming user add [flags] <name>
+
+  add         create a user and print its API token, which can't be
+              shown again
+
+`
+
+func runUser(args []string) int {
+	action, args := splitAction(args)
+	flags := newFlagSet("user", userUsage)
+	admin := flags.Bool("admin", false, "let the user use /admin endpoints")
+	cfg, err := config.Load(flags, args)
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+	if action != "add" || flags.NArg() != 1 || flags.Arg(0) == "" {
+		flags.Usage()
+		return 2
+	}
+	token, err := store.NewToken()
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+	database, err := openStore(cfg)
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+	defer database.Close()
+	err = database.InsertUser(&models.User{
+		Name:      flags.Arg(0),
+		TokenHash: store.HashToken(token),
+		Admin:     *admin,
+		CreatedAt: time.Now(),
+	})
+	if err == store.ErrDuplicate {
+		log.Printf("user '%s' already exists", flags.Arg(0))
+		return 1
+	}
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+	fmt.Println(token)
+	return 0
+}
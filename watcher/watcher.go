@@ -3,20 +3,20 @@ package watcher
 import (
 	"github.com/anacrolix/torrent"
 	"github.com/waelbendhia/music-streaming/wms/models"
-	mgo "gopkg.in/mgo.v2"
+	"github.com/waelbendhia/music-streaming/wms/store"
 )
 
 //Watcher watches downloading tracks and updates their status accordingly
 type Watcher struct {
 	activeDownloads []models.Track
-	db              *mgo.Database
+	db              store.TrackStore
 	torrentCli      *torrent.Client
 	stopChannel     chan bool
 	downloadDir     string
 }
 
 //Start the watcher
-func (w *Watcher) Start(db *mgo.Database, torCli *torrent.Client) {
+func (w *Watcher) Start(db store.TrackStore, torCli *torrent.Client) {
 	w.db = db
 	w.torrentCli = torCli
 	w.stopChannel = make(chan bool, 1)
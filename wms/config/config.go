@@ -0,0 +1,584 @@
+//Package config loads the settings of the music streaming server from a YAML
+// or TOML file, the environment and command line flags
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/waelbendhia/music-streaming/wms/cover"
+	"github.com/waelbendhia/music-streaming/wms/db"
+	"github.com/waelbendhia/music-streaming/wms/disk"
+	"github.com/waelbendhia/music-streaming/wms/enrich"
+	"github.com/waelbendhia/music-streaming/wms/logging"
+	"github.com/waelbendhia/music-streaming/wms/metadata"
+	"github.com/waelbendhia/music-streaming/wms/torrent"
+)
+
+//Config of the music streaming server, every setting is a key of the
+// configuration file made of its section's and its own yaml tag. It can also
+// be set by the WMS_<SECTION>_<KEY> environment variable, or the one in its
+// env tag, and by the -<section>.<key> flag.
+type Config struct {
+	Storage  Storage  `yaml:"storage" toml:"storage"`
+	HTTP     HTTP     `yaml:"http" toml:"http"`
+	Metadata Metadata `yaml:"metadata" toml:"metadata"`
+	Torrent  Torrent  `yaml:"torrent" toml:"torrent"`
+	Library  Library  `yaml:"library" toml:"library"`
+	Naming   Naming   `yaml:"naming" toml:"naming"`
+	Auth     Auth     `yaml:"auth" toml:"auth"`
+	Log      Log      `yaml:"log" toml:"log"`
+	Health   Health   `yaml:"health" toml:"health"`
+	Disk     Disk     `yaml:"disk" toml:"disk"`
+	Covers   Covers   `yaml:"covers" toml:"covers"`
+
+	//file the configuration was read from, if any
+	file string
+	//sources maps settings to where their value comes from
+	sources map[string]string
+}
+
+//Storage settings
+type Storage struct {
+	URL string `yaml:"url" toml:"url" usage:"storage backend URL"`
+}
+
+//HTTP settings
+type HTTP struct {
+	Addr            string   `yaml:"addr" toml:"addr" usage:"address the API listens on"`
+	ShutdownTimeout Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout" usage:"how long requests and downloads are given to finish on shutdown"`
+}
+
+//Metadata provider settings
+type Metadata struct {
+	Provider             string   `yaml:"provider" toml:"provider" usage:"lastfm or musicbrainz"`
+	LastFMAPIKey         string   `yaml:"lastfm_api_key" toml:"lastfm_api_key" env:"LASTFM_API_KEY" secret:"true" usage:"lastFM API key"`
+	MusicBrainzUserAgent string   `yaml:"musicbrainz_user_agent" toml:"musicbrainz_user_agent" env:"MUSICBRAINZ_USER_AGENT" usage:"User-Agent sent to MusicBrainz"`
+	RequestsPerSecond    float64  `yaml:"requests_per_second" toml:"requests_per_second" usage:"requests per second allowed against the provider"`
+	RefreshInterval      Duration `yaml:"refresh_interval" toml:"refresh_interval" usage:"interval between two enrichment passes"`
+	StaleAfter           Duration `yaml:"stale_after" toml:"stale_after" usage:"how long metadata is kept before it is fetched again"`
+}
+
+//Torrent client settings
+type Torrent struct {
+	ListenAddr        string        `yaml:"listen_addr" toml:"listen_addr" usage:"address the torrent client listens on"`
+	DownloadDir       string        `yaml:"download_dir" toml:"download_dir" usage:"directory torrents are downloaded to"`
+	DownloadRate      int64         `yaml:"download_rate" toml:"download_rate" usage:"download limit in KiB/s, 0 is unlimited"`
+	UploadRate        int64         `yaml:"upload_rate" toml:"upload_rate" usage:"upload limit in KiB/s, 0 is unlimited"`
+	ConnsPerTorrent   int           `yaml:"conns_per_torrent" toml:"conns_per_torrent" usage:"peers per torrent, 0 is the client's default"`
+	Seed              bool          `yaml:"seed" toml:"seed" usage:"seed completed torrents"`
+	SeedRatio         float64       `yaml:"seed_ratio" toml:"seed_ratio" usage:"upload to download ratio after which seeding stops, 0 is no target"`
+	SeedTime          Duration      `yaml:"seed_time" toml:"seed_time" usage:"time after which seeding stops, 0 is no target"`
+	QuietStart        torrent.Clock `yaml:"quiet_start" toml:"quiet_start" usage:"local time quiet hours start at, like 08:00"`
+	QuietEnd          torrent.Clock `yaml:"quiet_end" toml:"quiet_end" usage:"local time quiet hours end at, quiet hours are disabled if it is quiet_start"`
+	QuietDownloadRate int64         `yaml:"quiet_download_rate" toml:"quiet_download_rate" usage:"download limit in KiB/s during quiet hours, 0 is unlimited"`
+	QuietUploadRate   int64         `yaml:"quiet_upload_rate" toml:"quiet_upload_rate" usage:"upload limit in KiB/s during quiet hours, 0 is unlimited"`
+	MaxActive         int           `yaml:"max_active" toml:"max_active" usage:"downloads running at once, 0 is unlimited"`
+	MaxQueuedPerUser  int           `yaml:"max_queued_per_user" toml:"max_queued_per_user" usage:"unfinished downloads a user, or anonymous requests together, may have, 0 is unlimited"`
+	StallTimeout      Duration      `yaml:"stall_timeout" toml:"stall_timeout" usage:"time without progress after which a download moves on to its next torrent, 0 is never"`
+	InfoTimeout       Duration      `yaml:"info_timeout" toml:"info_timeout" usage:"time a torrent has to find its metadata before the download moves on to its next torrent, 0 is unlimited"`
+	MinMatch          float64       `yaml:"min_match" toml:"min_match" usage:"share of a release's tracks, from 0 to 1, a torrent's files must match"`
+	VerifyTags        bool          `yaml:"verify_tags" toml:"verify_tags" usage:"move on to the next torrent if the downloaded files are tagged with another release"`
+	Extras            []string      `yaml:"extras" toml:"extras" usage:"comma separated extensions of the files, like cover art and cue sheets, downloaded along with the tracks"`
+	MaxExtraSize      int64         `yaml:"max_extra_size" toml:"max_extra_size" usage:"largest extra file downloaded in KiB, 0 is unlimited"`
+	Readahead         int64         `yaml:"readahead" toml:"readahead" usage:"KiB ahead of the playhead downloaded first when a track is streamed while it downloads"`
+	ReadTimeout       Duration      `yaml:"read_timeout" toml:"read_timeout" usage:"time a track streamed while it downloads waits for its data before the stream fails, 0 is unlimited"`
+	Debug             bool          `yaml:"debug" toml:"debug" usage:"log the torrent library's debug messages"`
+}
+
+//Library settings
+type Library struct {
+	Paths []string `yaml:"paths" toml:"paths" usage:"comma separated directories holding music already on disk"`
+}
+
+//Naming templates of the links to downloaded tracks' files, which tracks
+// are streamed from, they are text/template templates executed with a
+// NameData
+type Naming struct {
+	ReleaseDir string `yaml:"release_dir" toml:"release_dir" usage:"template of a release's directory, relative to the download directory"`
+	TrackFile  string `yaml:"track_file" toml:"track_file" usage:"template of a track's file name, without extension"`
+}
+
+//Auth settings
+type Auth struct {
+	AdminToken string `yaml:"admin_token" toml:"admin_token" secret:"true" usage:"bearer token accepted by /admin endpoints besides admin users' tokens"`
+}
+
+//Log settings
+type Log struct {
+	Format string   `yaml:"format" toml:"format" usage:"logfmt or json"`
+	Level  string   `yaml:"level" toml:"level" usage:"debug, info, warn or error"`
+	Levels []string `yaml:"levels" toml:"levels" usage:"comma separated subsystem=level overrides of log.level, like torrent=debug"`
+}
+
+//Health settings of the readiness checks
+type Health struct {
+	Timeout          Duration `yaml:"timeout" toml:"timeout" usage:"how long every readiness check may take"`
+	MetadataInterval Duration `yaml:"metadata_interval" toml:"metadata_interval" usage:"how long the metadata provider's reachability is cached"`
+	MinFreeSpace     int64    `yaml:"min_free_space" toml:"min_free_space" usage:"free space in MiB of the download directory below which the server isn't ready"`
+}
+
+//What happens to downloads that don't fit on disk
+const (
+	QueueWhenFull  = "queue"
+	RefuseWhenFull = "refuse"
+)
+
+//Disk settings of the download directory
+type Disk struct {
+	Quota     int64  `yaml:"quota" toml:"quota" usage:"maximum size in MiB of the download directory, 0 is unlimited"`
+	MinFree   int64  `yaml:"min_free" toml:"min_free" usage:"space in MiB downloads must leave free on the file system"`
+	WhenFull  string `yaml:"when_full" toml:"when_full" usage:"queue or refuse downloads that don't fit"`
+	AutoEvict bool   `yaml:"auto_evict" toml:"auto_evict" usage:"delete the least played releases to make room for downloads"`
+}
+
+//Covers settings of the cover art served
+type Covers struct {
+	Dir          string   `yaml:"dir" toml:"dir" usage:"directory cover art and its resized variants are stored in"`
+	Sizes        []int    `yaml:"sizes" toml:"sizes" usage:"comma separated sizes in pixels covers are resized to, requested sizes are rounded up to one of them"`
+	Quality      int      `yaml:"quality" toml:"quality" usage:"quality of the resized covers from 1 to 100"`
+	MaxSize      int64    `yaml:"max_size" toml:"max_size" usage:"largest cover art stored in KiB"`
+	FetchTimeout Duration `yaml:"fetch_timeout" toml:"fetch_timeout" usage:"time cover art has to be fetched from the metadata provider's URL"`
+}
+
+//Duration is a time.Duration written like 1h30m
+type Duration struct {
+	time.Duration
+}
+
+//UnmarshalText parses text with time.ParseDuration
+func (d *Duration) UnmarshalText(text []byte) error {
+	var err error
+	d.Duration, err = time.ParseDuration(string(text))
+	return err
+}
+
+//MarshalText formats d like time.Duration.String
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+//NameData is what naming templates are executed with
+type NameData struct {
+	Artist      string
+	Release     string
+	Year        int
+	Disc        int
+	TrackNumber int
+	Track       string
+}
+
+//Default returns the configuration used for settings that aren't set
+func Default() *Config {
+	return &Config{
+		Storage: Storage{URL: db.DefaultURL},
+		HTTP: HTTP{
+			Addr:            ":8082",
+			ShutdownTimeout: Duration{30 * time.Second},
+		},
+		Metadata: Metadata{
+			Provider:          metadata.LastFM,
+			RequestsPerSecond: enrich.DefaultConfig.RequestsPerSecond,
+			RefreshInterval:   Duration{enrich.DefaultConfig.Interval},
+			StaleAfter:        Duration{enrich.DefaultConfig.StaleAfter},
+		},
+		Torrent: Torrent{
+			ListenAddr:       "0.0.0.0:12345",
+			DownloadDir:      filepath.Join(os.TempDir(), "music-streaming"),
+			Seed:             true,
+			SeedRatio:        1,
+			SeedTime:         Duration{24 * time.Hour},
+			MaxActive:        3,
+			MaxQueuedPerUser: 10,
+			StallTimeout:     Duration{10 * time.Minute},
+			InfoTimeout:      Duration{5 * time.Minute},
+			MinMatch:         0.8,
+			VerifyTags:       true,
+			Extras:           []string{".jpg", ".jpeg", ".png", ".cue", ".log"},
+			MaxExtraSize:     20 << 10,
+			Readahead:        2 << 10,
+			ReadTimeout:      Duration{30 * time.Second},
+		},
+		Naming: Naming{
+			ReleaseDir: "{{.Artist}}/{{.Release}}",
+			TrackFile:  `{{printf "%02d" .TrackNumber}} - {{.Track}}`,
+		},
+		Log: Log{Format: logging.Logfmt, Level: logging.Info.String()},
+		Health: Health{
+			Timeout:          Duration{2 * time.Second},
+			MetadataInterval: Duration{time.Minute},
+			MinFreeSpace:     512,
+		},
+		Disk: Disk{MinFree: 1024, WhenFull: QueueWhenFull},
+		Covers: Covers{
+			Dir:          filepath.Join(os.TempDir(), "music-streaming-covers"),
+			Sizes:        []int{64, 128, 256, 512, 1024},
+			Quality:      85,
+			MaxSize:      10 << 10,
+			FetchTimeout: Duration{10 * time.Second},
+		},
+	}
+}
+
+//MetadataConfig returns the configuration of the metadata provider
+func (c *Config) MetadataConfig() metadata.Config {
+	return metadata.Config{
+		Provider:             c.Metadata.Provider,
+		LastFMAPIKey:         c.Metadata.LastFMAPIKey,
+		MusicBrainzUserAgent: c.Metadata.MusicBrainzUserAgent,
+	}
+}
+
+//EnrichConfig returns the configuration of the enrichment worker
+func (c *Config) EnrichConfig() enrich.Config {
+	cfg := enrich.DefaultConfig
+	cfg.Interval = c.Metadata.RefreshInterval.Duration
+	cfg.StaleAfter = c.Metadata.StaleAfter.Duration
+	cfg.RequestsPerSecond = c.Metadata.RequestsPerSecond
+	return cfg
+}
+
+//TorrentConfig returns the configuration of the torrent client
+func (c *Config) TorrentConfig() torrent.Config {
+	return torrent.Config{
+		DownloadDir: c.Torrent.DownloadDir,
+		ListenAddr:  c.Torrent.ListenAddr,
+		Debug:       c.Torrent.Debug,
+		Policy: torrent.Policy{
+			DownloadRate: c.Torrent.DownloadRate * 1024,
+			UploadRate:   c.Torrent.UploadRate * 1024,
+			MaxConns:     c.Torrent.ConnsPerTorrent,
+			Seed:         c.Torrent.Seed,
+			SeedRatio:    c.Torrent.SeedRatio,
+			SeedTime:     c.Torrent.SeedTime.Duration,
+			QuietHours: torrent.QuietHours{
+				Start:        c.Torrent.QuietStart,
+				End:          c.Torrent.QuietEnd,
+				DownloadRate: c.Torrent.QuietDownloadRate * 1024,
+				UploadRate:   c.Torrent.QuietUploadRate * 1024,
+			},
+		},
+	}
+}
+
+//SchedulerConfig returns the configuration of the download scheduler, the
+// server sets its hooks
+func (c *Config) SchedulerConfig() torrent.SchedulerConfig {
+	return torrent.SchedulerConfig{
+		MaxActive:        c.Torrent.MaxActive,
+		MaxQueuedPerUser: c.Torrent.MaxQueuedPerUser,
+		StallTimeout:     c.Torrent.StallTimeout.Duration,
+	}
+}
+
+//DiskConfig returns the configuration of the download directory's manager
+func (c *Config) DiskConfig() disk.Config {
+	return disk.Config{
+		Dir:     c.Torrent.DownloadDir,
+		Quota:   c.Disk.Quota << 20,
+		MinFree: c.Disk.MinFree << 20,
+	}
+}
+
+//CoversConfig returns the configuration of the cover art store
+func (c *Config) CoversConfig() cover.Config {
+	return cover.Config{
+		Dir:     c.Covers.Dir,
+		Sizes:   c.Covers.Sizes,
+		Quality: c.Covers.Quality,
+		MaxSize: c.Covers.MaxSize << 10,
+	}
+}
+
+//Logger returns a logger writing to w at the configured levels
+func (c *Config) Logger(w io.Writer) (*logging.Logger, error) {
+	level, err := logging.ParseLevel(c.Log.Level)
+	if err != nil {
+		return nil, err
+	}
+	logger, err := logging.New(w, c.Log.Format, level)
+	if err != nil {
+		return nil, err
+	}
+	for _, override := range c.Log.Levels {
+		subsystem, level, err := parseLevelOverride(override)
+		if err != nil {
+			return nil, err
+		}
+		logger.SetLevel(subsystem, level)
+	}
+	return logger, nil
+}
+
+//parseLevelOverride parses a subsystem=level override
+func parseLevelOverride(
+	override string,
+) (subsystem string, level logging.Level, err error) {
+	parts := strings.SplitN(override, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return "", 0, fmt.Errorf("'%s' is not subsystem=level", override)
+	}
+	level, err = logging.ParseLevel(parts[1])
+	return parts[0], level, err
+}
+
+//TrackPath returns the path of a track's file, without extension, relative
+// to the download directory
+func (n Naming) TrackPath(data NameData) (string, error) {
+	dir, err := execute("release_dir", n.ReleaseDir, data)
+	if err != nil {
+		return "", err
+	}
+	file, err := execute("track_file", n.TrackFile, data)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, file), nil
+}
+
+func execute(name, text string, data NameData) (string, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+//Errors lists everything wrong with a configuration
+type Errors []string
+
+func (errs Errors) Error() string {
+	return "invalid configuration:\n\t" + strings.Join(errs, "\n\t")
+}
+
+//Validate returns Errors listing every invalid setting, or nil
+func (c *Config) Validate() error {
+	var errs Errors
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Sprintf(format, args...))
+		}
+	}
+	if err := db.Validate(c.Storage.URL); err != nil {
+		errs = append(errs, "storage.url: "+err.Error())
+	}
+	_, _, err := net.SplitHostPort(c.HTTP.Addr)
+	check(err == nil, "http.addr: %v", err)
+	check(
+		c.HTTP.ShutdownTimeout.Duration > 0,
+		"http.shutdown_timeout: must be positive",
+	)
+
+	switch c.Metadata.Provider {
+	case metadata.LastFM:
+		check(
+			c.Metadata.LastFMAPIKey != "",
+			"metadata.lastfm_api_key: required by the lastfm provider",
+		)
+	case metadata.MusicBrainz:
+	default:
+		errs = append(errs, fmt.Sprintf(
+			"metadata.provider: '%s' is neither %s nor %s",
+			c.Metadata.Provider,
+			metadata.LastFM,
+			metadata.MusicBrainz,
+		))
+	}
+	check(
+		c.Metadata.RequestsPerSecond > 0,
+		"metadata.requests_per_second: must be positive",
+	)
+	check(
+		c.Metadata.RefreshInterval.Duration > 0,
+		"metadata.refresh_interval: must be positive",
+	)
+	check(
+		c.Metadata.StaleAfter.Duration >= 0,
+		"metadata.stale_after: must not be negative",
+	)
+
+	_, _, err = net.SplitHostPort(c.Torrent.ListenAddr)
+	check(err == nil, "torrent.listen_addr: %v", err)
+	check(c.Torrent.DownloadDir != "", "torrent.download_dir: required")
+	if info, err := os.Stat(c.Torrent.DownloadDir); err == nil {
+		check(
+			info.IsDir(),
+			"torrent.download_dir: '%s' is not a directory",
+			c.Torrent.DownloadDir,
+		)
+	}
+	check(
+		c.Torrent.DownloadRate >= 0,
+		"torrent.download_rate: must not be negative",
+	)
+	check(
+		c.Torrent.UploadRate >= 0,
+		"torrent.upload_rate: must not be negative",
+	)
+	check(
+		c.Torrent.ConnsPerTorrent >= 0,
+		"torrent.conns_per_torrent: must not be negative",
+	)
+	check(
+		c.Torrent.SeedRatio >= 0,
+		"torrent.seed_ratio: must not be negative",
+	)
+	check(
+		c.Torrent.SeedTime.Duration >= 0,
+		"torrent.seed_time: must not be negative",
+	)
+	check(
+		c.Torrent.QuietDownloadRate >= 0,
+		"torrent.quiet_download_rate: must not be negative",
+	)
+	check(
+		c.Torrent.QuietUploadRate >= 0,
+		"torrent.quiet_upload_rate: must not be negative",
+	)
+	check(
+		c.Torrent.MaxActive >= 0,
+		"torrent.max_active: must not be negative",
+	)
+	check(
+		c.Torrent.MaxQueuedPerUser >= 0,
+		"torrent.max_queued_per_user: must not be negative",
+	)
+	check(
+		c.Torrent.StallTimeout.Duration >= 0,
+		"torrent.stall_timeout: must not be negative",
+	)
+	check(
+		c.Torrent.InfoTimeout.Duration >= 0,
+		"torrent.info_timeout: must not be negative",
+	)
+	check(
+		c.Torrent.MinMatch >= 0 && c.Torrent.MinMatch <= 1,
+		"torrent.min_match: must be between 0 and 1",
+	)
+	for _, ext := range c.Torrent.Extras {
+		check(
+			strings.HasPrefix(ext, ".") && len(ext) > 1,
+			"torrent.extras: '%s' is not an extension like .jpg",
+			ext,
+		)
+	}
+	check(
+		c.Torrent.MaxExtraSize >= 0,
+		"torrent.max_extra_size: must not be negative",
+	)
+	check(
+		c.Torrent.Readahead >= 0,
+		"torrent.readahead: must not be negative",
+	)
+	check(
+		c.Torrent.ReadTimeout.Duration >= 0,
+		"torrent.read_timeout: must not be negative",
+	)
+
+	for _, path := range c.Library.Paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			errs = append(errs, "library.paths: "+err.Error())
+			continue
+		}
+		check(info.IsDir(), "library.paths: '%s' is not a directory", path)
+	}
+
+	path, err := c.Naming.TrackPath(NameData{
+		Artist:      "Artist",
+		Release:     "Release",
+		Year:        2000,
+		Disc:        1,
+		TrackNumber: 1,
+		Track:       "Track",
+	})
+	if err != nil {
+		errs = append(errs, "naming: "+err.Error())
+	} else {
+		check(
+			!filepath.IsAbs(path) &&
+				path != "." &&
+				!strings.HasPrefix(path, ".."),
+			"naming: '%s' is not inside the download directory",
+			path,
+		)
+	}
+
+	check(
+		c.Auth.AdminToken == "" || len(c.Auth.AdminToken) >= 16,
+		"auth.admin_token: must be at least 16 characters long",
+	)
+
+	if _, err := logging.New(ioutil.Discard, c.Log.Format, 0); err != nil {
+		errs = append(errs, "log.format: "+err.Error())
+	}
+	if _, err := logging.ParseLevel(c.Log.Level); err != nil {
+		errs = append(errs, "log.level: "+err.Error())
+	}
+	for _, override := range c.Log.Levels {
+		if _, _, err := parseLevelOverride(override); err != nil {
+			errs = append(errs, "log.levels: "+err.Error())
+		}
+	}
+
+	check(
+		c.Health.Timeout.Duration > 0,
+		"health.timeout: must be positive",
+	)
+	check(
+		c.Health.MetadataInterval.Duration > 0,
+		"health.metadata_interval: must be positive",
+	)
+	check(
+		c.Health.MinFreeSpace >= 0,
+		"health.min_free_space: must not be negative",
+	)
+
+	check(c.Disk.Quota >= 0, "disk.quota: must not be negative")
+	check(c.Disk.MinFree >= 0, "disk.min_free: must not be negative")
+	check(
+		c.Disk.WhenFull == QueueWhenFull || c.Disk.WhenFull == RefuseWhenFull,
+		"disk.when_full: '%s' is neither %s nor %s",
+		c.Disk.WhenFull,
+		QueueWhenFull,
+		RefuseWhenFull,
+	)
+
+	check(c.Covers.Dir != "", "covers.dir: required")
+	if info, err := os.Stat(c.Covers.Dir); err == nil {
+		check(
+			info.IsDir(),
+			"covers.dir: '%s' is not a directory",
+			c.Covers.Dir,
+		)
+	}
+	check(len(c.Covers.Sizes) > 0, "covers.sizes: required")
+	for _, size := range c.Covers.Sizes {
+		check(size > 0, "covers.sizes: %d is not a positive size", size)
+	}
+	check(
+		c.Covers.Quality >= 1 && c.Covers.Quality <= 100,
+		"covers.quality: must be between 1 and 100",
+	)
+	check(c.Covers.MaxSize > 0, "covers.max_size: must be positive")
+	check(
+		c.Covers.FetchTimeout.Duration > 0,
+		"covers.fetch_timeout: must be positive",
+	)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
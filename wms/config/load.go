@@ -0,0 +1,245 @@
+package config
+
+import (
+	"encoding"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+)
+
+//Where the value of a setting comes from
+const (
+	FromDefault = "default"
+	FromFile    = "file"
+	FromEnv     = "env"
+	FromFlag    = "flag"
+)
+
+//FileEnv is the environment variable naming the configuration file when the
+// -config flag isn't given
+const FileEnv = "WMS_CONFIG"
+
+//Load returns the default configuration overridden by the configuration file,
+// then by the environment and then by the flags in args. The flags are
+// registered on fs which is left with the remaining arguments. The returned
+// configuration isn't validated.
+func Load(fs *flag.FlagSet, args []string) (*Config, error) {
+	cfg := Default()
+	file := fs.String(
+		"config",
+		os.Getenv(FileEnv),
+		"YAML or TOML configuration file, defaults to $"+FileEnv,
+	)
+	fields := cfg.fields()
+	flags := make([]*flagValue, len(fields))
+	for i, f := range fields {
+		flags[i] = &flagValue{value: format(f.value)}
+		fs.Var(flags[i], f.key, f.usage+" ($"+f.env+")")
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	cfg.file = *file
+	cfg.sources = make(map[string]string, len(fields))
+	for _, f := range fields {
+		cfg.sources[f.key] = FromDefault
+	}
+	if cfg.file != "" {
+		defaults := make([]string, len(fields))
+		for i, f := range fields {
+			defaults[i] = format(f.value)
+		}
+		if err := cfg.loadFile(cfg.file); err != nil {
+			return nil, err
+		}
+		for i, f := range fields {
+			if format(f.value) != defaults[i] {
+				cfg.sources[f.key] = FromFile
+			}
+		}
+	}
+	for _, f := range fields {
+		value, ok := os.LookupEnv(f.env)
+		if !ok {
+			continue
+		}
+		if err := set(f.value, value); err != nil {
+			return nil, fmt.Errorf("$%s: %v", f.env, err)
+		}
+		cfg.sources[f.key] = FromEnv
+	}
+	for i, f := range fields {
+		if !flags[i].set {
+			continue
+		}
+		if err := set(f.value, flags[i].value); err != nil {
+			return nil, fmt.Errorf("-%s: %v", f.key, err)
+		}
+		cfg.sources[f.key] = FromFlag
+	}
+	return cfg, nil
+}
+
+func (c *Config) loadFile(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalStrict(data, c)
+	case ".toml":
+		var md toml.MetaData
+		md, err = toml.Decode(string(data), c)
+		if undecoded := md.Undecoded(); err == nil && len(undecoded) > 0 {
+			err = fmt.Errorf("unknown settings %v", undecoded)
+		}
+	default:
+		err = errors.New("unsupported format, use .yaml, .yml or .toml")
+	}
+	if err != nil {
+		return fmt.Errorf("configuration file '%s': %v", path, err)
+	}
+	return nil
+}
+
+//field is a single setting of a Config
+type field struct {
+	key, env, usage string
+	secret          bool
+	value           reflect.Value
+}
+
+//fields returns the settings of c in declaration order, their values can be
+// set
+func (c *Config) fields() []field {
+	var (
+		fields   []field
+		sections = reflect.ValueOf(c).Elem()
+	)
+	for i := 0; i < sections.NumField(); i++ {
+		section := sections.Type().Field(i).Tag.Get("yaml")
+		if section == "" {
+			continue
+		}
+		settings := sections.Field(i)
+		for j := 0; j < settings.NumField(); j++ {
+			var (
+				tag = settings.Type().Field(j).Tag
+				key = section + "." + tag.Get("yaml")
+				env = tag.Get("env")
+			)
+			if env == "" {
+				env = "WMS_" + strings.ToUpper(
+					strings.Replace(key, ".", "_", 1),
+				)
+			}
+			fields = append(fields, field{
+				key:    key,
+				env:    env,
+				usage:  tag.Get("usage"),
+				secret: tag.Get("secret") == "true",
+				value:  settings.Field(j),
+			})
+		}
+	}
+	return fields
+}
+
+//set parses s into v, lists are comma separated
+func set(v reflect.Value, s string) error {
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(s))
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		var list []string
+		for _, e := range strings.Split(s, ",") {
+			if e = strings.TrimSpace(e); e != "" {
+				list = append(list, e)
+			}
+		}
+		if v.Type().Elem().Kind() != reflect.Int {
+			v.Set(reflect.ValueOf(list))
+			break
+		}
+		ints := make([]int, len(list))
+		for i, e := range list {
+			n, err := strconv.Atoi(e)
+			if err != nil {
+				return err
+			}
+			ints[i] = n
+		}
+		v.Set(reflect.ValueOf(ints))
+	default:
+		return fmt.Errorf("unsupported setting type %v", v.Type())
+	}
+	return nil
+}
+
+//format returns v as set would parse it
+func format(v reflect.Value) string {
+	if s, ok := v.Interface().(fmt.Stringer); ok {
+		return s.String()
+	}
+	if list, ok := v.Interface().([]string); ok {
+		return strings.Join(list, ",")
+	}
+	if ints, ok := v.Interface().([]int); ok {
+		list := make([]string, len(ints))
+		for i, n := range ints {
+			list[i] = strconv.Itoa(n)
+		}
+		return strings.Join(list, ",")
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+//flagValue records a flag's value so it can be applied after the file and
+// the environment
+type flagValue struct {
+	value string
+	set   bool
+}
+
+func (f *flagValue) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.value
+}
+
+func (f *flagValue) Set(value string) error {
+	f.value, f.set = value, true
+	return nil
+}
@@ -0,0 +1,29 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+//Print writes every setting of c with its value and where it comes from,
+// secrets are masked unless showSecrets is true
+func (c *Config) Print(w io.Writer, showSecrets bool) error {
+	if c.file != "" {
+		fmt.Fprintf(w, "Configuration file: %s\n\n", c.file)
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SETTING\tVALUE\tSOURCE\tENV")
+	for _, f := range c.fields() {
+		value := format(f.value)
+		if f.secret && value != "" && !showSecrets {
+			value = "********"
+		}
+		source := c.sources[f.key]
+		if source == "" {
+			source = FromDefault
+		}
+		fmt.Fprintf(tw, "%s\t%q\t%s\t%s\n", f.key, value, source, f.env)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,363 @@
+//Package cover stores the cover art of releases and the images of artists,
+// identified by the SHA-1 of their data, and the resized variants of them
+// that are served
+package cover
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	// Formats decoded besides JPEG, WebP's decoder is registered by the
+	// package that encodes it
+	_ "image/gif"
+	_ "image/png"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/chai2010/webp"
+	"golang.org/x/image/draw"
+)
+
+var (
+	//ErrNotFound is returned when no image is stored with a hash
+	ErrNotFound = errors.New("image not found")
+	//ErrNotImage is returned when data isn't an image in a known format
+	ErrNotImage = errors.New("not a supported image")
+	//ErrTooLarge is returned when an image is larger than the limit
+	ErrTooLarge = errors.New("image is too large")
+)
+
+//Formats of the variants
+const (
+	JPEG = "jpeg"
+	WebP = "webp"
+)
+
+var contentTypes = map[string]string{
+	JPEG: "image/jpeg",
+	WebP: "image/webp",
+}
+
+//retryAfter is how long a URL that couldn't be fetched isn't tried again
+const retryAfter = time.Hour
+
+//Config of a Store
+type Config struct {
+	//Dir the images and their variants are stored in
+	Dir string
+	//Sizes in pixels of the largest side of the variants, requested sizes
+	// are rounded up to one of them
+	Sizes []int
+	//Quality of the variants, from 1 to 100
+	Quality int
+	//MaxSize in bytes of the images stored, 0 is unlimited
+	MaxSize int64
+	//HTTPClient fetches the remote images, defaults to http.DefaultClient
+	HTTPClient *http.Client
+}
+
+//Store of images on disk, it is safe for concurrent use
+type Store struct {
+	cfg Config
+
+	//mu guards the fields below
+	mu sync.Mutex
+	//making are closed once the variants being made, by path, are
+	making map[string]chan struct{}
+	//failed are the errors of the URLs that couldn't be fetched recently
+	failed map[string]failure
+}
+
+type failure struct {
+	err error
+	at  time.Time
+}
+
+//Variant of an image, resized and encoded in a format
+type Variant struct {
+	//Path of the variant's file
+	Path        string
+	ContentType string
+	//ETag identifies the variant's content, quoted
+	ETag string
+}
+
+//NewStore creates a store of images in cfg.Dir, creating it if needed
+func NewStore(cfg Config) (*Store, error) {
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+	cfg.Sizes = append([]int(nil), cfg.Sizes...)
+	sort.Ints(cfg.Sizes)
+	for _, dir := range []string{"images", "variants"} {
+		if err := os.MkdirAll(filepath.Join(cfg.Dir, dir), 0755); err != nil {
+			return nil, err
+		}
+	}
+	return &Store{
+		cfg:    cfg,
+		making: make(map[string]chan struct{}),
+		failed: make(map[string]failure),
+	}, nil
+}
+
+//Put stores data, if it is an image, and returns its hash
+func (s *Store) Put(data []byte) (string, error) {
+	if s.cfg.MaxSize > 0 && int64(len(data)) > s.cfg.MaxSize {
+		return "", ErrTooLarge
+	}
+	if _, _, err := image.DecodeConfig(bytes.NewReader(data)); err != nil {
+		return "", ErrNotImage
+	}
+	sum := sha1.Sum(data)
+	hash := hex.EncodeToString(sum[:])
+	if s.Has(hash) {
+		return hash, nil
+	}
+	return hash, writeFile(s.image(hash), func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+//Fetch downloads the image at url and stores it, a URL that couldn't be
+// fetched isn't tried again for an hour and its error is returned instead
+func (s *Store) Fetch(ctx context.Context, url string) (string, error) {
+	s.mu.Lock()
+	failed, found := s.failed[url]
+	s.mu.Unlock()
+	if found && time.Since(failed.at) < retryAfter {
+		return "", failed.err
+	}
+	hash, err := s.fetch(ctx, url)
+	if err != nil && ctx.Err() == nil {
+		s.mu.Lock()
+		s.failed[url] = failure{err, time.Now()}
+		s.mu.Unlock()
+	}
+	return hash, err
+}
+
+func (s *Store) fetch(ctx context.Context, url string) (string, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := s.cfg.HTTPClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("fetching %s: %s", url, resp.Status)
+	}
+	body := io.Reader(resp.Body)
+	if s.cfg.MaxSize > 0 {
+		// One more byte than allowed for Put to refuse it
+		body = io.LimitReader(body, s.cfg.MaxSize+1)
+	}
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return "", err
+	}
+	return s.Put(data)
+}
+
+//Has returns true if an image is stored with the given hash
+func (s *Store) Has(hash string) bool {
+	if !validHash(hash) {
+		return false
+	}
+	_, err := os.Stat(s.image(hash))
+	return err == nil
+}
+
+//Variant returns the variant of the image with the given hash in format,
+// its largest side no larger than size rounded up to one of the sizes, or
+// than the image's if size is 0. Images are never enlarged. Variants are
+// made once and kept on disk.
+func (s *Store) Variant(
+	hash string,
+	size int,
+	format string,
+) (*Variant, error) {
+	if !s.Has(hash) {
+		return nil, ErrNotFound
+	}
+	return s.variant(hash, size, format, func() (image.Image, error) {
+		f, err := os.Open(s.image(hash))
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		img, _, err := image.Decode(f)
+		return img, err
+	})
+}
+
+//Placeholder returns the variant of a placeholder image for name, like
+// Variant does
+func (s *Store) Placeholder(
+	name string,
+	size int,
+	format string,
+) (*Variant, error) {
+	initial, background := placeholderStyle(name)
+	key := fmt.Sprintf("placeholder-%x-%d", initial, background)
+	return s.variant(key, size, format, func() (image.Image, error) {
+		return placeholder(initial, palette[background]), nil
+	})
+}
+
+//variant returns the variant of the image loaded by load identified by key,
+// see Variant, load is only called if the variant isn't on disk yet
+func (s *Store) variant(
+	key string,
+	size int,
+	format string,
+	load func() (image.Image, error),
+) (*Variant, error) {
+	contentType, ok := contentTypes[format]
+	if !ok {
+		return nil, fmt.Errorf("unknown image format '%s'", format)
+	}
+	size = s.roundSize(size)
+	name := fmt.Sprintf("%s-%d.%s", key, size, format)
+	variant := &Variant{
+		Path:        filepath.Join(s.cfg.Dir, "variants", name),
+		ContentType: contentType,
+		ETag:        `"` + name + `"`,
+	}
+	for {
+		if _, err := os.Stat(variant.Path); err == nil {
+			return variant, nil
+		}
+		s.mu.Lock()
+		making, found := s.making[variant.Path]
+		if !found {
+			making = make(chan struct{})
+			s.making[variant.Path] = making
+		}
+		s.mu.Unlock()
+		if found {
+			// Made by another request, or not if it failed and this one
+			// tries again
+			<-making
+			continue
+		}
+		err := s.make(variant.Path, size, format, load)
+		s.mu.Lock()
+		delete(s.making, variant.Path)
+		close(making)
+		s.mu.Unlock()
+		if err != nil {
+			return nil, err
+		}
+		return variant, nil
+	}
+}
+
+//make the variant at path of the image load returns
+func (s *Store) make(
+	path string,
+	size int,
+	format string,
+	load func() (image.Image, error),
+) error {
+	img, err := load()
+	if err != nil {
+		return err
+	}
+	img = resize(img, size)
+	return writeFile(path, func(w io.Writer) error {
+		if format == WebP {
+			return webp.Encode(w, img, &webp.Options{
+				Quality: float32(s.cfg.Quality),
+			})
+		}
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: s.cfg.Quality})
+	})
+}
+
+//roundSize rounds size up to the next of the sizes, or to the largest, 0
+// stays 0
+func (s *Store) roundSize(size int) int {
+	if size <= 0 || len(s.cfg.Sizes) == 0 {
+		return 0
+	}
+	for _, allowed := range s.cfg.Sizes {
+		if allowed >= size {
+			return allowed
+		}
+	}
+	return s.cfg.Sizes[len(s.cfg.Sizes)-1]
+}
+
+//image returns the path of the image with the given hash
+func (s *Store) image(hash string) string {
+	return filepath.Join(s.cfg.Dir, "images", hash[:2], hash)
+}
+
+//resize img so its largest side is size, if it is larger and size isn't 0
+func resize(img image.Image, size int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if size <= 0 || (width <= size && height <= size) {
+		return img
+	}
+	if width >= height {
+		width, height = size, max(1, height*size/width)
+	} else {
+		width, height = max(1, width*size/height), size
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Src, nil)
+	return dst
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+//writeFile writes the file at path with write, through a temporary file so
+// the file is either complete or missing
+func writeFile(path string, write func(io.Writer) error) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func validHash(hash string) bool {
+	if len(hash) != 2*sha1.Size {
+		return false
+	}
+	_, err := hex.DecodeString(hash)
+	return err == nil
+}
@@ -0,0 +1,88 @@
+package cover
+
+import (
+	"hash/fnv"
+	"image"
+	"image/color"
+	"unicode"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+//placeholderSize is the size in pixels of the placeholders before they are
+// resized
+const placeholderSize = 512
+
+//palette the backgrounds of placeholders are picked from
+var palette = []color.RGBA{
+	{0x5c, 0x6b, 0x73, 0xff},
+	{0x8e, 0x44, 0x3d, 0xff},
+	{0x2e, 0x6f, 0x5e, 0xff},
+	{0x3b, 0x5b, 0x92, 0xff},
+	{0x7d, 0x5a, 0x8c, 0xff},
+	{0xa0, 0x7a, 0x2d, 0xff},
+	{0x4a, 0x4e, 0x69, 0xff},
+	{0x6b, 0x8e, 0x23, 0xff},
+}
+
+//placeholderStyle returns the initial of name drawn on its placeholder, 0 if
+// the font has no glyph for it, and the index of its background in palette
+func placeholderStyle(name string) (rune, int) {
+	var initial rune
+	for _, r := range name {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if r = unicode.ToUpper(r); r < unicode.MaxASCII {
+				initial = r
+			}
+			break
+		}
+	}
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	return initial, int(h.Sum32() % uint32(len(palette)))
+}
+
+//placeholder draws initial, if it isn't 0, in white on a square of
+// background
+func placeholder(initial rune, background color.RGBA) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, placeholderSize, placeholderSize))
+	draw.Draw(
+		img,
+		img.Bounds(),
+		image.NewUniform(background),
+		image.ZP,
+		draw.Src,
+	)
+	if initial == 0 {
+		return img
+	}
+	// The font is a small bitmap one, the glyph is drawn at its size then
+	// scaled up
+	face := basicfont.Face7x13
+	glyph := image.NewRGBA(image.Rect(0, 0, face.Advance, face.Height))
+	drawer := font.Drawer{
+		Dst:  glyph,
+		Src:  image.White,
+		Face: face,
+		Dot:  fixed.P(0, face.Ascent),
+	}
+	drawer.DrawString(string(initial))
+	scale := placeholderSize / 2 / face.Height
+	width, height := face.Advance*scale, face.Height*scale
+	offset := image.Pt(
+		(placeholderSize-width)/2,
+		(placeholderSize-height)/2,
+	)
+	draw.NearestNeighbor.Scale(
+		img,
+		image.Rectangle{offset, offset.Add(image.Pt(width, height))},
+		glyph,
+		glyph.Bounds(),
+		draw.Over,
+		nil,
+	)
+	return img
+}
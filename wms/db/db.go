@@ -1,12 +1,89 @@
 package db
 
-import mgo "gopkg.in/mgo.v2"
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
 
-//OpenDB opens database on host, returns error if fails
-func OpenDB(host, database string) (*mgo.Database, error) {
-	session, err := mgo.Dial(host)
+	"github.com/waelbendhia/music-streaming/wms/store"
+	"github.com/waelbendhia/music-streaming/wms/store/memory"
+	"github.com/waelbendhia/music-streaming/wms/store/mongo"
+	"github.com/waelbendhia/music-streaming/wms/store/sqlite"
+)
+
+//DefaultURL is the storage used when none is configured
+const DefaultURL = "mongodb://localhost/wmsDB"
+
+//Open the storage backend described by storageURL, returns error if fails,
+// supported URLs are:
+//
+//	mongodb://host[:port]/database
+//	sqlite:///absolute/path.db, sqlite:relative/path.db or sqlite::memory:
+//	memory:
+func Open(storageURL string, logger *log.Logger) (store.Store, error) {
+	scheme, target, err := parse(storageURL)
 	if err != nil {
 		return nil, err
 	}
-	return session.DB(database), nil
+	switch scheme {
+	case "mongodb":
+		return mongo.Open(storageURL, target, logger)
+	case "sqlite":
+		return sqlite.Open(target, logger)
+	}
+	return memory.New(), nil
+}
+
+//Validate returns an error if storageURL isn't one Open supports, without
+// opening it
+func Validate(storageURL string) error {
+	_, _, err := parse(storageURL)
+	return err
+}
+
+//Backend returns the name of the backend storageURL selects: mongodb,
+// sqlite or memory
+func Backend(storageURL string) (string, error) {
+	scheme, _, err := parse(storageURL)
+	return scheme, err
+}
+
+//parse returns the backend storageURL selects and the database or file it
+// names
+func parse(storageURL string) (scheme, target string, err error) {
+	u, err := url.Parse(storageURL)
+	if err != nil {
+		return "", "", fmt.Errorf(
+			"invalid storage URL '%s': %v",
+			storageURL,
+			err,
+		)
+	}
+	switch u.Scheme {
+	case "mongodb":
+		target = strings.TrimPrefix(u.Path, "/")
+		if target == "" {
+			return "", "", fmt.Errorf(
+				"storage URL '%s' does not name a database",
+				storageURL,
+			)
+		}
+		return u.Scheme, target, nil
+	case "sqlite", "sqlite3":
+		target = u.Opaque
+		if target == "" {
+			target = u.Path
+		}
+		if target == "" {
+			return "", "", fmt.Errorf(
+				"storage URL '%s' does not name a file",
+				storageURL,
+			)
+		}
+		return "sqlite", target, nil
+	case "memory":
+		return u.Scheme, "", nil
+	}
+	return "", "", fmt.Errorf("unsupported storage URL scheme '%s'", u.Scheme)
 }
@@ -0,0 +1,175 @@
+//Package dedupe finds artists and releases that are likely the same and
+// merges them
+package dedupe
+
+import (
+	"sort"
+
+	"github.com/texttheater/golang-levenshtein/levenshtein"
+	"github.com/waelbendhia/music-streaming/wms/models"
+	"github.com/waelbendhia/music-streaming/wms/store"
+)
+
+//DefaultThreshold is the similarity above which two keys are considered the
+// same, 1 only groups records with identical keys
+const DefaultThreshold = 0.9
+
+//Candidate is a record that may be a duplicate
+type Candidate struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	MBID string `json:"mbid,omitempty"`
+	//Artist is the name of a release's album artist
+	Artist string `json:"artist,omitempty"`
+}
+
+//Group of records of the same kind that are likely duplicates of each other
+type Group struct {
+	Kind string `json:"kind"`
+	//Score is the lowest similarity that joined two members of the group
+	Score      float64     `json:"score"`
+	Candidates []Candidate `json:"candidates"`
+}
+
+//item is a record being compared, only items in the same block are compared
+type item struct {
+	block, key string
+	candidate  Candidate
+}
+
+//Find groups the artists and the releases of s that are likely duplicates,
+// releases are only compared with releases of the same album artist
+func Find(s store.Store, threshold float64) ([]Group, error) {
+	artists, err := s.Artists()
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]string, len(artists))
+	items := make([]item, len(artists))
+	for i, artist := range artists {
+		names[artist.ID.Hex()] = artist.Name
+		key := ArtistKey(artist.Name)
+		items[i] = item{
+			// Comparing every pair of artists is too slow for big libraries,
+			// typos in the first letter are missed
+			block:     firstLetter(key),
+			key:       key,
+			candidate: artistCandidate(artist),
+		}
+	}
+	groups := group(store.ArtistAlias, items, threshold)
+	rels, err := s.Releases()
+	if err != nil {
+		return nil, err
+	}
+	items = make([]item, len(rels))
+	for i, rel := range rels {
+		items[i] = item{
+			block: rel.AlbumArtistID,
+			key:   ReleaseKey(rel.Name),
+			candidate: Candidate{
+				ID:     rel.ID.Hex(),
+				Name:   rel.Name,
+				MBID:   rel.MBID,
+				Artist: names[rel.AlbumArtistID],
+			},
+		}
+	}
+	return append(groups, group(store.ReleaseAlias, items, threshold)...), nil
+}
+
+func artistCandidate(artist models.Artist) Candidate {
+	return Candidate{ID: artist.ID.Hex(), Name: artist.Name, MBID: artist.MBID}
+}
+
+func firstLetter(key string) string {
+	for _, r := range key {
+		return string(r)
+	}
+	return ""
+}
+
+//group clusters the items whose keys are similar enough, two items with
+// different MBIDs are never grouped
+func group(kind string, items []item, threshold float64) []Group {
+	var (
+		parent = make([]int, len(items))
+		score  = make([]float64, len(items))
+		find   func(int) int
+	)
+	find = func(i int) int {
+		for parent[i] != i {
+			parent[i] = parent[parent[i]]
+			i = parent[i]
+		}
+		return i
+	}
+	blocks := make(map[string][]int)
+	for i, it := range items {
+		parent[i], score[i] = i, 1
+		blocks[it.block] = append(blocks[it.block], i)
+	}
+	for _, members := range blocks {
+		for x, i := range members {
+			for _, j := range members[x+1:] {
+				a, b := items[i], items[j]
+				if a.key == "" || b.key == "" ||
+					a.candidate.MBID != "" && b.candidate.MBID != "" &&
+						a.candidate.MBID != b.candidate.MBID {
+					continue
+				}
+				sim := similarity(a.key, b.key)
+				if sim < threshold {
+					continue
+				}
+				ri, rj := find(i), find(j)
+				if ri == rj {
+					continue
+				}
+				parent[rj] = ri
+				score[ri] = min(sim, min(score[ri], score[rj]))
+			}
+		}
+	}
+	byRoot := make(map[int]*Group)
+	var roots []int
+	for i, it := range items {
+		root := find(i)
+		g, ok := byRoot[root]
+		if !ok {
+			g = &Group{Kind: kind}
+			byRoot[root] = g
+			roots = append(roots, root)
+		}
+		g.Candidates = append(g.Candidates, it.candidate)
+	}
+	var groups []Group
+	for _, root := range roots {
+		if g := byRoot[root]; len(g.Candidates) > 1 {
+			g.Score = score[root]
+			groups = append(groups, *g)
+		}
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		return groups[i].Score > groups[j].Score
+	})
+	return groups
+}
+
+func similarity(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+	return levenshtein.RatioForStrings(
+		[]rune(a),
+		[]rune(b),
+		levenshtein.DefaultOptions,
+	)
+}
+
+func min(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,48 @@
+package dedupe
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/waelbendhia/music-streaming/wms/store"
+)
+
+var (
+	leadingThe  = regexp.MustCompile(`(?i)^the\s+`)
+	trailingThe = regexp.MustCompile(`(?i),\s*the$`)
+	// "Album (2011 Remastered)", "Album [Deluxe Edition]"
+	editionSuffix = regexp.MustCompile(
+		`(?i)\s*[(\[][^)\]]*\b(remaster(ed)?|deluxe|edition|expanded|` +
+			`anniversary|bonus|version|mono|stereo|reissue)\b[^)\]]*[)\]]\s*$`,
+	)
+	// "Album - 2011 Remaster"
+	editionDash = regexp.MustCompile(
+		`(?i)\s+-\s+[^-]*\b(remaster(ed)?|deluxe|edition|expanded|` +
+			`anniversary|version|reissue)\b[^-]*$`,
+	)
+)
+
+//ArtistKey returns the key artists that are likely the same have in common,
+// it ignores a leading or trailing "The" on top of what NormalizeName
+// ignores so "The Beatles" and "Beatles, The" have the same key
+func ArtistKey(name string) string {
+	name = strings.TrimSpace(name)
+	name = trailingThe.ReplaceAllString(name, "")
+	name = leadingThe.ReplaceAllString(name, "")
+	return store.NormalizeName(name)
+}
+
+//ReleaseKey returns the key releases that are likely the same have in
+// common, it ignores edition suffixes such as "(Remastered)" on top of what
+// NormalizeName ignores
+func ReleaseKey(name string) string {
+	for {
+		stripped := editionSuffix.ReplaceAllString(name, "")
+		stripped = editionDash.ReplaceAllString(stripped, "")
+		if stripped == name || stripped == "" {
+			break
+		}
+		name = stripped
+	}
+	return store.NormalizeName(name)
+}
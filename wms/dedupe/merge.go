@@ -0,0 +1,301 @@
+package dedupe
+
+import (
+	"errors"
+
+	"github.com/waelbendhia/music-streaming/wms/models"
+	"github.com/waelbendhia/music-streaming/wms/store"
+	"gopkg.in/mgo.v2/bson"
+)
+
+//ErrMergeIntoSelf is returned when the survivor is among the duplicates
+var ErrMergeIntoSelf = errors.New("can't merge a record into itself")
+
+//MergeArtists merges the duplicate artists into the survivor: their
+// releases and tracks are moved to it, releases it already has are merged,
+// artists related to them become related to it and their names and MBIDs
+// become aliases of it. The duplicates are then removed.
+func MergeArtists(
+	s store.Store,
+	survivorID bson.ObjectId,
+	duplicateIDs []bson.ObjectId,
+) error {
+	return s.Transaction(func(tx store.Store) error {
+		survivor, err := tx.Artist(survivorID)
+		if err != nil {
+			return err
+		}
+		for _, id := range duplicateIDs {
+			if id == survivorID {
+				return ErrMergeIntoSelf
+			}
+			if err := mergeArtist(tx, survivor, id); err != nil {
+				return err
+			}
+		}
+		return tx.UpdateArtist(survivor)
+	})
+}
+
+func mergeArtist(
+	tx store.Store,
+	survivor *models.Artist,
+	id bson.ObjectId,
+) error {
+	dup, err := tx.Artist(id)
+	if err != nil {
+		return err
+	}
+	var (
+		survivorID = survivor.ID.Hex()
+		dupID      = dup.ID.Hex()
+	)
+	rels, err := tx.ReleasesByArtist(dupID)
+	if err != nil {
+		return err
+	}
+	for i := range rels {
+		rel := &rels[i]
+		existing, err := tx.ReleaseByName(survivorID, rel.Name)
+		if err == store.ErrNotFound {
+			rel.AlbumArtistID = survivorID
+			err = tx.UpdateRelease(rel)
+		} else if err == nil {
+			err = mergeRelease(tx, existing, rel)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	tracks, err := tx.TracksByArtist(dupID)
+	if err != nil {
+		return err
+	}
+	for i := range tracks {
+		tracks[i].ArtistID = survivorID
+		if err := tx.UpdateTrack(&tracks[i]); err != nil {
+			return err
+		}
+	}
+	if err := repointRelatedArtists(tx, dupID, survivorID); err != nil {
+		return err
+	}
+	if err := tx.DeleteArtist(dup.ID); err != nil {
+		return err
+	}
+	store.MergeArtist(survivor, dup)
+	survivor.RelatedArtistIDs = remove(
+		union(survivor.RelatedArtistIDs, dup.RelatedArtistIDs),
+		survivorID,
+		dupID,
+	)
+	return setAliases(
+		tx,
+		store.ArtistAlias,
+		store.ArtistAliasKeys(dup),
+		dupID,
+		survivorID,
+	)
+}
+
+func repointRelatedArtists(tx store.Store, fromID, toID string) error {
+	artists, err := tx.Artists()
+	if err != nil {
+		return err
+	}
+	for i := range artists {
+		artist := &artists[i]
+		if artist.ID.Hex() == fromID ||
+			!contains(artist.RelatedArtistIDs, fromID) {
+			continue
+		}
+		related := remove(artist.RelatedArtistIDs, fromID)
+		if artist.ID.Hex() != toID {
+			related = union(related, []string{toID})
+		}
+		artist.RelatedArtistIDs = related
+		if err := tx.UpdateArtist(artist); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//MergeReleases merges the duplicate releases into the survivor: their tracks
+// are merged with its tracks or added to it, listens and download jobs of
+// merged tracks and releases move to it and their names and MBIDs become
+// aliases of it. The duplicates and their merged tracks are then removed.
+func MergeReleases(
+	s store.Store,
+	survivorID bson.ObjectId,
+	duplicateIDs []bson.ObjectId,
+) error {
+	return s.Transaction(func(tx store.Store) error {
+		survivor, err := tx.Release(survivorID)
+		if err != nil {
+			return err
+		}
+		for _, id := range duplicateIDs {
+			if id == survivorID {
+				return ErrMergeIntoSelf
+			}
+			dup, err := tx.Release(id)
+			if err != nil {
+				return err
+			}
+			if err := mergeRelease(tx, survivor, dup); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+//mergeRelease merges dup into survivor and saves survivor
+func mergeRelease(tx store.Store, survivor, dup *models.Release) error {
+	var (
+		survivorID = survivor.ID.Hex()
+		dupID      = dup.ID.Hex()
+	)
+	tracks, err := releaseTracks(tx, survivor)
+	if err != nil {
+		return err
+	}
+	dupTracks, err := releaseTracks(tx, dup)
+	if err != nil {
+		return err
+	}
+	var (
+		matched = make([]bool, len(tracks))
+		// merged maps the IDs of deleted tracks to those they merged into
+		merged = make(map[string]string)
+	)
+	for _, track := range dupTracks {
+		i := store.MatchTrack(tracks, matched, track)
+		if i < 0 {
+			survivor.TrackIDs = append(survivor.TrackIDs, track.ID.Hex())
+			continue
+		}
+		matched[i] = true
+		if store.MergeTrack(&tracks[i], &track) {
+			if err := tx.UpdateTrack(&tracks[i]); err != nil {
+				return err
+			}
+		}
+		err := tx.ReassignStatistics(track.ID.Hex(), tracks[i].ID.Hex())
+		if err != nil {
+			return err
+		}
+		if err := tx.DeleteTrack(track.ID); err != nil {
+			return err
+		}
+		merged[track.ID.Hex()] = tracks[i].ID.Hex()
+	}
+	jobs, err := tx.Jobs()
+	if err != nil {
+		return err
+	}
+	for i := range jobs {
+		if jobs[i].ReleaseID != dupID {
+			continue
+		}
+		jobs[i].ReleaseID = survivorID
+		for j, file := range jobs[i].Files {
+			if id, ok := merged[file.TrackID]; ok {
+				jobs[i].Files[j].TrackID = id
+			}
+		}
+		if err := tx.UpdateJob(&jobs[i]); err != nil {
+			return err
+		}
+	}
+	if err := tx.DeleteRelease(dup.ID); err != nil {
+		return err
+	}
+	store.MergeRelease(survivor, dup)
+	if err := tx.UpdateRelease(survivor); err != nil {
+		return err
+	}
+	// The duplicate's name must also resolve under the survivor's artist, as
+	// it's where its own artist's name resolves to once artists are merged
+	return setAliases(
+		tx,
+		store.ReleaseAlias,
+		append(
+			store.ReleaseAliasKeys(dup, dup.AlbumArtistID),
+			store.ReleaseAliasKeys(dup, survivor.AlbumArtistID)...,
+		),
+		dupID,
+		survivorID,
+	)
+}
+
+func releaseTracks(
+	tx store.Store,
+	rel *models.Release,
+) ([]models.Track, error) {
+	var tracks []models.Track
+	for _, id := range rel.TrackIDs {
+		if !bson.IsObjectIdHex(id) {
+			continue
+		}
+		track, err := tx.Track(bson.ObjectIdHex(id))
+		if err == store.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		tracks = append(tracks, *track)
+	}
+	return tracks, nil
+}
+
+//setAliases makes keys and the aliases of the record with ID fromID aliases
+// of the record with ID toID
+func setAliases(
+	tx store.Store,
+	kind string,
+	keys []string,
+	fromID, toID string,
+) error {
+	if err := tx.RetargetAliases(kind, fromID, toID); err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := tx.SetAlias(kind, key, toID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+//union returns a followed by the elements of b it doesn't contain
+func union(a, b []string) []string {
+	for _, s := range b {
+		if !contains(a, s) {
+			a = append(a, s)
+		}
+	}
+	return a
+}
+
+//remove returns list without the given elements
+func remove(list []string, elems ...string) []string {
+	var res []string
+	for _, s := range list {
+		if !contains(elems, s) {
+			res = append(res, s)
+		}
+	}
+	return res
+}
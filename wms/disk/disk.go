@@ -0,0 +1,421 @@
+//Package disk keeps the download directory within its quota and the free
+// space of its file system: it admits downloads that fit, reports what the
+// releases use and evicts the least played ones
+package disk
+
+import (
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/waelbendhia/music-streaming/wms/models"
+	"github.com/waelbendhia/music-streaming/wms/store"
+	"gopkg.in/mgo.v2/bson"
+)
+
+var (
+	//ErrQuotaExceeded is returned when a download would take the download
+	// directory over its quota
+	ErrQuotaExceeded = errors.New("download quota exceeded")
+	//ErrNoSpace is returned when a download would leave less than the
+	// minimum free space on the file system
+	ErrNoSpace = errors.New("not enough free disk space")
+)
+
+//Config of a manager
+type Config struct {
+	//Dir is the download directory
+	Dir string
+	//Quota is the maximum size of the download directory in bytes, 0 is
+	// unlimited
+	Quota int64
+	//MinFree is the space in bytes downloads must leave free on the file
+	// system
+	MinFree int64
+}
+
+//Manager of the download directory's space
+type Manager struct {
+	cfg Config
+	db  store.Store
+	//mu serializes admissions, so two downloads can't both take the last
+	// of the space
+	mu sync.Mutex
+}
+
+//NewManager creates a manager of the download directory in cfg, the jobs
+// and statistics are read from db
+func NewManager(cfg Config, db store.Store) *Manager {
+	return &Manager{cfg: cfg, db: db}
+}
+
+//Usage of the download directory
+type Usage struct {
+	//Used is the size of the files in the download directory
+	Used int64 `json:"usedBytes"`
+	//Pending is what the downloads in progress still have to write
+	Pending  int64          `json:"pendingBytes"`
+	Free     int64          `json:"freeBytes"`
+	Quota    int64          `json:"quotaBytes"`
+	MinFree  int64          `json:"minFreeBytes"`
+	Artists  []ArtistUsage  `json:"artists,omitempty"`
+	Releases []ReleaseUsage `json:"releases,omitempty"`
+}
+
+//ArtistUsage is the space used by an artist's releases
+type ArtistUsage struct {
+	ArtistID string `json:"artistID,omitempty"`
+	Artist   string `json:"artist"`
+	Bytes    int64  `json:"bytes"`
+}
+
+//ReleaseUsage is the space used by the downloads of a release
+type ReleaseUsage struct {
+	ReleaseID string `json:"releaseID"`
+	Release   string `json:"release"`
+	ArtistID  string `json:"artistID,omitempty"`
+	Artist    string `json:"artist,omitempty"`
+	Bytes     int64  `json:"bytes"`
+	//Plays of the release's tracks
+	Plays int `json:"plays"`
+	//Active is true while the release is being downloaded, active releases
+	// are never evicted
+	Active bool `json:"active"`
+	//Jobs that downloaded the release
+	Jobs []models.Job `json:"-"`
+}
+
+//Usage returns the space used by the download directory and by each
+// release, largest first
+func (m *Manager) Usage() (*Usage, error) {
+	usage, err := m.usage("")
+	if err != nil {
+		return nil, err
+	}
+	releases, err := m.releases()
+	if err != nil {
+		return nil, err
+	}
+	artists := make(map[string]*ArtistUsage)
+	for _, rel := range releases {
+		a, found := artists[rel.ArtistID]
+		if !found {
+			a = &ArtistUsage{ArtistID: rel.ArtistID, Artist: rel.Artist}
+			artists[rel.ArtistID] = a
+		}
+		a.Bytes += rel.Bytes
+	}
+	for _, a := range artists {
+		usage.Artists = append(usage.Artists, *a)
+	}
+	sort.Slice(usage.Artists, func(i, j int) bool {
+		return usage.Artists[i].Bytes > usage.Artists[j].Bytes
+	})
+	sort.Slice(releases, func(i, j int) bool {
+		return releases[i].Bytes > releases[j].Bytes
+	})
+	usage.Releases = releases
+	return usage, nil
+}
+
+//usage returns the totals of the download directory, the job with ID
+// except isn't counted as pending
+func (m *Manager) usage(except bson.ObjectId) (*Usage, error) {
+	used, err := dirSize(m.cfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+	free, err := FreeSpace(m.cfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+	jobs, err := m.db.Jobs(models.JobDownloading)
+	if err != nil {
+		return nil, err
+	}
+	usage := &Usage{
+		Used:    used,
+		Free:    int64(free),
+		Quota:   m.cfg.Quota,
+		MinFree: m.cfg.MinFree,
+	}
+	for _, job := range jobs {
+		if job.ID == except {
+			continue
+		}
+		written, err := m.jobSize(job)
+		if err != nil {
+			return nil, err
+		}
+		if job.Size > written {
+			usage.Pending += job.Size - written
+		}
+	}
+	return usage, nil
+}
+
+//Admit calls commit, which should start the download of job, if what job
+// still has to write, going by job.Size, fits in the quota and the free
+// space
+func (m *Manager) Admit(job *models.Job, commit func() error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	written, err := m.jobSize(*job)
+	if err != nil {
+		return err
+	}
+	var size int64
+	if job.Size > written {
+		size = job.Size - written
+	}
+	if err := m.check(size, job.ID); err != nil {
+		return err
+	}
+	return commit()
+}
+
+//check returns ErrQuotaExceeded or ErrNoSpace if size more bytes don't fit,
+// the job with ID except isn't counted as pending
+func (m *Manager) check(size int64, except bson.ObjectId) error {
+	usage, err := m.usage(except)
+	if err != nil {
+		return err
+	}
+	return usage.fits(size)
+}
+
+//fits returns an error if size more bytes don't fit
+func (u *Usage) fits(size int64) error {
+	if u.Quota > 0 && u.Used+u.Pending+size > u.Quota {
+		return ErrQuotaExceeded
+	}
+	if u.Free-u.Pending-size < u.MinFree {
+		return ErrNoSpace
+	}
+	return nil
+}
+
+//overBy returns how many bytes must be freed for size more bytes to fit
+func (u *Usage) overBy(size int64) int64 {
+	var over int64
+	if u.Quota > 0 {
+		over = u.Used + u.Pending + size - u.Quota
+	}
+	if short := u.MinFree - (u.Free - u.Pending - size); short > over {
+		over = short
+	}
+	return over
+}
+
+//Plan returns the releases to evict for size more bytes to fit, least
+// played first then least recently downloaded, or nothing if they already
+// fit. Active releases are never part of a plan, so it may not free enough.
+func (m *Manager) Plan(size int64) ([]ReleaseUsage, error) {
+	usage, err := m.usage("")
+	if err != nil {
+		return nil, err
+	}
+	over := usage.overBy(size)
+	if over <= 0 {
+		return nil, nil
+	}
+	releases, err := m.releases()
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(releases, func(i, j int) bool {
+		a, b := releases[i], releases[j]
+		if a.Plays != b.Plays {
+			return a.Plays < b.Plays
+		}
+		return lastDownload(a).Before(lastDownload(b))
+	})
+	var plan []ReleaseUsage
+	for _, rel := range releases {
+		if over <= 0 {
+			break
+		}
+		if rel.Active || rel.Bytes == 0 {
+			continue
+		}
+		plan = append(plan, rel)
+		over -= rel.Bytes
+	}
+	return plan, nil
+}
+
+//Evict deletes the files of rel's jobs and marks them evicted, their
+// torrents must have been dropped first
+func (m *Manager) Evict(rel ReleaseUsage) error {
+	for i := range rel.Jobs {
+		job := &rel.Jobs[i]
+		if dir, ok := m.jobDir(*job); ok {
+			if err := os.RemoveAll(dir); err != nil {
+				return err
+			}
+		}
+		job.State = models.JobEvicted
+		job.Error = ""
+		job.UpdatedAt = time.Now()
+		if err := m.db.UpdateJob(job); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//Discard deletes the files of job, which must not be downloading anymore,
+// unless another job has them
+func (m *Manager) Discard(job models.Job) error {
+	dir, ok := m.jobDir(job)
+	if !ok {
+		return nil
+	}
+	jobs, err := m.db.Jobs(
+		models.JobQueued,
+		models.JobDownloading,
+		models.JobComplete,
+	)
+	if err != nil {
+		return err
+	}
+	for _, other := range jobs {
+		if other.ID != job.ID && other.Dir == job.Dir {
+			return nil
+		}
+	}
+	return os.RemoveAll(dir)
+}
+
+//releases returns the usage of every release with downloaded files
+func (m *Manager) releases() ([]ReleaseUsage, error) {
+	jobs, err := m.db.Jobs(
+		models.JobQueued,
+		models.JobDownloading,
+		models.JobComplete,
+	)
+	if err != nil {
+		return nil, err
+	}
+	plays, err := m.plays()
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*ReleaseUsage)
+	var ids []string
+	for _, job := range jobs {
+		if !bson.IsObjectIdHex(job.ReleaseID) {
+			continue
+		}
+		rel, found := byID[job.ReleaseID]
+		if !found {
+			rel = &ReleaseUsage{ReleaseID: job.ReleaseID, Release: job.Name}
+			byID[job.ReleaseID] = rel
+			ids = append(ids, job.ReleaseID)
+		}
+		size, err := m.jobSize(job)
+		if err != nil {
+			return nil, err
+		}
+		rel.Bytes += size
+		rel.Active = rel.Active || !job.State.Done()
+		rel.Jobs = append(rel.Jobs, job)
+	}
+	releases := make([]ReleaseUsage, 0, len(ids))
+	for _, id := range ids {
+		rel := byID[id]
+		full, err := store.FullRelease(m.db, bson.ObjectIdHex(id))
+		if err != nil && err != store.ErrNotFound {
+			return nil, err
+		}
+		if full != nil {
+			rel.Release = full.Name
+			rel.ArtistID = full.AlbumArtistID
+			if full.AlbumArtist != nil {
+				rel.Artist = full.AlbumArtist.Name
+			}
+			for _, trackID := range full.TrackIDs {
+				rel.Plays += plays[trackID]
+			}
+		}
+		releases = append(releases, *rel)
+	}
+	return releases, nil
+}
+
+//plays returns the number of listens of every track listened to
+func (m *Manager) plays() (map[string]int, error) {
+	counts, err := m.db.TopTracks(time.Time{}, math.MaxInt32)
+	if err != nil {
+		return nil, err
+	}
+	plays := make(map[string]int, len(counts))
+	for _, c := range counts {
+		plays[c.TrackID] = c.Count
+	}
+	return plays, nil
+}
+
+//jobDir returns the directory of job's files, if it is known and inside
+// the download directory
+func (m *Manager) jobDir(job models.Job) (string, bool) {
+	if job.Dir == "" {
+		return "", false
+	}
+	dir := filepath.Join(m.cfg.Dir, job.Dir)
+	rel, err := filepath.Rel(m.cfg.Dir, dir)
+	if err != nil ||
+		rel == "." ||
+		rel == ".." ||
+		strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return dir, true
+}
+
+//jobSize returns the size of job's files on disk
+func (m *Manager) jobSize(job models.Job) (int64, error) {
+	dir, ok := m.jobDir(job)
+	if !ok {
+		return 0, nil
+	}
+	return dirSize(dir)
+}
+
+//dirSize returns the total size of the files in dir, or of dir itself if
+// it is a file, 0 if it doesn't exist
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.Walk(dir, func(
+		_ string,
+		info os.FileInfo,
+		err error,
+	) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+func lastDownload(rel ReleaseUsage) time.Time {
+	var last time.Time
+	for _, job := range rel.Jobs {
+		if job.CreatedAt.After(last) {
+			last = job.CreatedAt
+		}
+	}
+	return last
+}
@@ -0,0 +1,29 @@
+package disk
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var getDiskFreeSpaceEx = syscall.NewLazyDLL("kernel32.dll").
+	NewProc("GetDiskFreeSpaceExW")
+
+//FreeSpace returns the bytes available to the user on the volume holding
+// path
+func FreeSpace(path string) (uint64, error) {
+	p, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+	var free uint64
+	ok, _, err := getDiskFreeSpaceEx.Call(
+		uintptr(unsafe.Pointer(p)),
+		uintptr(unsafe.Pointer(&free)),
+		0,
+		0,
+	)
+	if ok == 0 {
+		return 0, err
+	}
+	return free, nil
+}
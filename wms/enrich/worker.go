@@ -0,0 +1,344 @@
+package enrich
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/waelbendhia/music-streaming/wms/metadata"
+	"github.com/waelbendhia/music-streaming/wms/models"
+	"github.com/waelbendhia/music-streaming/wms/store"
+	"golang.org/x/time/rate"
+	"gopkg.in/mgo.v2/bson"
+)
+
+//Config of an enrichment worker
+type Config struct {
+	//Interval between two enrichment passes
+	Interval time.Duration
+	//StaleAfter is how long metadata is kept before it is fetched again
+	StaleAfter time.Duration
+	//RequestsPerSecond allowed against the metadata provider
+	RequestsPerSecond float64
+	//BatchSize is the maximum number of artists and of releases handled in
+	// a single pass
+	BatchSize int
+	//SimilarArtists is the number of similar artists linked to an artist
+	SimilarArtists int
+	//Genres is the number of top tags kept as genres
+	Genres int
+	//MaxBackoff caps the delay between passes after provider failures
+	MaxBackoff time.Duration
+}
+
+//DefaultConfig is a sensible configuration for the lastFM api, MusicBrainz
+// clients enforce their own stricter limit
+var DefaultConfig = Config{
+	Interval:          time.Hour,
+	StaleAfter:        30 * 24 * time.Hour,
+	RequestsPerSecond: 4,
+	BatchSize:         50,
+	SimilarArtists:    10,
+	Genres:            5,
+	MaxBackoff:        time.Hour,
+}
+
+//Worker fills in missing artist and release metadata in the background
+type Worker struct {
+	cfg               Config
+	db                store.Store
+	provider          metadata.Provider
+	limiter           *rate.Limiter
+	infoLog, errorLog *log.Logger
+	backoff           time.Duration
+	cancel            context.CancelFunc
+	done              chan struct{}
+}
+
+//NewWorker creates an enrichment worker
+func NewWorker(
+	cfg Config,
+	db store.Store,
+	provider metadata.Provider,
+	infoLog, errorLog *log.Logger,
+) *Worker {
+	return &Worker{
+		cfg:      cfg,
+		db:       db,
+		provider: provider,
+		limiter:  rate.NewLimiter(rate.Limit(cfg.RequestsPerSecond), 1),
+		infoLog:  infoLog,
+		errorLog: errorLog,
+	}
+}
+
+//Start the worker, the first pass runs immediately
+func (w *Worker) Start() {
+	var ctx context.Context
+	ctx, w.cancel = context.WithCancel(context.Background())
+	w.done = make(chan struct{})
+	go w.run(ctx)
+}
+
+//Stop the worker and wait for the running pass to return
+func (w *Worker) Stop() {
+	if w.cancel == nil {
+		return
+	}
+	w.cancel()
+	<-w.done
+	w.cancel = nil
+}
+
+func (w *Worker) run(ctx context.Context) {
+	defer close(w.done)
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+		wait := w.cfg.Interval
+		if err := w.pass(ctx); err != nil && ctx.Err() == nil {
+			w.backoff = nextBackoff(w.backoff, w.cfg.MaxBackoff)
+			wait = w.backoff
+			w.errorLog.Printf(
+				"enrichment pass failed, retrying in %v: %v",
+				wait,
+				err,
+			)
+		} else {
+			w.backoff = 0
+		}
+		timer.Reset(wait)
+	}
+}
+
+func nextBackoff(prev, max time.Duration) time.Duration {
+	next := 2 * prev
+	if next == 0 {
+		next = 30 * time.Second
+	}
+	if next > max {
+		next = max
+	}
+	return next
+}
+
+//pass enriches one batch of artists and one of releases, it only returns an
+// error if the provider or the database can't be reached, records the
+// provider has no data for are marked as enriched anyway
+func (w *Worker) pass(ctx context.Context) error {
+	staleBefore := time.Now().Add(-w.cfg.StaleAfter)
+	artists, err := w.db.ArtistsToEnrich(staleBefore, w.cfg.BatchSize)
+	if err != nil {
+		return err
+	}
+	for i := range artists {
+		if err := w.enrichArtist(ctx, &artists[i]); err != nil {
+			return err
+		}
+	}
+	rels, err := w.db.ReleasesToEnrich(staleBefore, w.cfg.BatchSize)
+	if err != nil {
+		return err
+	}
+	for i := range rels {
+		if err := w.enrichRelease(ctx, &rels[i]); err != nil {
+			return err
+		}
+	}
+	if len(artists) > 0 || len(rels) > 0 {
+		w.infoLog.Printf(
+			"Enriched %d artists and %d releases",
+			len(artists),
+			len(rels),
+		)
+	}
+	return nil
+}
+
+func (w *Worker) enrichArtist(ctx context.Context, artist *models.Artist) error {
+	if err := w.limiter.Wait(ctx); err != nil {
+		return err
+	}
+	info, err := w.provider.GetArtist(ctx, artist)
+	if err != nil {
+		return w.skipOrFail(err, artist.Name, func() error {
+			artist.LastEnriched = time.Now()
+			return w.db.UpdateArtist(artist)
+		})
+	}
+	if info.MBID != "" {
+		artist.MBID = info.MBID
+	}
+	if info.Bio != "" {
+		artist.Bio = info.Bio
+	}
+	if info.ImageURL != "" {
+		artist.ImageURL = info.ImageURL
+	}
+	if genres := firstN(info.Genres, w.cfg.Genres); len(genres) > 0 {
+		artist.Genres = genres
+	}
+	// Stubs only get their own metadata, linking their similar artists too
+	// would crawl the provider's whole graph
+	if !artist.Stub {
+		if err := w.linkSimilarArtists(ctx, artist); err != nil {
+			return err
+		}
+	}
+	artist.LastEnriched = time.Now()
+	return w.db.UpdateArtist(artist)
+}
+
+func (w *Worker) linkSimilarArtists(
+	ctx context.Context,
+	artist *models.Artist,
+) error {
+	if err := w.limiter.Wait(ctx); err != nil {
+		return err
+	}
+	similar, err := w.provider.SimilarArtists(
+		ctx,
+		artist,
+		w.cfg.SimilarArtists,
+	)
+	if err != nil {
+		return w.skipOrFail(err, artist.Name, func() error { return nil })
+	}
+	var ids []string
+	for _, sim := range similar {
+		if sim.Name == "" || sim.Name == artist.Name {
+			continue
+		}
+		stub := &models.Artist{
+			Name:     sim.Name,
+			MBID:     sim.MBID,
+			ImageURL: sim.ImageURL,
+			Stub:     true,
+		}
+		related, err := store.FindArtist(w.db, stub)
+		if err == store.ErrNotFound {
+			related = stub
+			err = w.db.InsertArtist(related)
+			if err == store.ErrDuplicate {
+				// Inserted concurrently, link to that one
+				related, err = store.FindArtist(w.db, stub)
+			}
+		}
+		if err != nil {
+			return err
+		}
+		id := related.ID.Hex()
+		if related.ID == artist.ID || contains(ids, id) {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) > 0 {
+		artist.RelatedArtistIDs = ids
+	}
+	return nil
+}
+
+func (w *Worker) enrichRelease(ctx context.Context, rel *models.Release) error {
+	markEnriched := func() error {
+		rel.LastEnriched = time.Now()
+		return w.db.UpdateRelease(rel)
+	}
+	if !bson.IsObjectIdHex(rel.AlbumArtistID) {
+		return markEnriched()
+	}
+	artist, err := w.db.Artist(bson.ObjectIdHex(rel.AlbumArtistID))
+	if err == store.ErrNotFound {
+		return markEnriched()
+	} else if err != nil {
+		return err
+	}
+	rel.AlbumArtist = artist
+	if rel.CoverURL == "" {
+		if err := w.limiter.Wait(ctx); err != nil {
+			return err
+		}
+		info, err := w.provider.GetRelease(ctx, rel)
+		if err != nil {
+			return w.skipOrFail(err, rel.Name, markEnriched)
+		}
+		rel.CoverURL = info.CoverURL
+		if rel.MBID == "" {
+			rel.MBID = info.MBID
+		}
+		if rel.GroupMBID == "" {
+			rel.GroupMBID = info.GroupMBID
+		}
+	}
+	if err := w.limiter.Wait(ctx); err != nil {
+		return err
+	}
+	tags, err := w.provider.ReleaseGenres(ctx, rel)
+	if err != nil {
+		return w.skipOrFail(err, rel.Name, markEnriched)
+	}
+	if genres := firstN(tags, w.cfg.Genres); len(genres) > 0 {
+		rel.Genres = genres
+		if err := w.setTrackGenres(rel, genres[0]); err != nil {
+			return err
+		}
+	}
+	return markEnriched()
+}
+
+//setTrackGenres sets genre on the release's tracks that have none
+func (w *Worker) setTrackGenres(rel *models.Release, genre string) error {
+	for _, trackID := range rel.TrackIDs {
+		if !bson.IsObjectIdHex(trackID) {
+			continue
+		}
+		track, err := w.db.Track(bson.ObjectIdHex(trackID))
+		if err == store.ErrNotFound {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if track.Genre != "" {
+			continue
+		}
+		track.Genre = genre
+		if err := w.db.UpdateTrack(track); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//skipOrFail returns err if it is worth retrying the whole pass later,
+// otherwise it logs it and calls skip so the record isn't retried until it
+// goes stale
+func (w *Worker) skipOrFail(err error, name string, skip func() error) error {
+	if metadata.IsTemporary(err) {
+		return err
+	}
+	if err != metadata.ErrNotFound {
+		w.errorLog.Printf("Could not enrich '%s': %v", name, err)
+	}
+	return skip()
+}
+
+func firstN(names []string, n int) []string {
+	if len(names) > n {
+		names = names[:n]
+	}
+	return names
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,170 @@
+//Package health checks the components the server depends on, to tell an
+// orchestrator whether it is ready to serve requests
+package health
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+//Status of a component or of the whole server
+type Status string
+
+//Statuses
+const (
+	Up   Status = "up"
+	Down Status = "down"
+)
+
+//ErrPending is returned by cached checks that haven't completed yet
+var ErrPending = errors.New("not checked yet")
+
+//Check returns details about a component and an error if it is unhealthy,
+// it should return once ctx is done
+type Check func(ctx context.Context) (interface{}, error)
+
+//Result of a component's check
+type Result struct {
+	Status     Status      `json:"status"`
+	Error      string      `json:"error,omitempty"`
+	Details    interface{} `json:"details,omitempty"`
+	DurationMS float64     `json:"durationMs"`
+}
+
+//Report of every component, the server is up if all of them are
+type Report struct {
+	Status     Status            `json:"status"`
+	Components map[string]Result `json:"components"`
+}
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+//Checker runs the checks of the components
+type Checker struct {
+	timeout time.Duration
+	checks  []namedCheck
+}
+
+//NewChecker creates a checker giving every check up to timeout
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout}
+}
+
+//Add the check of the named component, checks can't be added while the
+// checker runs
+func (c *Checker) Add(name string, check Check) {
+	c.checks = append(c.checks, namedCheck{name, check})
+	sort.Slice(c.checks, func(i, j int) bool {
+		return c.checks[i].name < c.checks[j].name
+	})
+}
+
+//Run the checks concurrently, those that don't return in time are down
+func (c *Checker) Run(ctx context.Context) Report {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	results := make([]Result, len(c.checks))
+	var wg sync.WaitGroup
+	for i, check := range c.checks {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			results[i] = run(ctx, check)
+		}(i, check.check)
+	}
+	wg.Wait()
+	report := Report{Status: Up, Components: make(map[string]Result)}
+	for i, check := range c.checks {
+		if results[i].Status != Up {
+			report.Status = Down
+		}
+		report.Components[check.name] = results[i]
+	}
+	return report
+}
+
+//run check and returns its result, or a timeout once ctx is done if the
+// check ignores it
+func run(ctx context.Context, check Check) Result {
+	type outcome struct {
+		details interface{}
+		err     error
+	}
+	start := time.Now()
+	done := make(chan outcome, 1)
+	go func() {
+		details, err := check(ctx)
+		done <- outcome{details, err}
+	}()
+	var o outcome
+	select {
+	case o = <-done:
+	case <-ctx.Done():
+		o.err = ctx.Err()
+	}
+	res := Result{
+		Status:     Up,
+		Details:    o.details,
+		DurationMS: float64(time.Since(start)) / float64(time.Millisecond),
+	}
+	if o.err != nil {
+		res.Status, res.Error = Down, o.err.Error()
+	}
+	return res
+}
+
+//CachedDetails are the details of a cached check
+type CachedDetails struct {
+	CheckedAt time.Time   `json:"checkedAt"`
+	Details   interface{} `json:"details,omitempty"`
+}
+
+type cached struct {
+	check    Check
+	interval time.Duration
+	timeout  time.Duration
+
+	mu      sync.Mutex
+	running bool
+	checked time.Time
+	details interface{}
+	err     error
+}
+
+//Cached returns a check that never waits for check: it returns the last
+// result while check runs in the background, with up to timeout to
+// complete, if that result is older than interval. It is meant for slow or
+// rate limited dependencies, like remote APIs.
+func Cached(check Check, interval, timeout time.Duration) Check {
+	c := &cached{check: check, interval: interval, timeout: timeout}
+	return c.result
+}
+
+func (c *cached) result(context.Context) (interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.running && time.Since(c.checked) >= c.interval {
+		c.running = true
+		go c.refresh()
+	}
+	if c.checked.IsZero() {
+		return nil, ErrPending
+	}
+	return CachedDetails{CheckedAt: c.checked, Details: c.details}, c.err
+}
+
+func (c *cached) refresh() {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	details, err := c.check(ctx)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.running = false
+	c.checked, c.details, c.err = time.Now(), details, err
+}
@@ -0,0 +1,132 @@
+//Package library imports the music files already on disk into the catalog
+package library
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/waelbendhia/music-streaming/wms/models"
+	"github.com/waelbendhia/music-streaming/wms/store"
+)
+
+//Extensions of the audio files scanned
+var Extensions = map[string]bool{
+	".flac": true,
+	".m4a":  true,
+	".mp3":  true,
+	".ogg":  true,
+	".opus": true,
+	".wav":  true,
+}
+
+var (
+	discDir = regexp.MustCompile(`(?i)^(?:cd|disc|disk)\s*(\d+)$`)
+	// 1-01 Name, 01 - Name, 01. Name or 01 Name
+	discTrackFile = regexp.MustCompile(`^(\d)-(\d{1,3})\s*[-._ ]\s*(.+)$`)
+	trackFile     = regexp.MustCompile(`^(\d{1,3})\s*[-._ ]\s*(.+)$`)
+)
+
+//Result of a scan
+type Result struct {
+	//Releases and Tracks found and upserted
+	Releases, Tracks int
+	//Skipped audio files that are not laid out as Scan expects
+	Skipped []string
+}
+
+//Scan walks the directories in paths and upserts a release for every
+// directory of audio files laid out as Artist/Release/[CD1/]01 - Track.ext,
+// the tracks' URLs are the files' absolute paths. Scanning again only adds
+// what is new.
+func Scan(s store.Store, paths []string) (Result, error) {
+	var (
+		res      Result
+		releases = make(map[[2]string]*models.Release)
+	)
+	for _, root := range paths {
+		root, err := filepath.Abs(root)
+		if err != nil {
+			return res, err
+		}
+		err = filepath.Walk(root, func(
+			path string,
+			info os.FileInfo,
+			err error,
+		) error {
+			if err != nil {
+				return err
+			}
+			ext := strings.ToLower(filepath.Ext(path))
+			if info.IsDir() || !Extensions[ext] {
+				return nil
+			}
+			artist, release, track, ok := parsePath(root, path)
+			if !ok {
+				res.Skipped = append(res.Skipped, path)
+				return nil
+			}
+			key := [2]string{artist, release}
+			rel, ok := releases[key]
+			if !ok {
+				rel = &models.Release{
+					Name:        release,
+					AlbumArtist: &models.Artist{Name: artist},
+				}
+				releases[key] = rel
+			}
+			rel.Tracks = append(rel.Tracks, track)
+			return nil
+		})
+		if err != nil {
+			return res, err
+		}
+	}
+	for _, rel := range releases {
+		// Files without a number are numbered in file name order
+		sort.SliceStable(rel.Tracks, func(i, j int) bool {
+			return rel.Tracks[i].TrackURL < rel.Tracks[j].TrackURL
+		})
+		res.Tracks += len(rel.Tracks)
+		if err := s.UpsertRelease(rel); err != nil {
+			return res, err
+		}
+		res.Releases++
+	}
+	return res, nil
+}
+
+//parsePath returns the artist, release and track path describes, ok is
+// false if path isn't at least two directories below root
+func parsePath(
+	root, path string,
+) (artist, release string, track models.Track, ok bool) {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return "", "", track, false
+	}
+	dir := filepath.Dir(rel)
+	if m := discDir.FindStringSubmatch(filepath.Base(dir)); m != nil {
+		track.DiscNumber, _ = strconv.Atoi(m[1])
+		dir = filepath.Dir(dir)
+	}
+	release, artist = filepath.Base(dir), filepath.Base(filepath.Dir(dir))
+	if artist == "." || release == "." {
+		return "", "", track, false
+	}
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	if m := discTrackFile.FindStringSubmatch(name); m != nil {
+		track.DiscNumber, _ = strconv.Atoi(m[1])
+		track.TrackNumber, _ = strconv.Atoi(m[2])
+		name = m[3]
+	} else if m := trackFile.FindStringSubmatch(name); m != nil {
+		track.TrackNumber, _ = strconv.Atoi(m[1])
+		name = m[2]
+	}
+	track.Name = strings.TrimSpace(name)
+	track.TrackURL = path
+	return artist, release, track, true
+}
@@ -0,0 +1,94 @@
+//Package lifecycle starts and stops the subsystems of the server in order
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+)
+
+//Hook starts and stops a subsystem, either function may be nil
+type Hook struct {
+	Name string
+	//Start must return once the subsystem is running
+	Start func() error
+	//Stop must return once the subsystem is stopped or ctx is done
+	Stop func(ctx context.Context) error
+}
+
+//Manager starts subsystems in the order their hooks were registered and
+// stops them in the reverse order, so a subsystem can rely on those
+// registered before it while it runs and stops
+type Manager struct {
+	mu      sync.Mutex
+	hooks   []Hook
+	started int
+	logger  *log.Logger
+}
+
+//New creates a manager that logs the subsystems it starts and stops
+func New(logger *log.Logger) *Manager {
+	return &Manager{logger: logger}
+}
+
+//Register a subsystem's hook, subsystems can't be registered once started
+func (m *Manager) Register(hook Hook) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.started > 0 {
+		panic("lifecycle: " + hook.Name + " registered after Start")
+	}
+	m.hooks = append(m.hooks, hook)
+}
+
+//Start the subsystems, if one fails those already started are stopped and
+// its error returned
+func (m *Manager) Start() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, hook := range m.hooks[m.started:] {
+		if hook.Start != nil {
+			m.logger.Printf("Starting %s", hook.Name)
+			if err := hook.Start(); err != nil {
+				err = fmt.Errorf("could not start %s: %v", hook.Name, err)
+				if stopErr := m.stop(context.Background()); stopErr != nil {
+					m.logger.Println(stopErr)
+				}
+				return err
+			}
+		}
+		m.started++
+	}
+	return nil
+}
+
+//Stop the started subsystems, every one of them is given a chance to stop
+// even if ctx is done, the errors of those that failed are combined
+func (m *Manager) Stop(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.stop(ctx)
+}
+
+func (m *Manager) stop(ctx context.Context) error {
+	var errs []string
+	for ; m.started > 0; m.started-- {
+		hook := m.hooks[m.started-1]
+		if hook.Stop == nil {
+			continue
+		}
+		m.logger.Printf("Stopping %s", hook.Name)
+		if err := hook.Stop(ctx); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", hook.Name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf(
+			"could not stop cleanly: %s",
+			strings.Join(errs, "; "),
+		)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//appendLogfmt appends keyvals as key=value pairs, values holding spaces,
+// quotes or equal signs are quoted
+func appendLogfmt(b []byte, keyvals []interface{}) []byte {
+	for i := 0; i < len(keyvals); i += 2 {
+		if i > 0 {
+			b = append(b, ' ')
+		}
+		b = append(b, key(keyvals[i])...)
+		b = append(b, '=')
+		value := text(keyvals[i+1])
+		if value == "" || strings.IndexFunc(value, needsQuotes) >= 0 {
+			b = strconv.AppendQuote(b, value)
+		} else {
+			b = append(b, value...)
+		}
+	}
+	return b
+}
+
+func needsQuotes(r rune) bool {
+	return r <= ' ' || r == '=' || r == '"' || r == 0x7f
+}
+
+//appendJSON appends keyvals as a JSON object, keeping their order
+func appendJSON(b []byte, keyvals []interface{}) []byte {
+	b = append(b, '{')
+	for i := 0; i < len(keyvals); i += 2 {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		k, _ := json.Marshal(key(keyvals[i]))
+		b = append(append(b, k...), ':')
+		var value interface{}
+		switch v := keyvals[i+1].(type) {
+		case error, fmt.Stringer:
+			value = text(v)
+		default:
+			value = v
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			data, _ = json.Marshal(text(value))
+		}
+		b = append(b, data...)
+	}
+	return append(b, '}')
+}
+
+func key(k interface{}) string {
+	if s, ok := k.(string); ok {
+		return s
+	}
+	return fmt.Sprint(k)
+}
+
+//text returns v as written in a record
+func text(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case error:
+		return v.Error()
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	case fmt.Stringer:
+		return v.String()
+	}
+	return fmt.Sprint(v)
+}
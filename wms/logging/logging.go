@@ -0,0 +1,263 @@
+//Package logging writes leveled records as logfmt or JSON lines. Every
+// subsystem logs at its own level, which can be changed while running.
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+//Level of a record, records below a subsystem's level are dropped
+type Level int
+
+//Levels
+const (
+	Debug Level = iota
+	Info
+	Warn
+	Error
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+func (l Level) String() string {
+	if l < Debug || l > Error {
+		return fmt.Sprintf("level(%d)", int(l))
+	}
+	return levelNames[l]
+}
+
+//ParseLevel parses the name of a level
+func ParseLevel(name string) (Level, error) {
+	name = strings.ToLower(name)
+	if name == "warning" {
+		return Warn, nil
+	}
+	for l, n := range levelNames {
+		if n == name {
+			return Level(l), nil
+		}
+	}
+	return 0, fmt.Errorf(
+		"unknown level '%s', use one of %s",
+		name,
+		strings.Join(levelNames, ", "),
+	)
+}
+
+//MarshalText returns the name of l
+func (l Level) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+//UnmarshalText parses the name of a level
+func (l *Level) UnmarshalText(text []byte) error {
+	var err error
+	*l, err = ParseLevel(string(text))
+	return err
+}
+
+//Formats of records
+const (
+	Logfmt = "logfmt"
+	JSON   = "json"
+)
+
+//ErrUnknownFormat is returned for formats other than Logfmt and JSON
+var ErrUnknownFormat = errors.New("unknown log format, use logfmt or json")
+
+//sink is shared by a logger and all the loggers derived from it
+type sink struct {
+	mu   sync.Mutex
+	w    io.Writer
+	json bool
+
+	levelsMu sync.RWMutex
+	//def is the level of subsystems without one in levels
+	def        Level
+	levels     map[string]Level
+	subsystems map[string]bool
+}
+
+//Logger writes the records of a subsystem, it is safe for concurrent use
+type Logger struct {
+	sink      *sink
+	subsystem string
+	//keyvals are added to every record
+	keyvals []interface{}
+}
+
+//New creates a logger writing records in format to w, subsystems log at
+// level unless it is changed
+func New(w io.Writer, format string, level Level) (*Logger, error) {
+	if format != Logfmt && format != JSON {
+		return nil, ErrUnknownFormat
+	}
+	return &Logger{sink: &sink{
+		w:          w,
+		json:       format == JSON,
+		def:        level,
+		levels:     make(map[string]Level),
+		subsystems: make(map[string]bool),
+	}}, nil
+}
+
+//Discard returns a logger dropping every record
+func Discard() *Logger {
+	l, _ := New(ioutil.Discard, Logfmt, Error+1)
+	return l
+}
+
+//Subsystem returns a logger of the named subsystem with the same key-value
+// pairs as l
+func (l *Logger) Subsystem(name string) *Logger {
+	l.sink.levelsMu.Lock()
+	l.sink.subsystems[name] = true
+	l.sink.levelsMu.Unlock()
+	return &Logger{sink: l.sink, subsystem: name, keyvals: l.keyvals}
+}
+
+//With returns a logger adding the key-value pairs in keyvals to every
+// record after those of l
+func (l *Logger) With(keyvals ...interface{}) *Logger {
+	all := make([]interface{}, 0, len(l.keyvals)+len(keyvals))
+	all = append(append(all, l.keyvals...), keyvals...)
+	return &Logger{sink: l.sink, subsystem: l.subsystem, keyvals: all}
+}
+
+//Enabled returns true if records at level are written
+func (l *Logger) Enabled(level Level) bool {
+	l.sink.levelsMu.RLock()
+	defer l.sink.levelsMu.RUnlock()
+	min, ok := l.sink.levels[l.subsystem]
+	if !ok {
+		min = l.sink.def
+	}
+	return level >= min
+}
+
+//Debug writes a debug record of msg followed by the key-value pairs of l
+// and those in keyvals
+func (l *Logger) Debug(msg string, keyvals ...interface{}) {
+	l.Log(Debug, msg, keyvals...)
+}
+
+//Info writes an info record, like Debug
+func (l *Logger) Info(msg string, keyvals ...interface{}) {
+	l.Log(Info, msg, keyvals...)
+}
+
+//Warn writes a warn record, like Debug
+func (l *Logger) Warn(msg string, keyvals ...interface{}) {
+	l.Log(Warn, msg, keyvals...)
+}
+
+//Error writes an error record, like Debug
+func (l *Logger) Error(msg string, keyvals ...interface{}) {
+	l.Log(Error, msg, keyvals...)
+}
+
+//Log writes a record at level if it is enabled
+func (l *Logger) Log(level Level, msg string, keyvals ...interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+	all := make([]interface{}, 0, 8+len(l.keyvals)+len(keyvals))
+	all = append(
+		all,
+		"time", time.Now().UTC().Format("2006-01-02T15:04:05.000Z07:00"),
+		"level", level.String(),
+	)
+	if l.subsystem != "" {
+		all = append(all, "subsystem", l.subsystem)
+	}
+	all = append(all, "msg", msg)
+	all = append(append(all, l.keyvals...), keyvals...)
+	if len(all)%2 != 0 {
+		all = append(all, "(MISSING)")
+	}
+	var line []byte
+	if l.sink.json {
+		line = appendJSON(nil, all)
+	} else {
+		line = appendLogfmt(nil, all)
+	}
+	line = append(line, '\n')
+	l.sink.mu.Lock()
+	defer l.sink.mu.Unlock()
+	l.sink.w.Write(line)
+}
+
+//Std returns a standard logger writing every line printed to it as a
+// record at level, for packages that log through a *log.Logger
+func (l *Logger) Std(level Level) *log.Logger {
+	return log.New(Writer(l, level), "", 0)
+}
+
+//Writer returns a writer writing every line written to it as a record at
+// level
+func Writer(l *Logger, level Level) io.Writer {
+	return lineWriter{l, level}
+}
+
+type lineWriter struct {
+	logger *Logger
+	level  Level
+}
+
+func (w lineWriter) Write(p []byte) (int, error) {
+	lines := strings.Split(strings.TrimRight(string(p), "\n"), "\n")
+	for _, line := range lines {
+		w.logger.Log(w.level, line)
+	}
+	return len(p), nil
+}
+
+//SetLevel sets the level of a subsystem, or the default level of those
+// without one if subsystem is empty
+func (l *Logger) SetLevel(subsystem string, level Level) {
+	l.sink.levelsMu.Lock()
+	defer l.sink.levelsMu.Unlock()
+	if subsystem == "" {
+		l.sink.def = level
+		return
+	}
+	l.sink.levels[subsystem] = level
+}
+
+//Levels returns the default level and the level of every subsystem that
+// was set or that a logger was created for
+func (l *Logger) Levels() (Level, map[string]Level) {
+	l.sink.levelsMu.RLock()
+	defer l.sink.levelsMu.RUnlock()
+	levels := make(map[string]Level, len(l.sink.subsystems))
+	for name := range l.sink.subsystems {
+		levels[name] = l.sink.def
+	}
+	for name, level := range l.sink.levels {
+		levels[name] = level
+	}
+	return l.sink.def, levels
+}
+
+type contextKey struct{}
+
+//NewContext returns a copy of ctx carrying l
+func NewContext(ctx context.Context, l *Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+//FromContext returns the logger ctx carries, or fallback if it has none
+func FromContext(ctx context.Context, fallback *Logger) *Logger {
+	if l, ok := ctx.Value(contextKey{}).(*Logger); ok {
+		return l
+	}
+	return fallback
+}
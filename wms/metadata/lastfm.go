@@ -0,0 +1,136 @@
+package metadata
+
+import (
+	"context"
+	"time"
+
+	"github.com/waelbendhia/music-streaming/lastfm"
+	"github.com/waelbendhia/music-streaming/wms/models"
+)
+
+type lastFMProvider struct {
+	cli *lastfm.Client
+}
+
+//NewLastFM creates a provider backed by the lastFM api
+func NewLastFM(cli *lastfm.Client) Provider {
+	return lastFMProvider{cli}
+}
+
+func (p lastFMProvider) Name() string {
+	return LastFM
+}
+
+func (p lastFMProvider) SearchReleases(
+	ctx context.Context,
+	query string,
+) ([]models.Release, error) {
+	lfmSearch, err := p.cli.SearchAlbums(ctx, query, lastfm.Page{})
+	if err != nil {
+		return nil, lfmErr(err)
+	}
+	res := make([]models.Release, len(lfmSearch.Results.AlbumMatches))
+	for i, alb := range lfmSearch.Results.AlbumMatches {
+		res[i] = lfmAlbumConverter(&alb)
+	}
+	return res, nil
+}
+
+func (p lastFMProvider) GetRelease(
+	ctx context.Context,
+	rel *models.Release,
+) (*models.Release, error) {
+	var (
+		fmAlbum *lastfm.AlbumResponse
+		err     error
+	)
+	if rel.MBID != "" {
+		fmAlbum, err = p.cli.GetAlbumInfoByMBID(ctx, rel.MBID)
+	}
+	if rel.MBID == "" || lastfm.IsNotFound(err) {
+		fmAlbum, err = p.cli.GetAlbumInfo(ctx, artistName(rel), rel.Name)
+	}
+	if err != nil {
+		return nil, lfmErr(err)
+	}
+	converted := lfmAlbumConverter(&fmAlbum.Album)
+	return &converted, nil
+}
+
+func (p lastFMProvider) ReleaseGenres(
+	ctx context.Context,
+	rel *models.Release,
+) ([]string, error) {
+	tags, err := p.cli.GetAlbumTopTags(ctx, artistName(rel), rel.Name)
+	if err != nil {
+		return nil, lfmErr(err)
+	}
+	return tags.TopTags.Tags.Names(), nil
+}
+
+func (p lastFMProvider) GetArtist(
+	ctx context.Context,
+	artist *models.Artist,
+) (*models.Artist, error) {
+	info, err := p.cli.GetArtistInfo(ctx, artist.Name)
+	if err != nil {
+		return nil, lfmErr(err)
+	}
+	return &models.Artist{
+		Name:     info.Artist.Name,
+		MBID:     info.Artist.MBID,
+		ImageURL: info.Artist.Image.Largest(),
+		Bio:      info.Artist.Bio.Summary,
+		Genres:   info.Artist.Tags.Names(),
+	}, nil
+}
+
+func (p lastFMProvider) SimilarArtists(
+	ctx context.Context,
+	artist *models.Artist,
+	limit int,
+) ([]models.Artist, error) {
+	similar, err := p.cli.GetSimilarArtists(ctx, artist.Name, limit)
+	if err != nil {
+		return nil, lfmErr(err)
+	}
+	res := make([]models.Artist, len(similar.SimilarArtists.Artists))
+	for i, sim := range similar.SimilarArtists.Artists {
+		res[i] = models.Artist{
+			Name:     sim.Name,
+			MBID:     sim.MBID,
+			ImageURL: sim.Image.Largest(),
+		}
+	}
+	return res, nil
+}
+
+func lfmErr(err error) error {
+	if lastfm.IsNotFound(err) {
+		return ErrNotFound
+	}
+	return err
+}
+
+func lfmAlbumConverter(lfmAlbum *lastfm.Album) models.Release {
+	var album models.Release
+	album.AlbumArtist = &models.Artist{
+		Name: lfmAlbum.Artist.Name,
+		MBID: lfmAlbum.Artist.MBID,
+	}
+	album.Name = lfmAlbum.Name
+	album.MBID = lfmAlbum.MBID
+	// Covers are resized when served
+	album.CoverURL = lfmAlbum.Image.Largest()
+	album.Genres = lfmAlbum.Tags.Names()
+	for _, track := range lfmAlbum.Tracks {
+		var newTrack models.Track
+		newTrack.Length = time.Duration(track.Duration) * time.Second
+		newTrack.Name = track.Name
+		newTrack.TrackNumber = int(track.Attr.Rank)
+		album.Tracks = append(album.Tracks, newTrack)
+	}
+	album.NumberTracks()
+	album.SortTracks()
+	return album
+}
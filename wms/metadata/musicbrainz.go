@@ -0,0 +1,300 @@
+package metadata
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/waelbendhia/music-streaming/musicbrainz"
+	"github.com/waelbendhia/music-streaming/wms/models"
+)
+
+var releaseIncludes = []string{
+	"recordings",
+	"artist-credits",
+	"isrcs",
+	"release-groups",
+	"labels",
+}
+
+type musicBrainzProvider struct {
+	cli *musicbrainz.Client
+}
+
+//NewMusicBrainz creates a provider backed by the MusicBrainz api
+func NewMusicBrainz(cli *musicbrainz.Client) Provider {
+	return musicBrainzProvider{cli}
+}
+
+func (p musicBrainzProvider) Name() string {
+	return MusicBrainz
+}
+
+func (p musicBrainzProvider) SearchReleases(
+	ctx context.Context,
+	query string,
+) ([]models.Release, error) {
+	results, err := p.cli.SearchReleaseGroups(
+		ctx,
+		musicbrainz.Escape(query),
+		25,
+		0,
+	)
+	if err != nil {
+		return nil, mbErr(err)
+	}
+	res := make([]models.Release, len(results.ReleaseGroups))
+	for i, rg := range results.ReleaseGroups {
+		res[i] = mbReleaseGroupConverter(&rg)
+	}
+	return res, nil
+}
+
+func (p musicBrainzProvider) GetRelease(
+	ctx context.Context,
+	rel *models.Release,
+) (*models.Release, error) {
+	mbid, err := p.findRelease(ctx, rel)
+	if err != nil {
+		return nil, mbErr(err)
+	}
+	mbRel, err := p.cli.LookupRelease(ctx, mbid, releaseIncludes...)
+	if err != nil {
+		return nil, mbErr(err)
+	}
+	converted := mbReleaseConverter(mbRel)
+	return &converted, nil
+}
+
+//findRelease returns the MBID of the release best matching rel, preferring
+// official releases
+func (p musicBrainzProvider) findRelease(
+	ctx context.Context,
+	rel *models.Release,
+) (string, error) {
+	if rel.MBID != "" {
+		return rel.MBID, nil
+	}
+	var candidates []musicbrainz.Release
+	if rel.GroupMBID != "" {
+		rg, err := p.cli.LookupReleaseGroup(ctx, rel.GroupMBID, "releases")
+		if err != nil {
+			return "", err
+		}
+		candidates = rg.Releases
+	} else {
+		query := musicbrainz.Phrase("release", rel.Name)
+		if name := artistName(rel); name != "" {
+			query += " AND " + musicbrainz.Phrase("artist", name)
+		}
+		results, err := p.cli.SearchReleases(ctx, query, 10, 0)
+		if err != nil {
+			return "", err
+		}
+		candidates = results.Releases
+	}
+	if len(candidates) == 0 {
+		return "", ErrNotFound
+	}
+	best := candidates[0]
+	for _, candidate := range candidates {
+		if candidate.Status == "Official" &&
+			candidate.Score >= best.Score &&
+			best.Status != "Official" {
+			best = candidate
+		}
+	}
+	return best.ID, nil
+}
+
+func (p musicBrainzProvider) ReleaseGenres(
+	ctx context.Context,
+	rel *models.Release,
+) ([]string, error) {
+	groupMBID := rel.GroupMBID
+	if groupMBID == "" {
+		query := musicbrainz.Phrase("releasegroup", rel.Name)
+		if name := artistName(rel); name != "" {
+			query += " AND " + musicbrainz.Phrase("artist", name)
+		}
+		results, err := p.cli.SearchReleaseGroups(ctx, query, 1, 0)
+		if err != nil {
+			return nil, mbErr(err)
+		}
+		if len(results.ReleaseGroups) == 0 {
+			return nil, ErrNotFound
+		}
+		groupMBID = results.ReleaseGroups[0].ID
+	}
+	rg, err := p.cli.LookupReleaseGroup(ctx, groupMBID, "genres", "tags")
+	if err != nil {
+		return nil, mbErr(err)
+	}
+	return mbGenres(rg.Genres, rg.Tags), nil
+}
+
+func (p musicBrainzProvider) GetArtist(
+	ctx context.Context,
+	artist *models.Artist,
+) (*models.Artist, error) {
+	mbid := artist.MBID
+	if mbid == "" {
+		results, err := p.cli.SearchArtists(
+			ctx,
+			musicbrainz.Phrase("artist", artist.Name),
+			1,
+			0,
+		)
+		if err != nil {
+			return nil, mbErr(err)
+		}
+		if len(results.Artists) == 0 {
+			return nil, ErrNotFound
+		}
+		mbid = results.Artists[0].ID
+	}
+	mbArtist, err := p.cli.LookupArtist(ctx, mbid, "genres", "tags")
+	if err != nil {
+		return nil, mbErr(err)
+	}
+	return &models.Artist{
+		Name:   mbArtist.Name,
+		MBID:   mbArtist.ID,
+		Genres: mbGenres(mbArtist.Genres, mbArtist.Tags),
+	}, nil
+}
+
+func (p musicBrainzProvider) SimilarArtists(
+	ctx context.Context,
+	artist *models.Artist,
+	limit int,
+) ([]models.Artist, error) {
+	return nil, nil
+}
+
+func mbErr(err error) error {
+	if musicbrainz.IsNotFound(err) {
+		return ErrNotFound
+	}
+	return err
+}
+
+//mbGenres returns the names of genres, or of tags if there are no genres,
+// ordered by vote count
+func mbGenres(genres, tags []musicbrainz.Tag) []string {
+	if len(genres) == 0 {
+		genres = tags
+	}
+	sorted := append([]musicbrainz.Tag{}, genres...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Count > sorted[j].Count
+	})
+	names := make([]string, len(sorted))
+	for i, genre := range sorted {
+		names[i] = genre.Name
+	}
+	return names
+}
+
+func mbArtistConverter(credit musicbrainz.ArtistCredit) *models.Artist {
+	return &models.Artist{
+		Name: credit.String(),
+		MBID: credit.MainArtist().ID,
+	}
+}
+
+//mbReleaseType maps a release group's types to ours, secondary types such
+// as live or compilation take precedence over the primary type
+func mbReleaseType(rg *musicbrainz.ReleaseGroup) models.ReleaseType {
+	for _, secondary := range rg.SecondaryTypes {
+		switch strings.ToLower(secondary) {
+		case "live":
+			return models.Live
+		case "compilation":
+			return models.Compilation
+		}
+	}
+	switch strings.ToLower(rg.PrimaryType) {
+	case "album":
+		return models.Album
+	case "ep":
+		return models.EP
+	case "single":
+		return models.Single
+	}
+	return ""
+}
+
+func mbReleaseGroupConverter(rg *musicbrainz.ReleaseGroup) models.Release {
+	rel := models.Release{
+		Name:        rg.Title,
+		GroupMBID:   rg.ID,
+		Type:        mbReleaseType(rg),
+		AlbumArtist: mbArtistConverter(rg.ArtistCredit),
+	}
+	rel.ReleaseDate, _ = musicbrainz.ParseDate(rg.FirstReleaseDate)
+	return rel
+}
+
+func mbReleaseConverter(mbRel *musicbrainz.Release) models.Release {
+	rel := models.Release{
+		Name:        mbRel.Title,
+		MBID:        mbRel.ID,
+		Edition:     mbRel.Disambiguation,
+		AlbumArtist: mbArtistConverter(mbRel.ArtistCredit),
+	}
+	rel.ReleaseDate, _ = musicbrainz.ParseDate(mbRel.Date)
+	if mbRel.CoverArt.Front {
+		rel.CoverURL = musicbrainz.CoverArtURL(mbRel.ID)
+	}
+	for _, info := range mbRel.LabelInfo {
+		if info.Label != nil && rel.Label == "" {
+			rel.Label = info.Label.Name
+		}
+		if rel.CatalogNumber == "" {
+			rel.CatalogNumber = info.CatalogNumber
+		}
+	}
+	if mbRel.ReleaseGroup != nil {
+		rel.Type = mbReleaseType(mbRel.ReleaseGroup)
+		rel.GroupMBID = mbRel.ReleaseGroup.ID
+		if date, ok := musicbrainz.ParseDate(
+			mbRel.ReleaseGroup.FirstReleaseDate,
+		); ok && rel.ReleaseDate.IsZero() {
+			rel.ReleaseDate = date
+		}
+	}
+	media := append([]musicbrainz.Medium{}, mbRel.Media...)
+	sort.SliceStable(media, func(i, j int) bool {
+		return media[i].Position < media[j].Position
+	})
+	for _, medium := range media {
+		if len(media) > 1 || medium.Title != "" {
+			rel.Discs = append(rel.Discs, models.Disc{
+				Number:   medium.Position,
+				Subtitle: medium.Title,
+			})
+		}
+		tracks := append([]musicbrainz.Track{}, medium.Tracks...)
+		sort.SliceStable(tracks, func(i, j int) bool {
+			return tracks[i].Position < tracks[j].Position
+		})
+		for _, mbTrack := range tracks {
+			track := models.Track{
+				Name:        mbTrack.Title,
+				MBID:        mbTrack.Recording.ID,
+				ISRCs:       mbTrack.Recording.ISRCs,
+				DiscNumber:  medium.Position,
+				TrackNumber: mbTrack.Position,
+				Length:      mbTrack.Duration(),
+			}
+			credit := mbTrack.ArtistCredit.String()
+			if credit != "" &&
+				!strings.EqualFold(credit, rel.AlbumArtist.Name) {
+				track.Artist = mbArtistConverter(mbTrack.ArtistCredit)
+			}
+			rel.Tracks = append(rel.Tracks, track)
+		}
+	}
+	return rel
+}
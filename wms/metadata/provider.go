@@ -0,0 +1,101 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/waelbendhia/music-streaming/lastfm"
+	"github.com/waelbendhia/music-streaming/musicbrainz"
+	"github.com/waelbendhia/music-streaming/wms/models"
+)
+
+//Names of the available providers
+const (
+	LastFM      = "lastfm"
+	MusicBrainz = "musicbrainz"
+)
+
+//ErrNotFound is returned when the provider has no data for the requested
+// entity
+var ErrNotFound = errors.New("metadata not found")
+
+//Provider is a source of artist and release metadata
+type Provider interface {
+	//Name of the provider
+	Name() string
+	//SearchReleases matching query, the results have no tracks
+	SearchReleases(ctx context.Context, query string) ([]models.Release, error)
+	//GetRelease returns the release identified by rel's MBIDs, or its name
+	// and album artist's name, with its track listing
+	GetRelease(ctx context.Context, rel *models.Release) (*models.Release, error)
+	//ReleaseGenres returns the genres of rel, most relevant first
+	ReleaseGenres(ctx context.Context, rel *models.Release) ([]string, error)
+	//GetArtist returns the metadata of the artist identified by artist's MBID
+	// or name
+	GetArtist(ctx context.Context, artist *models.Artist) (*models.Artist, error)
+	//SimilarArtists returns up to limit artists similar to artist, providers
+	// that don't know about similarity return no artists
+	SimilarArtists(
+		ctx context.Context,
+		artist *models.Artist,
+		limit int,
+	) ([]models.Artist, error)
+}
+
+//Config selects and configures a provider
+type Config struct {
+	//Provider is either LastFM or MusicBrainz
+	Provider string
+	//LastFMAPIKey is required by the lastFM provider
+	LastFMAPIKey string
+	//MusicBrainzUserAgent is sent to MusicBrainz, defaults to
+	// musicbrainz.DefaultUserAgent
+	MusicBrainzUserAgent string
+	//HTTPClient sends the requests to the provider, defaults to
+	// http.DefaultClient
+	HTTPClient *http.Client
+}
+
+//New creates the provider selected by cfg
+func New(cfg Config) (Provider, error) {
+	switch cfg.Provider {
+	case LastFM, "":
+		if cfg.LastFMAPIKey == "" {
+			return nil, errors.New("lastfm provider requires an api key")
+		}
+		return NewLastFM(
+			lastfm.NewClient(cfg.LastFMAPIKey, cfg.HTTPClient),
+		), nil
+	case MusicBrainz:
+		return NewMusicBrainz(
+			musicbrainz.NewClient(cfg.MusicBrainzUserAgent, cfg.HTTPClient),
+		), nil
+	default:
+		return nil, fmt.Errorf("unknown metadata provider '%s'", cfg.Provider)
+	}
+}
+
+type temporary interface {
+	Temporary() bool
+}
+
+//IsTemporary returns true if err may go away if the request is retried
+// later, e.g. rate limiting or the provider being unreachable
+func IsTemporary(err error) bool {
+	if tmp, ok := err.(temporary); ok && tmp.Temporary() {
+		return true
+	}
+	// Transport errors from the http client
+	_, ok := err.(*url.Error)
+	return ok || err == context.Canceled || err == context.DeadlineExceeded
+}
+
+func artistName(rel *models.Release) string {
+	if rel.AlbumArtist == nil {
+		return ""
+	}
+	return rel.AlbumArtist.Name
+}
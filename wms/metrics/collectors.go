@@ -0,0 +1,141 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/waelbendhia/music-streaming/wms/models"
+	"github.com/waelbendhia/music-streaming/wms/torrent"
+)
+
+var (
+	torrentsDesc = prometheus.NewDesc(
+		"wms_torrent_torrents",
+		"Torrents in the torrent client.",
+		nil,
+		nil,
+	)
+	torrentBytesDesc = prometheus.NewDesc(
+		"wms_torrent_payload_bytes_total",
+		"Payload bytes downloaded and uploaded by the torrents in the "+
+			"client, dropping a torrent resets it.",
+		[]string{"direction"},
+		nil,
+	)
+	torrentPeersDesc = prometheus.NewDesc(
+		"wms_torrent_peers",
+		"Peers of the torrents in the client, active ones are connected.",
+		[]string{"state"},
+		nil,
+	)
+	jobsDesc = prometheus.NewDesc(
+		"wms_download_jobs",
+		"Download jobs by state.",
+		[]string{"state"},
+		nil,
+	)
+	jobsUpDesc = prometheus.NewDesc(
+		"wms_download_jobs_up",
+		"Whether the download jobs could be counted.",
+		nil,
+		nil,
+	)
+)
+
+//TorrentCollector collects the stats returned by stats, which is called at
+// every scrape
+func TorrentCollector(stats func() torrent.Stats) prometheus.Collector {
+	return torrentCollector(stats)
+}
+
+type torrentCollector func() torrent.Stats
+
+func (c torrentCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- torrentsDesc
+	ch <- torrentBytesDesc
+	ch <- torrentPeersDesc
+}
+
+func (c torrentCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c()
+	for _, m := range []struct {
+		desc   *prometheus.Desc
+		typ    prometheus.ValueType
+		value  float64
+		labels []string
+	}{
+		{torrentsDesc, prometheus.GaugeValue, float64(stats.Torrents), nil},
+		{
+			torrentBytesDesc,
+			prometheus.CounterValue,
+			float64(stats.BytesRead),
+			[]string{"down"},
+		}, {
+			torrentBytesDesc,
+			prometheus.CounterValue,
+			float64(stats.BytesWritten),
+			[]string{"up"},
+		}, {
+			torrentPeersDesc,
+			prometheus.GaugeValue,
+			float64(stats.ActivePeers),
+			[]string{"active"},
+		}, {
+			torrentPeersDesc,
+			prometheus.GaugeValue,
+			float64(stats.TotalPeers),
+			[]string{"known"},
+		},
+	} {
+		ch <- prometheus.MustNewConstMetric(m.desc, m.typ, m.value, m.labels...)
+	}
+}
+
+//JobStates are the states jobs are counted in, even when there are none
+var JobStates = []models.JobState{
+	models.JobQueued,
+	models.JobDownloading,
+	models.JobComplete,
+	models.JobFailed,
+	models.JobCancelled,
+	models.JobEvicted,
+}
+
+//JobsCollector collects the number of jobs in every state of the jobs
+// returned by jobs, which is called at every scrape
+func JobsCollector(jobs func() ([]models.Job, error)) prometheus.Collector {
+	return jobsCollector(jobs)
+}
+
+type jobsCollector func() ([]models.Job, error)
+
+func (c jobsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- jobsDesc
+	ch <- jobsUpDesc
+}
+
+func (c jobsCollector) Collect(ch chan<- prometheus.Metric) {
+	jobs, err := c()
+	if err != nil {
+		ch <- prometheus.MustNewConstMetric(
+			jobsUpDesc,
+			prometheus.GaugeValue,
+			0,
+		)
+		return
+	}
+	counts := make(map[models.JobState]int, len(JobStates))
+	for _, state := range JobStates {
+		counts[state] = 0
+	}
+	for _, job := range jobs {
+		counts[job.State]++
+	}
+	for state, n := range counts {
+		ch <- prometheus.MustNewConstMetric(
+			jobsDesc,
+			prometheus.GaugeValue,
+			float64(n),
+			string(state),
+		)
+	}
+	ch <- prometheus.MustNewConstMetric(jobsUpDesc, prometheus.GaugeValue, 1)
+}
@@ -0,0 +1,213 @@
+//Package metrics exposes the server's Prometheus metrics, it instruments
+// the HTTP routes, the metadata provider, the calls made to upstream APIs
+// and the storage
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "wms"
+
+//Metrics of a server, it has its own registry so several servers can run
+// in a process
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests   *prometheus.CounterVec
+	httpDuration   *prometheus.HistogramVec
+	httpInFlight   *prometheus.GaugeVec
+	httpBytes      *prometheus.CounterVec
+	providerCalls  *prometheus.HistogramVec
+	providerErrors *prometheus.CounterVec
+	upstreamCalls  *prometheus.CounterVec
+	storeDuration  *prometheus.HistogramVec
+	storeErrors    *prometheus.CounterVec
+}
+
+//New creates the metrics along with the Go runtime and process metrics
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "requests_total",
+			Help:      "HTTP requests served by route, method and status code.",
+		}, []string{"route", "method", "code"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "Time taken to serve HTTP requests by route.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route"}),
+		httpInFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "requests_in_flight",
+			Help:      "HTTP requests, streams included, in flight by route.",
+		}, []string{"route"}),
+		httpBytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "response_bytes_total",
+			Help:      "Bytes of response bodies served by route.",
+		}, []string{"route"}),
+		providerCalls: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "metadata",
+			Name:      "call_duration_seconds",
+			Help:      "Time taken by metadata provider calls by method.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"provider", "method"}),
+		providerErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "metadata",
+			Name:      "errors_total",
+			Help:      "Metadata provider calls that failed by method.",
+		}, []string{"provider", "method"}),
+		upstreamCalls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "upstream",
+			Name:      "requests_total",
+			Help: "HTTP requests sent to upstream APIs by service and " +
+				"status code, the code is 0 if no response was received.",
+		}, []string{"service", "code"}),
+		storeDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "store",
+			Name:      "operation_duration_seconds",
+			Help:      "Time taken by storage operations by backend.",
+			Buckets: []float64{
+				.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1,
+			},
+		}, []string{"backend", "operation"}),
+		storeErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "store",
+			Name:      "errors_total",
+			Help: "Storage operations that failed by backend, not " +
+				"found errors excluded.",
+		}, []string{"backend", "operation"}),
+	}
+	m.registry.MustRegister(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		m.httpRequests,
+		m.httpDuration,
+		m.httpInFlight,
+		m.httpBytes,
+		m.providerCalls,
+		m.providerErrors,
+		m.upstreamCalls,
+		m.storeDuration,
+		m.storeErrors,
+	)
+	return m
+}
+
+//Register adds collectors, like those of the torrent client and the jobs,
+// to the metrics exposed
+func (m *Metrics) Register(collectors ...prometheus.Collector) error {
+	for _, c := range collectors {
+		if err := m.registry.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//Handler serves the metrics in the Prometheus exposition format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+//InstrumentHandler returns h counting, timing and measuring the responses
+// to the requests it serves as those of route
+func (m *Metrics) InstrumentHandler(
+	route string,
+	h http.Handler,
+) http.Handler {
+	var (
+		inFlight = m.httpInFlight.WithLabelValues(route)
+		duration = m.httpDuration.WithLabelValues(route)
+		bytes    = m.httpBytes.WithLabelValues(route)
+	)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inFlight.Inc()
+		defer inFlight.Dec()
+		start := time.Now()
+		cw := &countingWriter{ResponseWriter: w, status: 200, bytes: bytes}
+		h.ServeHTTP(cw, r)
+		duration.Observe(time.Since(start).Seconds())
+		m.httpRequests.WithLabelValues(
+			route,
+			r.Method,
+			strconv.Itoa(cw.status),
+		).Inc()
+	})
+}
+
+//countingWriter records the status of a response and adds the bytes of its
+// body to a counter as they are written, so streams are accounted for while
+// they last
+type countingWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	bytes       prometheus.Counter
+}
+
+func (w *countingWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader, w.status = true, status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *countingWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes.Add(float64(n))
+	return n, err
+}
+
+//Flush lets streamed responses through
+func (w *countingWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		w.wroteHeader = true
+		f.Flush()
+	}
+}
+
+//Transport returns rt counting the requests sent through it as calls to
+// service, http.DefaultTransport is used if rt is nil
+func (m *Metrics) Transport(
+	service string,
+	rt http.RoundTripper,
+) http.RoundTripper {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	return roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		resp, err := rt.RoundTrip(r)
+		code := "0"
+		if err == nil {
+			code = strconv.Itoa(resp.StatusCode)
+		}
+		m.upstreamCalls.WithLabelValues(service, code).Inc()
+		return resp, err
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
@@ -0,0 +1,82 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/waelbendhia/music-streaming/wms/metadata"
+	"github.com/waelbendhia/music-streaming/wms/models"
+)
+
+//Provider returns p timing its calls and counting those that fail
+func (m *Metrics) Provider(p metadata.Provider) metadata.Provider {
+	return &provider{Provider: p, metrics: m}
+}
+
+type provider struct {
+	metadata.Provider
+	metrics *Metrics
+}
+
+//observe records a call to method that started at start and failed if err
+// isn't nil
+func (p *provider) observe(method string, start time.Time, err error) {
+	name := p.Name()
+	p.metrics.providerCalls.
+		WithLabelValues(name, method).
+		Observe(time.Since(start).Seconds())
+	if err != nil && err != metadata.ErrNotFound {
+		p.metrics.providerErrors.WithLabelValues(name, method).Inc()
+	}
+}
+
+func (p *provider) SearchReleases(
+	ctx context.Context,
+	query string,
+) ([]models.Release, error) {
+	start := time.Now()
+	rels, err := p.Provider.SearchReleases(ctx, query)
+	p.observe("SearchReleases", start, err)
+	return rels, err
+}
+
+func (p *provider) GetRelease(
+	ctx context.Context,
+	rel *models.Release,
+) (*models.Release, error) {
+	start := time.Now()
+	rel, err := p.Provider.GetRelease(ctx, rel)
+	p.observe("GetRelease", start, err)
+	return rel, err
+}
+
+func (p *provider) ReleaseGenres(
+	ctx context.Context,
+	rel *models.Release,
+) ([]string, error) {
+	start := time.Now()
+	genres, err := p.Provider.ReleaseGenres(ctx, rel)
+	p.observe("ReleaseGenres", start, err)
+	return genres, err
+}
+
+func (p *provider) GetArtist(
+	ctx context.Context,
+	artist *models.Artist,
+) (*models.Artist, error) {
+	start := time.Now()
+	artist, err := p.Provider.GetArtist(ctx, artist)
+	p.observe("GetArtist", start, err)
+	return artist, err
+}
+
+func (p *provider) SimilarArtists(
+	ctx context.Context,
+	artist *models.Artist,
+	limit int,
+) ([]models.Artist, error) {
+	start := time.Now()
+	artists, err := p.Provider.SimilarArtists(ctx, artist, limit)
+	p.observe("SimilarArtists", start, err)
+	return artists, err
+}
@@ -0,0 +1,324 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/waelbendhia/music-streaming/wms/models"
+	"github.com/waelbendhia/music-streaming/wms/store"
+	"gopkg.in/mgo.v2/bson"
+)
+
+//Store returns s timing its operations as those of backend and counting
+// those that fail
+func (m *Metrics) Store(backend string, s store.Store) store.Store {
+	return &instrumentedStore{Store: s, metrics: m, backend: backend}
+}
+
+type instrumentedStore struct {
+	store.Store
+	metrics *Metrics
+	backend string
+}
+
+//observe records an operation that started at start and failed if err
+// isn't nil
+func (s *instrumentedStore) observe(op string, start time.Time, err error) {
+	s.metrics.storeDuration.
+		WithLabelValues(s.backend, op).
+		Observe(time.Since(start).Seconds())
+	if err != nil && err != store.ErrNotFound {
+		s.metrics.storeErrors.WithLabelValues(s.backend, op).Inc()
+	}
+}
+
+//Transaction times the whole transaction, the operations within it are
+// timed too
+func (s *instrumentedStore) Transaction(fn func(store.Store) error) error {
+	start := time.Now()
+	err := s.Store.Transaction(func(tx store.Store) error {
+		return fn(s.metrics.Store(s.backend, tx))
+	})
+	s.observe("Transaction", start, err)
+	return err
+}
+
+func (s *instrumentedStore) Artist(id bson.ObjectId) (*models.Artist, error) {
+	start := time.Now()
+	v, err := s.Store.Artist(id)
+	s.observe("Artist", start, err)
+	return v, err
+}
+
+func (s *instrumentedStore) ArtistByName(name string) (*models.Artist, error) {
+	start := time.Now()
+	v, err := s.Store.ArtistByName(name)
+	s.observe("ArtistByName", start, err)
+	return v, err
+}
+
+func (s *instrumentedStore) ArtistByMBID(mbid string) (*models.Artist, error) {
+	start := time.Now()
+	v, err := s.Store.ArtistByMBID(mbid)
+	s.observe("ArtistByMBID", start, err)
+	return v, err
+}
+
+func (s *instrumentedStore) InsertArtist(artist *models.Artist) error {
+	start := time.Now()
+	err := s.Store.InsertArtist(artist)
+	s.observe("InsertArtist", start, err)
+	return err
+}
+
+func (s *instrumentedStore) UpdateArtist(artist *models.Artist) error {
+	start := time.Now()
+	err := s.Store.UpdateArtist(artist)
+	s.observe("UpdateArtist", start, err)
+	return err
+}
+
+func (s *instrumentedStore) ArtistsToEnrich(
+	staleBefore time.Time, limit int,
+) ([]models.Artist, error) {
+	start := time.Now()
+	v, err := s.Store.ArtistsToEnrich(staleBefore, limit)
+	s.observe("ArtistsToEnrich", start, err)
+	return v, err
+}
+
+func (s *instrumentedStore) Artists() ([]models.Artist, error) {
+	start := time.Now()
+	v, err := s.Store.Artists()
+	s.observe("Artists", start, err)
+	return v, err
+}
+
+func (s *instrumentedStore) DeleteArtist(id bson.ObjectId) error {
+	start := time.Now()
+	err := s.Store.DeleteArtist(id)
+	s.observe("DeleteArtist", start, err)
+	return err
+}
+
+func (s *instrumentedStore) Release(id bson.ObjectId) (*models.Release, error) {
+	start := time.Now()
+	v, err := s.Store.Release(id)
+	s.observe("Release", start, err)
+	return v, err
+}
+
+func (s *instrumentedStore) ReleaseByName(
+	albumArtistID, name string,
+) (*models.Release, error) {
+	start := time.Now()
+	v, err := s.Store.ReleaseByName(albumArtistID, name)
+	s.observe("ReleaseByName", start, err)
+	return v, err
+}
+
+func (s *instrumentedStore) ReleaseByMBID(
+	mbid string,
+) (*models.Release, error) {
+	start := time.Now()
+	v, err := s.Store.ReleaseByMBID(mbid)
+	s.observe("ReleaseByMBID", start, err)
+	return v, err
+}
+
+func (s *instrumentedStore) SearchReleases(
+	query string,
+) ([]models.Release, error) {
+	start := time.Now()
+	v, err := s.Store.SearchReleases(query)
+	s.observe("SearchReleases", start, err)
+	return v, err
+}
+
+func (s *instrumentedStore) ReleasesByArtist(
+	albumArtistID string,
+) ([]models.Release, error) {
+	start := time.Now()
+	v, err := s.Store.ReleasesByArtist(albumArtistID)
+	s.observe("ReleasesByArtist", start, err)
+	return v, err
+}
+
+func (s *instrumentedStore) InsertRelease(rel *models.Release) error {
+	start := time.Now()
+	err := s.Store.InsertRelease(rel)
+	s.observe("InsertRelease", start, err)
+	return err
+}
+
+func (s *instrumentedStore) UpsertRelease(rel *models.Release) error {
+	start := time.Now()
+	err := s.Store.UpsertRelease(rel)
+	s.observe("UpsertRelease", start, err)
+	return err
+}
+
+func (s *instrumentedStore) UpdateRelease(rel *models.Release) error {
+	start := time.Now()
+	err := s.Store.UpdateRelease(rel)
+	s.observe("UpdateRelease", start, err)
+	return err
+}
+
+func (s *instrumentedStore) ReleasesToEnrich(
+	staleBefore time.Time, limit int,
+) ([]models.Release, error) {
+	start := time.Now()
+	v, err := s.Store.ReleasesToEnrich(staleBefore, limit)
+	s.observe("ReleasesToEnrich", start, err)
+	return v, err
+}
+
+func (s *instrumentedStore) Releases() ([]models.Release, error) {
+	start := time.Now()
+	v, err := s.Store.Releases()
+	s.observe("Releases", start, err)
+	return v, err
+}
+
+func (s *instrumentedStore) DeleteRelease(id bson.ObjectId) error {
+	start := time.Now()
+	err := s.Store.DeleteRelease(id)
+	s.observe("DeleteRelease", start, err)
+	return err
+}
+
+func (s *instrumentedStore) Track(id bson.ObjectId) (*models.Track, error) {
+	start := time.Now()
+	v, err := s.Store.Track(id)
+	s.observe("Track", start, err)
+	return v, err
+}
+
+func (s *instrumentedStore) TracksByArtist(
+	artistID string,
+) ([]models.Track, error) {
+	start := time.Now()
+	v, err := s.Store.TracksByArtist(artistID)
+	s.observe("TracksByArtist", start, err)
+	return v, err
+}
+
+func (s *instrumentedStore) InsertTrack(track *models.Track) error {
+	start := time.Now()
+	err := s.Store.InsertTrack(track)
+	s.observe("InsertTrack", start, err)
+	return err
+}
+
+func (s *instrumentedStore) UpdateTrack(track *models.Track) error {
+	start := time.Now()
+	err := s.Store.UpdateTrack(track)
+	s.observe("UpdateTrack", start, err)
+	return err
+}
+
+func (s *instrumentedStore) DeleteTrack(id bson.ObjectId) error {
+	start := time.Now()
+	err := s.Store.DeleteTrack(id)
+	s.observe("DeleteTrack", start, err)
+	return err
+}
+
+func (s *instrumentedStore) InsertStatistic(stat *models.Statistic) error {
+	start := time.Now()
+	err := s.Store.InsertStatistic(stat)
+	s.observe("InsertStatistic", start, err)
+	return err
+}
+
+func (s *instrumentedStore) TopTracks(
+	since time.Time, limit int,
+) ([]store.TrackCount, error) {
+	start := time.Now()
+	v, err := s.Store.TopTracks(since, limit)
+	s.observe("TopTracks", start, err)
+	return v, err
+}
+
+func (s *instrumentedStore) ReassignStatistics(
+	fromTrackID, toTrackID string,
+) error {
+	start := time.Now()
+	err := s.Store.ReassignStatistics(fromTrackID, toTrackID)
+	s.observe("ReassignStatistics", start, err)
+	return err
+}
+
+func (s *instrumentedStore) Job(id bson.ObjectId) (*models.Job, error) {
+	start := time.Now()
+	v, err := s.Store.Job(id)
+	s.observe("Job", start, err)
+	return v, err
+}
+
+func (s *instrumentedStore) Jobs(
+	states ...models.JobState,
+) ([]models.Job, error) {
+	start := time.Now()
+	v, err := s.Store.Jobs(states...)
+	s.observe("Jobs", start, err)
+	return v, err
+}
+
+func (s *instrumentedStore) InsertJob(job *models.Job) error {
+	start := time.Now()
+	err := s.Store.InsertJob(job)
+	s.observe("InsertJob", start, err)
+	return err
+}
+
+func (s *instrumentedStore) UpdateJob(job *models.Job) error {
+	start := time.Now()
+	err := s.Store.UpdateJob(job)
+	s.observe("UpdateJob", start, err)
+	return err
+}
+
+func (s *instrumentedStore) UserByName(name string) (*models.User, error) {
+	start := time.Now()
+	v, err := s.Store.UserByName(name)
+	s.observe("UserByName", start, err)
+	return v, err
+}
+
+func (s *instrumentedStore) UserByToken(
+	tokenHash string,
+) (*models.User, error) {
+	start := time.Now()
+	v, err := s.Store.UserByToken(tokenHash)
+	s.observe("UserByToken", start, err)
+	return v, err
+}
+
+func (s *instrumentedStore) InsertUser(user *models.User) error {
+	start := time.Now()
+	err := s.Store.InsertUser(user)
+	s.observe("InsertUser", start, err)
+	return err
+}
+
+func (s *instrumentedStore) Alias(kind, key string) (string, error) {
+	start := time.Now()
+	v, err := s.Store.Alias(kind, key)
+	s.observe("Alias", start, err)
+	return v, err
+}
+
+func (s *instrumentedStore) SetAlias(kind, key, targetID string) error {
+	start := time.Now()
+	err := s.Store.SetAlias(kind, key, targetID)
+	s.observe("SetAlias", start, err)
+	return err
+}
+
+func (s *instrumentedStore) RetargetAliases(kind, fromID, toID string) error {
+	start := time.Now()
+	err := s.Store.RetargetAliases(kind, fromID, toID)
+	s.observe("RetargetAliases", start, err)
+	return err
+}
@@ -0,0 +1,212 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	migrationsColName = "schema_migrations"
+	lockColName       = "schema_migrations_lock"
+	lockID            = "lock"
+	lockTTL           = 10 * time.Minute
+)
+
+var (
+	//ErrLocked is returned when another instance holds the migration lock
+	// for longer than the lock timeout
+	ErrLocked = errors.New("migrations are locked by another instance")
+	//ErrIrreversible is returned when reverting a migration that has no
+	// Down function
+	ErrIrreversible = errors.New("migration can't be reverted")
+)
+
+//Migration changes the database from schema Version-1 to Version
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(*mgo.Database) error
+	//Down reverts Up, it may be nil if the migration is irreversible
+	Down func(*mgo.Database) error
+}
+
+//Status of a migration
+type Status struct {
+	Version   int       `bson:"_id"`
+	Name      string    `bson:"name"`
+	Applied   bool      `bson:"-"`
+	AppliedAt time.Time `bson:"applied_at"`
+}
+
+//Migrator applies and reverts migrations
+type Migrator struct {
+	//LockTimeout is how long to wait for another instance to finish
+	// migrating before giving up
+	LockTimeout time.Duration
+	db          *mgo.Database
+	migrations  []Migration
+	logger      *log.Logger
+}
+
+//New creates a migrator for the migrations defined in this package
+func New(db *mgo.Database, logger *log.Logger) *Migrator {
+	return &Migrator{
+		LockTimeout: 2 * time.Minute,
+		db:          db,
+		migrations:  migrations,
+		logger:      logger,
+	}
+}
+
+//Status of every known migration ordered by version
+func (m *Migrator) Status() ([]Status, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]Status, len(m.migrations))
+	for i, mig := range m.migrations {
+		statuses[i] = Status{Version: mig.Version, Name: mig.Name}
+		if st, ok := applied[mig.Version]; ok {
+			statuses[i].Applied = true
+			statuses[i].AppliedAt = st.AppliedAt
+		}
+	}
+	return statuses, nil
+}
+
+//Up applies all pending migrations in order and returns how many were
+// applied, then makes normalized names unique if their duplicates were merged
+func (m *Migrator) Up() (int, error) {
+	count := 0
+	err := m.withLock(func() error {
+		applied, err := m.applied()
+		if err != nil {
+			return err
+		}
+		for _, mig := range m.migrations {
+			if _, ok := applied[mig.Version]; ok {
+				continue
+			}
+			m.logger.Printf("Applying migration %d: %s", mig.Version, mig.Name)
+			if err := mig.Up(m.db); err != nil {
+				return fmt.Errorf("migration %d %s: %v", mig.Version, mig.Name, err)
+			}
+			err := m.db.C(migrationsColName).Insert(Status{
+				Version:   mig.Version,
+				Name:      mig.Name,
+				AppliedAt: time.Now(),
+			})
+			if err != nil {
+				return err
+			}
+			count++
+			if err := m.refreshLock(); err != nil {
+				return err
+			}
+		}
+		return m.uniqueNames()
+	})
+	return count, err
+}
+
+//Down reverts the last steps applied migrations in reverse order
+func (m *Migrator) Down(steps int) error {
+	return m.withLock(func() error {
+		applied, err := m.applied()
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0 && steps > 0; i-- {
+			mig := m.migrations[i]
+			if _, ok := applied[mig.Version]; !ok {
+				continue
+			}
+			if mig.Down == nil {
+				return fmt.Errorf(
+					"migration %d %s: %v",
+					mig.Version,
+					mig.Name,
+					ErrIrreversible,
+				)
+			}
+			m.logger.Printf("Reverting migration %d: %s", mig.Version, mig.Name)
+			if err := mig.Down(m.db); err != nil {
+				return fmt.Errorf("migration %d %s: %v", mig.Version, mig.Name, err)
+			}
+			if err := m.db.C(migrationsColName).RemoveId(mig.Version); err != nil {
+				return err
+			}
+			steps--
+			if err := m.refreshLock(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (m *Migrator) applied() (map[int]Status, error) {
+	var statuses []Status
+	if err := m.db.C(migrationsColName).Find(nil).All(&statuses); err != nil {
+		return nil, err
+	}
+	applied := make(map[int]Status, len(statuses))
+	for _, st := range statuses {
+		applied[st.Version] = st
+	}
+	return applied, nil
+}
+
+//withLock runs fn while holding the migration lock, locks left behind by
+// crashed instances expire after lockTTL
+func (m *Migrator) withLock(fn func() error) error {
+	host, _ := os.Hostname()
+	owner := fmt.Sprintf("%s-%d-%s", host, os.Getpid(), bson.NewObjectId().Hex())
+	col := m.db.C(lockColName)
+	deadline := time.Now().Add(m.LockTimeout)
+	for {
+		_, err := col.RemoveAll(bson.M{
+			"_id":        lockID,
+			"expires_at": bson.M{"$lt": time.Now()},
+		})
+		if err != nil {
+			return err
+		}
+		err = col.Insert(bson.M{
+			"_id":        lockID,
+			"owner":      owner,
+			"expires_at": time.Now().Add(lockTTL),
+		})
+		if err == nil {
+			break
+		}
+		if !mgo.IsDup(err) {
+			return err
+		}
+		if time.Now().After(deadline) {
+			return ErrLocked
+		}
+		m.logger.Println("Waiting for migration lock held by another instance")
+		time.Sleep(time.Second)
+	}
+	defer func() {
+		if err := col.Remove(bson.M{"_id": lockID, "owner": owner}); err != nil {
+			m.logger.Printf("Could not release migration lock: %v", err)
+		}
+	}()
+	return fn()
+}
+
+func (m *Migrator) refreshLock() error {
+	return m.db.C(lockColName).UpdateId(
+		lockID,
+		bson.M{"$set": bson.M{"expires_at": time.Now().Add(lockTTL)}},
+	)
+}
@@ -0,0 +1,241 @@
+package migrate
+
+import (
+	"github.com/waelbendhia/music-streaming/wms/store"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+//migrations in the order they are applied, versions must be increasing and
+// released migrations must never be edited, add a new one instead
+var migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "create_indexes",
+		Up:      createIndexes,
+		Down:    dropIndexes,
+	}, {
+		Version: 2,
+		Name:    "ordered_track_listings",
+		Up:      orderTrackListings,
+	}, {
+		Version: 3,
+		Name:    "track_artist_object_ids",
+		Up:      trackArtistIDsUp,
+		Down:    trackArtistIDsDown,
+	}, {
+		Version: 4,
+		Name:    "job_state_index",
+		Up: func(db *mgo.Database) error {
+			return db.C("job").EnsureIndex(jobStateIndex)
+		},
+		Down: func(db *mgo.Database) error {
+			err := db.C("job").DropIndex(jobStateIndex.Key...)
+			if err != nil && !isNotFound(err) {
+				return err
+			}
+			return nil
+		},
+	}, {
+		Version: normalizedNamesVersion,
+		Name:    "normalized_names",
+		Up:      normalizedNamesUp,
+		Down:    normalizedNamesDown,
+	}, {
+		Version: 6,
+		Name:    "alias_index",
+		Up: func(db *mgo.Database) error {
+			return db.C("alias").EnsureIndex(aliasIndex)
+		},
+		Down: func(db *mgo.Database) error {
+			err := db.C("alias").DropIndex(aliasIndex.Key...)
+			if err != nil && !isNotFound(err) {
+				return err
+			}
+			return nil
+		},
+	}, {
+		Version: 7,
+		Name:    "user_indexes",
+		Up: func(db *mgo.Database) error {
+			for _, index := range userIndexes {
+				if err := db.C("user").EnsureIndex(index); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(db *mgo.Database) error {
+			for _, index := range userIndexes {
+				err := db.C("user").DropIndex(index.Key...)
+				if err != nil && !isNotFound(err) {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+}
+
+var userIndexes = []mgo.Index{
+	{Key: []string{"name"}, Unique: true},
+	{Key: []string{"token_hash"}, Unique: true},
+}
+
+var aliasIndex = mgo.Index{Key: []string{"kind", "key"}, Unique: true}
+
+var jobStateIndex = mgo.Index{Key: []string{"state", "created_at"}}
+
+var indexes = map[string]mgo.Index{
+	"artist":  {Key: []string{"name"}, Unique: true},
+	"release": {Key: []string{"name", "album_artist_id"}, Unique: true},
+	"track":   {Key: []string{"track_url"}},
+}
+
+func createIndexes(db *mgo.Database) error {
+	for col, index := range indexes {
+		if err := db.C(col).EnsureIndex(index); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func dropIndexes(db *mgo.Database) error {
+	for col, index := range indexes {
+		err := db.C(col).DropIndex(index.Key...)
+		if err != nil && !isNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+//trackArtistIDsUp replaces the integer artist_id of tracks, which could never
+// reference an artist, by the hex ID of their release's album artist
+func trackArtistIDsUp(db *mgo.Database) error {
+	var (
+		rel struct {
+			AlbumArtistID string   `bson:"album_artist_id"`
+			TrackIDs      []string `bson:"track_ids"`
+		}
+		iter = db.
+			C("release").
+			Find(bson.M{"album_artist_id": bson.M{"$type": 2, "$ne": ""}}).
+			Iter()
+	)
+	for iter.Next(&rel) {
+		var ids []bson.ObjectId
+		for _, id := range rel.TrackIDs {
+			if bson.IsObjectIdHex(id) {
+				ids = append(ids, bson.ObjectIdHex(id))
+			}
+		}
+		_, err := db.C("track").UpdateAll(
+			bson.M{
+				"_id":       bson.M{"$in": ids},
+				"artist_id": bson.M{"$not": bson.M{"$type": 2}},
+			},
+			bson.M{"$set": bson.M{"artist_id": rel.AlbumArtistID}},
+		)
+		if err != nil {
+			return err
+		}
+		rel.TrackIDs = nil
+	}
+	if err := iter.Close(); err != nil {
+		return err
+	}
+	_, err := db.C("track").UpdateAll(
+		bson.M{"artist_id": bson.M{"$not": bson.M{"$type": 2}}},
+		bson.M{"$set": bson.M{"artist_id": ""}},
+	)
+	return err
+}
+
+func trackArtistIDsDown(db *mgo.Database) error {
+	_, err := db.C("track").UpdateAll(
+		bson.M{"artist_id": bson.M{"$type": 2}},
+		bson.M{"$set": bson.M{"artist_id": 0}},
+	)
+	return err
+}
+
+var normalizedIndexes = []struct {
+	col   string
+	index mgo.Index
+}{
+	{"artist", mgo.Index{Key: []string{"norm_name"}, Unique: true}},
+	{"artist", mgo.Index{Key: []string{"mbid"}}},
+	{
+		"release",
+		mgo.Index{Key: []string{"album_artist_id", "norm_name"}, Unique: true},
+	},
+	{"release", mgo.Index{Key: []string{"mbid"}}},
+}
+
+//normalizedNamesUp stores the normalized name of artists and releases, which
+// upserts identify them by, and indexes it. Names that collide once
+// normalized, like "AC/DC" and "AC DC", get a non-unique index so startup
+// isn't blocked, uniqueNames reports them and makes the index unique once
+// they are merged
+func normalizedNamesUp(db *mgo.Database) error {
+	for _, col := range []string{"artist", "release"} {
+		var (
+			doc struct {
+				ID   bson.ObjectId `bson:"_id"`
+				Name string        `bson:"name"`
+			}
+			iter = db.C(col).Find(nil).Select(bson.M{"name": 1}).Iter()
+		)
+		for iter.Next(&doc) {
+			err := db.C(col).UpdateId(
+				doc.ID,
+				bson.M{"$set": bson.M{"norm_name": store.NormalizeName(doc.Name)}},
+			)
+			if err != nil {
+				return err
+			}
+		}
+		if err := iter.Close(); err != nil {
+			return err
+		}
+	}
+	for _, idx := range normalizedIndexes {
+		err := db.C(idx.col).EnsureIndex(idx.index)
+		if mgo.IsDup(err) {
+			fallback := idx.index
+			fallback.Unique = false
+			err = db.C(idx.col).EnsureIndex(fallback)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func normalizedNamesDown(db *mgo.Database) error {
+	for _, idx := range normalizedIndexes {
+		err := db.C(idx.col).DropIndex(idx.index.Key...)
+		if err != nil && !isNotFound(err) {
+			return err
+		}
+	}
+	for _, col := range []string{"artist", "release"} {
+		_, err := db.C(col).UpdateAll(
+			nil,
+			bson.M{"$unset": bson.M{"norm_name": ""}},
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isNotFound(err error) bool {
+	queryErr, ok := err.(*mgo.QueryError)
+	// 27 is IndexNotFound
+	return err == mgo.ErrNotFound || ok && queryErr.Code == 27
+}
@@ -0,0 +1,61 @@
+package migrate
+
+import (
+	"sort"
+	"strconv"
+
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+//orderTrackListings converts releases stored with the old map[int]string
+// track listing into ordered track ID lists and numbers their tracks
+// accordingly on disc 1
+func orderTrackListings(db *mgo.Database) error {
+	var (
+		doc struct {
+			ID       bson.ObjectId     `bson:"_id"`
+			TrackIDs map[string]string `bson:"track_ids"`
+		}
+		// Embedded documents have BSON type 3, arrays type 4
+		iter = db.
+			C("release").
+			Find(bson.M{"track_ids": bson.M{"$type": 3}}).
+			Iter()
+	)
+	for iter.Next(&doc) {
+		keys := make([]int, 0, len(doc.TrackIDs))
+		for k := range doc.TrackIDs {
+			if i, err := strconv.Atoi(k); err == nil {
+				keys = append(keys, i)
+			}
+		}
+		sort.Ints(keys)
+		trackIDs := make([]string, len(keys))
+		for i, k := range keys {
+			trackIDs[i] = doc.TrackIDs[strconv.Itoa(k)]
+			if !bson.IsObjectIdHex(trackIDs[i]) {
+				continue
+			}
+			err := db.C("track").Update(
+				bson.M{
+					"_id":          bson.ObjectIdHex(trackIDs[i]),
+					"track_number": bson.M{"$in": []interface{}{nil, 0}},
+				},
+				bson.M{"$set": bson.M{"disc_number": 1, "track_number": i + 1}},
+			)
+			if err != nil && err != mgo.ErrNotFound {
+				return err
+			}
+		}
+		err := db.C("release").UpdateId(
+			doc.ID,
+			bson.M{"$set": bson.M{"track_ids": trackIDs}},
+		)
+		if err != nil {
+			return err
+		}
+		doc.TrackIDs = nil
+	}
+	return iter.Close()
+}
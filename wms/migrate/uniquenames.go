@@ -0,0 +1,107 @@
+package migrate
+
+import (
+	"strings"
+
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const normalizedNamesVersion = 5
+
+//uniqueNames makes the normalized name indexes that normalizedNamesUp left
+// non-unique unique once no names collide, and logs the names that still do
+// so they can be merged
+func (m *Migrator) uniqueNames() error {
+	applied, err := m.applied()
+	if err != nil {
+		return err
+	}
+	if _, ok := applied[normalizedNamesVersion]; !ok {
+		return nil
+	}
+	for _, idx := range normalizedIndexes {
+		if !idx.index.Unique {
+			continue
+		}
+		col := m.db.C(idx.col)
+		unique, err := hasUniqueIndex(col, idx.index.Key)
+		if err != nil {
+			return err
+		}
+		if unique {
+			continue
+		}
+		collisions, err := nameCollisions(col, idx.index.Key)
+		if err != nil {
+			return err
+		}
+		for _, names := range collisions {
+			m.logger.Printf(
+				"%ss '%s' have the same normalized name, merge them to "+
+					"make names unique",
+				idx.col,
+				strings.Join(names, "', '"),
+			)
+		}
+		if len(collisions) > 0 {
+			continue
+		}
+		m.logger.Printf("Making %s names unique", idx.col)
+		if err := col.DropIndex(idx.index.Key...); err != nil {
+			return err
+		}
+		err = col.EnsureIndex(idx.index)
+		if mgo.IsDup(err) {
+			// A duplicate was inserted since, try again on next start
+			fallback := idx.index
+			fallback.Unique = false
+			err = col.EnsureIndex(fallback)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func hasUniqueIndex(col *mgo.Collection, key []string) (bool, error) {
+	indexes, err := col.Indexes()
+	if err != nil {
+		return false, err
+	}
+	for _, index := range indexes {
+		if index.Unique &&
+			strings.Join(index.Key, ",") == strings.Join(key, ",") {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+//nameCollisions returns the names of the documents in col that share key
+func nameCollisions(col *mgo.Collection, key []string) ([][]string, error) {
+	group := bson.M{}
+	for _, field := range key {
+		group[field] = "$" + field
+	}
+	var res []struct {
+		Names []string `bson:"names"`
+	}
+	err := col.Pipe([]bson.M{
+		{"$group": bson.M{
+			"_id":   group,
+			"names": bson.M{"$push": "$name"},
+			"count": bson.M{"$sum": 1},
+		}},
+		{"$match": bson.M{"count": bson.M{"$gt": 1}}},
+	}).All(&res)
+	if err != nil {
+		return nil, err
+	}
+	collisions := make([][]string, len(res))
+	for i, r := range res {
+		collisions[i] = r.Names
+	}
+	return collisions, nil
+}
@@ -0,0 +1,103 @@
+package models
+
+import (
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+//JobState is the state of a download job
+type JobState string
+
+//Job states
+const (
+	JobQueued      JobState = "queued"
+	JobDownloading JobState = "downloading"
+	JobComplete    JobState = "complete"
+	JobFailed      JobState = "failed"
+	JobCancelled   JobState = "cancelled"
+	//JobEvicted jobs were complete until their files were deleted to free
+	// disk space
+	JobEvicted JobState = "evicted"
+)
+
+//Done returns true if a job in state will not change anymore
+func (state JobState) Done() bool {
+	return state == JobComplete ||
+		state == JobFailed ||
+		state == JobCancelled ||
+		state == JobEvicted
+}
+
+//Job is the download of a release's torrent
+type Job struct {
+	ID        bson.ObjectId `json:"id" bson:"_id"`
+	ReleaseID string        `json:"releaseID,omitempty" bson:"release_id"`
+	Name      string        `json:"name" bson:"name"`
+	InfoHash  string        `json:"infoHash,omitempty" bson:"info_hash"`
+	Magnet    string        `json:"magnet,omitempty" bson:"magnet"`
+	State     JobState      `json:"state" bson:"state"`
+	Priority  int           `json:"priority" bson:"priority"`
+	User      string        `json:"user,omitempty" bson:"user"`
+	Error     string        `json:"error,omitempty" bson:"error"`
+	Limits    JobLimits     `json:"limits" bson:"limits"`
+	//Size is the number of bytes of the files downloaded, estimated from the
+	// whole torrent until its info is received
+	Size int64 `json:"size" bson:"size"`
+	//Dir is the directory of the torrent's files in the download directory
+	Dir string `json:"-" bson:"dir"`
+	//Candidates are the torrents that may hold the release, best ranked
+	// first, the job moves on to the next one if its torrent fails
+	Candidates []Candidate `json:"candidates,omitempty" bson:"candidates"`
+	//MetaInfo is the .torrent file the job was added with, if any, its
+	// torrent is added from it instead of Magnet
+	MetaInfo []byte `json:"-" bson:"metainfo"`
+	//Attempts are the torrents the job abandoned, oldest first
+	Attempts []Attempt `json:"attempts,omitempty" bson:"attempts"`
+	//Files downloaded from the torrent, known once its info is received
+	Files     []JobFile `json:"files,omitempty" bson:"files"`
+	CreatedAt time.Time `json:"createdAt" bson:"created_at"`
+	UpdatedAt time.Time `json:"updatedAt" bson:"updated_at"`
+}
+
+//JobLimits of a job's torrent on top of the torrent client's policy, 0 keeps
+// the policy's
+type JobLimits struct {
+	//DownloadRate and UploadRate in KiB/s
+	DownloadRate int64 `json:"downloadRate" bson:"download_rate"`
+	UploadRate   int64 `json:"uploadRate" bson:"upload_rate"`
+	MaxConns     int   `json:"maxConns" bson:"max_conns"`
+}
+
+//Candidate is a torrent found for a job's release
+type Candidate struct {
+	Name    string `json:"name" bson:"name"`
+	Magnet  string `json:"magnet" bson:"magnet"`
+	Size    int64  `json:"size" bson:"size"`
+	Seeders int    `json:"seeders" bson:"seeders"`
+}
+
+//Attempt is a torrent a job abandoned
+type Attempt struct {
+	Name   string `json:"name" bson:"name"`
+	Magnet string `json:"magnet" bson:"magnet"`
+	//Reason the torrent was abandoned
+	Reason string    `json:"reason" bson:"reason"`
+	At     time.Time `json:"at" bson:"at"`
+}
+
+//JobFile is a file a job downloads, a track's or an extra like cover art or
+// a cue sheet
+type JobFile struct {
+	//Path of the file relative to the download directory
+	Path string `json:"path" bson:"path"`
+	//TrackID of the track the file holds, empty for extras and until the
+	// release is detected
+	TrackID string `json:"trackID,omitempty" bson:"track_id"`
+	//Extra is true for files that hold no track
+	Extra bool  `json:"extra,omitempty" bson:"extra"`
+	Size  int64 `json:"size" bson:"size"`
+	//Completed is the number of bytes downloaded and verified
+	Completed int64 `json:"completed" bson:"completed"`
+	Complete  bool  `json:"complete" bson:"complete"`
+}
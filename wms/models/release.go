@@ -1,103 +1,80 @@
 package models
 
 import (
-	"fmt"
+	"sort"
 	"time"
 
-	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 )
 
-const relColName = "release"
+//ReleaseType is the kind of a release
+type ReleaseType string
 
-//Release represents an artist/band/person
-type Release struct {
-	ID            bson.ObjectId  `json:"id,omitempty" bson:"_id"`
-	ReleaseDate   time.Time      `json:"releaseDate,omitempty" bson:"release_date"`
-	Name          string         `json:"name,omitempty" bson:"name"`
-	AlbumArtistID string         `json:"-" bson:"album_artist_id"`
-	AlbumArtist   *Artist        `json:"artist,omitempty" bson:"-"`
-	CoverURL      string         `json:"coverURL,omitempty" bson:"cover_url"`
-	TrackIDs      map[int]string `json:"-" bson:"track_ids"`
-	Tracks        []Track        `json:"tracks,omitempty" bson:"-"`
-}
+//Release types
+const (
+	Album       ReleaseType = "album"
+	EP          ReleaseType = "ep"
+	Single      ReleaseType = "single"
+	Compilation ReleaseType = "compilation"
+	Live        ReleaseType = "live"
+)
 
-//Get rel by ID or Name from db
-func (rel *Release) Get(db *mgo.Database) (bool, error) {
-	finder := bson.M{"_id": rel.ID}
-	if rel.ID == "" {
-		finder = bson.M{"name": rel.Name, "album_artist_id": rel.AlbumArtistID}
-	}
-	return notFoundOrErr(db.C(relColName).Find(finder).One(rel))
+//Disc of a multi-disc release
+type Disc struct {
+	Number   int    `json:"number" bson:"number"`
+	Subtitle string `json:"subtitle,omitempty" bson:"subtitle"`
 }
 
-//GetFull rel by ID from db
-func (rel *Release) GetFull(db *mgo.Database) (bool, error) {
-	found, err := rel.Get(db)
-	if !found || err != nil {
-		return found, err
-	}
-	rel.AlbumArtist = &Artist{ID: bson.ObjectId(rel.AlbumArtistID)}
-	found, err = rel.AlbumArtist.Get(db)
-	if !found || err != nil {
-		return found, err
-	}
-	var quErr error
-	for _, trcID := range rel.TrackIDs {
-		track := Track{ID: bson.ObjectId(trcID)}
-		found, quErr = track.Get(db)
-		if !found {
-			quErr = fmt.Errorf("Track with ID: '%s' not found", string(track.ID))
-		}
-		if quErr != nil {
-			break
-		}
-	}
-	return true, quErr
+//Release represents an artist/band/person
+type Release struct {
+	ID            bson.ObjectId `json:"id,omitempty" bson:"_id"`
+	MBID          string        `json:"mbid,omitempty" bson:"mbid"`
+	GroupMBID     string        `json:"groupMBID,omitempty" bson:"group_mbid"`
+	ReleaseDate   time.Time     `json:"releaseDate,omitempty" bson:"release_date"`
+	Name          string        `json:"name,omitempty" bson:"name"`
+	NormName      string        `json:"-" bson:"norm_name"`
+	Type          ReleaseType   `json:"type,omitempty" bson:"type"`
+	Edition       string        `json:"edition,omitempty" bson:"edition"`
+	Label         string        `json:"label,omitempty" bson:"label"`
+	CatalogNumber string        `json:"catalogNumber,omitempty" bson:"catalog_number"`
+	AlbumArtistID string        `json:"-" bson:"album_artist_id"`
+	AlbumArtist   *Artist       `json:"artist,omitempty" bson:"-"`
+	CoverURL      string        `json:"coverURL,omitempty" bson:"cover_url"`
+	Cover         string        `json:"-" bson:"cover"`
+	Genres        []string      `json:"genres,omitempty" bson:"genres"`
+	Discs         []Disc        `json:"discs,omitempty" bson:"discs"`
+	TrackIDs      []string      `json:"-" bson:"track_ids"`
+	Tracks        []Track       `json:"tracks,omitempty" bson:"-"`
+	LastEnriched  time.Time     `json:"-" bson:"last_enriched"`
 }
 
-//ColCreate creates tables in db
-func (rel *Release) ColCreate(db *mgo.Database) error {
-	return db.
-	C(relColName).
-	EnsureIndex(mgo.Index{
-		Key: []string{"name", "album_artist_id"}, 
-		Unique: true,
+//SortTracks orders rel's tracks by disc then track number, tracks without
+// numbers keep their relative order
+func (rel *Release) SortTracks() {
+	sort.SliceStable(rel.Tracks, func(i, j int) bool {
+		a, b := rel.Tracks[i], rel.Tracks[j]
+		if a.DiscNumber != b.DiscNumber {
+			return a.DiscNumber < b.DiscNumber
+		}
+		return a.TrackNumber < b.TrackNumber
 	})
 }
 
-//Search for releases by artist then name
-func (rel *Release) Search(db *mgo.Database) ([]Release, error) {
-	var (
-		rels []Release
-		err  error
-	)
-	if rel.AlbumArtistID != "" {
-		err = db.C(relColName).Find(bson.M{"album_artist_id": rel.AlbumArtistID}).All(&rels)
-	} else {
-		err = db.C(relColName).Find(bson.M{"name": rel.Name}).All(&rels)
-	}
-	return rels, err
-}
-
-//Save rel to db
-func (rel *Release) Save(db *mgo.Database) error {
-	copy := Release{
-		Name:        rel.Name,
-		AlbumArtist: rel.AlbumArtist,
-	}
-	_, err := copy.Get(db)
-	if err == nil {
-		return nil
-	}
-	if rel.AlbumArtist == nil {
-		return ErrIncompleteEntity
-	}
-	rel.AlbumArtist.Save(db)
-	rel.ID = bson.NewObjectId()
+//NumberTracks gives the tracks that have no position one following the
+// track before them, on disc 1 if the first track has no disc
+func (rel *Release) NumberTracks() {
+	disc, number := 1, 0
 	for i := range rel.Tracks {
-		rel.Tracks[i].Save(db)
-		rel.TrackIDs[i] = rel.Tracks[i].ID.Hex()
+		track := &rel.Tracks[i]
+		if track.DiscNumber == 0 {
+			track.DiscNumber = disc
+		}
+		if track.DiscNumber != disc {
+			disc, number = track.DiscNumber, 0
+		}
+		if track.TrackNumber == 0 {
+			track.TrackNumber = number + 1
+		}
+		number = track.TrackNumber
 	}
-	return db.C(relColName).Insert(rel)
 }
@@ -3,48 +3,21 @@ package models
 import (
 	"time"
 
-	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 )
 
-const trackColName = "track"
-
 //Track represents an artist/band/person
 type Track struct {
-	ID       bson.ObjectId `json:"id,omitempty" bson:"_id"`
-	Name     string        `json:"name,omitempty" bson:"name"`
-	Length   time.Duration `json:"length,omitempty" bson:"length"`
-	TrackURL string        `json:"-" bson:"track_url"`
-	Genre    string        `json:"genre,omitempty" bson:"genre"`
-	ArtistID int           `json:"-" bson:"artist_id"`
-	Artist   *Artist       `json:"artist,omitempty" bson:"-"`
-	Releases []Release     `json:"releases,omitempty" bson:"-"`
-}
-
-//Get track by ID from db
-func (track *Track) Get(db *mgo.Database) (bool, error) {
-	return notFoundOrErr(
-		db.C(trackColName).Find(bson.M{"_id": track.ID}).One(track),
-	)
-}
-
-//Search for tracks by
-func (track *Track) Search(db *mgo.Database) ([]Track, error) {
-	var tracks []Track
-	err := db.
-		C(trackColName).
-		Find(bson.M{"artist_id": track.ArtistID}).
-		One(&tracks)
-	return tracks, err
-}
-
-//ColCreate creates a collection in db with the appropriate indexes
-func (track *Track) ColCreate(db *mgo.Database) error {
-	return db.C(trackColName).EnsureIndex(mgo.Index{Key: []string{"track_url"}})
-}
-
-//Save rel to db
-func (track *Track) Save(db *mgo.Database) error {
-	track.ID = bson.NewObjectId()
-	return db.C(trackColName).Insert(track)
+	ID          bson.ObjectId `json:"id,omitempty" bson:"_id"`
+	MBID        string        `json:"mbid,omitempty" bson:"mbid"`
+	ISRCs       []string      `json:"isrcs,omitempty" bson:"isrcs"`
+	Name        string        `json:"name,omitempty" bson:"name"`
+	DiscNumber  int           `json:"discNumber,omitempty" bson:"disc_number"`
+	TrackNumber int           `json:"trackNumber,omitempty" bson:"track_number"`
+	Length      time.Duration `json:"length,omitempty" bson:"length"`
+	TrackURL    string        `json:"-" bson:"track_url"`
+	Genre       string        `json:"genre,omitempty" bson:"genre"`
+	ArtistID    string        `json:"-" bson:"artist_id"`
+	Artist      *Artist       `json:"artist,omitempty" bson:"-"`
+	Releases    []Release     `json:"releases,omitempty" bson:"-"`
 }
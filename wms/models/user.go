@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+//User of the API, users authenticate with a token of which only a hash is
+// stored
+type User struct {
+	ID        bson.ObjectId `json:"id" bson:"_id"`
+	Name      string        `json:"name" bson:"name"`
+	TokenHash string        `json:"-" bson:"token_hash"`
+	Admin     bool          `json:"admin" bson:"admin"`
+	CreatedAt time.Time     `json:"createdAt" bson:"created_at"`
+}
@@ -1,22 +1,9 @@
 package models
 
 import (
-	"database/sql"
 	"errors"
 )
 
+//ErrIncompleteEntity is returned when an entity is missing a required
+// relation, e.g. a release without an album artist
 var ErrIncompleteEntity = errors.New("incomplete entity")
-
-func notFoundOrErr(err error) (bool, error) {
-	if err == sql.ErrNoRows {
-		return false, nil
-	}
-	return err == nil, err
-}
-
-func errOr(a, b error) error {
-	if a == nil {
-		return b
-	}
-	return a
-}
@@ -0,0 +1,166 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/waelbendhia/music-streaming/wms/dedupe"
+	"github.com/waelbendhia/music-streaming/wms/disk"
+	"github.com/waelbendhia/music-streaming/wms/logging"
+	"github.com/waelbendhia/music-streaming/wms/store"
+	"gopkg.in/mgo.v2/bson"
+)
+
+//mergeRequest is the body of POST /admin/merge
+type mergeRequest struct {
+	Kind       string   `json:"kind"`
+	Survivor   string   `json:"survivor"`
+	Duplicates []string `json:"duplicates"`
+}
+
+func (s *Server) duplicatesHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) error {
+	threshold := dedupe.DefaultThreshold
+	if param := r.URL.Query().Get("threshold"); param != "" {
+		var err error
+		threshold, err = strconv.ParseFloat(param, 64)
+		if err != nil || threshold <= 0 || threshold > 1 {
+			return badRequest("threshold must be in ]0, 1]").
+				withDetails(map[string]string{"param": "threshold"})
+		}
+	}
+	groups, err := dedupe.Find(s.db, threshold)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, 200, groups)
+}
+
+func (s *Server) mergeHandler(w http.ResponseWriter, r *http.Request) error {
+	var req mergeRequest
+	err := json.NewDecoder(io.LimitReader(r.Body, 1048576)).Decode(&req)
+	if err != nil {
+		return badRequest("invalid request body: %v", err)
+	}
+	if len(req.Duplicates) == 0 {
+		return badRequest("no duplicates to merge")
+	}
+	ids := append([]string{req.Survivor}, req.Duplicates...)
+	objIDs := make([]bson.ObjectId, len(ids))
+	for i, id := range ids {
+		if !bson.IsObjectIdHex(id) {
+			return badRequest("invalid ID: %s", id)
+		}
+		objIDs[i] = bson.ObjectIdHex(id)
+	}
+	switch req.Kind {
+	case store.ArtistAlias:
+		err = dedupe.MergeArtists(s.db, objIDs[0], objIDs[1:])
+	case store.ReleaseAlias:
+		err = dedupe.MergeReleases(s.db, objIDs[0], objIDs[1:])
+	default:
+		return badRequest("kind must be 'artist' or 'release'")
+	}
+	if err == store.ErrNotFound {
+		return notFound("survivor or duplicate not found")
+	}
+	if err != nil {
+		return err
+	}
+	s.requestLog(r, "dedupe").Info(
+		"Merged duplicates",
+		"kind", req.Kind,
+		"duplicates", strings.Join(req.Duplicates, ","),
+		"survivor", req.Survivor,
+	)
+	w.WriteHeader(204)
+	return nil
+}
+
+//logLevels is the body of GET and PUT /admin/log/levels, the default level
+// is that of subsystems without their own
+type logLevels struct {
+	Default    *logging.Level           `json:"default,omitempty"`
+	Subsystems map[string]logging.Level `json:"subsystems,omitempty"`
+}
+
+func (s *Server) logLevelsHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) error {
+	def, levels := s.log.Levels()
+	return writeJSON(w, 200, logLevels{Default: &def, Subsystems: levels})
+}
+
+//setLogLevelsHandler changes the levels in the body, the others are left
+// as they are
+func (s *Server) setLogLevelsHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) error {
+	var req logLevels
+	err := json.NewDecoder(io.LimitReader(r.Body, 1048576)).Decode(&req)
+	if err != nil {
+		return badRequest("invalid request body: %v", err)
+	}
+	if _, ok := req.Subsystems[""]; ok {
+		return badRequest("subsystem names can't be empty")
+	}
+	if req.Default != nil {
+		s.log.SetLevel("", *req.Default)
+	}
+	for subsystem, level := range req.Subsystems {
+		s.log.SetLevel(subsystem, level)
+	}
+	s.requestLog(r, "logging").Info("Changed log levels")
+	return s.logLevelsHandler(w, r)
+}
+
+func (s *Server) diskUsageHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) error {
+	usage, err := s.disk.Usage()
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, 200, usage)
+}
+
+//evictResult is the body of POST /admin/disk/evict
+type evictResult struct {
+	DryRun   bool                `json:"dryRun"`
+	Bytes    int64               `json:"bytes"`
+	Releases []disk.ReleaseUsage `json:"releases"`
+}
+
+//evictHandler evicts the least played releases until the download
+// directory is within its quota and leaves enough free space, or only lists
+// them if the dryRun parameter is true
+func (s *Server) evictHandler(w http.ResponseWriter, r *http.Request) error {
+	var dryRun bool
+	if param := r.URL.Query().Get("dryRun"); param != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(param); err != nil {
+			return badRequest("dryRun must be a boolean").
+				withDetails(map[string]string{"param": "dryRun"})
+		}
+	}
+	evicted, err := s.evict(s.requestLog(r, "disk"), 0, dryRun)
+	if err != nil {
+		return err
+	}
+	res := evictResult{DryRun: dryRun, Releases: evicted}
+	if res.Releases == nil {
+		res.Releases = []disk.ReleaseUsage{}
+	}
+	for _, rel := range evicted {
+		res.Bytes += rel.Bytes
+	}
+	return writeJSON(w, 200, res)
+}
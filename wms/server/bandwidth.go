@@ -0,0 +1,153 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/waelbendhia/music-streaming/wms/config"
+	"github.com/waelbendhia/music-streaming/wms/models"
+	"github.com/waelbendhia/music-streaming/wms/store"
+	"github.com/waelbendhia/music-streaming/wms/torrent"
+	"gopkg.in/mgo.v2/bson"
+)
+
+//torrentPolicy is the body of GET and PUT /admin/torrent/policy, rates are
+// in KiB/s like in the configuration
+type torrentPolicy struct {
+	DownloadRate int64           `json:"downloadRate"`
+	UploadRate   int64           `json:"uploadRate"`
+	MaxConns     int             `json:"maxConns"`
+	Seed         bool            `json:"seed"`
+	SeedRatio    float64         `json:"seedRatio"`
+	SeedTime     config.Duration `json:"seedTime"`
+	QuietHours   struct {
+		Start        torrent.Clock `json:"start"`
+		End          torrent.Clock `json:"end"`
+		DownloadRate int64         `json:"downloadRate"`
+		UploadRate   int64         `json:"uploadRate"`
+		//Active is true if the quiet hours' limits are in effect, it is
+		// ignored by PUT
+		Active bool `json:"active"`
+	} `json:"quietHours"`
+}
+
+func fromPolicy(p torrent.Policy) torrentPolicy {
+	var body torrentPolicy
+	body.DownloadRate = p.DownloadRate / 1024
+	body.UploadRate = p.UploadRate / 1024
+	body.MaxConns = p.MaxConns
+	body.Seed = p.Seed
+	body.SeedRatio = p.SeedRatio
+	body.SeedTime = config.Duration{Duration: p.SeedTime}
+	body.QuietHours.Start = p.QuietHours.Start
+	body.QuietHours.End = p.QuietHours.End
+	body.QuietHours.DownloadRate = p.QuietHours.DownloadRate / 1024
+	body.QuietHours.UploadRate = p.QuietHours.UploadRate / 1024
+	body.QuietHours.Active = p.QuietHours.Enabled() &&
+		p.QuietHours.Contains(time.Now())
+	return body
+}
+
+func (body torrentPolicy) policy() torrent.Policy {
+	return torrent.Policy{
+		DownloadRate: body.DownloadRate * 1024,
+		UploadRate:   body.UploadRate * 1024,
+		MaxConns:     body.MaxConns,
+		Seed:         body.Seed,
+		SeedRatio:    body.SeedRatio,
+		SeedTime:     body.SeedTime.Duration,
+		QuietHours: torrent.QuietHours{
+			Start:        body.QuietHours.Start,
+			End:          body.QuietHours.End,
+			DownloadRate: body.QuietHours.DownloadRate * 1024,
+			UploadRate:   body.QuietHours.UploadRate * 1024,
+		},
+	}
+}
+
+//jobLimits returns the limits of a job's torrent
+func jobLimits(limits models.JobLimits) torrent.Limits {
+	return torrent.Limits{
+		DownloadRate: limits.DownloadRate * 1024,
+		UploadRate:   limits.UploadRate * 1024,
+		MaxConns:     limits.MaxConns,
+	}
+}
+
+func (s *Server) torrentPolicyHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) error {
+	return writeJSON(w, 200, fromPolicy(s.torrentCli.Policy()))
+}
+
+//setTorrentPolicyHandler changes the settings in the body, the others are
+// left as they are. Changes last until the server restarts.
+func (s *Server) setTorrentPolicyHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) error {
+	body := fromPolicy(s.torrentCli.Policy())
+	err := json.NewDecoder(io.LimitReader(r.Body, 1048576)).Decode(&body)
+	if err != nil {
+		return badRequest("invalid request body: %v", err)
+	}
+	if err := s.torrentCli.SetPolicy(body.policy()); err != nil {
+		return badRequest(err.Error())
+	}
+	s.requestLog(r, "torrent").Info(
+		"Changed torrent policy",
+		"download_rate", body.DownloadRate,
+		"upload_rate", body.UploadRate,
+		"max_conns", body.MaxConns,
+	)
+	return s.torrentPolicyHandler(w, r)
+}
+
+//setJobLimitsHandler changes the limits in the body of a download's
+// torrent, the others are left as they are
+func (s *Server) setJobLimitsHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) error {
+	id := mux.Vars(r)["id"]
+	if !bson.IsObjectIdHex(id) {
+		return badRequest("invalid ID: %s", id)
+	}
+	job, err := s.db.Job(bson.ObjectIdHex(id))
+	if err == store.ErrNotFound {
+		return notFound("download not found")
+	}
+	if err != nil {
+		return err
+	}
+	limits := job.Limits
+	err = json.NewDecoder(io.LimitReader(r.Body, 1048576)).Decode(&limits)
+	if err != nil {
+		return badRequest("invalid request body: %v", err)
+	}
+	if limits.DownloadRate < 0 || limits.UploadRate < 0 || limits.MaxConns < 0 {
+		return badRequest("limits must not be negative")
+	}
+	// Jobs that aren't in the client get their limits when they are resumed
+	err = s.torrentCli.SetLimits(job.InfoHash, jobLimits(limits))
+	if err != nil && err != torrent.ErrTorrentNotFound {
+		return err
+	}
+	job.Limits = limits
+	job.UpdatedAt = time.Now()
+	if err := s.db.UpdateJob(job); err != nil {
+		return err
+	}
+	s.requestLog(r, "downloads").Info(
+		"Changed download limits",
+		"job", job.ID.Hex(),
+		"download_rate", limits.DownloadRate,
+		"upload_rate", limits.UploadRate,
+		"max_conns", limits.MaxConns,
+	)
+	return writeJSON(w, 200, job)
+}
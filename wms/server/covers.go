@@ -0,0 +1,300 @@
+package server
+
+import (
+	"context"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/waelbendhia/music-streaming/wms/cover"
+	"github.com/waelbendhia/music-streaming/wms/logging"
+	"github.com/waelbendhia/music-streaming/wms/models"
+	"github.com/waelbendhia/music-streaming/wms/store"
+	"github.com/waelbendhia/music-streaming/wms/tags"
+	"gopkg.in/mgo.v2/bson"
+)
+
+//Sources of the cover served for a release
+const (
+	coverFromRelease     = "release"
+	coverFromArtist      = "artist"
+	coverFromPlaceholder = "placeholder"
+)
+
+//coverNames are the names, without extension, of the cover art files
+// downloaded with releases, the most likely front cover first
+var coverNames = []string{"cover", "folder", "front"}
+
+func (s *Server) initCovers(cfg cover.Config) error {
+	cfg.HTTPClient = &http.Client{
+		Transport: s.metrics.Transport("covers", nil),
+	}
+	covers, err := cover.NewStore(cfg)
+	if err != nil {
+		s.log.Subsystem("covers").Error(
+			"Could not create cover store",
+			"err", err,
+		)
+		return err
+	}
+	s.covers = covers
+	return nil
+}
+
+func (s *Server) releaseCoverHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) error {
+	id := mux.Vars(r)["id"]
+	if !bson.IsObjectIdHex(id) {
+		return badRequest("invalid ID: %s", id)
+	}
+	rel, err := s.db.Release(bson.ObjectIdHex(id))
+	if err == store.ErrNotFound {
+		return notFound("release not found")
+	}
+	if err != nil {
+		return err
+	}
+	size := 0
+	if raw := r.URL.Query().Get("size"); raw != "" {
+		size, err = strconv.Atoi(raw)
+		if err != nil || size < 1 {
+			return badRequest(
+				"size must be a positive integer",
+			).withDetails(map[string]string{"param": "size"})
+		}
+	}
+	format := cover.JPEG
+	if acceptsWebP(r) {
+		format = cover.WebP
+	}
+	variant, source, err := s.releaseCover(r, rel, size, format)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(variant.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	header := w.Header()
+	header.Set("Content-Type", variant.ContentType)
+	header.Set("ETag", variant.ETag)
+	header.Set("Vary", "Accept")
+	header.Set("X-Cover-Source", source)
+	if source == coverFromRelease {
+		header.Set("Cache-Control", "public, max-age=86400")
+	} else {
+		// Fallbacks are replaced once the release's cover is stored
+		header.Set("Cache-Control", "public, no-cache")
+	}
+	http.ServeContent(w, r, "", info.ModTime(), f)
+	return nil
+}
+
+//releaseCover returns the variant of rel's cover, fetched from its URL the
+// first time, or else of its album artist's image or of a placeholder, and
+// where it comes from
+func (s *Server) releaseCover(
+	r *http.Request,
+	rel *models.Release,
+	size int,
+	format string,
+) (*cover.Variant, string, error) {
+	logger := s.requestLog(r, "covers").With("release", rel.ID.Hex())
+	hash := s.storedImage(
+		r.Context(),
+		logger,
+		rel.Cover,
+		rel.CoverURL,
+		func(hash string) error { return s.setReleaseCover(rel.ID, hash) },
+	)
+	if hash != "" {
+		variant, err := s.covers.Variant(hash, size, format)
+		if err == nil {
+			return variant, coverFromRelease, nil
+		}
+		logger.Warn("Could not resize cover", "cover", hash, "err", err)
+	}
+	if bson.IsObjectIdHex(rel.AlbumArtistID) {
+		artist, err := s.db.Artist(bson.ObjectIdHex(rel.AlbumArtistID))
+		if err != nil && err != store.ErrNotFound {
+			return nil, "", err
+		}
+		if err == nil {
+			hash := s.storedImage(
+				r.Context(),
+				logger,
+				artist.Image,
+				artist.ImageURL,
+				func(hash string) error {
+					return s.setArtistImage(artist.ID, hash)
+				},
+			)
+			if hash != "" {
+				variant, err := s.covers.Variant(hash, size, format)
+				if err == nil {
+					return variant, coverFromArtist, nil
+				}
+				logger.Warn(
+					"Could not resize artist image",
+					"image", hash,
+					"err", err,
+				)
+			}
+		}
+	}
+	variant, err := s.covers.Placeholder(rel.Name, size, format)
+	return variant, coverFromPlaceholder, err
+}
+
+//storedImage returns hash if its image is stored, or else fetches the image
+// at url, stores it and records its hash with set. It returns an empty
+// string if there is no image.
+func (s *Server) storedImage(
+	ctx context.Context,
+	logger *logging.Logger,
+	hash, url string,
+	set func(hash string) error,
+) string {
+	if hash != "" && s.covers.Has(hash) {
+		return hash
+	}
+	if url == "" {
+		return ""
+	}
+	ctx, cancel := context.WithTimeout(
+		ctx,
+		s.cfg.Covers.FetchTimeout.Duration,
+	)
+	defer cancel()
+	hash, err := s.covers.Fetch(ctx, url)
+	if err != nil {
+		// Failures are returned again for a while, at every request
+		logger.Debug("Could not fetch image", "url", url, "err", err)
+		return ""
+	}
+	if err := set(hash); err != nil {
+		logger.Error("Could not record image", "url", url, "err", err)
+	}
+	return hash
+}
+
+//setReleaseCover sets the cover of the release with the given ID to the
+// image with the given hash
+func (s *Server) setReleaseCover(id bson.ObjectId, hash string) error {
+	rel, err := s.db.Release(id)
+	if err != nil {
+		return err
+	}
+	if rel.Cover == hash {
+		return nil
+	}
+	rel.Cover = hash
+	return s.db.UpdateRelease(rel)
+}
+
+//setArtistImage sets the image of the artist with the given ID to the image
+// with the given hash
+func (s *Server) setArtistImage(id bson.ObjectId, hash string) error {
+	artist, err := s.db.Artist(id)
+	if err != nil {
+		return err
+	}
+	if artist.Image == hash {
+		return nil
+	}
+	artist.Image = hash
+	return s.db.UpdateArtist(artist)
+}
+
+//storeCover stores the cover art downloaded with job as its release's, it
+// replaces the one from the metadata provider. It is taken from a cover file
+// or else from the tags of the first track.
+func (s *Server) storeCover(logger *logging.Logger, job *models.Job) {
+	if !bson.IsObjectIdHex(job.ReleaseID) {
+		return
+	}
+	for _, file := range coverFiles(job.Files) {
+		var (
+			data []byte
+			err  error
+		)
+		if file.Extra {
+			data, err = ioutil.ReadFile(s.downloadPath(file.Path))
+		} else {
+			data, err = tags.Picture(s.downloadPath(file.Path))
+		}
+		if err != nil {
+			if err != tags.ErrNoPicture && err != tags.ErrNoTags {
+				logger.Warn(
+					"Could not read cover",
+					"file", file.Path,
+					"err", err,
+				)
+			}
+			continue
+		}
+		hash, err := s.covers.Put(data)
+		if err != nil {
+			logger.Warn(
+				"Could not store cover",
+				"file", file.Path,
+				"err", err,
+			)
+			continue
+		}
+		err = s.setReleaseCover(bson.ObjectIdHex(job.ReleaseID), hash)
+		if err != nil {
+			logger.Error("Could not record cover", "err", err)
+			return
+		}
+		logger.Info("Stored cover", "file", file.Path, "cover", hash)
+		return
+	}
+}
+
+//coverFiles returns the complete files among files that may hold a cover:
+// the image files named like covers, most likely first, then the first
+// track whose tags may embed one
+func coverFiles(files []models.JobFile) []models.JobFile {
+	var candidates []models.JobFile
+	for _, name := range coverNames {
+		for _, file := range files {
+			base := path.Base(file.Path)
+			ext := path.Ext(base)
+			if file.Extra && file.Complete &&
+				strings.EqualFold(strings.TrimSuffix(base, ext), name) &&
+				strings.HasPrefix(mime.TypeByExtension(ext), "image/") {
+				candidates = append(candidates, file)
+			}
+		}
+	}
+	for _, file := range files {
+		if !file.Extra && file.Complete {
+			return append(candidates, file)
+		}
+	}
+	return candidates
+}
+
+//acceptsWebP returns true if r's client accepts WebP images
+func acceptsWebP(r *http.Request) bool {
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(accepted)
+		if err == nil && mediaType == "image/webp" && params["q"] != "0" {
+			return true
+		}
+	}
+	return false
+}
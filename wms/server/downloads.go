@@ -0,0 +1,534 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/waelbendhia/music-streaming/wms/config"
+	"github.com/waelbendhia/music-streaming/wms/disk"
+	"github.com/waelbendhia/music-streaming/wms/logging"
+	"github.com/waelbendhia/music-streaming/wms/models"
+	"github.com/waelbendhia/music-streaming/wms/store"
+	"github.com/waelbendhia/music-streaming/wms/torrent"
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (s *Server) downloadsHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) error {
+	var states []models.JobState
+	if param := r.URL.Query().Get("state"); param != "" {
+		for _, state := range strings.Split(param, ",") {
+			states = append(states, models.JobState(state))
+		}
+	}
+	jobs, err := s.db.Jobs(states...)
+	if err != nil {
+		return err
+	}
+	if jobs == nil {
+		jobs = []models.Job{}
+	}
+	for i := range jobs {
+		if jobs[i].State == models.JobDownloading {
+			s.fileProgress(&jobs[i])
+		}
+	}
+	return writeJSON(w, 200, jobs)
+}
+
+//maxUploadMemory is how much of an uploaded form is kept in memory, the
+// rest is stored in temporary files
+const maxUploadMemory = 1 << 20
+
+//addDownloadHandler queues the download of the torrent of a magnet link, or
+// of an uploaded .torrent file, holding the release identified by its ID or
+// its artist's and its name, or one detected from the files' tags if none
+// is given
+func (s *Server) addDownloadHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) error {
+	r.Body = http.MaxBytesReader(w, r.Body, torrent.MaxTorrentFileSize<<1)
+	err := r.ParseMultipartForm(maxUploadMemory)
+	if err != nil && err != http.ErrNotMultipart {
+		return badRequest("invalid form: %v", err)
+	}
+	priority, err := priorityParam(r.FormValue("priority"))
+	if err != nil {
+		return err
+	}
+	job := models.Job{
+		Priority:  priority,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	link := r.FormValue("magnet")
+	file, _, err := r.FormFile("torrent")
+	switch {
+	case err == nil && link != "":
+		file.Close()
+		return badRequest("either a magnet link or a torrent file, not both")
+	case err == nil:
+		defer file.Close()
+		tf, err := torrent.ParseTorrentFile(file)
+		if err != nil {
+			return badRequest("invalid torrent file: %v", err).withDetails(
+				map[string]string{"param": "torrent"},
+			)
+		}
+		job.InfoHash, job.Name, job.Size = tf.InfoHash, tf.Name, tf.Length
+		job.MetaInfo = tf.Data
+	case err != http.ErrMissingFile && err != http.ErrNotMultipart:
+		return badRequest("invalid torrent file: %v", err)
+	case link == "":
+		return badRequest("a magnet link or a torrent file is needed")
+	default:
+		if job.InfoHash, job.Name, err = torrent.ParseMagnet(link); err != nil {
+			return badRequest("invalid magnet link: %v", err).withDetails(
+				map[string]string{"param": "magnet"},
+			)
+		}
+		job.Magnet = link
+	}
+	if job.Name == "" {
+		job.Name = job.InfoHash
+	}
+	rel, err := s.targetRelease(r)
+	if err != nil {
+		return err
+	}
+	if rel != nil {
+		job.ReleaseID = rel.ID.Hex()
+	}
+	user, err := s.requestUser(r)
+	if err != nil {
+		return err
+	}
+	if user != nil {
+		job.User = user.Name
+	}
+	if err := s.scheduler.Enqueue(&job); err != nil {
+		return err
+	}
+	s.requestLog(r, "downloads").Info(
+		"Queued download",
+		"job", job.ID.Hex(),
+		"name", job.Name,
+		"release", job.ReleaseID,
+		"user", job.User,
+		"priority", job.Priority,
+	)
+	return writeJSON(w, 202, job)
+}
+
+//targetRelease returns the release a download added by r holds, identified
+// by the releaseID form value or the artist and release ones, or nil if it
+// is to be detected from the tags of its files
+func (s *Server) targetRelease(r *http.Request) (*models.Release, error) {
+	id := r.FormValue("releaseID")
+	artist, name := r.FormValue("artist"), r.FormValue("release")
+	switch {
+	case id != "":
+		if !bson.IsObjectIdHex(id) {
+			return nil, badRequest("invalid release ID: %s", id)
+		}
+		rel, err := s.db.Release(bson.ObjectIdHex(id))
+		if err == store.ErrNotFound {
+			return nil, notFound("release not found")
+		}
+		return rel, err
+	case name != "":
+		rel, err := s.meta.GetRelease(r.Context(), &models.Release{
+			Name:        name,
+			AlbumArtist: &models.Artist{Name: artist},
+		})
+		if err != nil {
+			return nil, upstreamError("metadata provider", err)
+		}
+		return rel, s.db.UpsertRelease(rel)
+	case artist != "":
+		return nil, badRequest("the release's name is needed").withDetails(
+			map[string]string{"param": "release"},
+		)
+	}
+	return nil, nil
+}
+
+//fileProgress sets the bytes completed of job's files from its torrent,
+// they are only stored when a file completes
+func (s *Server) fileProgress(job *models.Job) {
+	progress, err := s.torrentCli.FileProgress(job.InfoHash)
+	if err != nil {
+		return
+	}
+	completed := make(map[string]int64, len(progress))
+	for _, file := range progress {
+		completed[file.Path] = file.Completed
+	}
+	for i := range job.Files {
+		job.Files[i].Completed = completed[job.Files[i].Path]
+	}
+}
+
+//cancelDownloadHandler cancels a download, only the user who requested it or
+// an admin may
+func (s *Server) cancelDownloadHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) error {
+	id := mux.Vars(r)["id"]
+	if !bson.IsObjectIdHex(id) {
+		return badRequest("invalid ID: %s", id)
+	}
+	job, err := s.db.Job(bson.ObjectIdHex(id))
+	if err == store.ErrNotFound {
+		return notFound("download not found")
+	}
+	if err != nil {
+		return err
+	}
+	// Downloads requested anonymously have no owner, anyone may cancel them
+	if job.User != "" {
+		if err := s.requireOwner(r, job.User); err != nil {
+			return err
+		}
+	}
+	if job.State.Done() {
+		return conflict("download is already %s", job.State)
+	}
+	s.torrentCli.DropTorrent(job.InfoHash)
+	job.State = models.JobCancelled
+	job.UpdatedAt = time.Now()
+	if err := s.db.UpdateJob(job); err != nil {
+		return err
+	}
+	s.scheduler.Wake()
+	s.requestLog(r, "downloads").Info(
+		"Cancelled download",
+		"job", job.ID.Hex(),
+		"name", job.Name,
+	)
+	w.WriteHeader(204)
+	return nil
+}
+
+//startJob starts downloading job's torrent, holding rel or, if rel is nil,
+// a release detected from the tags of its files, if there is room for it.
+// Otherwise job is queued, or failed if downloads that don't fit are
+// refused, and an error for which isNoSpace is true returned.
+func (s *Server) startJob(
+	logger *logging.Logger,
+	job *models.Job,
+	rel *models.Release,
+) error {
+	err := s.admit(logger, job, func() error {
+		hash, err := s.addTorrent(job)
+		if err != nil {
+			return err
+		}
+		job.InfoHash = hash
+		err = s.torrentCli.SetLimits(job.InfoHash, jobLimits(job.Limits))
+		if err != nil {
+			return err
+		}
+		job.State = models.JobDownloading
+		job.Error = ""
+		job.UpdatedAt = time.Now()
+		return s.db.UpdateJob(job)
+	})
+	if err != nil {
+		return err
+	}
+	s.download(logger, job, rel)
+	return nil
+}
+
+//addTorrent adds job's torrent to the torrent client, from its .torrent
+// file if it has one, and returns its info-hash
+func (s *Server) addTorrent(job *models.Job) (string, error) {
+	if len(job.MetaInfo) > 0 {
+		return s.torrentCli.AddTorrentFile(job.MetaInfo)
+	}
+	return s.torrentCli.AddLink(job.Magnet)
+}
+
+//admit calls commit if job fits on disk, evicting releases first if it
+// doesn't and that is allowed. If it still doesn't fit its torrent is
+// dropped and it is queued, or failed, with the reason as its error.
+func (s *Server) admit(
+	logger *logging.Logger,
+	job *models.Job,
+	commit func() error,
+) error {
+	err := s.disk.Admit(job, commit)
+	if isNoSpace(err) && s.cfg.Disk.AutoEvict {
+		evicted, evictErr := s.evict(logger, job.Size, false)
+		if evictErr != nil {
+			logger.Error("Could not evict releases", "err", evictErr)
+		}
+		if len(evicted) > 0 {
+			err = s.disk.Admit(job, commit)
+		}
+	}
+	if !isNoSpace(err) {
+		return err
+	}
+	s.torrentCli.DropTorrent(job.InfoHash)
+	job.State = models.JobQueued
+	if s.cfg.Disk.WhenFull == config.RefuseWhenFull {
+		job.State = models.JobFailed
+	}
+	job.Error = err.Error()
+	job.UpdatedAt = time.Now()
+	if updateErr := s.db.UpdateJob(job); updateErr != nil {
+		return updateErr
+	}
+	return err
+}
+
+//isNoSpace returns true if err means a download doesn't fit on disk
+func isNoSpace(err error) bool {
+	return err == disk.ErrQuotaExceeded || err == disk.ErrNoSpace
+}
+
+//evict the least played releases to make room for size more bytes, or
+// only return them if dryRun is true
+func (s *Server) evict(
+	logger *logging.Logger,
+	size int64,
+	dryRun bool,
+) ([]disk.ReleaseUsage, error) {
+	plan, err := s.disk.Plan(size)
+	if err != nil || dryRun {
+		return plan, err
+	}
+	for i, rel := range plan {
+		for _, job := range rel.Jobs {
+			s.torrentCli.DropTorrent(job.InfoHash)
+			s.unlinkTracks(logger, job)
+		}
+		if err := s.disk.Evict(rel); err != nil {
+			return plan[:i], err
+		}
+		logger.Info(
+			"Evicted release",
+			"release", rel.ReleaseID,
+			"name", rel.Release,
+			"bytes", rel.Bytes,
+			"plays", rel.Plays,
+		)
+	}
+	return plan, nil
+}
+
+//startDownloads queues the downloads that were interrupted and starts the
+// scheduler, which resumes the queued ones
+func (s *Server) startDownloads() error {
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	s.disk = disk.NewManager(s.cfg.DiskConfig(), s.db)
+	if err := s.queueUnfinished(); err != nil {
+		return err
+	}
+	cfg := s.cfg.SchedulerConfig()
+	cfg.Start = s.startQueued
+	cfg.Stalled = s.fallback
+	cfg.Logger = s.log.Subsystem("downloads").Std(logging.Warn)
+	s.scheduler = torrent.NewScheduler(cfg, s.torrentCli, s.db)
+	s.tasks.Add(1)
+	go func() {
+		defer s.tasks.Done()
+		s.scheduler.Run(s.ctx)
+	}()
+	return nil
+}
+
+//startQueued starts a queued job for the scheduler. Jobs that don't fit on
+// disk are left queued, or failed, and those that can't be resumed failed.
+func (s *Server) startQueued(job *models.Job) error {
+	logger := s.log.Subsystem("downloads").With(
+		"job", job.ID.Hex(),
+		"name", job.Name,
+	)
+	if job.Magnet == "" && len(job.MetaInfo) == 0 {
+		return s.failJob(logger, job, "no torrent to download")
+	}
+	if len(job.MetaInfo) == 0 {
+		if _, _, err := torrent.ParseMagnet(job.Magnet); err != nil {
+			s.fallback(job, "invalid magnet link: "+err.Error())
+			return nil
+		}
+	}
+	var rel *models.Release
+	if job.ReleaseID != "" {
+		if !bson.IsObjectIdHex(job.ReleaseID) {
+			return s.failJob(logger, job, "release not found")
+		}
+		var err error
+		rel, err = store.FullRelease(s.db, bson.ObjectIdHex(job.ReleaseID))
+		if err == store.ErrNotFound {
+			return s.failJob(logger, job, "release not found")
+		}
+		if err != nil {
+			return err
+		}
+	}
+	err := s.startJob(logger, job, rel)
+	if isNoSpace(err) {
+		logger.Debug("Download waiting for space", "reason", err)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	logger.Info("Started download", "user", job.User, "priority", job.Priority)
+	return nil
+}
+
+//failJob marks job failed because of reason
+func (s *Server) failJob(
+	logger *logging.Logger,
+	job *models.Job,
+	reason string,
+) error {
+	job.State = models.JobFailed
+	job.Error = reason
+	job.UpdatedAt = time.Now()
+	if err := s.db.UpdateJob(job); err != nil {
+		return err
+	}
+	logger.Warn("Download failed", "reason", reason)
+	return nil
+}
+
+//abandon job's torrent with the given info-hash because of reason, unless
+// it was dropped in the meantime, and move the job on to its next candidate
+func (s *Server) abandon(job *models.Job, hash, reason string) {
+	if !s.torrentCli.Has(hash) {
+		return
+	}
+	s.fallback(job, reason)
+}
+
+//fallback drops job's torrent, which failed because of reason, and moves
+// the job on to its next candidate torrent with seeders, or fails it if
+// there is none left. Every torrent given up is recorded as an attempt.
+func (s *Server) fallback(job *models.Job, reason string) {
+	logger := s.log.Subsystem("downloads").With(
+		"job", job.ID.Hex(),
+		"name", job.Name,
+	)
+	s.torrentCli.DropTorrent(job.InfoHash)
+	s.unlinkTracks(logger, *job)
+	if err := s.disk.Discard(*job); err != nil {
+		logger.Warn("Could not delete abandoned torrent's files", "err", err)
+	}
+	defer s.scheduler.Wake()
+	logger.Warn("Abandoned torrent", "reason", reason)
+	now := time.Now()
+	job.Attempts = append(job.Attempts, models.Attempt{
+		Name:   job.Name,
+		Magnet: job.Magnet,
+		Reason: reason,
+		At:     now,
+	})
+	next, skipped, found := nextCandidate(job)
+	for _, candidate := range skipped {
+		job.Attempts = append(job.Attempts, models.Attempt{
+			Name:   candidate.Name,
+			Magnet: candidate.Magnet,
+			Reason: "no seeders",
+			At:     now,
+		})
+	}
+	if !found {
+		if len(job.Candidates) > 0 {
+			reason = "no torrent left, the last one was abandoned: " + reason
+		}
+		if err := s.failJob(logger, job, reason); err != nil {
+			logger.Error("Could not fail download", "err", err)
+		}
+		return
+	}
+	logger.Info("Trying next torrent", "next", next.Name)
+	job.Name, job.Magnet, job.Size = next.Name, next.Magnet, next.Size
+	job.InfoHash, _, _ = torrent.ParseMagnet(next.Magnet)
+	job.Dir, job.Files = "", nil
+	job.State = models.JobQueued
+	job.Error = ""
+	job.UpdatedAt = now
+	if err := s.db.UpdateJob(job); err != nil {
+		logger.Error("Could not queue download", "err", err)
+	}
+}
+
+//nextCandidate returns the first candidate with seeders ranked after job's
+// torrent, if there is one, and those without seeders skipped to find it
+func nextCandidate(
+	job *models.Job,
+) (next models.Candidate, skipped []models.Candidate, found bool) {
+	current := -1
+	for i, candidate := range job.Candidates {
+		if candidate.Magnet == job.Magnet {
+			current = i
+			break
+		}
+	}
+	if current < 0 {
+		return next, nil, false
+	}
+	for _, candidate := range job.Candidates[current+1:] {
+		if candidate.Seeders > 0 {
+			return candidate, skipped, true
+		}
+		skipped = append(skipped, candidate)
+	}
+	return next, skipped, false
+}
+
+//queueUnfinished queues the jobs left downloading so the scheduler resumes
+// them
+func (s *Server) queueUnfinished() error {
+	jobs, err := s.db.Jobs(models.JobDownloading)
+	if err != nil {
+		return err
+	}
+	for i := range jobs {
+		jobs[i].State = models.JobQueued
+		jobs[i].UpdatedAt = time.Now()
+		if err := s.db.UpdateJob(&jobs[i]); err != nil {
+			return err
+		}
+	}
+	s.log.Subsystem("downloads").Info(
+		"Queued unfinished downloads",
+		"count", len(jobs),
+	)
+	return nil
+}
+
+//stopDownloads stops the scheduler and the background downloads, waiting
+// for them until ctx is done, and queues the unfinished ones so they are
+// resumed on the next start
+func (s *Server) stopDownloads(ctx context.Context) error {
+	s.cancel()
+	stopped := make(chan struct{})
+	go func() {
+		s.tasks.Wait()
+		close(stopped)
+	}()
+	var waitErr error
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		waitErr = ctx.Err()
+	}
+	if err := s.queueUnfinished(); err != nil {
+		return err
+	}
+	return waitErr
+}
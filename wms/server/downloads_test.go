@@ -0,0 +1,124 @@
+package server
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/waelbendhia/music-streaming/wms/logging"
+	"github.com/waelbendhia/music-streaming/wms/models"
+	"github.com/waelbendhia/music-streaming/wms/store"
+	"github.com/waelbendhia/music-streaming/wms/torrent"
+)
+
+//testAdminToken is the admin token of the servers cancelling downloads
+const testAdminToken = "0123456789abcdef"
+
+//cancelDownload has s cancel the download of the job with the given ID for
+// a request with token and returns its status
+func cancelDownload(s *Server, id, token string) int {
+	router := mux.NewRouter()
+	router.Handle("/downloads/{id}", s.handle(s.cancelDownloadHandler))
+	r := httptest.NewRequest("DELETE", "/downloads/"+id, nil)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	return w.Code
+}
+
+func TestCancelDownload(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		owner string
+		token string
+		state models.JobState
+		//status of the response, the job is only cancelled and its torrent
+		// dropped if it is 204
+		status int
+	}{
+		{"owner", "alice", "alice-token", models.JobDownloading, 204},
+		{"admin", "alice", "admin-token", models.JobDownloading, 204},
+		{"admin token", "alice", testAdminToken, models.JobDownloading, 204},
+		{"queued", "alice", "alice-token", models.JobQueued, 204},
+		{"anonymous download", "", "", models.JobDownloading, 204},
+		{"other user", "alice", "bob-token", models.JobDownloading, 403},
+		{"anonymous", "alice", "", models.JobDownloading, 403},
+		{"invalid token", "alice", "nobody-token", models.JobDownloading, 403},
+		{"complete", "alice", "alice-token", models.JobComplete, 409},
+		{"cancelled", "alice", "alice-token", models.JobCancelled, 409},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			s, fake := newTestServer(t)
+			s.cfg.Auth.AdminToken = testAdminToken
+			cfg := torrent.SchedulerConfig{
+				Logger: logging.Discard().Std(logging.Info),
+			}
+			s.scheduler = torrent.NewScheduler(cfg, fake, s.db)
+			for _, user := range []models.User{
+				{Name: "alice"},
+				{Name: "bob"},
+				{Name: "admin", Admin: true},
+			} {
+				user.TokenHash = store.HashToken(user.Name + "-token")
+				if err := s.db.InsertUser(&user); err != nil {
+					t.Fatal(err)
+				}
+			}
+			hash, err := fake.AddLink("magnet:?xt=urn:btih:" + testHash)
+			if err != nil {
+				t.Fatal(err)
+			}
+			job := &models.Job{
+				User:      test.owner,
+				InfoHash:  hash,
+				State:     test.state,
+				CreatedAt: time.Now(),
+			}
+			if err := s.db.InsertJob(job); err != nil {
+				t.Fatal(err)
+			}
+			status := cancelDownload(s, job.ID.Hex(), test.token)
+			if status != test.status {
+				t.Fatalf("got status %d, want %d", status, test.status)
+			}
+			stored, err := s.db.Job(job.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			state, added := test.state, true
+			if status == 204 {
+				state, added = models.JobCancelled, false
+			}
+			if stored.State != state || fake.Has(hash) != added {
+				t.Errorf(
+					"job is %s and its torrent added %v, want %s and %v",
+					stored.State, fake.Has(hash), state, added,
+				)
+			}
+		})
+	}
+}
+
+func TestCancelRunningDownload(t *testing.T) {
+	s, fake := newTestServer(t)
+	startDownloads(t, s)
+	rel := insertRelease(t, s)
+	job := startDownload(t, s, fake, rel)
+	if status := cancelDownload(s, job.ID.Hex(), ""); status != 204 {
+		t.Fatalf("got status %d, want 204", status)
+	}
+	// The download only stops once its torrent is dropped, after the job was
+	// read to be cancelled
+	s.cancel()
+	s.tasks.Wait()
+	stored, err := s.db.Job(job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.State != models.JobCancelled {
+		t.Errorf("cancelled download is %s", stored.State)
+	}
+}
@@ -0,0 +1,257 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/waelbendhia/music-streaming/gopirate"
+	"github.com/waelbendhia/music-streaming/wms/dedupe"
+	"github.com/waelbendhia/music-streaming/wms/disk"
+	"github.com/waelbendhia/music-streaming/wms/metadata"
+	"github.com/waelbendhia/music-streaming/wms/store"
+	"github.com/waelbendhia/music-streaming/wms/torrent"
+)
+
+//Codes of API errors
+const (
+	CodeBadRequest   = "bad_request"
+	CodeUnauthorized = "unauthorized"
+	CodeForbidden    = "forbidden"
+	CodeNotFound     = "not_found"
+	CodeConflict     = "conflict"
+	CodeUpstream     = "upstream_error"
+	CodeTimeout      = "timeout"
+	CodeInternal     = "internal_error"
+	CodeNoSpace      = "insufficient_storage"
+	CodeQueueFull    = "queue_full"
+)
+
+//APIError is the body of every error response, it is sent as a JSON
+// problem with the application/problem+json content type
+type APIError struct {
+	Status    int         `json:"status"`
+	Code      string      `json:"code"`
+	Message   string      `json:"message"`
+	Details   interface{} `json:"details,omitempty"`
+	RequestID string      `json:"requestId,omitempty"`
+	//cause is logged but never sent
+	cause error
+}
+
+func (e *APIError) Error() string {
+	if e.cause != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.cause)
+	}
+	return e.Message
+}
+
+//withDetails sets the details of e and returns it
+func (e *APIError) withDetails(details interface{}) *APIError {
+	e.Details = details
+	return e
+}
+
+func newAPIError(
+	status int,
+	code, format string,
+	args ...interface{},
+) *APIError {
+	return &APIError{
+		Status:  status,
+		Code:    code,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+func badRequest(format string, args ...interface{}) *APIError {
+	return newAPIError(400, CodeBadRequest, format, args...)
+}
+
+func notFound(format string, args ...interface{}) *APIError {
+	return newAPIError(404, CodeNotFound, format, args...)
+}
+
+func conflict(format string, args ...interface{}) *APIError {
+	return newAPIError(409, CodeConflict, format, args...)
+}
+
+func unauthorized(format string, args ...interface{}) *APIError {
+	return newAPIError(401, CodeUnauthorized, format, args...)
+}
+
+func forbidden(format string, args ...interface{}) *APIError {
+	return newAPIError(403, CodeForbidden, format, args...)
+}
+
+//upstreamError is returned when a service the server depends on, like the
+// metadata provider or the torrent index, fails
+func upstreamError(service string, err error) *APIError {
+	if apiErr := knownError(err); apiErr != nil {
+		return apiErr
+	}
+	apiErr := newAPIError(502, CodeUpstream, "%s is unavailable", service)
+	apiErr.cause = err
+	return apiErr
+}
+
+//toAPIError returns err as an API error, errors that aren't known are
+// internal errors whose cause isn't disclosed
+func toAPIError(err error) *APIError {
+	if apiErr := knownError(err); apiErr != nil {
+		return apiErr
+	}
+	apiErr := newAPIError(500, CodeInternal, "internal server error")
+	apiErr.cause = err
+	return apiErr
+}
+
+//knownError maps the errors handlers are expected to run into to API
+// errors, it returns nil for any other
+func knownError(err error) *APIError {
+	if apiErr, ok := err.(*APIError); ok {
+		return apiErr
+	}
+	switch err {
+	case gopirate.ErrNoResults:
+		return notFound("no torrent found")
+	case torrent.ErrTorrentNotFound:
+		return notFound("torrent not found")
+	case torrent.ErrQueueFull:
+		return newAPIError(429, CodeQueueFull, err.Error())
+	case torrent.ErrAlreadyQueued:
+		return conflict(err.Error())
+	case metadata.ErrNotFound:
+		return notFound("release not found by the metadata provider")
+	case store.ErrNotFound:
+		return notFound("not found")
+	case store.ErrDuplicate:
+		return conflict("already exists")
+	case dedupe.ErrMergeIntoSelf:
+		return badRequest(err.Error())
+	case disk.ErrQuotaExceeded, disk.ErrNoSpace:
+		return newAPIError(507, CodeNoSpace, err.Error())
+	case context.DeadlineExceeded:
+		return newAPIError(504, CodeTimeout, "request timed out")
+	}
+	return nil
+}
+
+//handlerFunc is an HTTP handler that returns the error to respond with
+// instead of writing it
+type handlerFunc func(w http.ResponseWriter, r *http.Request) error
+
+//handle adapts h to an http.HandlerFunc that writes the error h returns
+func (s *Server) handle(h handlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := h(w, r); err != nil {
+			s.writeError(w, r, err)
+		}
+	}
+}
+
+//writeError responds to r with err as an API error, the causes of server
+// errors are logged
+func (s *Server) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	apiErr := *toAPIError(err)
+	apiErr.RequestID = requestID(r.Context())
+	if apiErr.cause != nil {
+		s.requestLog(r, "http").Error(
+			"Request failed",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", apiErr.Status,
+			"err", apiErr.Error(),
+		)
+	}
+	output, err := json.Marshal(apiErr)
+	if err != nil {
+		http.Error(w, apiErr.Message, apiErr.Status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(apiErr.Status)
+	w.Write(output)
+}
+
+//writeJSON responds with v encoded as JSON
+func writeJSON(w http.ResponseWriter, status int, v interface{}) error {
+	output, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, err = w.Write(output)
+	return err
+}
+
+//recoveryMiddleware turns a panic into an internal error response, if
+// nothing was written yet, and logs it with its stack
+func (s *Server) recoveryMiddleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := &recordingWriter{ResponseWriter: w}
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+			s.requestLog(r, "http").Error(
+				"Recovered from panic",
+				"method", r.Method,
+				"path", r.URL.Path,
+				"panic", fmt.Sprint(v),
+				"stack", string(debug.Stack()),
+			)
+			if !rw.wroteHeader {
+				s.writeError(w, r, newAPIError(
+					500,
+					CodeInternal,
+					"internal server error",
+				))
+			}
+		}()
+		h.ServeHTTP(rw, r)
+	})
+}
+
+//recordingWriter records the status of a response and how many bytes of
+// body were written
+type recordingWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+	status      int
+	bytes       int64
+}
+
+func (w *recordingWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader, w.status = true, status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.wroteHeader, w.status = true, 200
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+//Flush lets streamed responses through
+func (w *recordingWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		if !w.wroteHeader {
+			w.wroteHeader, w.status = true, 200
+		}
+		f.Flush()
+	}
+}
@@ -0,0 +1,533 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/waelbendhia/music-streaming/wms/config"
+	"github.com/waelbendhia/music-streaming/wms/logging"
+	"github.com/waelbendhia/music-streaming/wms/models"
+	"github.com/waelbendhia/music-streaming/wms/store"
+	"github.com/waelbendhia/music-streaming/wms/tags"
+	"github.com/waelbendhia/music-streaming/wms/torrent"
+	"gopkg.in/mgo.v2/bson"
+)
+
+//errNoReleaseTags is returned when none of a download's files is tagged with
+// the release they belong to
+var errNoReleaseTags = errors.New("no file is tagged with its release")
+
+//errJobStopped is returned when a download's job was cancelled, or stopped
+// downloading otherwise, while the download was running
+var errJobStopped = errors.New("download stopped")
+
+//download fetches the files of rel's tracks, and the extras next to them,
+// from job's torrent, which must have been added to the torrent client, in
+// the background until the server stops or they are complete. If rel is nil
+// every audio file is fetched and the release is detected from their tags
+// once they are. Tracks can be streamed as soon as their file is complete.
+// It logs through logger, which carries the ID of the request that started
+// it if there is one. Only the fields the download sets are written back to
+// the stored job, and none once it stopped downloading.
+func (s *Server) download(
+	logger *logging.Logger,
+	job *models.Job,
+	rel *models.Release,
+) {
+	hash := job.InfoHash
+	// The caller may still read its own copy
+	copied := *job
+	job = &copied
+	s.tasks.Add(1)
+	go func() {
+		defer s.tasks.Done()
+		ctx := s.ctx
+		infoTimeout := s.cfg.Torrent.InfoTimeout.Duration
+		if infoTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, infoTimeout)
+			defer cancel()
+		}
+		info, err := s.torrentCli.WaitForInfo(ctx, hash)
+		if err == context.DeadlineExceeded {
+			reason := fmt.Sprintf("no metadata within %v", infoTimeout)
+			s.abandon(job, hash, reason)
+			return
+		}
+		if err != nil {
+			// Cancelled before its info was received or stopping
+			return
+		}
+		audio := audioFiles(info.Files)
+		var tracks map[bson.ObjectId]torrent.File
+		if rel != nil {
+			tracks = matchTracksToFiles(rel.Tracks, audio)
+			if reason := s.mismatch(rel, tracks); reason != "" {
+				s.abandon(job, hash, reason)
+				return
+			}
+		} else if len(audio) == 0 {
+			s.abandon(job, hash, "no audio files")
+			return
+		}
+		job.Files = s.jobFiles(info, audio, tracks)
+		job.Size, job.Dir = 0, info.Name
+		paths := make([]string, len(job.Files))
+		for i, file := range job.Files {
+			job.Size += file.Size
+			paths[i] = file.Path
+		}
+		// Admit the job again now that the size of its files is known
+		err = s.admit(logger, job, func() error {
+			return s.updateJob(job.ID, func(current *models.Job) {
+				current.Files, current.Size = job.Files, job.Size
+				current.Dir = job.Dir
+			})
+		})
+		if err == errJobStopped {
+			return
+		}
+		if err != nil {
+			logger.Warn("Stopped download", "err", err)
+			s.scheduler.Wake()
+			return
+		}
+		// Subscribed first not to miss the files complete already
+		sub, err := s.torrentCli.Subscribe(hash)
+		if err != nil {
+			return
+		}
+		defer sub.Close()
+		if err := s.torrentCli.Download(hash, paths...); err != nil {
+			logger.Warn("Stopped download", "err", err)
+			return
+		}
+		for _, path := range paths {
+			logger.Info("Downloading file", "file", path)
+		}
+		ticker := time.NewTicker(5 * time.Second)
+		defer ticker.Stop()
+		var prev int64
+		for {
+			select {
+			case _, open := <-sub.Events():
+				if !open {
+					// Dropped
+					return
+				}
+			case <-ticker.C:
+				progress, err := s.torrentCli.Progress(hash)
+				if err != nil {
+					return
+				}
+				logger.Debug(
+					"Progress",
+					"completed", progress.Completed,
+					"missing", progress.Missing,
+					"rate_kibps", (progress.Completed-prev)/(5*1024),
+				)
+				prev = progress.Completed
+			case <-s.ctx.Done():
+				return
+			}
+			// Events only hurry the update, the ones dropped are caught up
+			// with on the next tick
+			done, err := s.updateFiles(logger, job)
+			if err == torrent.ErrTorrentNotFound || err == errJobStopped {
+				return
+			}
+			if err != nil {
+				logger.Error("Could not update download", "err", err)
+				continue
+			}
+			if !done {
+				continue
+			}
+			var trackPaths []string
+			for _, file := range job.Files {
+				if !file.Extra {
+					trackPaths = append(trackPaths, s.downloadPath(file.Path))
+				}
+			}
+			if rel == nil {
+				if rel, err = s.detectRelease(trackPaths); err != nil {
+					if s.ctx.Err() == nil {
+						s.abandon(job, hash, err.Error())
+					}
+					return
+				}
+				logger.Info("Detected release", "release", rel.ID.Hex())
+				job.ReleaseID = rel.ID.Hex()
+				tracks = matchTracksToFiles(rel.Tracks, audio)
+				if reason := s.mismatch(rel, tracks); reason != "" {
+					s.abandon(job, hash, reason)
+					return
+				}
+				s.linkTracks(logger, job, tracks)
+			}
+			if s.cfg.Torrent.VerifyTags {
+				if err := verifyTags(rel, trackPaths); err != nil {
+					s.abandon(job, hash, err.Error())
+					return
+				}
+			}
+			err = s.updateJob(job.ID, func(current *models.Job) {
+				current.State = models.JobComplete
+				current.ReleaseID = job.ReleaseID
+				current.Files = job.Files
+			})
+			if err == errJobStopped {
+				return
+			}
+			if err != nil {
+				logger.Error("Could not complete download", "err", err)
+				continue
+			}
+			logger.Info("Completed download")
+			s.storeCover(logger, job)
+			s.scheduler.Wake()
+			return
+		}
+	}()
+}
+
+//jobFiles returns the files a job downloads from a torrent described by
+// info: the audio files matched to tracks, or every one if there are no
+// tracks yet, and the extras in the directory holding them
+func (s *Server) jobFiles(
+	info *torrent.Info,
+	audio []torrent.File,
+	tracks map[bson.ObjectId]torrent.File,
+) []models.JobFile {
+	var files []models.JobFile
+	if tracks == nil {
+		for _, file := range audio {
+			files = append(files, models.JobFile{
+				Path: file.Path,
+				Size: file.Length,
+			})
+		}
+	}
+	for id, file := range tracks {
+		files = append(files, models.JobFile{
+			Path:    file.Path,
+			TrackID: id.Hex(),
+			Size:    file.Length,
+		})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+	dir := ""
+	for i, file := range files {
+		if i == 0 {
+			dir = path.Dir(file.Path)
+			continue
+		}
+		for !strings.HasPrefix(file.Path, dir+"/") && dir != "." {
+			dir = path.Dir(dir)
+		}
+	}
+	selected := make(map[string]bool, len(files))
+	for _, file := range files {
+		selected[file.Path] = true
+	}
+	maxSize := s.cfg.Torrent.MaxExtraSize << 10
+	for _, file := range extraFiles(info.Files, s.cfg.Torrent.Extras) {
+		if selected[file.Path] ||
+			(maxSize > 0 && file.Length > maxSize) ||
+			(dir != "." && !strings.HasPrefix(file.Path, dir+"/")) {
+			continue
+		}
+		files = append(files, models.JobFile{
+			Path:  file.Path,
+			Extra: true,
+			Size:  file.Length,
+		})
+	}
+	return files
+}
+
+//updateFiles updates the progress of job's files and links the tracks of
+// those that completed, it returns true once every file holding a track is
+// complete or errJobStopped if the job stopped downloading
+func (s *Server) updateFiles(
+	logger *logging.Logger,
+	job *models.Job,
+) (bool, error) {
+	progress, err := s.torrentCli.FileProgress(job.InfoHash)
+	if err != nil {
+		return false, err
+	}
+	byPath := make(map[string]torrent.FileProgress, len(progress))
+	for _, file := range progress {
+		byPath[file.Path] = file
+	}
+	var completed []models.JobFile
+	done := true
+	for i := range job.Files {
+		file := &job.Files[i]
+		current, found := byPath[file.Path]
+		if !found {
+			continue
+		}
+		file.Completed = current.Completed
+		if !file.Complete && current.Completed == current.Length {
+			file.Complete = true
+			completed = append(completed, *file)
+			logger.Info("Completed file", "file", file.Path)
+		}
+		done = done && (file.Extra || file.Complete)
+	}
+	if len(completed) == 0 {
+		return done, nil
+	}
+	err = s.updateJob(job.ID, func(current *models.Job) {
+		current.Files = job.Files
+	})
+	if err != nil {
+		return false, err
+	}
+	for _, file := range completed {
+		if file.TrackID != "" {
+			s.linkTrack(logger, job.ReleaseID, file)
+		}
+	}
+	return done, nil
+}
+
+//updateJob has update change the stored job with the given ID, re-read as
+// it may have changed since a download read it, unless the job stopped
+// downloading, in which case errJobStopped is returned
+func (s *Server) updateJob(id bson.ObjectId, update func(*models.Job)) error {
+	job, err := s.db.Job(id)
+	if err != nil {
+		return err
+	}
+	if job.State != models.JobDownloading {
+		return errJobStopped
+	}
+	update(job)
+	job.UpdatedAt = time.Now()
+	return s.db.UpdateJob(job)
+}
+
+//linkTracks sets the tracks of job's files from tracks, the files matched
+// to rel's tracks, and links those of the complete files
+func (s *Server) linkTracks(
+	logger *logging.Logger,
+	job *models.Job,
+	tracks map[bson.ObjectId]torrent.File,
+) {
+	ids := make(map[string]bson.ObjectId, len(tracks))
+	for id, file := range tracks {
+		ids[file.Path] = id
+	}
+	for i := range job.Files {
+		file := &job.Files[i]
+		if id, found := ids[file.Path]; found {
+			file.TrackID = id.Hex()
+			if file.Complete {
+				s.linkTrack(logger, job.ReleaseID, *file)
+			}
+		}
+	}
+}
+
+//linkTrack has the track of file, which must be complete and belong to
+// the release with the given ID, streamed from it through a link named by
+// the naming templates, or from the file itself if it can't be linked
+func (s *Server) linkTrack(
+	logger *logging.Logger,
+	releaseID string,
+	file models.JobFile,
+) {
+	url := s.downloadPath(file.Path)
+	link, err := s.nameTrack(releaseID, file.TrackID, url)
+	if err != nil {
+		logger.Warn("Could not name track", "file", file.Path, "err", err)
+	} else {
+		url = link
+	}
+	if err := s.setTrackURL(file.TrackID, url); err != nil {
+		logger.Error("Could not link track", "file", file.Path, "err", err)
+	}
+}
+
+//nameTrack links the file at path, holding the track with the given ID of
+// the release with the given ID, to the path the naming templates give it
+// and returns that path
+func (s *Server) nameTrack(releaseID, trackID, path string) (string, error) {
+	if !bson.IsObjectIdHex(releaseID) || !bson.IsObjectIdHex(trackID) {
+		return "", store.ErrNotFound
+	}
+	rel, err := s.db.Release(bson.ObjectIdHex(releaseID))
+	if err != nil {
+		return "", err
+	}
+	track, err := s.db.Track(bson.ObjectIdHex(trackID))
+	if err != nil {
+		return "", err
+	}
+	data := config.NameData{
+		Release:     nameReplacer.Replace(rel.Name),
+		Disc:        track.DiscNumber,
+		TrackNumber: track.TrackNumber,
+		Track:       nameReplacer.Replace(track.Name),
+	}
+	if !rel.ReleaseDate.IsZero() {
+		data.Year = rel.ReleaseDate.Year()
+	}
+	if bson.IsObjectIdHex(rel.AlbumArtistID) {
+		artist, err := s.db.Artist(bson.ObjectIdHex(rel.AlbumArtistID))
+		if err != nil {
+			return "", err
+		}
+		data.Artist = nameReplacer.Replace(artist.Name)
+	}
+	name, err := s.cfg.Naming.TrackPath(data)
+	if err != nil {
+		return "", err
+	}
+	dir := s.cfg.Torrent.DownloadDir
+	link := filepath.Join(dir, name+filepath.Ext(path))
+	if rel, err := filepath.Rel(dir, link); err != nil ||
+		rel == ".." ||
+		strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("'%s' is not inside the download directory", link)
+	}
+	if link == path {
+		return link, nil
+	}
+	if info, err := os.Lstat(link); err == nil {
+		// Only links are replaced, by the latest download of the track
+		if info.Mode()&os.ModeSymlink == 0 {
+			return "", fmt.Errorf("'%s' already exists", link)
+		}
+		if err := os.Remove(link); err != nil {
+			return "", err
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(link), 0755); err != nil {
+		return "", err
+	}
+	return link, os.Symlink(path, link)
+}
+
+//nameReplacer replaces the path separators in the names naming templates
+// are executed with, so they don't add directories
+var nameReplacer = strings.NewReplacer("/", "-", `\`, "-")
+
+//unlinkTracks has the tracks streamed from job's files, which are about to
+// be deleted, unavailable
+func (s *Server) unlinkTracks(logger *logging.Logger, job models.Job) {
+	for _, file := range job.Files {
+		if file.TrackID == "" || !file.Complete {
+			continue
+		}
+		if err := s.unlinkTrack(file); err != nil {
+			logger.Error(
+				"Could not unlink track",
+				"file", file.Path,
+				"err", err,
+			)
+		}
+	}
+}
+
+//unlinkTrack has the track of file unavailable if it is streamed from it,
+// directly or through a link, which is removed
+func (s *Server) unlinkTrack(file models.JobFile) error {
+	if !bson.IsObjectIdHex(file.TrackID) {
+		return store.ErrNotFound
+	}
+	track, err := s.db.Track(bson.ObjectIdHex(file.TrackID))
+	if err != nil {
+		return err
+	}
+	path := s.downloadPath(file.Path)
+	if track.TrackURL == "" {
+		return nil
+	}
+	if track.TrackURL != path {
+		target, err := os.Readlink(track.TrackURL)
+		if err != nil || target != path {
+			// Streamed from another file
+			return nil
+		}
+		if err := os.Remove(track.TrackURL); err != nil {
+			return err
+		}
+	}
+	track.TrackURL = ""
+	return s.db.UpdateTrack(track)
+}
+
+//setTrackURL sets the URL of the track with the given ID to url
+func (s *Server) setTrackURL(id, url string) error {
+	if !bson.IsObjectIdHex(id) {
+		return store.ErrNotFound
+	}
+	track, err := s.db.Track(bson.ObjectIdHex(id))
+	if err != nil {
+		return err
+	}
+	if track.TrackURL == url {
+		return nil
+	}
+	track.TrackURL = url
+	return s.db.UpdateTrack(track)
+}
+
+//downloadPath returns the path of the file at path, slash separated and
+// relative to the download directory
+func (s *Server) downloadPath(path string) string {
+	return filepath.Join(s.cfg.Torrent.DownloadDir, filepath.FromSlash(path))
+}
+
+//detectRelease finds the release most of the files at paths are tagged
+// with, storing it if it wasn't yet
+func (s *Server) detectRelease(paths []string) (*models.Release, error) {
+	type key struct{ artist, album string }
+	var (
+		counts = make(map[key]int)
+		best   key
+	)
+	for _, path := range paths {
+		t, err := tags.Read(path)
+		if err != nil || t.Album == "" {
+			continue
+		}
+		k := key{t.AlbumArtist, t.Album}
+		if k.artist == "" {
+			k.artist = t.Artist
+		}
+		counts[k]++
+		if counts[k] > counts[best] {
+			best = k
+		}
+	}
+	if counts[best] == 0 {
+		return nil, errNoReleaseTags
+	}
+	rel, err := s.meta.GetRelease(s.ctx, &models.Release{
+		Name:        best.album,
+		AlbumArtist: &models.Artist{Name: best.artist},
+	})
+	if err != nil {
+		return nil, fmt.Errorf(
+			"could not find release '%s' by '%s': %v",
+			best.album,
+			best.artist,
+			err,
+		)
+	}
+	return rel, s.db.UpsertRelease(rel)
+}
@@ -0,0 +1,359 @@
+package server
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/waelbendhia/music-streaming/wms/config"
+	"github.com/waelbendhia/music-streaming/wms/logging"
+	"github.com/waelbendhia/music-streaming/wms/models"
+	"github.com/waelbendhia/music-streaming/wms/store/memory"
+	"github.com/waelbendhia/music-streaming/wms/torrent"
+)
+
+//testHash is the info-hash of the torrent downloaded by the tests, holding
+// testFiles
+const testHash = "0123456789abcdef0123456789abcdef01234567"
+
+var testFiles = []torrent.File{
+	{Path: "Dummy/01 - Mysterons.flac", Length: 100},
+	{Path: "Dummy/02 - Sour Times.flac", Length: 200},
+}
+
+//newTestServer returns a server storing in memory and downloading with a
+// fake torrent client to a temporary directory, its downloads aren't started
+func newTestServer(t *testing.T) (*Server, *torrent.Fake) {
+	dir, err := ioutil.TempDir("", "wms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	cfg := config.Default()
+	cfg.Torrent.DownloadDir = dir
+	cfg.Torrent.VerifyTags = false
+	cfg.Disk.MinFree = 0
+	fake := torrent.NewFake()
+	s := &Server{
+		cfg:        cfg,
+		log:        logging.Discard(),
+		db:         memory.New(),
+		torrentCli: fake,
+	}
+	return s, fake
+}
+
+//startDownloads starts the downloads of s until t ends
+func startDownloads(t *testing.T, s *Server) {
+	if err := s.startDownloads(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		s.cancel()
+		s.tasks.Wait()
+	})
+}
+
+//insertRelease stores the release held by testFiles
+func insertRelease(t *testing.T, s *Server) *models.Release {
+	rel := &models.Release{
+		Name:        "Dummy",
+		AlbumArtist: &models.Artist{Name: "Portishead"},
+		Tracks:      []models.Track{{Name: "Mysterons"}, {Name: "Sour Times"}},
+	}
+	if err := s.db.UpsertRelease(rel); err != nil {
+		t.Fatal(err)
+	}
+	return rel
+}
+
+//startDownload enqueues the download of rel from the torrent holding
+// testFiles and waits until they are being downloaded
+func startDownload(
+	t *testing.T,
+	s *Server,
+	fake *torrent.Fake,
+	rel *models.Release,
+) *models.Job {
+	job := &models.Job{
+		ReleaseID: rel.ID.Hex(),
+		Name:      rel.Name,
+		InfoHash:  testHash,
+		Magnet:    "magnet:?xt=urn:btih:" + testHash,
+		CreatedAt: time.Now(),
+	}
+	if err := s.scheduler.Enqueue(job); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "torrent", func() bool { return fake.Has(testHash) })
+	err := fake.SetInfo(testHash, torrent.Info{Name: "Dummy", Files: testFiles})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "download", func() bool {
+		paths, _ := fake.Downloading(testHash)
+		return len(paths) == len(testFiles)
+	})
+	return job
+}
+
+//waitFor fails t if cond isn't true within a few seconds
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for i := 0; i < 300; i++ {
+		if cond() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %s", what)
+}
+
+//completed returns the bytes completed of job's files by path
+func completed(job *models.Job) map[string]int64 {
+	completed := make(map[string]int64, len(job.Files))
+	for _, file := range job.Files {
+		completed[file.Path] = file.Completed
+	}
+	return completed
+}
+
+//linked returns the names of rel's tracks streamed from their named link
+func linked(t *testing.T, s *Server, rel *models.Release) []string {
+	var names []string
+	for _, track := range rel.Tracks {
+		stored, err := s.db.Track(track.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored.TrackURL == "" {
+			continue
+		}
+		link, err := s.cfg.Naming.TrackPath(config.NameData{
+			Artist:      "Portishead",
+			Release:     "Dummy",
+			TrackNumber: stored.TrackNumber,
+			Track:       stored.Name,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		link = filepath.Join(s.cfg.Torrent.DownloadDir, link+".flac")
+		if stored.TrackURL != link {
+			t.Errorf(
+				"%s streamed from %s, want %s",
+				track.Name, stored.TrackURL, link,
+			)
+		}
+		names = append(names, track.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestDownload(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		//complete are the files completed once data, the bytes downloaded of
+		// the files by path, is set
+		complete []string
+		data     map[string]int
+		state    models.JobState
+		//completed are the bytes stored as completed of the files by path
+		completed map[string]int64
+		linked    []string
+	}{
+		{
+			name: "complete",
+			complete: []string{
+				"Dummy/01 - Mysterons.flac",
+				"Dummy/02 - Sour Times.flac",
+			},
+			state: models.JobComplete,
+			completed: map[string]int64{
+				"Dummy/01 - Mysterons.flac":  100,
+				"Dummy/02 - Sour Times.flac": 200,
+			},
+			linked: []string{"Mysterons", "Sour Times"},
+		},
+		{
+			name:     "partly complete",
+			complete: []string{"Dummy/01 - Mysterons.flac"},
+			data:     map[string]int{"Dummy/02 - Sour Times.flac": 50},
+			state:    models.JobDownloading,
+			completed: map[string]int64{
+				"Dummy/01 - Mysterons.flac":  100,
+				"Dummy/02 - Sour Times.flac": 50,
+			},
+			linked: []string{"Mysterons"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			s, fake := newTestServer(t)
+			startDownloads(t, s)
+			rel := insertRelease(t, s)
+			job := startDownload(t, s, fake, rel)
+			for path, size := range test.data {
+				err := fake.SetFileData(testHash, path, make([]byte, size))
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, path := range test.complete {
+				if err := fake.CompleteFile(testHash, path); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var stored *models.Job
+			waitFor(t, "files", func() bool {
+				var err error
+				if stored, err = s.db.Job(job.ID); err != nil {
+					t.Fatal(err)
+				}
+				return stored.State == test.state &&
+					reflect.DeepEqual(completed(stored), test.completed)
+			})
+			names := linked(t, s, rel)
+			if !reflect.DeepEqual(names, test.linked) {
+				t.Errorf("linked %v, want %v", names, test.linked)
+			}
+		})
+	}
+}
+
+func TestUpdateFiles(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		//complete are the files completed once data, the bytes downloaded of
+		// the files by path, is set
+		complete []string
+		data     map[string]int
+		//stored is the state of the stored job and limits are set on it after
+		// the download read it
+		stored models.JobState
+		limits models.JobLimits
+		done   bool
+		err    error
+		//completed are the bytes stored as completed of the files by path
+		completed map[string]int64
+		linked    []string
+	}{
+		{
+			name:   "progress",
+			data:   map[string]int{"Dummy/01 - Mysterons.flac": 40},
+			stored: models.JobDownloading,
+			completed: map[string]int64{
+				"Dummy/01 - Mysterons.flac":  0,
+				"Dummy/02 - Sour Times.flac": 0,
+			},
+		},
+		{
+			name:     "file complete",
+			complete: []string{"Dummy/01 - Mysterons.flac"},
+			data:     map[string]int{"Dummy/02 - Sour Times.flac": 50},
+			stored:   models.JobDownloading,
+			limits:   models.JobLimits{DownloadRate: 64},
+			completed: map[string]int64{
+				"Dummy/01 - Mysterons.flac":  100,
+				"Dummy/02 - Sour Times.flac": 50,
+			},
+			linked: []string{"Mysterons"},
+		},
+		{
+			name: "all complete",
+			complete: []string{
+				"Dummy/01 - Mysterons.flac",
+				"Dummy/02 - Sour Times.flac",
+			},
+			stored: models.JobDownloading,
+			done:   true,
+			completed: map[string]int64{
+				"Dummy/01 - Mysterons.flac":  100,
+				"Dummy/02 - Sour Times.flac": 200,
+			},
+			linked: []string{"Mysterons", "Sour Times"},
+		},
+		{
+			name:     "cancelled",
+			complete: []string{"Dummy/01 - Mysterons.flac"},
+			stored:   models.JobCancelled,
+			err:      errJobStopped,
+			completed: map[string]int64{
+				"Dummy/01 - Mysterons.flac":  0,
+				"Dummy/02 - Sour Times.flac": 0,
+			},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			s, fake := newTestServer(t)
+			rel := insertRelease(t, s)
+			_, err := fake.AddLink("magnet:?xt=urn:btih:" + testHash)
+			if err != nil {
+				t.Fatal(err)
+			}
+			info := torrent.Info{Name: "Dummy", Files: testFiles}
+			if err := fake.SetInfo(testHash, info); err != nil {
+				t.Fatal(err)
+			}
+			job := &models.Job{
+				ReleaseID: rel.ID.Hex(),
+				InfoHash:  testHash,
+				State:     models.JobDownloading,
+			}
+			for i, file := range testFiles {
+				job.Files = append(job.Files, models.JobFile{
+					Path:    file.Path,
+					TrackID: rel.Tracks[i].ID.Hex(),
+					Size:    file.Length,
+				})
+			}
+			if err := s.db.InsertJob(job); err != nil {
+				t.Fatal(err)
+			}
+			stored := *job
+			stored.State, stored.Limits = test.stored, test.limits
+			if err := s.db.UpdateJob(&stored); err != nil {
+				t.Fatal(err)
+			}
+			for path, size := range test.data {
+				err := fake.SetFileData(testHash, path, make([]byte, size))
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, path := range test.complete {
+				if err := fake.CompleteFile(testHash, path); err != nil {
+					t.Fatal(err)
+				}
+			}
+			done, err := s.updateFiles(s.log, job)
+			if done != test.done || err != test.err {
+				t.Errorf(
+					"got %v, %v, want %v, %v",
+					done, err, test.done, test.err,
+				)
+			}
+			got, err := s.db.Job(job.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c := completed(got); !reflect.DeepEqual(c, test.completed) {
+				t.Errorf("stored completed %v, want %v", c, test.completed)
+			}
+			if got.State != test.stored || got.Limits != test.limits {
+				t.Errorf(
+					"stored job is %s with %+v, want %s with %+v",
+					got.State, got.Limits, test.stored, test.limits,
+				)
+			}
+			names := linked(t, s, rel)
+			if !reflect.DeepEqual(names, test.linked) {
+				t.Errorf("linked %v, want %v", names, test.linked)
+			}
+		})
+	}
+}
@@ -2,114 +2,136 @@ package server
 
 import (
 	"net/http"
+	"strconv"
 	"time"
 
-	"encoding/json"
-
-	"log"
-
 	"github.com/waelbendhia/music-streaming/gopirate"
 	"github.com/waelbendhia/music-streaming/wms/models"
+	"github.com/waelbendhia/music-streaming/wms/torrent"
 )
 
-func (s *Server) searchAlbumsHandler(w http.ResponseWriter, r *http.Request) {
+func (s *Server) searchAlbumsHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) error {
+	type result struct {
+		rels []models.Release
+		err  error
+	}
 	var (
-		name        = r.URL.Query().Get("name")
-		search      = models.Release{Name: name}
-		finalResult []models.Release
-		intResC     = make(chan []models.Release, 1)
-		lfmResC     = make(chan []models.Release, 1)
-		intErrC     = make(chan error, 1)
-		lfmErrC     = make(chan error, 1)
-		run         = func(
-			resC chan<- []models.Release,
-			errC chan<- error,
-			fn func() ([]models.Release, error),
-		) {
-			res, err := fn()
-			resC <- res
-			errC <- err
-			close(resC)
-			close(errC)
-		}
+		name = r.URL.Query().Get("name")
+		intC = make(chan result, 1)
+		lfmC = make(chan result, 1)
 	)
-	go run(intResC, intErrC, func() ([]models.Release, error) {
-		return search.Search(s.db)
-	})
-	go run(lfmResC, lfmErrC, func() ([]models.Release, error) {
-		return lfmSearchConverter(s.lfmCli.SearchAlbums(name))
-	})
-	wait := func(
-		resC <-chan []models.Release,
-		errC <-chan error,
-	) (res []models.Release, err error) {
-		select {
-		case res = <-resC:
-		case err = <-errC:
-		}
-		return res, err
-	}
-	intRes, intErr := wait(intResC, intErrC)
-	lfmRes, lfmErr := wait(lfmResC, lfmErrC)
-	panicIfErr(intErr)
-	panicIfErr(lfmErr)
-	finalResult = append(intRes, lfmRes...)
-	output, err := json.Marshal(finalResult)
-	panicIfErr(err)
-	w.WriteHeader(200)
-	panicIfErr(w.Write(output))
+	go func() {
+		rels, err := s.db.SearchReleases(name)
+		intC <- result{rels, err}
+	}()
+	go func() {
+		rels, err := s.meta.SearchReleases(r.Context(), name)
+		lfmC <- result{rels, err}
+	}()
+	intRes, lfmRes := <-intC, <-lfmC
+	if intRes.err != nil {
+		return intRes.err
+	}
+	if lfmRes.err != nil {
+		return upstreamError("metadata provider", lfmRes.err)
+	}
+	finalResult := append(intRes.rels, lfmRes.rels...)
+	if finalResult == nil {
+		finalResult = []models.Release{}
+	}
+	return writeJSON(w, 200, finalResult)
 }
 
-func (s *Server) downloadAlbumHandler(w http.ResponseWriter, r *http.Request) {
+//maxCandidates is the number of ranked torrents a download keeps to fall
+// back on
+const maxCandidates = 5
+
+func (s *Server) downloadAlbumHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) error {
 	album := r.Context().Value(requestKey).(*models.Release)
-	fmAlbum, err := s.lfmCli.GetAlbumInfo(album.AlbumArtist.Name, album.Name)
-	panicIfErr(err)
+	priority, err := priorityParam(r.URL.Query().Get("priority"))
+	if err != nil {
+		return err
+	}
+	converted, err := s.meta.GetRelease(r.Context(), album)
+	if err != nil {
+		return upstreamError("metadata provider", err)
+	}
 	searchString := album.Name
 	if album.AlbumArtist != nil {
 		searchString = album.AlbumArtist.Name + " " + album.Name
 	}
 	res, err := gopirate.Search(searchString)
-	if err == gopirate.ErrNoResults {
-		http.Error(w, "no results found", 404)
-		return
+	if err == nil && len(res) == 0 {
+		err = gopirate.ErrNoResults
+	}
+	if err != nil {
+		return upstreamError("torrent search", err)
+	}
+	if err := s.db.UpsertRelease(converted); err != nil {
+		return err
 	}
-	converted := lfmAlbumConverter(&fmAlbum.Album)
-	converted.Save(s.db)
 	res = torrentSort(res, func(tor gopirate.Torrent) int {
 		score := scoreTorrentHealth(tor) + scoreTorrentName(searchString)(tor)
 		return score
 	})
-	s.torrentCli.AddTPBTorrent(res[0])
-	w.WriteHeader(200)
-	output, err := json.Marshal(res)
-	panicIfErr(err)
-	w.WriteHeader(200)
-	panicIfErr(w.Write(output))
-	go func() {
-		_ = <-s.torrentCli.GotInfo(res[0])
-		s.infoLog.Println(s.torrentCli.GetInfo(res[0]).Files)
-		matched := matchTracksToFiles(
-			converted.Tracks,
-			s.torrentCli.GetTorrent(res[0]).Files(),
+	// Unparsable links are left for the scheduler to fail
+	hash, _, _ := torrent.ParseMagnet(res[0].Link)
+	job := models.Job{
+		ReleaseID: converted.ID.Hex(),
+		InfoHash:  hash,
+		Name:      res[0].Name,
+		Magnet:    res[0].Link,
+		Priority:  priority,
+		// The size of the whole torrent until its info tells that of the
+		// files downloaded
+		Size:      res[0].Size,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	for i := 0; i < len(res) && i < maxCandidates; i++ {
+		job.Candidates = append(job.Candidates, models.Candidate{
+			Name:    res[i].Name,
+			Magnet:  res[i].Link,
+			Size:    res[i].Size,
+			Seeders: res[i].Seeders,
+		})
+	}
+	user, err := s.requestUser(r)
+	if err != nil {
+		return err
+	}
+	if user != nil {
+		job.User = user.Name
+	}
+	if err := s.scheduler.Enqueue(&job); err != nil {
+		return err
+	}
+	s.requestLog(r, "downloads").Info(
+		"Queued download",
+		"job", job.ID.Hex(),
+		"name", job.Name,
+		"user", job.User,
+		"priority", job.Priority,
+	)
+	return writeJSON(w, 202, res)
+}
+
+//priorityParam parses the priority of a download, which defaults to 0
+func priorityParam(raw string) (int, error) {
+	if raw == "" {
+		return 0, nil
+	}
+	priority, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, badRequest("priority must be an integer").withDetails(
+			map[string]string{"param": "priority"},
 		)
-		log.Println(converted.Tracks)
-		for _, v := range matched {
-			v.Download()
-			log.Println("Downloading", v.DisplayPath())
-		}
-		log.Println(matched)
-		// panicIfErr(s.torrentCli.StartAll(res[0]))
-		go func() {
-			var prev int64
-			for {
-				s.torrentCli.PrintStatus(res[0], s.infoLog)
-				now := s.torrentCli.GetTorrent(res[0]).BytesCompleted()
-				delta := (now - prev) / (5 * 1024)
-				prev = now
-				s.infoLog.Println("Delta", delta, "kbps")
-				s.torrentCli.GetTorrent(res[0]).BytesCompleted()
-				time.Sleep(5 * time.Second)
-			}
-		}()
-	}()
+	}
+	return priority, nil
 }
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/waelbendhia/music-streaming/wms/disk"
+	"github.com/waelbendhia/music-streaming/wms/health"
+	"github.com/waelbendhia/music-streaming/wms/metadata"
+	"github.com/waelbendhia/music-streaming/wms/store"
+	"github.com/waelbendhia/music-streaming/wms/torrent"
+)
+
+//errNotStarted is the error of the checks of subsystems that aren't running
+var errNotStarted = errors.New("not started")
+
+//probeQuery is searched to check the metadata provider can be reached
+const probeQuery = "health check"
+
+func (s *Server) initHealth() {
+	cfg := s.cfg.Health
+	s.health = health.NewChecker(cfg.Timeout.Duration)
+	s.health.Add("database", s.checkDB)
+	s.health.Add("torrent", s.checkTorrentClient)
+	s.health.Add("metadata", health.Cached(
+		s.checkMetadata,
+		cfg.MetadataInterval.Duration,
+		cfg.Timeout.Duration,
+	))
+	s.health.Add("disk", s.checkDisk)
+}
+
+func (s *Server) checkDB(context.Context) (interface{}, error) {
+	return nil, s.withOpenDB(func(db store.Store) error { return db.Ping() })
+}
+
+func (s *Server) checkTorrentClient(context.Context) (interface{}, error) {
+	var details map[string]interface{}
+	err := s.withTorrentClient(func(cli torrent.Torrents) error {
+		addr := cli.ListenAddr()
+		if addr == nil {
+			return errors.New("not listening")
+		}
+		details = map[string]interface{}{
+			"listenAddr": addr.String(),
+			"torrents":   cli.Stats().Torrents,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return details, nil
+}
+
+//checkMetadata searches the provider, it is only run in the background as
+// the provider may be slow or rate limited
+func (s *Server) checkMetadata(ctx context.Context) (interface{}, error) {
+	if s.meta == nil {
+		return nil, errNotStarted
+	}
+	details := map[string]string{"provider": s.meta.Name()}
+	_, err := s.meta.SearchReleases(ctx, probeQuery)
+	if err == metadata.ErrNotFound {
+		err = nil
+	}
+	return details, err
+}
+
+func (s *Server) checkDisk(context.Context) (interface{}, error) {
+	dir := s.cfg.Torrent.DownloadDir
+	free, err := disk.FreeSpace(dir)
+	if err != nil {
+		return nil, err
+	}
+	min := uint64(s.cfg.Health.MinFreeSpace) << 20
+	details := map[string]interface{}{
+		"path":         dir,
+		"freeBytes":    free,
+		"minFreeBytes": min,
+	}
+	if free < min {
+		return details, fmt.Errorf(
+			"%d MiB free, at least %d MiB required",
+			free>>20,
+			min>>20,
+		)
+	}
+	return details, nil
+}
+
+//healthzHandler answers as long as the server can serve requests
+func (s *Server) healthzHandler(w http.ResponseWriter, r *http.Request) error {
+	return writeJSON(w, 200, map[string]health.Status{"status": health.Up})
+}
+
+//readyzHandler reports the status of every component the server depends
+// on, it answers 503 if any of them is down
+func (s *Server) readyzHandler(w http.ResponseWriter, r *http.Request) error {
+	report := s.health.Run(r.Context())
+	status := 200
+	if report.Status != health.Up {
+		status = 503
+		s.requestLog(r, "health").Warn("Not ready", "components", down(report))
+	}
+	return writeJSON(w, status, report)
+}
+
+//down returns the names of the components that are down, separated by
+// commas
+func down(report health.Report) string {
+	var names []string
+	for name, res := range report.Components {
+		if res.Status != health.Up {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
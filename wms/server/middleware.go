@@ -2,49 +2,53 @@ package server
 
 import (
 	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
 	"encoding/json"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/waelbendhia/music-streaming/wms/logging"
+	"github.com/waelbendhia/music-streaming/wms/models"
+	"github.com/waelbendhia/music-streaming/wms/store"
 )
 
 type key int
 
 const (
 	requestKey key = iota
+	requestIDKey
 )
 
+//requestParsingMiddleware decodes the JSON body of requests into a new
+// value of the type v points to and passes it in the request's context
 func (s *Server) requestParsingMiddleware(v interface{}) middleware {
+	typ := reflect.TypeOf(v).Elem()
 	return func(h http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			body, err := ioutil.ReadAll(io.LimitReader(r.Body, 1048576))
 			if err != nil {
-				http.Error(w, "Error parsing request body", 400)
-				s.errorLog.Printf(
-					"requestParsingMiddleware: error reading from body: %v",
-					err,
-				)
+				s.writeError(w, r, badRequest("could not read request body"))
 				return
 			}
 			if err := r.Body.Close(); err != nil {
-				w.WriteHeader(500)
-				w.Header().Set("Content-Type", "text/plain")
-				s.errorLog.Printf(
-					"requestParsingMiddleware: error in closing body: %v",
-					err,
-				)
+				s.writeError(w, r, err)
 				return
 			}
-			if err := json.Unmarshal(body, v); err != nil {
-				w.WriteHeader(400)
-				s.errorLog.Printf(
-					"requestParsingMiddleware: error unmarshaling from body: %v", 
-					err,
-				)
+			parsed := reflect.New(typ).Interface()
+			if err := json.Unmarshal(body, parsed); err != nil {
+				s.writeError(w, r, badRequest("invalid request body: %v", err))
 				return
 			}
 
-			ctx, ctxCancel := ctxWithValCancel(r.Context(), requestKey, v)
+			ctx, ctxCancel := ctxWithValCancel(r.Context(), requestKey, parsed)
 			defer ctxCancel()
 			// Rewrite body in case it's needed down the line
 			r.Body = ioutil.NopCloser(bytes.NewBuffer(body))
@@ -53,3 +57,138 @@ func (s *Server) requestParsingMiddleware(v interface{}) middleware {
 		})
 	}
 }
+
+//requestIDMiddleware gives every request an ID, the one in its X-Request-ID
+// header if it is sensible, which is sent back in the same header and in
+// error responses
+func (s *Server) requestIDMiddleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !validRequestID.MatchString(id) {
+			id = newRequestID()
+		}
+		w.Header().Set("X-Request-ID", id)
+		ctx, ctxCancel := ctxWithValCancel(r.Context(), requestIDKey, id)
+		defer ctxCancel()
+		ctx = logging.NewContext(ctx, s.log.With("request_id", id))
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+//accessLogMiddleware logs every request once it is served
+func (s *Server) accessLogMiddleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rw := &recordingWriter{ResponseWriter: w, status: 200}
+		h.ServeHTTP(rw, r)
+		latency := time.Since(start)
+		s.requestLog(r, "access").Info(
+			"Served request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", rw.status,
+			"bytes", rw.bytes,
+			"latency_ms", float64(latency)/float64(time.Millisecond),
+			"remote", r.RemoteAddr,
+		)
+	})
+}
+
+//requestLog returns the logger of subsystem for r, its records carry r's ID
+func (s *Server) requestLog(r *http.Request, subsystem string) *logging.Logger {
+	return logging.FromContext(r.Context(), s.log).Subsystem(subsystem)
+}
+
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
+
+//requestID returns the ID of the request ctx belongs to, if any
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+//adminMiddleware only lets requests bearing the configured admin token or
+// the token of an admin user through, the tokens of other users are
+// forbidden
+func (s *Server) adminMiddleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := bearerToken(r)
+		if token == "" {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			s.writeError(w, r, unauthorized("admin token required"))
+			return
+		}
+		if s.isAdminToken(token) {
+			h.ServeHTTP(w, r)
+			return
+		}
+		user, err := s.db.UserByToken(store.HashToken(token))
+		if err == store.ErrNotFound {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			s.writeError(w, r, unauthorized("invalid admin token"))
+			return
+		}
+		if err != nil {
+			s.writeError(w, r, err)
+			return
+		}
+		if !user.Admin {
+			s.writeError(w, r, forbidden("admin rights required"))
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+//requestUser returns the user whose token r bears, or nil
+func (s *Server) requestUser(r *http.Request) (*models.User, error) {
+	token := bearerToken(r)
+	if token == "" {
+		return nil, nil
+	}
+	user, err := s.db.UserByToken(store.HashToken(token))
+	if err == store.ErrNotFound {
+		return nil, nil
+	}
+	return user, err
+}
+
+//requireOwner returns a forbidden error unless r bears the token of owner,
+// the token of an admin user or the admin token
+func (s *Server) requireOwner(r *http.Request, owner string) error {
+	if s.isAdminToken(bearerToken(r)) {
+		return nil
+	}
+	user, err := s.requestUser(r)
+	if err != nil {
+		return err
+	}
+	if user == nil || user.Name != owner && !user.Admin {
+		return forbidden("only %s or an admin may do this", owner)
+	}
+	return nil
+}
+
+//isAdminToken returns whether token is the configured admin token
+func (s *Server) isAdminToken(token string) bool {
+	return s.cfg.Auth.AdminToken != "" && subtle.ConstantTimeCompare(
+		[]byte(token),
+		[]byte(s.cfg.Auth.AdminToken),
+	) == 1
+}
+
+func bearerToken(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+}
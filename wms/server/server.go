@@ -2,17 +2,25 @@ package server
 
 import (
 	"context"
-	"io"
-	"log"
+	"net"
 	"net/http"
 	"runtime/debug"
+	"sync"
 
 	"github.com/gorilla/mux"
-	"github.com/waelbendhia/music-streaming/lastfm"
+	"github.com/waelbendhia/music-streaming/wms/config"
+	"github.com/waelbendhia/music-streaming/wms/cover"
 	"github.com/waelbendhia/music-streaming/wms/db"
+	"github.com/waelbendhia/music-streaming/wms/disk"
+	"github.com/waelbendhia/music-streaming/wms/enrich"
+	"github.com/waelbendhia/music-streaming/wms/health"
+	"github.com/waelbendhia/music-streaming/wms/lifecycle"
+	"github.com/waelbendhia/music-streaming/wms/logging"
+	"github.com/waelbendhia/music-streaming/wms/metadata"
+	"github.com/waelbendhia/music-streaming/wms/metrics"
 	"github.com/waelbendhia/music-streaming/wms/models"
+	"github.com/waelbendhia/music-streaming/wms/store"
 	"github.com/waelbendhia/music-streaming/wms/torrent"
-	"gopkg.in/mgo.v2"
 )
 
 type middleware func(http.Handler) http.Handler
@@ -20,85 +28,165 @@ type middleware func(http.Handler) http.Handler
 //Server is a music-streaming server
 type Server struct {
 	http.Handler
-	server                        *http.Server
-	infoLog, warningLog, errorLog *log.Logger
-	db                            *mgo.Database
-	lfmCli                        *lastfm.Client
-	torrentCli                    *torrent.Client
+	server     *http.Server
+	log        *logging.Logger
+	db         store.Store
+	meta       metadata.Provider
+	torrentCli torrent.Torrents
+	enricher   *enrich.Worker
+	cfg        *config.Config
+	lifecycle  *lifecycle.Manager
+	metrics    *metrics.Metrics
+	health     *health.Checker
+	disk       *disk.Manager
+	scheduler  *torrent.Scheduler
+	covers     *cover.Store
+	//ctx is cancelled to stop the background downloads tracked by tasks
+	ctx    context.Context
+	cancel context.CancelFunc
+	tasks  sync.WaitGroup
+	//dbMu guards dbOpen, it is held for reading while the collectors and
+	// health checks use db so it isn't closed under them
+	dbMu   sync.RWMutex
+	dbOpen bool
+	//torrentMu guards torrentCli for the collectors and health checks, which
+	// keep running after it is closed
+	torrentMu sync.RWMutex
 }
 
-//NewServer creates and initializes a new music streaming server
+//NewServer creates a new music streaming server logging to logger, cfg
+// must be valid. Its subsystems are only started by Start.
 func NewServer(
-	stdOut, stdErr io.Writer,
-	host, dbPath, lastFMApiKey, downDir, listenAddr string,
-) (Server, error) {
-	s := Server{}
-	return s, s.init(
-		stdOut,
-		stdErr,
-		host,
-		dbPath,
-		lastFMApiKey,
-		downDir,
-		listenAddr,
-	)
+	logger *logging.Logger,
+	cfg *config.Config,
+) (*Server, error) {
+	s := &Server{cfg: cfg, log: logger}
+	return s, s.init()
 }
 
-//Start server
+//Start the subsystems then serve the API on listenAddr, the returned
+// channel receives the exit value once the server stops serving: 1 if it
+// couldn't start or failed, 0 if it was stopped
 func (s *Server) Start(listenAddr string) <-chan int {
-	s.server = &http.Server{Addr: listenAddr, Handler: s}
 	doneChan := make(chan int, 1)
-	go func() {
-		s.infoLog.Println("Starting server, listening on address:", s.server.Addr)
-		var exitVal int
-		if err := s.server.ListenAndServe(); err != http.ErrServerClosed {
-			s.errorLog.Printf("Could not start server: %v", err)
-			exitVal = 1
-		}
-		doneChan <- exitVal
+	s.lifecycle.Register(lifecycle.Hook{
+		Name: "HTTP server",
+		Start: func() error {
+			return s.startHTTP(listenAddr, doneChan)
+		},
+		Stop: s.stopHTTP,
+	})
+	if err := s.lifecycle.Start(); err != nil {
+		s.log.Subsystem("lifecycle").Error("Could not start", "err", err)
+		doneChan <- 1
 		close(doneChan)
-	}()
+	}
 	return doneChan
 }
 
-//Stop the server
-func (s *Server) Stop() error {
-	err := s.server.Shutdown(context.TODO())
-	s.server = nil
-	s.closeDB()
-	return err
+//Stop the server: requests in flight and background downloads are given
+// until ctx is done to finish, interrupted downloads are queued to be resumed
+// on the next start, then the torrent client and the database are closed
+func (s *Server) Stop(ctx context.Context) error {
+	return s.lifecycle.Stop(ctx)
 }
 
-func (s *Server) init(
-	stdOut, stdErr io.Writer,
-	host, dbPath, lastFMApiKey, downDir, listenAddr string,
-) error {
-	s.initLogging(stdOut, stdErr)
-	s.initRouting()
-	s.infoLog.Println("Initialzing DB")
-	err := s.initDB(host, dbPath)
-	if err != nil {
+func (s *Server) init() error {
+	if err := s.initMetrics(); err != nil {
 		return err
 	}
-	s.infoLog.Println("Done")
-	s.infoLog.Println("Initializing lastFM client")
-	err = s.initlfmCli(lastFMApiKey)
+	s.initHealth()
+	s.initRouting()
+	s.lifecycle = lifecycle.New(
+		s.log.Subsystem("lifecycle").Std(logging.Info),
+	)
+	// Subsystems are stopped in the reverse order, each one can use those
+	// registered before it until it is stopped
+	s.lifecycle.Register(lifecycle.Hook{
+		Name: "database",
+		Start: func() error {
+			return s.initDB(s.cfg.Storage.URL)
+		},
+		Stop: func(context.Context) error {
+			return s.closeDB()
+		},
+	})
+	s.lifecycle.Register(lifecycle.Hook{
+		Name: "metadata provider",
+		Start: func() error {
+			return s.initMetadata(s.cfg.MetadataConfig())
+		},
+	})
+	s.lifecycle.Register(lifecycle.Hook{
+		Name: "covers",
+		Start: func() error {
+			return s.initCovers(s.cfg.CoversConfig())
+		},
+	})
+	s.lifecycle.Register(lifecycle.Hook{
+		Name: "torrent client",
+		Start: func() error {
+			return s.initTorrentClient(s.cfg.TorrentConfig())
+		},
+		Stop: func(context.Context) error {
+			s.closeTorrentClient()
+			return nil
+		},
+	})
+	s.lifecycle.Register(lifecycle.Hook{
+		Name:  "downloads",
+		Start: s.startDownloads,
+		Stop:  s.stopDownloads,
+	})
+	s.lifecycle.Register(lifecycle.Hook{
+		Name: "enrichment",
+		Start: func() error {
+			s.initEnrichment()
+			return nil
+		},
+		Stop: func(context.Context) error {
+			s.enricher.Stop()
+			return nil
+		},
+	})
+	return nil
+}
+
+func (s *Server) startHTTP(listenAddr string, doneChan chan<- int) error {
+	s.server = &http.Server{Addr: listenAddr, Handler: s}
+	listener, err := net.Listen("tcp", listenAddr)
 	if err != nil {
 		return err
 	}
-	s.infoLog.Println("Done")
-	return s.initTorrentClient(downDir, listenAddr)
+	logger := s.log.Subsystem("http")
+	logger.Info("Listening", "addr", listener.Addr())
+	s.server.ErrorLog = logger.Std(logging.Warn)
+	go func() {
+		var exitVal int
+		if err := s.server.Serve(listener); err != http.ErrServerClosed {
+			logger.Error("Server failed", "err", err)
+			exitVal = 1
+		}
+		doneChan <- exitVal
+		close(doneChan)
+	}()
+	return nil
 }
 
-func (s *Server) initLogging(stdOut, stdErr io.Writer) {
-	s.infoLog = log.New(stdOut, "INFO:", log.Ldate|log.Ltime)
-	s.warningLog = log.New(stdOut, "WARNING:", log.Ldate|log.Ltime)
-	s.errorLog = log.New(stdErr, "ERROR:", log.Ldate|log.Ltime)
+//stopHTTP stops accepting connections and waits for the requests in flight,
+// streams included, until ctx is done
+func (s *Server) stopHTTP(ctx context.Context) error {
+	err := s.server.Shutdown(ctx)
+	if err != nil {
+		// Cut the requests that didn't finish in time
+		s.server.Close()
+	}
+	return err
 }
 
 func (s *Server) initRouting() {
 	router := mux.NewRouter().StrictSlash(true)
-	s.infoLog.Println("Registering endpoints.")
+	logger := s.log.Subsystem("http")
 	for _, endpoint := range []struct {
 		name, method, path string
 		handler            http.Handler
@@ -107,89 +195,285 @@ func (s *Server) initRouting() {
 			"Index",
 			"GET",
 			"/",
-			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-				panicIfErr(w.Write([]byte("hello")))
+			s.handle(func(w http.ResponseWriter, r *http.Request) error {
+				_, err := w.Write([]byte("hello"))
+				return err
 			}),
 		}, {
 			"Search albums",
 			"GET",
 			"/albums",
-			http.HandlerFunc(s.searchAlbumsHandler),
+			s.handle(s.searchAlbumsHandler),
 		}, {
 			"Download album",
 			"POST",
 			"/album",
-			AddMiddleware(s.downloadAlbumHandler)(
+			AddMiddleware(s.handle(s.downloadAlbumHandler))(
 				s.requestParsingMiddleware(&models.Release{}),
 			),
+		}, {
+			"List downloads",
+			"GET",
+			"/downloads",
+			s.handle(s.downloadsHandler),
+		}, {
+			"Add download",
+			"POST",
+			"/downloads",
+			s.handle(s.addDownloadHandler),
+		}, {
+			"Cancel download",
+			"DELETE",
+			"/downloads/{id}",
+			s.handle(s.cancelDownloadHandler),
+		}, {
+			"Set download limits",
+			"PUT",
+			"/downloads/{id}/limits",
+			AddMiddleware(s.handle(s.setJobLimitsHandler))(s.adminMiddleware),
+		}, {
+			"Release cover",
+			"GET",
+			"/releases/{id}/cover",
+			s.handle(s.releaseCoverHandler),
+		}, {
+			"Stream track",
+			"GET",
+			"/tracks/{id}/stream",
+			s.handle(s.streamTrackHandler),
+		}, {
+			"Top tracks",
+			"GET",
+			"/stats/top",
+			s.handle(s.topTracksHandler),
+		}, {
+			"List duplicates",
+			"GET",
+			"/admin/duplicates",
+			AddMiddleware(s.handle(s.duplicatesHandler))(s.adminMiddleware),
+		}, {
+			"Merge duplicates",
+			"POST",
+			"/admin/merge",
+			AddMiddleware(s.handle(s.mergeHandler))(s.adminMiddleware),
+		}, {
+			"Log levels",
+			"GET",
+			"/admin/log/levels",
+			AddMiddleware(s.handle(s.logLevelsHandler))(s.adminMiddleware),
+		}, {
+			"Set log levels",
+			"PUT",
+			"/admin/log/levels",
+			AddMiddleware(s.handle(s.setLogLevelsHandler))(
+				s.adminMiddleware,
+			),
+		}, {
+			"Torrent policy",
+			"GET",
+			"/admin/torrent/policy",
+			AddMiddleware(s.handle(s.torrentPolicyHandler))(s.adminMiddleware),
+		}, {
+			"Set torrent policy",
+			"PUT",
+			"/admin/torrent/policy",
+			AddMiddleware(s.handle(s.setTorrentPolicyHandler))(
+				s.adminMiddleware,
+			),
+		}, {
+			"Disk usage",
+			"GET",
+			"/admin/disk",
+			AddMiddleware(s.handle(s.diskUsageHandler))(s.adminMiddleware),
+		}, {
+			"Evict releases",
+			"POST",
+			"/admin/disk/evict",
+			AddMiddleware(s.handle(s.evictHandler))(s.adminMiddleware),
+		}, {
+			"Liveness",
+			"GET",
+			"/healthz",
+			s.handle(s.healthzHandler),
+		}, {
+			"Readiness",
+			"GET",
+			"/readyz",
+			s.handle(s.readyzHandler),
+		}, {
+			"Metrics",
+			"GET",
+			"/metrics",
+			s.metrics.Handler(),
 		},
 	} {
-		s.infoLog.Printf(
-			"Registering '%s' endpoint: '%s': %s",
-			endpoint.name,
-			endpoint.path,
-			endpoint.path,
+		logger.Debug(
+			"Registering endpoint",
+			"name", endpoint.name,
+			"method", endpoint.method,
+			"path", endpoint.path,
 		)
 		router.
 			Methods(endpoint.method).
 			Path(endpoint.path).
 			Name(endpoint.name).
-			Handler(endpoint.handler)
+			Handler(s.metrics.InstrumentHandler(
+				endpoint.name,
+				endpoint.handler,
+			))
 	}
-	s.Handler = router
+	router.NotFoundHandler = s.metrics.InstrumentHandler(
+		"Not found",
+		s.handle(func(w http.ResponseWriter, r *http.Request) error {
+			return notFound("no endpoint at %s", r.URL.Path)
+		}),
+	)
+	// Panics are recovered inside the access log, itself inside the request
+	// ID middleware, so their responses are logged and carry the ID
+	s.Handler = AddMiddleware(router.ServeHTTP)(
+		s.recoveryMiddleware,
+		s.accessLogMiddleware,
+		s.requestIDMiddleware,
+	)
 }
 
-func (s *Server) initDB(host, DB string) error {
-	if s.db != nil {
-		s.warningLog.Println(
+func (s *Server) initDB(storageURL string) error {
+	logger := s.log.Subsystem("db")
+	s.dbMu.Lock()
+	defer s.dbMu.Unlock()
+	if s.dbOpen {
+		logger.Warn(
 			"Attempted to initialize already initialized database connection",
+			"stack", string(debug.Stack()),
 		)
-		s.warningLog.Println(string(debug.Stack()))
 		return nil
 	}
-	var err error
-	s.db, err = db.OpenDB(host, DB)
+	database, err := db.Open(storageURL, logger.Std(logging.Info))
 	if err != nil {
 		return err
 	}
-	for _, mdl := range []models.ColCreator{
-		&models.Artist{},
-		&models.Release{},
-		&models.Statistic{},
-		&models.Track{},
-	} {
-		err = mdl.ColCreate(s.db)
-		if err != nil {
-			break
-		}
+	backend, _ := db.Backend(storageURL)
+	s.db = s.metrics.Store(backend, database)
+	s.dbOpen = true
+	migrator, ok := database.(store.Migrator)
+	if !ok {
+		return nil
 	}
-	return err
+	applied, err := migrator.MigrateUp()
+	if err != nil {
+		return err
+	}
+	logger.Info("Applied migrations", "count", applied)
+	return nil
 }
 
-func (s *Server) closeDB() {
-	if s.db == nil {
-		s.warningLog.Println("Tried to close already closed database")
-	} else {
-		s.db.Session.Close()
-		s.db = nil
-		s.infoLog.Println("Database session closed")
+func (s *Server) initMetrics() error {
+	s.metrics = metrics.New()
+	return s.metrics.Register(
+		metrics.TorrentCollector(func() (stats torrent.Stats) {
+			s.withTorrentClient(func(cli torrent.Torrents) error {
+				stats = cli.Stats()
+				return nil
+			})
+			return stats
+		}),
+		metrics.JobsCollector(func() (jobs []models.Job, err error) {
+			err = s.withOpenDB(func(db store.Store) error {
+				jobs, err = db.Jobs()
+				return err
+			})
+			return jobs, err
+		}),
+	)
+}
+
+//closeDB waits for the collectors and health checks using the database
+// before closing it
+func (s *Server) closeDB() error {
+	logger := s.log.Subsystem("db")
+	s.dbMu.Lock()
+	defer s.dbMu.Unlock()
+	if !s.dbOpen {
+		logger.Warn("Tried to close already closed database")
+		return nil
 	}
+	s.dbOpen = false
+	if err := s.db.Close(); err != nil {
+		logger.Error("Could not close database", "err", err)
+		return err
+	}
+	logger.Info("Database session closed")
+	return nil
+}
+
+//withOpenDB calls fn with the database, which can't be closed until fn
+// returns, or returns errNotStarted if it isn't open
+func (s *Server) withOpenDB(fn func(store.Store) error) error {
+	s.dbMu.RLock()
+	defer s.dbMu.RUnlock()
+	if !s.dbOpen {
+		return errNotStarted
+	}
+	return fn(s.db)
 }
 
-func (s *Server) initlfmCli(apiKey string) error {
-	cli, err := lastfm.CreateLastFmClient(apiKey)
+func (s *Server) initMetadata(cfg metadata.Config) error {
+	cfg.HTTPClient = &http.Client{
+		Transport: s.metrics.Transport(cfg.Provider, nil),
+	}
+	meta, err := metadata.New(cfg)
+	logger := s.log.Subsystem("metadata")
 	if err != nil {
-		s.errorLog.Printf("Could not create last FM Client: %v", err)
+		logger.Error("Could not create metadata provider", "err", err)
+		return err
 	}
-	s.lfmCli = &cli
-	return err
+	s.meta = s.metrics.Provider(meta)
+	logger.Info("Using metadata provider", "provider", s.meta.Name())
+	return nil
+}
+
+func (s *Server) initEnrichment() {
+	s.enricher = enrich.NewWorker(
+		s.cfg.EnrichConfig(),
+		s.db,
+		s.meta,
+		s.log.Subsystem("enrich").Std(logging.Info),
+		s.log.Subsystem("enrich").Std(logging.Error),
+	)
+	s.enricher.Start()
 }
 
-func (s *Server) initTorrentClient(downloadDirectory, listenAddr string) error {
-	cli, err := torrent.NewClient(downloadDirectory, listenAddr)
+func (s *Server) initTorrentClient(cfg torrent.Config) error {
+	logger := s.log.Subsystem("torrent")
+	cfg.Logger = logger.Std(logging.Info)
+	cli, err := torrent.NewClient(cfg)
 	if err != nil {
-		s.errorLog.Printf("Could not create torrent Client: %v", err)
+		logger.Error("Could not create torrent client", "err", err)
+		return err
 	}
-	s.torrentCli = &cli
-	return err
+	s.torrentMu.Lock()
+	s.torrentCli = cli
+	s.torrentMu.Unlock()
+	return nil
+}
+
+//closeTorrentClient waits for the collectors and health checks using the
+// torrent client before closing it
+func (s *Server) closeTorrentClient() {
+	s.torrentMu.Lock()
+	defer s.torrentMu.Unlock()
+	s.torrentCli.Close()
+	s.torrentCli = nil
+	s.log.Subsystem("torrent").Info("Torrent client closed")
+}
+
+//withTorrentClient calls fn with the torrent client, which can't be closed
+// until fn returns, or returns errNotStarted if it isn't running
+func (s *Server) withTorrentClient(fn func(torrent.Torrents) error) error {
+	s.torrentMu.RLock()
+	defer s.torrentMu.RUnlock()
+	if s.torrentCli == nil {
+		return errNotStarted
+	}
+	return fn(s.torrentCli)
 }
@@ -0,0 +1,42 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/waelbendhia/music-streaming/wms/store"
+)
+
+func (s *Server) topTracksHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) error {
+	days, limit := 7, 10
+	for _, param := range []struct {
+		name  string
+		value *int
+	}{{"days", &days}, {"limit", &limit}} {
+		raw := r.URL.Query().Get(param.name)
+		if raw == "" {
+			continue
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			return badRequest(
+				"%s must be a positive integer",
+				param.name,
+			).withDetails(map[string]string{"param": param.name})
+		}
+		*param.value = n
+	}
+	top, err := store.FullTopTracks(
+		s.db,
+		time.Now().AddDate(0, 0, -days),
+		limit,
+	)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, 200, top)
+}
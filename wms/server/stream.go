@@ -0,0 +1,193 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/waelbendhia/music-streaming/wms/models"
+	"github.com/waelbendhia/music-streaming/wms/store"
+	"github.com/waelbendhia/music-streaming/wms/torrent"
+	"gopkg.in/mgo.v2/bson"
+)
+
+//audioTypes are the content types of audio files by extension, set so
+// streams aren't sniffed, which would wait for data still downloading
+var audioTypes = map[string]string{
+	".flac": "audio/flac",
+	".m4a":  "audio/mp4",
+	".mp3":  "audio/mpeg",
+	".ogg":  "audio/ogg",
+	".opus": "audio/ogg",
+	".wav":  "audio/wav",
+}
+
+func (s *Server) streamTrackHandler(
+	w http.ResponseWriter,
+	r *http.Request,
+) error {
+	id := mux.Vars(r)["id"]
+	if !bson.IsObjectIdHex(id) {
+		return badRequest("invalid ID: %s", id)
+	}
+	track, err := s.db.Track(bson.ObjectIdHex(id))
+	if err == store.ErrNotFound {
+		return notFound("track not found")
+	}
+	if err != nil {
+		return err
+	}
+	if track.TrackURL != "" {
+		file, err := os.Open(track.TrackURL)
+		if err == nil {
+			defer file.Close()
+			info, err := file.Stat()
+			if err != nil {
+				return err
+			}
+			s.recordListen(r, id)
+			setAudioType(w, track.TrackURL)
+			http.ServeContent(
+				w,
+				r,
+				filepath.Base(track.TrackURL),
+				info.ModTime(),
+				file,
+			)
+			return nil
+		}
+		if !os.IsNotExist(err) {
+			return err
+		}
+	}
+	job, file, err := s.downloadingFile(id)
+	if err != nil {
+		return err
+	}
+	reader, err := s.torrentCli.NewReader(
+		r.Context(),
+		job.InfoHash,
+		file.Path,
+		torrent.ReaderOptions{
+			Readahead: s.cfg.Torrent.Readahead << 10,
+			Timeout:   s.cfg.Torrent.ReadTimeout.Duration,
+		},
+	)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	s.recordListen(r, id)
+	stream := &streamReader{FileReader: reader}
+	setAudioType(w, file.Path)
+	http.ServeContent(w, r, path.Base(file.Path), time.Time{}, stream)
+	if stream.err == torrent.ErrReadTimeout {
+		s.requestLog(r, "downloads").Warn(
+			"Stream stalled",
+			"job", job.ID.Hex(),
+			"track", id,
+			"file", file.Path,
+			"read", stream.read,
+		)
+	}
+	return nil
+}
+
+//minListenRange is the smallest range from the start of a track requested
+// to play it, players probe tracks with smaller ones
+const minListenRange = 64 << 10
+
+//recordListen stores a listen of the track with the given ID if r starts
+// streaming it
+func (s *Server) recordListen(r *http.Request, trackID string) {
+	if !isListen(r) {
+		return
+	}
+	listener, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		listener = r.RemoteAddr
+	}
+	err = s.db.InsertStatistic(&models.Statistic{
+		TrackID:   trackID,
+		Listener:  listener,
+		TimeStamp: time.Now(),
+	})
+	if err != nil {
+		s.requestLog(r, "stats").Warn(
+			"Could not record listen",
+			"track", trackID,
+			"err", err,
+		)
+	}
+}
+
+//isListen returns true if r starts playing a track: it gets all of it or a
+// range from its start that is open-ended or large. Requests for later
+// ranges are the same listen seeking or resuming.
+func isListen(r *http.Request) bool {
+	if r.Method != "GET" {
+		return false
+	}
+	rng := strings.TrimSpace(r.Header.Get("Range"))
+	if rng == "" {
+		return true
+	}
+	if !strings.HasPrefix(rng, "bytes=0-") {
+		return false
+	}
+	end := strings.TrimPrefix(rng, "bytes=0-")
+	if end == "" {
+		return true
+	}
+	last, err := strconv.ParseInt(end, 10, 64)
+	return err == nil && last+1 >= minListenRange
+}
+
+//downloadingFile returns the job downloading the file of the track with
+// the given ID, and that file
+func (s *Server) downloadingFile(
+	trackID string,
+) (*models.Job, *models.JobFile, error) {
+	jobs, err := s.db.Jobs(models.JobDownloading)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range jobs {
+		for j, file := range jobs[i].Files {
+			if file.TrackID == trackID {
+				return &jobs[i], &jobs[i].Files[j], nil
+			}
+		}
+	}
+	return nil, nil, notFound("track is neither downloaded nor downloading")
+}
+
+//setAudioType sets the content type of the response to that of the audio
+// file name, if its extension is known
+func setAudioType(w http.ResponseWriter, name string) {
+	contentType, ok := audioTypes[strings.ToLower(filepath.Ext(name))]
+	if ok {
+		w.Header().Set("Content-Type", contentType)
+	}
+}
+
+//streamReader records the error a stream ended on and the bytes read,
+// http.ServeContent doesn't report them
+type streamReader struct {
+	torrent.FileReader
+	err  error
+	read int64
+}
+
+func (r *streamReader) Read(p []byte) (int, error) {
+	n, err := r.FileReader.Read(p)
+	r.read += int64(n)
+	r.err = err
+	return n, err
+}
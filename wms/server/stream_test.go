@@ -0,0 +1,36 @@
+package server
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIsListen(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		method string
+		rng    string
+		listen bool
+	}{
+		{"whole track", "GET", "", true},
+		{"open-ended from start", "GET", "bytes=0-", true},
+		{"large range from start", "GET", "bytes=0-1048575", true},
+		{"smallest listen", "GET", "bytes=0-65535", true},
+		{"probe", "GET", "bytes=0-1", false},
+		{"small range from start", "GET", "bytes=0-65534", false},
+		{"seek", "GET", "bytes=4096-", false},
+		{"several ranges", "GET", "bytes=0-1,4096-", false},
+		{"invalid range", "GET", "bytes=0-x", false},
+		{"head", "HEAD", "", false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(test.method, "/tracks/id/stream", nil)
+			if test.rng != "" {
+				r.Header.Set("Range", test.rng)
+			}
+			if listen := isListen(r); listen != test.listen {
+				t.Errorf("got %v, want %v", listen, test.listen)
+			}
+		})
+	}
+}
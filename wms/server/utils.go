@@ -5,16 +5,13 @@ import (
 	"math"
 	"math/rand"
 	"net/http"
-	"path/filepath"
-	"strconv"
+	"path"
 	"strings"
-	"time"
 
-	"github.com/anacrolix/torrent"
 	"github.com/texttheater/golang-levenshtein/levenshtein"
 	"github.com/waelbendhia/music-streaming/gopirate"
-	"github.com/waelbendhia/music-streaming/lastfm"
 	"github.com/waelbendhia/music-streaming/wms/models"
+	"github.com/waelbendhia/music-streaming/wms/torrent"
 	"gopkg.in/mgo.v2/bson"
 )
 
@@ -38,49 +35,6 @@ func ctxWithValCancel(
 	return context.WithCancel(context.WithValue(ctx, valKey, val))
 }
 
-func lfmSearchConverter(
-	lfmSearch *lastfm.SearchResults,
-	err error,
-) ([]models.Release, error) {
-	if err != nil {
-		return nil, err
-	}
-	res := make([]models.Release, len(lfmSearch.Results.AlbumMatches.Album))
-	for i, alb := range lfmSearch.Results.AlbumMatches.Album {
-		res[i] = lfmAlbumConverter(&alb)
-	}
-	return res, nil
-}
-
-func lfmAlbumConverter(lfmAlbum *lastfm.Album) models.Release {
-	var album models.Release
-	album.AlbumArtist = &models.Artist{Name: lfmAlbum.Artist}
-	album.Name = lfmAlbum.Name
-	for _, image := range lfmAlbum.Image {
-		if image.Size == "extralarge" {
-			album.CoverURL = image.Text
-			break
-		}
-	}
-	for _, track := range lfmAlbum.Tracks.Tracks {
-		var newTrack models.Track
-		duration, convErr := strconv.Atoi(track.Duration)
-		if convErr != nil {
-			duration = 0
-		}
-		newTrack.Length = time.Duration(duration) * time.Second
-		newTrack.Name = track.Name
-		album.Tracks = append(album.Tracks, newTrack)
-	}
-	return album
-}
-
-func panicIfErr(args ...interface{}) {
-	if err, ok := args[len(args)-1].(error); ok && err != nil {
-		panic(err)
-	}
-}
-
 func scoreTorrentName(name string) func(gopirate.Torrent) int {
 	return func(tor gopirate.Torrent) int {
 		return levDistance(name, tor.Name)
@@ -122,27 +76,6 @@ func torrentSort(
 	torrentSort(a[left+1:], score)
 	return a
 }
-func lfmAlbumInfoWrapper(
-	lfmAlb *lastfm.Album,
-	err error,
-) (*models.Release, error) {
-	if err != nil {
-		return nil, err
-	}
-	rel := &models.Release{
-		Name: lfmAlb.Name,
-		AlbumArtist: &models.Artist{
-			Name: lfmAlb.Artist,
-		},
-	}
-	for _, track := range lfmAlb.Tracks.Tracks {
-		rel.Tracks = append(rel.Tracks, models.Track{
-			Name: track.Name,
-		})
-	}
-	return rel, err
-}
-
 // type recResult struct {
 // 	pairs map[bson.ObjectId]torrent.File
 // 	dist  int
@@ -213,8 +146,10 @@ func lfmAlbumInfoWrapper(
 func matchTracksToFiles(
 	tracks []models.Track,
 	files []torrent.File,
-) map[bson.ObjectId]*torrent.File {
-	var tfMap = make(map[bson.ObjectId]*torrent.File, len(tracks))
+) map[bson.ObjectId]torrent.File {
+	var tfMap = make(map[bson.ObjectId]torrent.File, len(tracks))
+	// Matched files are removed from a copy, the caller's slice is left as is
+	files = append([]torrent.File(nil), files...)
 	compare := func(a, b string) int {
 		if strings.Contains(b, a) {
 			return 0
@@ -222,6 +157,9 @@ func matchTracksToFiles(
 		return levDistance(a, b)
 	}
 	for _, track := range tracks {
+		if len(files) == 0 {
+			break
+		}
 		var (
 			bestMatch    torrent.File
 			bestDistance = math.MaxInt32
@@ -230,7 +168,7 @@ func matchTracksToFiles(
 		for ind, file := range files {
 			dist := compare(
 				strings.ToLower(track.Name),
-				strings.ToLower(filepath.Base(file.DisplayPath())),
+				strings.ToLower(path.Base(file.Path)),
 			)
 			if dist < bestDistance {
 				bestInd = ind
@@ -239,7 +177,7 @@ func matchTracksToFiles(
 			}
 		}
 		files = append(files[:bestInd], files[bestInd+1:]...)
-		tfMap[track.ID] = &bestMatch
+		tfMap[track.ID] = bestMatch
 	}
 	return tfMap
 }
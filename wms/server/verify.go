@@ -0,0 +1,130 @@
+package server
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/waelbendhia/music-streaming/wms/library"
+	"github.com/waelbendhia/music-streaming/wms/models"
+	"github.com/waelbendhia/music-streaming/wms/tags"
+	"github.com/waelbendhia/music-streaming/wms/torrent"
+	"gopkg.in/mgo.v2/bson"
+)
+
+//audioFiles returns the files with the extension of an audio file
+func audioFiles(files []torrent.File) []torrent.File {
+	var audio []torrent.File
+	for _, file := range files {
+		ext := strings.ToLower(path.Ext(file.Path))
+		if library.Extensions[ext] {
+			audio = append(audio, file)
+		}
+	}
+	return audio
+}
+
+//extraFiles returns the files with one of extensions, ignoring case
+func extraFiles(files []torrent.File, extensions []string) []torrent.File {
+	var extras []torrent.File
+	for _, file := range files {
+		ext := path.Ext(file.Path)
+		for _, extra := range extensions {
+			if strings.EqualFold(ext, extra) {
+				extras = append(extras, file)
+				break
+			}
+		}
+	}
+	return extras
+}
+
+//matchConfidence returns the share of tracks matched to a file named
+// like them
+func matchConfidence(
+	tracks []models.Track,
+	files map[bson.ObjectId]torrent.File,
+) float64 {
+	if len(tracks) == 0 {
+		return 1
+	}
+	matched := 0
+	for _, track := range tracks {
+		file, found := files[track.ID]
+		if !found {
+			continue
+		}
+		base := path.Base(file.Path)
+		if sameName(track.Name, strings.TrimSuffix(base, path.Ext(base))) {
+			matched++
+		}
+	}
+	return float64(matched) / float64(len(tracks))
+}
+
+//sameName returns true if one of a and b contains the other, ignoring
+// case, or they are a few typos apart
+func sameName(a, b string) bool {
+	a = strings.ToLower(strings.TrimSpace(a))
+	b = strings.ToLower(strings.TrimSpace(b))
+	if a == "" || b == "" {
+		return false
+	}
+	if strings.Contains(a, b) || strings.Contains(b, a) {
+		return true
+	}
+	shortest := len(a)
+	if len(b) < shortest {
+		shortest = len(b)
+	}
+	return levDistance(a, b) <= shortest/4
+}
+
+//verifyTags returns an error if most of the tagged files in paths are
+// tagged with another release or album artist than rel's. Files without
+// tags can't be verified and are ignored.
+func verifyTags(rel *models.Release, paths []string) error {
+	var (
+		tagged, wrong int
+		example       string
+	)
+	for _, path := range paths {
+		t, err := tags.Read(path)
+		if err != nil || (t.Album == "" && t.AlbumArtist == "") {
+			continue
+		}
+		tagged++
+		switch {
+		case t.Album != "" && !sameName(t.Album, rel.Name):
+			wrong++
+			example = fmt.Sprintf("album '%s'", t.Album)
+		case t.AlbumArtist != "" &&
+			rel.AlbumArtist != nil &&
+			!sameName(t.AlbumArtist, rel.AlbumArtist.Name):
+			wrong++
+			example = fmt.Sprintf("album artist '%s'", t.AlbumArtist)
+		}
+	}
+	if wrong > 0 && wrong*2 >= tagged {
+		return fmt.Errorf(
+			"%d of %d files are tagged with another release, like %s",
+			wrong,
+			tagged,
+			example,
+		)
+	}
+	return nil
+}
+
+//mismatch returns why files, matched to rel's tracks, don't hold rel, or
+// nothing if enough of them are named like its tracks
+func (s *Server) mismatch(
+	rel *models.Release,
+	files map[bson.ObjectId]torrent.File,
+) string {
+	confidence := matchConfidence(rel.Tracks, files)
+	if confidence < s.cfg.Torrent.MinMatch {
+		return fmt.Sprintf("files match %.0f%% of the tracks", confidence*100)
+	}
+	return ""
+}
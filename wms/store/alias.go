@@ -0,0 +1,50 @@
+package store
+
+import (
+	"github.com/waelbendhia/music-streaming/wms/models"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// When duplicates are merged the keys the removed records were known by are
+// kept as aliases of the record they were merged into, so importing them
+// again resolves to it instead of recreating the duplicate
+
+//ArtistAliasKeys returns the keys artist is identified by
+func ArtistAliasKeys(artist *models.Artist) []string {
+	keys := []string{NormalizeName(artist.Name)}
+	if artist.MBID != "" {
+		keys = append(keys, "mbid:"+artist.MBID)
+	}
+	return keys
+}
+
+//ReleaseAliasKeys returns the keys rel is identified by, as a release of the
+// album artist with ID albumArtistID
+func ReleaseAliasKeys(rel *models.Release, albumArtistID string) []string {
+	keys := []string{albumArtistID + "/" + NormalizeName(rel.Name)}
+	if rel.MBID != "" {
+		keys = append(keys, "mbid:"+rel.MBID)
+	}
+	return keys
+}
+
+//aliasTarget returns the ID of the record one of keys is an alias of
+func aliasTarget(
+	s AliasStore,
+	kind string,
+	keys []string,
+) (bson.ObjectId, error) {
+	for _, key := range keys {
+		id, err := s.Alias(kind, key)
+		if err == ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if bson.IsObjectIdHex(id) {
+			return bson.ObjectIdHex(id), nil
+		}
+	}
+	return "", ErrNotFound
+}
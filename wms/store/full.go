@@ -0,0 +1,96 @@
+package store
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/waelbendhia/music-streaming/wms/models"
+	"gopkg.in/mgo.v2/bson"
+)
+
+//FullArtist returns the artist with its releases and related artists
+func FullArtist(s Store, id bson.ObjectId) (*models.Artist, error) {
+	artist, err := s.Artist(id)
+	if err != nil {
+		return nil, err
+	}
+	artist.Releases, err = s.ReleasesByArtist(artist.ID.Hex())
+	if err != nil {
+		return nil, err
+	}
+	for _, relID := range artist.RelatedArtistIDs {
+		if !bson.IsObjectIdHex(relID) {
+			continue
+		}
+		related, err := s.Artist(bson.ObjectIdHex(relID))
+		if err == ErrNotFound {
+			return nil, fmt.Errorf("Artist with ID: '%s' not found", relID)
+		}
+		if err != nil {
+			return nil, err
+		}
+		artist.RelatedArtists = append(artist.RelatedArtists, *related)
+	}
+	return artist, nil
+}
+
+//FullRelease returns the release with its album artist and its tracks
+// ordered by disc and track number
+func FullRelease(s Store, id bson.ObjectId) (*models.Release, error) {
+	rel, err := s.Release(id)
+	if err != nil {
+		return nil, err
+	}
+	if bson.IsObjectIdHex(rel.AlbumArtistID) {
+		rel.AlbumArtist, err = s.Artist(bson.ObjectIdHex(rel.AlbumArtistID))
+		if err != nil {
+			return nil, err
+		}
+	}
+	rel.Tracks = nil
+	for _, trcID := range rel.TrackIDs {
+		if !bson.IsObjectIdHex(trcID) {
+			continue
+		}
+		track, err := s.Track(bson.ObjectIdHex(trcID))
+		if err == ErrNotFound {
+			return nil, fmt.Errorf("Track with ID: '%s' not found", trcID)
+		}
+		if err != nil {
+			return nil, err
+		}
+		rel.Tracks = append(rel.Tracks, *track)
+	}
+	rel.SortTracks()
+	return rel, nil
+}
+
+//TopTrack is a track with its number of listens
+type TopTrack struct {
+	Track models.Track `json:"track"`
+	Count int          `json:"count"`
+}
+
+//FullTopTracks returns the limit most listened tracks since the given time,
+// tracks that no longer exist are left out
+func FullTopTracks(s Store, since time.Time, limit int) ([]TopTrack, error) {
+	counts, err := s.TopTracks(since, limit)
+	if err != nil {
+		return nil, err
+	}
+	top := make([]TopTrack, 0, len(counts))
+	for _, count := range counts {
+		if !bson.IsObjectIdHex(count.TrackID) {
+			continue
+		}
+		track, err := s.Track(bson.ObjectIdHex(count.TrackID))
+		if err == ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		top = append(top, TopTrack{Track: *track, Count: count.Count})
+	}
+	return top, nil
+}
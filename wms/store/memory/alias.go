@@ -0,0 +1,38 @@
+package memory
+
+import "github.com/waelbendhia/music-streaming/wms/store"
+
+type aliasKey struct {
+	kind, key string
+}
+
+//Alias returns the ID of the record key is an alias of
+func (s *Store) Alias(kind, key string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	id, ok := s.aliases[aliasKey{kind, key}]
+	if !ok {
+		return "", store.ErrNotFound
+	}
+	return id, nil
+}
+
+//SetAlias makes key an alias of the record with ID targetID
+func (s *Store) SetAlias(kind, key, targetID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.aliases[aliasKey{kind, key}] = targetID
+	return nil
+}
+
+//RetargetAliases makes the aliases of a record aliases of another
+func (s *Store) RetargetAliases(kind, fromID, toID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, id := range s.aliases {
+		if key.kind == kind && id == fromID {
+			s.aliases[key] = toID
+		}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package memory
+
+import (
+	"github.com/waelbendhia/music-streaming/wms/models"
+	"github.com/waelbendhia/music-streaming/wms/store"
+	"gopkg.in/mgo.v2/bson"
+)
+
+//Artists returns every artist
+func (s *Store) Artists() ([]models.Artist, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	artists := make([]models.Artist, 0, len(s.artists))
+	for _, artist := range s.artists {
+		artists = append(artists, *copyArtist(artist))
+	}
+	sortArtists(artists)
+	return artists, nil
+}
+
+//DeleteArtist removes the artist with the given ID
+func (s *Store) DeleteArtist(id bson.ObjectId) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.artists[id]; !ok {
+		return store.ErrNotFound
+	}
+	delete(s.artists, id)
+	return nil
+}
+
+//Releases returns every release
+func (s *Store) Releases() ([]models.Release, error) {
+	return s.filterReleases(func(models.Release) bool { return true }), nil
+}
+
+//DeleteRelease removes the release with the given ID
+func (s *Store) DeleteRelease(id bson.ObjectId) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.releases[id]; !ok {
+		return store.ErrNotFound
+	}
+	delete(s.releases, id)
+	return nil
+}
+
+//DeleteTrack removes the track with the given ID
+func (s *Store) DeleteTrack(id bson.ObjectId) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.tracks[id]; !ok {
+		return store.ErrNotFound
+	}
+	delete(s.tracks, id)
+	return nil
+}
+
+//ReassignStatistics moves the listens of a track to another
+func (s *Store) ReassignStatistics(fromTrackID, toTrackID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.stats {
+		if s.stats[i].TrackID == fromTrackID {
+			s.stats[i].TrackID = toTrackID
+		}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package memory
+
+import (
+	"sort"
+
+	"github.com/waelbendhia/music-streaming/wms/models"
+)
+
+// Records are copied in and out of the store so callers can't modify stored
+// records behind its back, relations that aren't persisted are dropped
+
+func copyArtist(artist models.Artist) *models.Artist {
+	artist.Genres = append([]string(nil), artist.Genres...)
+	artist.RelatedArtistIDs = append([]string(nil), artist.RelatedArtistIDs...)
+	artist.RelatedArtists = nil
+	artist.Releases = nil
+	return &artist
+}
+
+func copyRelease(rel models.Release) *models.Release {
+	rel.Genres = append([]string(nil), rel.Genres...)
+	rel.Discs = append([]models.Disc(nil), rel.Discs...)
+	rel.TrackIDs = append([]string(nil), rel.TrackIDs...)
+	rel.AlbumArtist = nil
+	rel.Tracks = nil
+	return &rel
+}
+
+func copyTrack(track models.Track) *models.Track {
+	track.ISRCs = append([]string(nil), track.ISRCs...)
+	track.Artist = nil
+	track.Releases = nil
+	return &track
+}
+
+func copyJob(job models.Job) *models.Job {
+	job.Candidates = append([]models.Candidate(nil), job.Candidates...)
+	job.MetaInfo = append([]byte(nil), job.MetaInfo...)
+	job.Attempts = append([]models.Attempt(nil), job.Attempts...)
+	job.Files = append([]models.JobFile(nil), job.Files...)
+	return &job
+}
+
+func sortArtists(artists []models.Artist) {
+	sort.Slice(artists, func(i, j int) bool {
+		return artists[i].ID < artists[j].ID
+	})
+}
+
+func sortReleases(rels []models.Release) {
+	sort.Slice(rels, func(i, j int) bool { return rels[i].ID < rels[j].ID })
+}
@@ -0,0 +1,68 @@
+package memory
+
+import (
+	"sort"
+
+	"github.com/waelbendhia/music-streaming/wms/models"
+	"github.com/waelbendhia/music-streaming/wms/store"
+	"gopkg.in/mgo.v2/bson"
+)
+
+//Job by ID
+func (s *Store) Job(id bson.ObjectId) (*models.Job, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return copyJob(job), nil
+}
+
+//Jobs in any of the given states, or all jobs, oldest first
+func (s *Store) Jobs(states ...models.JobState) ([]models.Job, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var jobs []models.Job
+	for _, job := range s.jobs {
+		if len(states) == 0 || hasState(states, job.State) {
+			jobs = append(jobs, *copyJob(job))
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		if !jobs[i].CreatedAt.Equal(jobs[j].CreatedAt) {
+			return jobs[i].CreatedAt.Before(jobs[j].CreatedAt)
+		}
+		return jobs[i].ID < jobs[j].ID
+	})
+	return jobs, nil
+}
+
+func hasState(states []models.JobState, state models.JobState) bool {
+	for _, st := range states {
+		if st == state {
+			return true
+		}
+	}
+	return false
+}
+
+//InsertJob stores a new job and sets its ID
+func (s *Store) InsertJob(job *models.Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job.ID = bson.NewObjectId()
+	s.jobs[job.ID] = *copyJob(*job)
+	return nil
+}
+
+//UpdateJob replaces the stored job with job
+func (s *Store) UpdateJob(job *models.Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.jobs[job.ID]; !ok {
+		return store.ErrNotFound
+	}
+	s.jobs[job.ID] = *copyJob(*job)
+	return nil
+}
@@ -0,0 +1,360 @@
+package memory
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/waelbendhia/music-streaming/wms/models"
+	"github.com/waelbendhia/music-streaming/wms/store"
+	"gopkg.in/mgo.v2/bson"
+)
+
+//Store keeps everything in memory, it is meant for tests and trying the
+// server out, nothing survives a restart
+type Store struct {
+	mu       sync.RWMutex
+	txMu     sync.Mutex
+	artists  map[bson.ObjectId]models.Artist
+	releases map[bson.ObjectId]models.Release
+	tracks   map[bson.ObjectId]models.Track
+	stats    []models.Statistic
+	jobs     map[bson.ObjectId]models.Job
+	aliases  map[aliasKey]string
+	users    map[bson.ObjectId]models.User
+}
+
+var _ store.Store = &Store{}
+
+//New creates an empty in-memory store
+func New() *Store {
+	return &Store{
+		artists:  make(map[bson.ObjectId]models.Artist),
+		releases: make(map[bson.ObjectId]models.Release),
+		tracks:   make(map[bson.ObjectId]models.Track),
+		jobs:     make(map[bson.ObjectId]models.Job),
+		aliases:  make(map[aliasKey]string),
+		users:    make(map[bson.ObjectId]models.User),
+	}
+}
+
+//Ping does nothing
+func (s *Store) Ping() error {
+	return nil
+}
+
+//Close does nothing
+func (s *Store) Close() error {
+	return nil
+}
+
+//Transaction calls fn with s, transactions are serialized but changes are
+// not rolled back if fn fails
+func (s *Store) Transaction(fn func(store.Store) error) error {
+	s.txMu.Lock()
+	defer s.txMu.Unlock()
+	return fn(s)
+}
+
+//Artist by ID
+func (s *Store) Artist(id bson.ObjectId) (*models.Artist, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	artist, ok := s.artists[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return copyArtist(artist), nil
+}
+
+//ArtistByName returns the artist whose name normalizes like name
+func (s *Store) ArtistByName(name string) (*models.Artist, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if artist, ok := s.artistByName(name); ok {
+		return copyArtist(artist), nil
+	}
+	return nil, store.ErrNotFound
+}
+
+//ArtistByMBID returns the artist with the given MusicBrainz ID
+func (s *Store) ArtistByMBID(mbid string) (*models.Artist, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, artist := range s.artists {
+		if mbid != "" && artist.MBID == mbid {
+			return copyArtist(artist), nil
+		}
+	}
+	return nil, store.ErrNotFound
+}
+
+func (s *Store) artistByName(name string) (models.Artist, bool) {
+	name = store.NormalizeName(name)
+	for _, artist := range s.artists {
+		if artist.NormName == name {
+			return artist, true
+		}
+	}
+	return models.Artist{}, false
+}
+
+//InsertArtist stores a new artist and sets its ID
+func (s *Store) InsertArtist(artist *models.Artist) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.artistByName(artist.Name); ok {
+		return store.ErrDuplicate
+	}
+	artist.ID = bson.NewObjectId()
+	artist.NormName = store.NormalizeName(artist.Name)
+	s.artists[artist.ID] = *copyArtist(*artist)
+	return nil
+}
+
+//UpdateArtist replaces the stored artist with artist
+func (s *Store) UpdateArtist(artist *models.Artist) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.artists[artist.ID]; !ok {
+		return store.ErrNotFound
+	}
+	if other, ok := s.artistByName(artist.Name); ok && other.ID != artist.ID {
+		return store.ErrDuplicate
+	}
+	artist.NormName = store.NormalizeName(artist.Name)
+	s.artists[artist.ID] = *copyArtist(*artist)
+	return nil
+}
+
+//ArtistsToEnrich returns up to limit artists that were never enriched or
+// were last enriched before staleBefore
+func (s *Store) ArtistsToEnrich(
+	staleBefore time.Time,
+	limit int,
+) ([]models.Artist, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var artists []models.Artist
+	for _, artist := range s.artists {
+		if artist.LastEnriched.Before(staleBefore) {
+			artists = append(artists, *copyArtist(artist))
+		}
+	}
+	sortArtists(artists)
+	if len(artists) > limit {
+		artists = artists[:limit]
+	}
+	return artists, nil
+}
+
+//Release by ID
+func (s *Store) Release(id bson.ObjectId) (*models.Release, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rel, ok := s.releases[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return copyRelease(rel), nil
+}
+
+//ReleaseByName returns the album artist's release whose name normalizes
+// like name
+func (s *Store) ReleaseByName(
+	albumArtistID, name string,
+) (*models.Release, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if rel, ok := s.releaseByName(albumArtistID, name); ok {
+		return copyRelease(rel), nil
+	}
+	return nil, store.ErrNotFound
+}
+
+func (s *Store) releaseByName(albumArtistID, name string) (models.Release, bool) {
+	name = store.NormalizeName(name)
+	for _, rel := range s.releases {
+		if rel.AlbumArtistID == albumArtistID && rel.NormName == name {
+			return rel, true
+		}
+	}
+	return models.Release{}, false
+}
+
+//ReleaseByMBID returns the release with the given MusicBrainz ID
+func (s *Store) ReleaseByMBID(mbid string) (*models.Release, error) {
+	rels := s.filterReleases(func(rel models.Release) bool {
+		return mbid != "" && rel.MBID == mbid
+	})
+	if len(rels) == 0 {
+		return nil, store.ErrNotFound
+	}
+	return &rels[0], nil
+}
+
+//SearchReleases whose name contains query
+func (s *Store) SearchReleases(query string) ([]models.Release, error) {
+	return s.filterReleases(func(rel models.Release) bool {
+		return strings.Contains(
+			strings.ToLower(rel.Name),
+			strings.ToLower(query),
+		)
+	}), nil
+}
+
+//ReleasesByArtist returns the releases of an album artist
+func (s *Store) ReleasesByArtist(
+	albumArtistID string,
+) ([]models.Release, error) {
+	return s.filterReleases(func(rel models.Release) bool {
+		return rel.AlbumArtistID == albumArtistID
+	}), nil
+}
+
+func (s *Store) filterReleases(keep func(models.Release) bool) []models.Release {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var rels []models.Release
+	for _, rel := range s.releases {
+		if keep(rel) {
+			rels = append(rels, *copyRelease(rel))
+		}
+	}
+	sortReleases(rels)
+	return rels
+}
+
+//InsertRelease stores a new release and sets its ID
+func (s *Store) InsertRelease(rel *models.Release) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.releaseByName(rel.AlbumArtistID, rel.Name); ok {
+		return store.ErrDuplicate
+	}
+	rel.ID = bson.NewObjectId()
+	rel.NormName = store.NormalizeName(rel.Name)
+	s.releases[rel.ID] = *copyRelease(*rel)
+	return nil
+}
+
+//UpsertRelease along with its album artist and tracks, upserts are
+// serialized so concurrent upserts of the same release don't race
+func (s *Store) UpsertRelease(rel *models.Release) error {
+	return s.Transaction(func(tx store.Store) error {
+		return store.Upsert(tx, rel)
+	})
+}
+
+//UpdateRelease replaces the stored release with rel
+func (s *Store) UpdateRelease(rel *models.Release) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.releases[rel.ID]; !ok {
+		return store.ErrNotFound
+	}
+	other, ok := s.releaseByName(rel.AlbumArtistID, rel.Name)
+	if ok && other.ID != rel.ID {
+		return store.ErrDuplicate
+	}
+	rel.NormName = store.NormalizeName(rel.Name)
+	s.releases[rel.ID] = *copyRelease(*rel)
+	return nil
+}
+
+//ReleasesToEnrich returns up to limit releases that were never enriched or
+// were last enriched before staleBefore
+func (s *Store) ReleasesToEnrich(
+	staleBefore time.Time,
+	limit int,
+) ([]models.Release, error) {
+	rels := s.filterReleases(func(rel models.Release) bool {
+		return rel.LastEnriched.Before(staleBefore)
+	})
+	if len(rels) > limit {
+		rels = rels[:limit]
+	}
+	return rels, nil
+}
+
+//Track by ID
+func (s *Store) Track(id bson.ObjectId) (*models.Track, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	track, ok := s.tracks[id]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return copyTrack(track), nil
+}
+
+//TracksByArtist returns the tracks of an artist
+func (s *Store) TracksByArtist(artistID string) ([]models.Track, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var tracks []models.Track
+	for _, track := range s.tracks {
+		if track.ArtistID == artistID {
+			tracks = append(tracks, *copyTrack(track))
+		}
+	}
+	sort.Slice(tracks, func(i, j int) bool { return tracks[i].ID < tracks[j].ID })
+	return tracks, nil
+}
+
+//InsertTrack stores a new track and sets its ID
+func (s *Store) InsertTrack(track *models.Track) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	track.ID = bson.NewObjectId()
+	s.tracks[track.ID] = *copyTrack(*track)
+	return nil
+}
+
+//UpdateTrack replaces the stored track with track
+func (s *Store) UpdateTrack(track *models.Track) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.tracks[track.ID]; !ok {
+		return store.ErrNotFound
+	}
+	s.tracks[track.ID] = *copyTrack(*track)
+	return nil
+}
+
+//InsertStatistic records a listen
+func (s *Store) InsertStatistic(stat *models.Statistic) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cp := *stat
+	cp.Track = nil
+	s.stats = append(s.stats, cp)
+	return nil
+}
+
+//TopTracks returns the limit most listened tracks since the given time
+func (s *Store) TopTracks(since time.Time, limit int) ([]store.TrackCount, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	counts := make(map[string]int)
+	for _, stat := range s.stats {
+		if !stat.TimeStamp.Before(since) {
+			counts[stat.TrackID]++
+		}
+	}
+	top := make([]store.TrackCount, 0, len(counts))
+	for id, count := range counts {
+		top = append(top, store.TrackCount{TrackID: id, Count: count})
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Count != top[j].Count {
+			return top[i].Count > top[j].Count
+		}
+		return top[i].TrackID < top[j].TrackID
+	})
+	if len(top) > limit {
+		top = top[:limit]
+	}
+	return top, nil
+}
@@ -0,0 +1,13 @@
+package memory_test
+
+import (
+	"testing"
+
+	"github.com/waelbendhia/music-streaming/wms/store"
+	"github.com/waelbendhia/music-streaming/wms/store/memory"
+	"github.com/waelbendhia/music-streaming/wms/store/storetest"
+)
+
+func TestStore(t *testing.T) {
+	storetest.Run(t, func(*testing.T) store.Store { return memory.New() })
+}
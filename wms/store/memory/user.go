@@ -0,0 +1,44 @@
+package memory
+
+import (
+	"github.com/waelbendhia/music-streaming/wms/models"
+	"github.com/waelbendhia/music-streaming/wms/store"
+	"gopkg.in/mgo.v2/bson"
+)
+
+//UserByName returns the user with the given name
+func (s *Store) UserByName(name string) (*models.User, error) {
+	return s.findUser(func(user models.User) bool { return user.Name == name })
+}
+
+//UserByToken returns the user whose token hashes to tokenHash
+func (s *Store) UserByToken(tokenHash string) (*models.User, error) {
+	return s.findUser(func(user models.User) bool {
+		return tokenHash != "" && user.TokenHash == tokenHash
+	})
+}
+
+func (s *Store) findUser(match func(models.User) bool) (*models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, user := range s.users {
+		if match(user) {
+			return &user, nil
+		}
+	}
+	return nil, store.ErrNotFound
+}
+
+//InsertUser stores a new user and sets its ID
+func (s *Store) InsertUser(user *models.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, other := range s.users {
+		if other.Name == user.Name || other.TokenHash == user.TokenHash {
+			return store.ErrDuplicate
+		}
+	}
+	user.ID = bson.NewObjectId()
+	s.users[user.ID] = *user
+	return nil
+}
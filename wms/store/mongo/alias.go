@@ -0,0 +1,37 @@
+package mongo
+
+import "gopkg.in/mgo.v2/bson"
+
+type alias struct {
+	Kind     string `bson:"kind"`
+	Key      string `bson:"key"`
+	TargetID string `bson:"target_id"`
+}
+
+//Alias returns the ID of the record key is an alias of
+func (s *Store) Alias(kind, key string) (string, error) {
+	var a alias
+	err := s.db.C(aliasColName).Find(bson.M{"kind": kind, "key": key}).One(&a)
+	if err != nil {
+		return "", wrapErr(err)
+	}
+	return a.TargetID, nil
+}
+
+//SetAlias makes key an alias of the record with ID targetID
+func (s *Store) SetAlias(kind, key, targetID string) error {
+	_, err := s.db.C(aliasColName).Upsert(
+		bson.M{"kind": kind, "key": key},
+		alias{Kind: kind, Key: key, TargetID: targetID},
+	)
+	return err
+}
+
+//RetargetAliases makes the aliases of a record aliases of another
+func (s *Store) RetargetAliases(kind, fromID, toID string) error {
+	_, err := s.db.C(aliasColName).UpdateAll(
+		bson.M{"kind": kind, "target_id": fromID},
+		bson.M{"$set": bson.M{"target_id": toID}},
+	)
+	return err
+}
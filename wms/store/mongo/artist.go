@@ -0,0 +1,80 @@
+package mongo
+
+import (
+	"time"
+
+	"github.com/waelbendhia/music-streaming/wms/models"
+	"github.com/waelbendhia/music-streaming/wms/store"
+	"gopkg.in/mgo.v2/bson"
+)
+
+//Artist by ID
+func (s *Store) Artist(id bson.ObjectId) (*models.Artist, error) {
+	var artist models.Artist
+	err := s.db.C(artistColName).FindId(id).One(&artist)
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	return &artist, nil
+}
+
+//ArtistByName returns the artist whose name normalizes like name
+func (s *Store) ArtistByName(name string) (*models.Artist, error) {
+	return s.findArtist(bson.M{"norm_name": store.NormalizeName(name)})
+}
+
+//ArtistByMBID returns the artist with the given MusicBrainz ID
+func (s *Store) ArtistByMBID(mbid string) (*models.Artist, error) {
+	if mbid == "" {
+		return nil, store.ErrNotFound
+	}
+	return s.findArtist(bson.M{"mbid": mbid})
+}
+
+func (s *Store) findArtist(finder bson.M) (*models.Artist, error) {
+	var artist models.Artist
+	if err := s.db.C(artistColName).Find(finder).One(&artist); err != nil {
+		return nil, wrapErr(err)
+	}
+	return &artist, nil
+}
+
+//InsertArtist into db
+func (s *Store) InsertArtist(artist *models.Artist) error {
+	artist.ID = bson.NewObjectId()
+	artist.NormName = store.NormalizeName(artist.Name)
+	return wrapErr(s.db.C(artistColName).Insert(artist))
+}
+
+//UpdateArtist replaces the stored artist with artist
+func (s *Store) UpdateArtist(artist *models.Artist) error {
+	artist.NormName = store.NormalizeName(artist.Name)
+	return wrapErr(s.db.C(artistColName).UpdateId(artist.ID, artist))
+}
+
+//ArtistsToEnrich returns up to limit artists that were never enriched or
+// were last enriched before staleBefore
+func (s *Store) ArtistsToEnrich(
+	staleBefore time.Time,
+	limit int,
+) ([]models.Artist, error) {
+	var artists []models.Artist
+	err := s.db.
+		C(artistColName).
+		Find(enrichFinder(staleBefore)).
+		Limit(limit).
+		All(&artists)
+	return artists, err
+}
+
+//Artists returns every artist
+func (s *Store) Artists() ([]models.Artist, error) {
+	var artists []models.Artist
+	err := s.db.C(artistColName).Find(nil).Sort("_id").All(&artists)
+	return artists, err
+}
+
+//DeleteArtist removes the artist with the given ID
+func (s *Store) DeleteArtist(id bson.ObjectId) error {
+	return wrapErr(s.db.C(artistColName).RemoveId(id))
+}
@@ -0,0 +1,37 @@
+package mongo
+
+import (
+	"github.com/waelbendhia/music-streaming/wms/models"
+	"gopkg.in/mgo.v2/bson"
+)
+
+//Job by ID
+func (s *Store) Job(id bson.ObjectId) (*models.Job, error) {
+	var job models.Job
+	if err := s.db.C(jobColName).FindId(id).One(&job); err != nil {
+		return nil, wrapErr(err)
+	}
+	return &job, nil
+}
+
+//Jobs in any of the given states, or all jobs, oldest first
+func (s *Store) Jobs(states ...models.JobState) ([]models.Job, error) {
+	finder := bson.M{}
+	if len(states) > 0 {
+		finder["state"] = bson.M{"$in": states}
+	}
+	var jobs []models.Job
+	err := s.db.C(jobColName).Find(finder).Sort("created_at", "_id").All(&jobs)
+	return jobs, err
+}
+
+//InsertJob stores a new job and sets its ID
+func (s *Store) InsertJob(job *models.Job) error {
+	job.ID = bson.NewObjectId()
+	return wrapErr(s.db.C(jobColName).Insert(job))
+}
+
+//UpdateJob replaces the stored job with job
+func (s *Store) UpdateJob(job *models.Job) error {
+	return wrapErr(s.db.C(jobColName).UpdateId(job.ID, job))
+}
@@ -0,0 +1,115 @@
+package mongo
+
+import (
+	"time"
+
+	"github.com/waelbendhia/music-streaming/wms/models"
+	"github.com/waelbendhia/music-streaming/wms/store"
+	"gopkg.in/mgo.v2/bson"
+)
+
+//Release by ID
+func (s *Store) Release(id bson.ObjectId) (*models.Release, error) {
+	var rel models.Release
+	if err := s.db.C(relColName).FindId(id).One(&rel); err != nil {
+		return nil, wrapErr(err)
+	}
+	return &rel, nil
+}
+
+//ReleaseByName returns the album artist's release whose name normalizes
+// like name
+func (s *Store) ReleaseByName(
+	albumArtistID, name string,
+) (*models.Release, error) {
+	return s.findRelease(bson.M{
+		"norm_name":       store.NormalizeName(name),
+		"album_artist_id": albumArtistID,
+	})
+}
+
+//ReleaseByMBID returns the release with the given MusicBrainz ID
+func (s *Store) ReleaseByMBID(mbid string) (*models.Release, error) {
+	if mbid == "" {
+		return nil, store.ErrNotFound
+	}
+	return s.findRelease(bson.M{"mbid": mbid})
+}
+
+func (s *Store) findRelease(finder bson.M) (*models.Release, error) {
+	var rel models.Release
+	if err := s.db.C(relColName).Find(finder).One(&rel); err != nil {
+		return nil, wrapErr(err)
+	}
+	return &rel, nil
+}
+
+//SearchReleases whose name contains query
+func (s *Store) SearchReleases(query string) ([]models.Release, error) {
+	var rels []models.Release
+	err := s.db.
+		C(relColName).
+		Find(bson.M{"name": containsFinder(query)}).
+		All(&rels)
+	return rels, err
+}
+
+//ReleasesByArtist returns the releases of an album artist
+func (s *Store) ReleasesByArtist(
+	albumArtistID string,
+) ([]models.Release, error) {
+	var rels []models.Release
+	err := s.db.
+		C(relColName).
+		Find(bson.M{"album_artist_id": albumArtistID}).
+		All(&rels)
+	return rels, err
+}
+
+//InsertRelease into db
+func (s *Store) InsertRelease(rel *models.Release) error {
+	rel.ID = bson.NewObjectId()
+	rel.NormName = store.NormalizeName(rel.Name)
+	return wrapErr(s.db.C(relColName).Insert(rel))
+}
+
+//UpsertRelease to db along with its album artist and tracks, the unique
+// indexes on normalized names catch concurrent upserts from other processes
+func (s *Store) UpsertRelease(rel *models.Release) error {
+	return s.Transaction(func(tx store.Store) error {
+		return store.Upsert(tx, rel)
+	})
+}
+
+//Releases returns every release
+func (s *Store) Releases() ([]models.Release, error) {
+	var rels []models.Release
+	err := s.db.C(relColName).Find(nil).Sort("_id").All(&rels)
+	return rels, err
+}
+
+//DeleteRelease removes the release with the given ID
+func (s *Store) DeleteRelease(id bson.ObjectId) error {
+	return wrapErr(s.db.C(relColName).RemoveId(id))
+}
+
+//UpdateRelease replaces the stored release with rel
+func (s *Store) UpdateRelease(rel *models.Release) error {
+	rel.NormName = store.NormalizeName(rel.Name)
+	return wrapErr(s.db.C(relColName).UpdateId(rel.ID, rel))
+}
+
+//ReleasesToEnrich returns up to limit releases that were never enriched or
+// were last enriched before staleBefore
+func (s *Store) ReleasesToEnrich(
+	staleBefore time.Time,
+	limit int,
+) ([]models.Release, error) {
+	var rels []models.Release
+	err := s.db.
+		C(relColName).
+		Find(enrichFinder(staleBefore)).
+		Limit(limit).
+		All(&rels)
+	return rels, err
+}
@@ -0,0 +1,35 @@
+package mongo
+
+import (
+	"time"
+
+	"github.com/waelbendhia/music-streaming/wms/models"
+	"github.com/waelbendhia/music-streaming/wms/store"
+	"gopkg.in/mgo.v2/bson"
+)
+
+//InsertStatistic records a listen
+func (s *Store) InsertStatistic(stat *models.Statistic) error {
+	return s.db.C(statColName).Insert(stat)
+}
+
+//TopTracks returns the limit most listened tracks since the given time
+func (s *Store) TopTracks(since time.Time, limit int) ([]store.TrackCount, error) {
+	var counts []store.TrackCount
+	err := s.db.C(statColName).Pipe([]bson.M{
+		{"$match": bson.M{"timestamp": bson.M{"$gte": since}}},
+		{"$group": bson.M{"_id": "$track_id", "count": bson.M{"$sum": 1}}},
+		{"$sort": bson.M{"count": -1}},
+		{"$limit": limit},
+	}).All(&counts)
+	return counts, err
+}
+
+//ReassignStatistics moves the listens of a track to another
+func (s *Store) ReassignStatistics(fromTrackID, toTrackID string) error {
+	_, err := s.db.C(statColName).UpdateAll(
+		bson.M{"track_id": fromTrackID},
+		bson.M{"$set": bson.M{"track_id": toTrackID}},
+	)
+	return err
+}
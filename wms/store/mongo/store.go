@@ -0,0 +1,110 @@
+package mongo
+
+import (
+	"log"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/waelbendhia/music-streaming/wms/migrate"
+	"github.com/waelbendhia/music-streaming/wms/store"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	artistColName = "artist"
+	relColName    = "release"
+	trackColName  = "track"
+	statColName   = "statistics"
+	jobColName    = "job"
+	aliasColName  = "alias"
+	userColName   = "user"
+)
+
+//Store is a MongoDB storage backend
+type Store struct {
+	db     *mgo.Database
+	logger *log.Logger
+	txMu   sync.Mutex
+}
+
+var (
+	_ store.Store    = &Store{}
+	_ store.Migrator = &Store{}
+)
+
+//Open database on host, returns error if fails, migrations are logged to
+// logger
+func Open(host, database string, logger *log.Logger) (*Store, error) {
+	session, err := mgo.Dial(host)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{db: session.DB(database), logger: logger}, nil
+}
+
+//Ping the server
+func (s *Store) Ping() error {
+	return s.db.Session.Ping()
+}
+
+//Close the database session
+func (s *Store) Close() error {
+	s.db.Session.Close()
+	return nil
+}
+
+//Transaction calls fn with s, Mongo has no multi-document transactions so
+// they are only serialized within the process and changes are not rolled
+// back if fn fails
+func (s *Store) Transaction(fn func(store.Store) error) error {
+	s.txMu.Lock()
+	defer s.txMu.Unlock()
+	return fn(s)
+}
+
+//MigrateUp applies pending migrations
+func (s *Store) MigrateUp() (int, error) {
+	return migrate.New(s.db, s.logger).Up()
+}
+
+//MigrateDown reverts the last steps applied migrations
+func (s *Store) MigrateDown(steps int) error {
+	return migrate.New(s.db, s.logger).Down(steps)
+}
+
+//MigrationStatus lists the known migrations
+func (s *Store) MigrationStatus() ([]store.MigrationStatus, error) {
+	statuses, err := migrate.New(s.db, s.logger).Status()
+	if err != nil {
+		return nil, err
+	}
+	res := make([]store.MigrationStatus, len(statuses))
+	for i, st := range statuses {
+		res[i] = store.MigrationStatus(st)
+	}
+	return res, nil
+}
+
+//wrapErr translates mgo errors to store errors
+func wrapErr(err error) error {
+	switch {
+	case err == mgo.ErrNotFound:
+		return store.ErrNotFound
+	case mgo.IsDup(err):
+		return store.ErrDuplicate
+	}
+	return err
+}
+
+func enrichFinder(staleBefore time.Time) bson.M {
+	return bson.M{"$or": []bson.M{
+		{"last_enriched": bson.M{"$exists": false}},
+		{"last_enriched": bson.M{"$lt": staleBefore}},
+	}}
+}
+
+func containsFinder(query string) bson.RegEx {
+	return bson.RegEx{Pattern: regexp.QuoteMeta(query), Options: "i"}
+}
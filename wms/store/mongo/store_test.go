@@ -0,0 +1,45 @@
+package mongo_test
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/waelbendhia/music-streaming/wms/store"
+	"github.com/waelbendhia/music-streaming/wms/store/mongo"
+	"github.com/waelbendhia/music-streaming/wms/store/storetest"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+//urlEnv names the server the tests run against, they are skipped if unset
+const urlEnv = "WMS_TEST_MONGODB_URL"
+
+func TestStore(t *testing.T) {
+	url := os.Getenv(urlEnv)
+	if url == "" {
+		t.Skipf("set %s to run the MongoDB tests", urlEnv)
+	}
+	storetest.Run(t, func(t *testing.T) store.Store {
+		// Every test gets its own database, dropped once it's done
+		database := "wms_test_" + bson.NewObjectId().Hex()
+		session, err := mgo.Dial(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() {
+			session.DB(database).DropDatabase()
+			session.Close()
+		})
+		s, err := mongo.Open(url, database, log.New(ioutil.Discard, "", 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.MigrateUp(); err != nil {
+			s.Close()
+			t.Fatal(err)
+		}
+		return s
+	})
+}
@@ -0,0 +1,41 @@
+package mongo
+
+import (
+	"github.com/waelbendhia/music-streaming/wms/models"
+	"gopkg.in/mgo.v2/bson"
+)
+
+//Track by ID
+func (s *Store) Track(id bson.ObjectId) (*models.Track, error) {
+	var track models.Track
+	if err := s.db.C(trackColName).FindId(id).One(&track); err != nil {
+		return nil, wrapErr(err)
+	}
+	return &track, nil
+}
+
+//TracksByArtist returns the tracks of an artist
+func (s *Store) TracksByArtist(artistID string) ([]models.Track, error) {
+	var tracks []models.Track
+	err := s.db.
+		C(trackColName).
+		Find(bson.M{"artist_id": artistID}).
+		All(&tracks)
+	return tracks, err
+}
+
+//InsertTrack into db
+func (s *Store) InsertTrack(track *models.Track) error {
+	track.ID = bson.NewObjectId()
+	return wrapErr(s.db.C(trackColName).Insert(track))
+}
+
+//UpdateTrack replaces the stored track with track
+func (s *Store) UpdateTrack(track *models.Track) error {
+	return wrapErr(s.db.C(trackColName).UpdateId(track.ID, track))
+}
+
+//DeleteTrack removes the track with the given ID
+func (s *Store) DeleteTrack(id bson.ObjectId) error {
+	return wrapErr(s.db.C(trackColName).RemoveId(id))
+}
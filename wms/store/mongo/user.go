@@ -0,0 +1,30 @@
+package mongo
+
+import (
+	"github.com/waelbendhia/music-streaming/wms/models"
+	"gopkg.in/mgo.v2/bson"
+)
+
+//UserByName returns the user with the given name
+func (s *Store) UserByName(name string) (*models.User, error) {
+	return s.findUser(bson.M{"name": name})
+}
+
+//UserByToken returns the user whose token hashes to tokenHash
+func (s *Store) UserByToken(tokenHash string) (*models.User, error) {
+	return s.findUser(bson.M{"token_hash": tokenHash})
+}
+
+func (s *Store) findUser(finder bson.M) (*models.User, error) {
+	var user models.User
+	if err := s.db.C(userColName).Find(finder).One(&user); err != nil {
+		return nil, wrapErr(err)
+	}
+	return &user, nil
+}
+
+//InsertUser stores a new user and sets its ID
+func (s *Store) InsertUser(user *models.User) error {
+	user.ID = bson.NewObjectId()
+	return wrapErr(s.db.C(userColName).Insert(user))
+}
@@ -0,0 +1,44 @@
+package store
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+//folds spells out the letters that don't decompose into a base letter and
+// accents
+var folds = map[rune]string{
+	'æ': "ae",
+	'œ': "oe",
+	'ø': "o",
+	'ß': "ss",
+	'ð': "d",
+	'đ': "d",
+	'þ': "th",
+	'ł': "l",
+	'ı': "i",
+}
+
+//NormalizeName returns the key names are matched by, it ignores case,
+// accents, punctuation and spacing so "Sigur Rós" and "sigur ros" or
+// "AC/DC" and "AC DC" are the same artist
+func NormalizeName(name string) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(name) {
+		r = unicode.ToLower(r)
+		switch fold, ok := folds[r]; {
+		case ok:
+			b.WriteString(fold)
+		case unicode.Is(unicode.Mn, r):
+		case r == '&':
+			b.WriteString(" and ")
+		case unicode.IsLetter(r) || unicode.IsNumber(r):
+			b.WriteRune(r)
+		default:
+			b.WriteByte(' ')
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
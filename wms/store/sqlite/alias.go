@@ -0,0 +1,34 @@
+package sqlite
+
+//Alias returns the ID of the record key is an alias of
+func (s *Store) Alias(kind, key string) (string, error) {
+	var id string
+	err := s.q.QueryRow(
+		"SELECT target_id FROM alias WHERE kind = ? AND key = ?",
+		kind,
+		key,
+	).Scan(&id)
+	return id, wrapErr(err)
+}
+
+//SetAlias makes key an alias of the record with ID targetID
+func (s *Store) SetAlias(kind, key, targetID string) error {
+	_, err := s.q.Exec(
+		"INSERT OR REPLACE INTO alias (kind, key, target_id) VALUES (?, ?, ?)",
+		kind,
+		key,
+		targetID,
+	)
+	return err
+}
+
+//RetargetAliases makes the aliases of a record aliases of another
+func (s *Store) RetargetAliases(kind, fromID, toID string) error {
+	_, err := s.q.Exec(
+		"UPDATE alias SET target_id = ? WHERE kind = ? AND target_id = ?",
+		toID,
+		kind,
+		fromID,
+	)
+	return err
+}
@@ -0,0 +1,167 @@
+package sqlite
+
+import (
+	"time"
+
+	"github.com/waelbendhia/music-streaming/wms/models"
+	"github.com/waelbendhia/music-streaming/wms/store"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const artistColumns = `id, name, norm_name, mbid, image_url, image, bio,
+	genres, related_artist_ids, stub, last_enriched`
+
+func scanArtist(row scanner) (*models.Artist, error) {
+	var (
+		artist              models.Artist
+		id, genres, related string
+		lastEnriched        int64
+	)
+	err := row.Scan(
+		&id,
+		&artist.Name,
+		&artist.NormName,
+		&artist.MBID,
+		&artist.ImageURL,
+		&artist.Image,
+		&artist.Bio,
+		&genres,
+		&related,
+		&artist.Stub,
+		&lastEnriched,
+	)
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	artist.ID = toID(id)
+	artist.LastEnriched = fromUnix(lastEnriched)
+	if err := fromJSON(genres, &artist.Genres); err != nil {
+		return nil, err
+	}
+	return &artist, fromJSON(related, &artist.RelatedArtistIDs)
+}
+
+func (s *Store) queryArtists(
+	query string,
+	args ...interface{},
+) ([]models.Artist, error) {
+	rows, err := s.q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var artists []models.Artist
+	for rows.Next() {
+		artist, err := scanArtist(rows)
+		if err != nil {
+			return nil, err
+		}
+		artists = append(artists, *artist)
+	}
+	return artists, rows.Err()
+}
+
+//Artist by ID
+func (s *Store) Artist(id bson.ObjectId) (*models.Artist, error) {
+	return scanArtist(s.q.QueryRow(
+		"SELECT "+artistColumns+" FROM artist WHERE id = ?",
+		id.Hex(),
+	))
+}
+
+//ArtistByName returns the artist whose name normalizes like name
+func (s *Store) ArtistByName(name string) (*models.Artist, error) {
+	return scanArtist(s.q.QueryRow(
+		"SELECT "+artistColumns+" FROM artist WHERE norm_name = ?",
+		store.NormalizeName(name),
+	))
+}
+
+//ArtistByMBID returns the artist with the given MusicBrainz ID
+func (s *Store) ArtistByMBID(mbid string) (*models.Artist, error) {
+	if mbid == "" {
+		return nil, store.ErrNotFound
+	}
+	return scanArtist(s.q.QueryRow(
+		"SELECT "+artistColumns+" FROM artist WHERE mbid = ?",
+		mbid,
+	))
+}
+
+//InsertArtist stores a new artist and sets its ID
+func (s *Store) InsertArtist(artist *models.Artist) error {
+	genres, err := toJSON(artist.Genres)
+	if err != nil {
+		return err
+	}
+	related, err := toJSON(artist.RelatedArtistIDs)
+	if err != nil {
+		return err
+	}
+	id := bson.NewObjectId()
+	_, err = s.q.Exec(
+		"INSERT INTO artist ("+artistColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		id.Hex(),
+		artist.Name,
+		store.NormalizeName(artist.Name),
+		artist.MBID,
+		artist.ImageURL,
+		artist.Image,
+		artist.Bio,
+		genres,
+		related,
+		artist.Stub,
+		toUnix(artist.LastEnriched),
+	)
+	if err != nil {
+		return wrapErr(err)
+	}
+	artist.ID = id
+	artist.NormName = store.NormalizeName(artist.Name)
+	return nil
+}
+
+//UpdateArtist replaces the stored artist with artist
+func (s *Store) UpdateArtist(artist *models.Artist) error {
+	genres, err := toJSON(artist.Genres)
+	if err != nil {
+		return err
+	}
+	related, err := toJSON(artist.RelatedArtistIDs)
+	if err != nil {
+		return err
+	}
+	artist.NormName = store.NormalizeName(artist.Name)
+	return updated(s.q.Exec(
+		`UPDATE artist SET name = ?, norm_name = ?, mbid = ?, image_url = ?,
+		image = ?, bio = ?, genres = ?, related_artist_ids = ?, stub = ?,
+		last_enriched = ?
+		WHERE id = ?`,
+		artist.Name,
+		artist.NormName,
+		artist.MBID,
+		artist.ImageURL,
+		artist.Image,
+		artist.Bio,
+		genres,
+		related,
+		artist.Stub,
+		toUnix(artist.LastEnriched),
+		artist.ID.Hex(),
+	))
+}
+
+//ArtistsToEnrich returns up to limit artists that were never enriched or
+// were last enriched before staleBefore
+func (s *Store) ArtistsToEnrich(
+	staleBefore time.Time,
+	limit int,
+) ([]models.Artist, error) {
+	return s.queryArtists(
+		"SELECT "+artistColumns+` FROM artist WHERE last_enriched < ?
+		ORDER BY id LIMIT ?`,
+		toUnix(staleBefore),
+		limit,
+	)
+}
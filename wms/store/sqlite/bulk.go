@@ -0,0 +1,43 @@
+package sqlite
+
+import (
+	"github.com/waelbendhia/music-streaming/wms/models"
+	"gopkg.in/mgo.v2/bson"
+)
+
+//Artists returns every artist
+func (s *Store) Artists() ([]models.Artist, error) {
+	return s.queryArtists("SELECT " + artistColumns + " FROM artist ORDER BY id")
+}
+
+//DeleteArtist removes the artist with the given ID
+func (s *Store) DeleteArtist(id bson.ObjectId) error {
+	return updated(s.q.Exec("DELETE FROM artist WHERE id = ?", id.Hex()))
+}
+
+//Releases returns every release
+func (s *Store) Releases() ([]models.Release, error) {
+	return s.queryReleases(
+		"SELECT " + releaseColumns + " FROM release ORDER BY id",
+	)
+}
+
+//DeleteRelease removes the release with the given ID
+func (s *Store) DeleteRelease(id bson.ObjectId) error {
+	return updated(s.q.Exec("DELETE FROM release WHERE id = ?", id.Hex()))
+}
+
+//DeleteTrack removes the track with the given ID
+func (s *Store) DeleteTrack(id bson.ObjectId) error {
+	return updated(s.q.Exec("DELETE FROM track WHERE id = ?", id.Hex()))
+}
+
+//ReassignStatistics moves the listens of a track to another
+func (s *Store) ReassignStatistics(fromTrackID, toTrackID string) error {
+	_, err := s.q.Exec(
+		"UPDATE statistic SET track_id = ? WHERE track_id = ?",
+		toTrackID,
+		fromTrackID,
+	)
+	return err
+}
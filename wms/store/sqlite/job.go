@@ -0,0 +1,181 @@
+package sqlite
+
+import (
+	"strings"
+
+	"github.com/waelbendhia/music-streaming/wms/models"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const jobColumns = `id, release_id, name, info_hash, magnet, state, priority,
+	user, error, download_rate, upload_rate, max_conns, size, dir, candidates,
+	attempts, metainfo, files, created_at, updated_at`
+
+func scanJob(row scanner) (*models.Job, error) {
+	var (
+		job                             models.Job
+		id, candidates, attempts, files string
+		createdAt, updatedAt            int64
+	)
+	err := row.Scan(
+		&id,
+		&job.ReleaseID,
+		&job.Name,
+		&job.InfoHash,
+		&job.Magnet,
+		&job.State,
+		&job.Priority,
+		&job.User,
+		&job.Error,
+		&job.Limits.DownloadRate,
+		&job.Limits.UploadRate,
+		&job.Limits.MaxConns,
+		&job.Size,
+		&job.Dir,
+		&candidates,
+		&attempts,
+		&job.MetaInfo,
+		&files,
+		&createdAt,
+		&updatedAt,
+	)
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	job.ID = toID(id)
+	job.CreatedAt = fromUnix(createdAt)
+	job.UpdatedAt = fromUnix(updatedAt)
+	if err := fromJSON(candidates, &job.Candidates); err != nil {
+		return nil, err
+	}
+	if err := fromJSON(attempts, &job.Attempts); err != nil {
+		return nil, err
+	}
+	return &job, fromJSON(files, &job.Files)
+}
+
+//Job by ID
+func (s *Store) Job(id bson.ObjectId) (*models.Job, error) {
+	return scanJob(s.q.QueryRow(
+		"SELECT "+jobColumns+" FROM job WHERE id = ?",
+		id.Hex(),
+	))
+}
+
+//Jobs in any of the given states, or all jobs, oldest first
+func (s *Store) Jobs(states ...models.JobState) ([]models.Job, error) {
+	query := "SELECT " + jobColumns + " FROM job"
+	args := make([]interface{}, len(states))
+	if len(states) > 0 {
+		for i, state := range states {
+			args[i] = state
+		}
+		query += " WHERE state IN (?" +
+			strings.Repeat(", ?", len(states)-1) +
+			")"
+	}
+	rows, err := s.q.Query(query+" ORDER BY created_at, id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var jobs []models.Job
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, *job)
+	}
+	return jobs, rows.Err()
+}
+
+//InsertJob stores a new job and sets its ID
+func (s *Store) InsertJob(job *models.Job) error {
+	candidates, err := toJSON(job.Candidates)
+	if err != nil {
+		return err
+	}
+	attempts, err := toJSON(job.Attempts)
+	if err != nil {
+		return err
+	}
+	files, err := toJSON(job.Files)
+	if err != nil {
+		return err
+	}
+	id := bson.NewObjectId()
+	_, err = s.q.Exec(
+		"INSERT INTO job ("+jobColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		id.Hex(),
+		job.ReleaseID,
+		job.Name,
+		job.InfoHash,
+		job.Magnet,
+		job.State,
+		job.Priority,
+		job.User,
+		job.Error,
+		job.Limits.DownloadRate,
+		job.Limits.UploadRate,
+		job.Limits.MaxConns,
+		job.Size,
+		job.Dir,
+		candidates,
+		attempts,
+		job.MetaInfo,
+		files,
+		toUnix(job.CreatedAt),
+		toUnix(job.UpdatedAt),
+	)
+	if err != nil {
+		return wrapErr(err)
+	}
+	job.ID = id
+	return nil
+}
+
+//UpdateJob replaces the stored job with job
+func (s *Store) UpdateJob(job *models.Job) error {
+	candidates, err := toJSON(job.Candidates)
+	if err != nil {
+		return err
+	}
+	attempts, err := toJSON(job.Attempts)
+	if err != nil {
+		return err
+	}
+	files, err := toJSON(job.Files)
+	if err != nil {
+		return err
+	}
+	return updated(s.q.Exec(
+		`UPDATE job SET release_id = ?, name = ?, info_hash = ?, magnet = ?,
+		state = ?, priority = ?, user = ?, error = ?, download_rate = ?,
+		upload_rate = ?, max_conns = ?, size = ?, dir = ?, candidates = ?,
+		attempts = ?, metainfo = ?, files = ?, created_at = ?,
+		updated_at = ?
+		WHERE id = ?`,
+		job.ReleaseID,
+		job.Name,
+		job.InfoHash,
+		job.Magnet,
+		job.State,
+		job.Priority,
+		job.User,
+		job.Error,
+		job.Limits.DownloadRate,
+		job.Limits.UploadRate,
+		job.Limits.MaxConns,
+		job.Size,
+		job.Dir,
+		candidates,
+		attempts,
+		job.MetaInfo,
+		files,
+		toUnix(job.CreatedAt),
+		toUnix(job.UpdatedAt),
+		job.ID.Hex(),
+	))
+}
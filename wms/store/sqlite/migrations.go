@@ -0,0 +1,401 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/waelbendhia/music-streaming/wms/store"
+)
+
+//ErrIrreversible is returned when reverting a migration that has no down
+// statements
+var ErrIrreversible = errors.New("migration can't be reverted")
+
+type migration struct {
+	version  int
+	name     string
+	up, down string
+	//fn runs after the up statements for changes that can't be made in SQL
+	fn func(tx *sql.Tx) error
+}
+
+//migrations in the order they are applied, versions must be increasing and
+// released migrations must never be edited, add a new one instead
+var migrations = []migration{
+	{
+		version: 1,
+		name:    "create_catalog",
+		up: `
+CREATE TABLE artist (
+	id TEXT PRIMARY KEY,
+	name TEXT NOT NULL UNIQUE,
+	mbid TEXT NOT NULL DEFAULT '',
+	image_url TEXT NOT NULL DEFAULT '',
+	bio TEXT NOT NULL DEFAULT '',
+	genres TEXT NOT NULL DEFAULT '',
+	related_artist_ids TEXT NOT NULL DEFAULT '',
+	stub INTEGER NOT NULL DEFAULT 0,
+	last_enriched INTEGER NOT NULL DEFAULT 0
+);
+CREATE TABLE release (
+	id TEXT PRIMARY KEY,
+	mbid TEXT NOT NULL DEFAULT '',
+	group_mbid TEXT NOT NULL DEFAULT '',
+	release_date INTEGER NOT NULL DEFAULT 0,
+	name TEXT NOT NULL,
+	type TEXT NOT NULL DEFAULT '',
+	edition TEXT NOT NULL DEFAULT '',
+	label TEXT NOT NULL DEFAULT '',
+	catalog_number TEXT NOT NULL DEFAULT '',
+	album_artist_id TEXT NOT NULL,
+	cover_url TEXT NOT NULL DEFAULT '',
+	genres TEXT NOT NULL DEFAULT '',
+	discs TEXT NOT NULL DEFAULT '',
+	track_ids TEXT NOT NULL DEFAULT '',
+	last_enriched INTEGER NOT NULL DEFAULT 0,
+	UNIQUE (album_artist_id, name)
+);
+CREATE TABLE track (
+	id TEXT PRIMARY KEY,
+	mbid TEXT NOT NULL DEFAULT '',
+	isrcs TEXT NOT NULL DEFAULT '',
+	name TEXT NOT NULL,
+	disc_number INTEGER NOT NULL DEFAULT 0,
+	track_number INTEGER NOT NULL DEFAULT 0,
+	length INTEGER NOT NULL DEFAULT 0,
+	track_url TEXT NOT NULL DEFAULT '',
+	genre TEXT NOT NULL DEFAULT '',
+	artist_id TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX track_artist_id ON track (artist_id);
+CREATE INDEX track_track_url ON track (track_url);
+CREATE TABLE statistic (
+	track_id TEXT NOT NULL,
+	listener TEXT NOT NULL,
+	timestamp INTEGER NOT NULL
+);
+CREATE INDEX statistic_timestamp ON statistic (timestamp);
+`,
+		down: `
+DROP TABLE statistic;
+DROP TABLE track;
+DROP TABLE release;
+DROP TABLE artist;
+`,
+	}, {
+		version: 2,
+		name:    "release_search",
+		up: `
+CREATE VIRTUAL TABLE release_fts USING fts5(name, release_id UNINDEXED);
+INSERT INTO release_fts (name, release_id) SELECT name, id FROM release;
+CREATE TRIGGER release_fts_insert AFTER INSERT ON release BEGIN
+	INSERT INTO release_fts (name, release_id) VALUES (new.name, new.id);
+END;
+CREATE TRIGGER release_fts_update AFTER UPDATE OF name ON release BEGIN
+	UPDATE release_fts SET name = new.name WHERE release_id = old.id;
+END;
+CREATE TRIGGER release_fts_delete AFTER DELETE ON release BEGIN
+	DELETE FROM release_fts WHERE release_id = old.id;
+END;
+`,
+		down: `
+DROP TRIGGER release_fts_delete;
+DROP TRIGGER release_fts_update;
+DROP TRIGGER release_fts_insert;
+DROP TABLE release_fts;
+`,
+	}, {
+		version: 3,
+		name:    "create_jobs",
+		up: `
+CREATE TABLE job (
+	id TEXT PRIMARY KEY,
+	release_id TEXT NOT NULL DEFAULT '',
+	name TEXT NOT NULL DEFAULT '',
+	info_hash TEXT NOT NULL DEFAULT '',
+	magnet TEXT NOT NULL DEFAULT '',
+	state TEXT NOT NULL,
+	priority INTEGER NOT NULL DEFAULT 0,
+	user TEXT NOT NULL DEFAULT '',
+	error TEXT NOT NULL DEFAULT '',
+	created_at INTEGER NOT NULL,
+	updated_at INTEGER NOT NULL
+);
+CREATE INDEX job_state ON job (state, created_at);
+`,
+		down: `DROP TABLE job;`,
+	}, {
+		// SQLite can't drop the columns, so this one can't be reverted
+		version: normalizedNamesVersion,
+		name:    "normalized_names",
+		up: `
+ALTER TABLE artist ADD COLUMN norm_name TEXT NOT NULL DEFAULT '';
+ALTER TABLE release ADD COLUMN norm_name TEXT NOT NULL DEFAULT '';
+`,
+		fn: normalizeNames,
+	}, {
+		version: 5,
+		name:    "create_aliases",
+		up: `
+CREATE TABLE alias (
+	kind TEXT NOT NULL,
+	key TEXT NOT NULL,
+	target_id TEXT NOT NULL,
+	PRIMARY KEY (kind, key)
+);
+CREATE INDEX alias_target_id ON alias (kind, target_id);
+`,
+		down: `DROP TABLE alias;`,
+	}, {
+		version: 6,
+		name:    "create_users",
+		up: `
+CREATE TABLE user (
+	id TEXT PRIMARY KEY,
+	name TEXT NOT NULL UNIQUE,
+	token_hash TEXT NOT NULL UNIQUE,
+	admin INTEGER NOT NULL DEFAULT 0,
+	created_at INTEGER NOT NULL
+);
+`,
+		down: `DROP TABLE user;`,
+	}, {
+		// SQLite can't drop the columns, so this one can't be reverted
+		version: 7,
+		name:    "job_limits",
+		up: `
+ALTER TABLE job ADD COLUMN download_rate INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE job ADD COLUMN upload_rate INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE job ADD COLUMN max_conns INTEGER NOT NULL DEFAULT 0;
+`,
+	}, {
+		// SQLite can't drop the columns, so this one can't be reverted
+		version: 8,
+		name:    "job_size",
+		up: `
+ALTER TABLE job ADD COLUMN size INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE job ADD COLUMN dir TEXT NOT NULL DEFAULT '';
+`,
+	}, {
+		version: 9,
+		name:    "job_candidates",
+		up: `
+ALTER TABLE job ADD COLUMN candidates TEXT NOT NULL DEFAULT '';
+`,
+	}, {
+		version: 10,
+		name:    "job_attempts",
+		up: `
+ALTER TABLE job ADD COLUMN attempts TEXT NOT NULL DEFAULT '';
+`,
+	}, {
+		version: 11,
+		name:    "job_metainfo",
+		up: `
+ALTER TABLE job ADD COLUMN metainfo BLOB;
+`,
+	},
+	{
+		version: 12,
+		name:    "job_files",
+		up: `
+ALTER TABLE job ADD COLUMN files TEXT NOT NULL DEFAULT '';
+`,
+	},
+	{
+		version: 13,
+		name:    "covers",
+		up: `
+ALTER TABLE release ADD COLUMN cover TEXT NOT NULL DEFAULT '';
+ALTER TABLE artist ADD COLUMN image TEXT NOT NULL DEFAULT '';
+`,
+	},
+}
+
+//normalizeNames stores the normalized name of artists and releases, which
+// upserts identify them by, and indexes it. Names that collide once
+// normalized, like "AC/DC" and "AC DC", get a non-unique index so startup
+// isn't blocked, uniqueNames reports them and makes the index unique once
+// they are merged
+func normalizeNames(tx *sql.Tx) error {
+	for _, table := range []string{"artist", "release"} {
+		rows, err := tx.Query("SELECT id, name FROM " + table)
+		if err != nil {
+			return err
+		}
+		names := make(map[string]string)
+		for rows.Next() {
+			var id, name string
+			if err := rows.Scan(&id, &name); err != nil {
+				rows.Close()
+				return err
+			}
+			names[id] = store.NormalizeName(name)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		for id, name := range names {
+			_, err := tx.Exec(
+				"UPDATE "+table+" SET norm_name = ? WHERE id = ?",
+				name,
+				id,
+			)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for _, idx := range normalizedIndexes {
+		_, err := tx.Exec(idx.create(true))
+		if wrapErr(err) == store.ErrDuplicate {
+			_, err = tx.Exec(idx.create(false))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	_, err := tx.Exec(`
+CREATE INDEX artist_mbid ON artist (mbid);
+CREATE INDEX release_mbid ON release (mbid);
+`)
+	return err
+}
+
+const createMigrationsTable = `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+	version INTEGER PRIMARY KEY,
+	name TEXT NOT NULL,
+	applied_at INTEGER NOT NULL
+)`
+
+//applied returns the applied migrations by version
+func applied(q queryer) (map[int]time.Time, error) {
+	if _, err := q.Exec(createMigrationsTable); err != nil {
+		return nil, err
+	}
+	rows, err := q.Query("SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := make(map[int]time.Time)
+	for rows.Next() {
+		var (
+			version int
+			at      int64
+		)
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		res[version] = fromUnix(at)
+	}
+	return res, rows.Err()
+}
+
+//MigrateUp applies pending migrations, each in its own transaction
+func (s *Store) MigrateUp() (int, error) {
+	done, err := applied(s.db)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, m := range migrations {
+		if _, ok := done[m.version]; ok {
+			continue
+		}
+		s.logger.Printf("Applying migration %d: %s", m.version, m.name)
+		err := s.inTx(func(tx *sql.Tx) error {
+			if _, err := tx.Exec(m.up); err != nil {
+				return err
+			}
+			if m.fn != nil {
+				if err := m.fn(tx); err != nil {
+					return err
+				}
+			}
+			_, err := tx.Exec(
+				"INSERT INTO schema_migrations VALUES (?, ?, ?)",
+				m.version,
+				m.name,
+				toUnix(time.Now()),
+			)
+			return err
+		})
+		if err != nil {
+			return count, fmt.Errorf(
+				"migration %d %s failed: %v",
+				m.version,
+				m.name,
+				err,
+			)
+		}
+		count++
+	}
+	return count, s.uniqueNames()
+}
+
+//MigrateDown reverts the last steps applied migrations
+func (s *Store) MigrateDown(steps int) error {
+	done, err := applied(s.db)
+	if err != nil {
+		return err
+	}
+	for i := len(migrations) - 1; i >= 0 && steps > 0; i-- {
+		m := migrations[i]
+		if _, ok := done[m.version]; !ok {
+			continue
+		}
+		if m.down == "" {
+			return fmt.Errorf(
+				"migration %d %s: %v",
+				m.version,
+				m.name,
+				ErrIrreversible,
+			)
+		}
+		s.logger.Printf("Reverting migration %d: %s", m.version, m.name)
+		err := s.inTx(func(tx *sql.Tx) error {
+			if _, err := tx.Exec(m.down); err != nil {
+				return err
+			}
+			_, err := tx.Exec(
+				"DELETE FROM schema_migrations WHERE version = ?",
+				m.version,
+			)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf(
+				"reverting migration %d %s failed: %v",
+				m.version,
+				m.name,
+				err,
+			)
+		}
+		steps--
+	}
+	return nil
+}
+
+//MigrationStatus lists the known migrations
+func (s *Store) MigrationStatus() ([]store.MigrationStatus, error) {
+	done, err := applied(s.db)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]store.MigrationStatus, len(migrations))
+	for i, m := range migrations {
+		at, ok := done[m.version]
+		res[i] = store.MigrationStatus{
+			Version:   m.version,
+			Name:      m.name,
+			Applied:   ok,
+			AppliedAt: at,
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,258 @@
+package sqlite
+
+import (
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/waelbendhia/music-streaming/wms/models"
+	"github.com/waelbendhia/music-streaming/wms/store"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const releaseColumns = `release.id, release.mbid, release.group_mbid,
+	release.release_date, release.name, release.norm_name, release.type,
+	release.edition, release.label, release.catalog_number,
+	release.album_artist_id, release.cover_url, release.cover, release.genres,
+	release.discs, release.track_ids, release.last_enriched`
+
+func scanRelease(row scanner) (*models.Release, error) {
+	var (
+		rel                         models.Release
+		id, genres, discs, trackIDs string
+		releaseDate, lastEnriched   int64
+	)
+	err := row.Scan(
+		&id,
+		&rel.MBID,
+		&rel.GroupMBID,
+		&releaseDate,
+		&rel.Name,
+		&rel.NormName,
+		&rel.Type,
+		&rel.Edition,
+		&rel.Label,
+		&rel.CatalogNumber,
+		&rel.AlbumArtistID,
+		&rel.CoverURL,
+		&rel.Cover,
+		&genres,
+		&discs,
+		&trackIDs,
+		&lastEnriched,
+	)
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	rel.ID = toID(id)
+	rel.ReleaseDate = fromUnix(releaseDate)
+	rel.LastEnriched = fromUnix(lastEnriched)
+	for _, list := range []struct {
+		json string
+		v    interface{}
+	}{
+		{genres, &rel.Genres},
+		{discs, &rel.Discs},
+		{trackIDs, &rel.TrackIDs},
+	} {
+		if err := fromJSON(list.json, list.v); err != nil {
+			return nil, err
+		}
+	}
+	return &rel, nil
+}
+
+func (s *Store) queryReleases(
+	query string,
+	args ...interface{},
+) ([]models.Release, error) {
+	rows, err := s.q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var rels []models.Release
+	for rows.Next() {
+		rel, err := scanRelease(rows)
+		if err != nil {
+			return nil, err
+		}
+		rels = append(rels, *rel)
+	}
+	return rels, rows.Err()
+}
+
+//releaseLists returns the JSON encoded lists of rel
+func releaseLists(rel *models.Release) (genres, discs, trackIDs string, err error) {
+	if genres, err = toJSON(rel.Genres); err != nil {
+		return
+	}
+	if discs, err = toJSON(rel.Discs); err != nil {
+		return
+	}
+	trackIDs, err = toJSON(rel.TrackIDs)
+	return
+}
+
+//Release by ID
+func (s *Store) Release(id bson.ObjectId) (*models.Release, error) {
+	return scanRelease(s.q.QueryRow(
+		"SELECT "+releaseColumns+" FROM release WHERE id = ?",
+		id.Hex(),
+	))
+}
+
+//ReleaseByName returns the album artist's release whose name normalizes
+// like name
+func (s *Store) ReleaseByName(
+	albumArtistID, name string,
+) (*models.Release, error) {
+	return scanRelease(s.q.QueryRow(
+		"SELECT "+releaseColumns+` FROM release
+		WHERE album_artist_id = ? AND norm_name = ?`,
+		albumArtistID,
+		store.NormalizeName(name),
+	))
+}
+
+//ReleaseByMBID returns the release with the given MusicBrainz ID
+func (s *Store) ReleaseByMBID(mbid string) (*models.Release, error) {
+	if mbid == "" {
+		return nil, store.ErrNotFound
+	}
+	return scanRelease(s.q.QueryRow(
+		"SELECT "+releaseColumns+" FROM release WHERE mbid = ?",
+		mbid,
+	))
+}
+
+//SearchReleases whose name has words starting with each of query's words,
+// best matches first
+func (s *Store) SearchReleases(query string) ([]models.Release, error) {
+	match := ftsQuery(query)
+	if match == "" {
+		return nil, nil
+	}
+	return s.queryReleases(
+		"SELECT "+releaseColumns+` FROM release_fts
+		JOIN release ON release.id = release_fts.release_id
+		WHERE release_fts MATCH ? ORDER BY release_fts.rank`,
+		match,
+	)
+}
+
+//ftsQuery turns query into an FTS5 query matching every word as a prefix,
+// words are quoted so the user can't inject FTS5 syntax
+func ftsQuery(query string) string {
+	words := strings.FieldsFunc(query, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	for i, word := range words {
+		words[i] = `"` + word + `"*`
+	}
+	return strings.Join(words, " ")
+}
+
+//ReleasesByArtist returns the releases of an album artist
+func (s *Store) ReleasesByArtist(
+	albumArtistID string,
+) ([]models.Release, error) {
+	return s.queryReleases(
+		"SELECT "+releaseColumns+` FROM release
+		WHERE album_artist_id = ? ORDER BY id`,
+		albumArtistID,
+	)
+}
+
+//UpsertRelease to db along with its album artist and tracks, either all of
+// them are saved or none is
+func (s *Store) UpsertRelease(rel *models.Release) error {
+	return s.Transaction(func(tx store.Store) error {
+		return store.Upsert(tx, rel)
+	})
+}
+
+//InsertRelease stores a new release and sets its ID
+func (s *Store) InsertRelease(rel *models.Release) error {
+	genres, discs, trackIDs, err := releaseLists(rel)
+	if err != nil {
+		return err
+	}
+	id := bson.NewObjectId()
+	_, err = s.q.Exec(
+		`INSERT INTO release (id, mbid, group_mbid, release_date, name,
+		norm_name, type, edition, label, catalog_number, album_artist_id,
+		cover_url, cover, genres, discs, track_ids, last_enriched)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		id.Hex(),
+		rel.MBID,
+		rel.GroupMBID,
+		toUnix(rel.ReleaseDate),
+		rel.Name,
+		store.NormalizeName(rel.Name),
+		rel.Type,
+		rel.Edition,
+		rel.Label,
+		rel.CatalogNumber,
+		rel.AlbumArtistID,
+		rel.CoverURL,
+		rel.Cover,
+		genres,
+		discs,
+		trackIDs,
+		toUnix(rel.LastEnriched),
+	)
+	if err != nil {
+		return wrapErr(err)
+	}
+	rel.ID = id
+	rel.NormName = store.NormalizeName(rel.Name)
+	return nil
+}
+
+//UpdateRelease replaces the stored release with rel
+func (s *Store) UpdateRelease(rel *models.Release) error {
+	genres, discs, trackIDs, err := releaseLists(rel)
+	if err != nil {
+		return err
+	}
+	rel.NormName = store.NormalizeName(rel.Name)
+	return updated(s.q.Exec(
+		`UPDATE release SET mbid = ?, group_mbid = ?, release_date = ?,
+		name = ?, norm_name = ?, type = ?, edition = ?, label = ?, catalog_number = ?,
+		album_artist_id = ?, cover_url = ?, cover = ?, genres = ?,
+		discs = ?, track_ids = ?, last_enriched = ?
+		WHERE id = ?`,
+		rel.MBID,
+		rel.GroupMBID,
+		toUnix(rel.ReleaseDate),
+		rel.Name,
+		rel.NormName,
+		rel.Type,
+		rel.Edition,
+		rel.Label,
+		rel.CatalogNumber,
+		rel.AlbumArtistID,
+		rel.CoverURL,
+		rel.Cover,
+		genres,
+		discs,
+		trackIDs,
+		toUnix(rel.LastEnriched),
+		rel.ID.Hex(),
+	))
+}
+
+//ReleasesToEnrich returns up to limit releases that were never enriched or
+// were last enriched before staleBefore
+func (s *Store) ReleasesToEnrich(
+	staleBefore time.Time,
+	limit int,
+) ([]models.Release, error) {
+	return s.queryReleases(
+		"SELECT "+releaseColumns+` FROM release WHERE last_enriched < ?
+		ORDER BY id LIMIT ?`,
+		toUnix(staleBefore),
+		limit,
+	)
+}
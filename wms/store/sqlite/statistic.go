@@ -0,0 +1,43 @@
+package sqlite
+
+import (
+	"time"
+
+	"github.com/waelbendhia/music-streaming/wms/models"
+	"github.com/waelbendhia/music-streaming/wms/store"
+)
+
+//InsertStatistic records a listen
+func (s *Store) InsertStatistic(stat *models.Statistic) error {
+	_, err := s.q.Exec(
+		"INSERT INTO statistic (track_id, listener, timestamp) VALUES (?, ?, ?)",
+		stat.TrackID,
+		stat.Listener,
+		toUnix(stat.TimeStamp),
+	)
+	return err
+}
+
+//TopTracks returns the limit most listened tracks since the given time
+func (s *Store) TopTracks(since time.Time, limit int) ([]store.TrackCount, error) {
+	rows, err := s.q.Query(
+		`SELECT track_id, COUNT(*) AS count FROM statistic
+		WHERE timestamp >= ? GROUP BY track_id
+		ORDER BY count DESC, track_id LIMIT ?`,
+		toUnix(since),
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var counts []store.TrackCount
+	for rows.Next() {
+		var count store.TrackCount
+		if err := rows.Scan(&count.TrackID, &count.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, count)
+	}
+	return counts, rows.Err()
+}
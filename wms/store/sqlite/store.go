@@ -0,0 +1,170 @@
+//Package sqlite is an embedded storage backend for single user deployments,
+// full-text search needs the driver built with the fts5 tag:
+//
+//	go build -tags fts5
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"time"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+	"github.com/waelbendhia/music-streaming/wms/store"
+	"gopkg.in/mgo.v2/bson"
+)
+
+//Store is a SQLite storage backend
+type Store struct {
+	db *sql.DB
+	//q runs the queries, it is either db or the transaction the store was
+	// scoped to by withTx
+	q      queryer
+	logger *log.Logger
+}
+
+var (
+	_ store.Store    = &Store{}
+	_ store.Migrator = &Store{}
+)
+
+//queryer is implemented by both *sql.DB and *sql.Tx
+type queryer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+//scanner is implemented by both *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+//Open the database file at path, creating it if needed, ":memory:" opens a
+// database that only lives as long as the store. Migrations are logged to
+// logger
+func Open(path string, logger *log.Logger) (*Store, error) {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite only allows a single writer, sharing one connection serializes
+	// writes instead of failing them with SQLITE_BUSY, it also keeps
+	// ":memory:" databases from being opened once per connection
+	db.SetMaxOpenConns(1)
+	for _, pragma := range []string{
+		"PRAGMA journal_mode = WAL",
+		"PRAGMA synchronous = NORMAL",
+	} {
+		if _, err := db.Exec(pragma); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	return &Store{db: db, q: db, logger: logger}, nil
+}
+
+//Ping checks the database file can still be opened
+func (s *Store) Ping() error {
+	return s.db.Ping()
+}
+
+//Close the database
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+//inTx runs fn in a transaction that is committed if fn succeeds and rolled
+// back otherwise
+func (s *Store) inTx(fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+//Transaction calls fn with a store scoped to a transaction, nested
+// transactions are part of the outer one
+func (s *Store) Transaction(fn func(store.Store) error) error {
+	if _, ok := s.q.(*sql.Tx); ok {
+		return fn(s)
+	}
+	return s.inTx(func(tx *sql.Tx) error { return fn(s.withTx(tx)) })
+}
+
+//withTx returns a copy of the store that runs its queries in tx
+func (s *Store) withTx(tx *sql.Tx) *Store {
+	return &Store{db: s.db, q: tx, logger: s.logger}
+}
+
+//wrapErr translates driver errors to store errors
+func wrapErr(err error) error {
+	if err == sql.ErrNoRows {
+		return store.ErrNotFound
+	}
+	if sqlErr, ok := err.(sqlite3.Error); ok &&
+		(sqlErr.ExtendedCode == sqlite3.ErrConstraintUnique ||
+			sqlErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey) {
+		return store.ErrDuplicate
+	}
+	return err
+}
+
+//updated returns ErrNotFound if an update did not match any row
+func updated(res sql.Result, err error) error {
+	if err != nil {
+		return wrapErr(err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return store.ErrNotFound
+	}
+	return nil
+}
+
+// Times are stored as unix nanoseconds so they compare correctly whatever
+// their location, the zero time is stored as 0
+
+func toUnix(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func fromUnix(n int64) time.Time {
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n)
+}
+
+// Lists that are never queried are stored as JSON
+
+func toJSON(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+func fromJSON(s string, v interface{}) error {
+	if s == "" || s == "null" {
+		return nil
+	}
+	return json.Unmarshal([]byte(s), v)
+}
+
+func toID(hex string) bson.ObjectId {
+	if !bson.IsObjectIdHex(hex) {
+		return ""
+	}
+	return bson.ObjectIdHex(hex)
+}
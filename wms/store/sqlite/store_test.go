@@ -0,0 +1,31 @@
+// +build fts5
+
+package sqlite_test
+
+import (
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"testing"
+
+	"github.com/waelbendhia/music-streaming/wms/store"
+	"github.com/waelbendhia/music-streaming/wms/store/sqlite"
+	"github.com/waelbendhia/music-streaming/wms/store/storetest"
+)
+
+func TestStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Store {
+		s, err := sqlite.Open(
+			filepath.Join(t.TempDir(), "wms.db"),
+			log.New(ioutil.Discard, "", 0),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.MigrateUp(); err != nil {
+			s.Close()
+			t.Fatal(err)
+		}
+		return s
+	})
+}
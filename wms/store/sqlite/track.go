@@ -0,0 +1,113 @@
+package sqlite
+
+import (
+	"github.com/waelbendhia/music-streaming/wms/models"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const trackColumns = `id, mbid, isrcs, name, disc_number, track_number,
+	length, track_url, genre, artist_id`
+
+func scanTrack(row scanner) (*models.Track, error) {
+	var (
+		track     models.Track
+		id, isrcs string
+	)
+	err := row.Scan(
+		&id,
+		&track.MBID,
+		&isrcs,
+		&track.Name,
+		&track.DiscNumber,
+		&track.TrackNumber,
+		&track.Length,
+		&track.TrackURL,
+		&track.Genre,
+		&track.ArtistID,
+	)
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	track.ID = toID(id)
+	return &track, fromJSON(isrcs, &track.ISRCs)
+}
+
+//Track by ID
+func (s *Store) Track(id bson.ObjectId) (*models.Track, error) {
+	return scanTrack(s.q.QueryRow(
+		"SELECT "+trackColumns+" FROM track WHERE id = ?",
+		id.Hex(),
+	))
+}
+
+//TracksByArtist returns the tracks of an artist
+func (s *Store) TracksByArtist(artistID string) ([]models.Track, error) {
+	rows, err := s.q.Query(
+		"SELECT "+trackColumns+" FROM track WHERE artist_id = ? ORDER BY id",
+		artistID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var tracks []models.Track
+	for rows.Next() {
+		track, err := scanTrack(rows)
+		if err != nil {
+			return nil, err
+		}
+		tracks = append(tracks, *track)
+	}
+	return tracks, rows.Err()
+}
+
+//InsertTrack stores a new track and sets its ID
+func (s *Store) InsertTrack(track *models.Track) error {
+	isrcs, err := toJSON(track.ISRCs)
+	if err != nil {
+		return err
+	}
+	id := bson.NewObjectId()
+	_, err = s.q.Exec(
+		"INSERT INTO track ("+trackColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		id.Hex(),
+		track.MBID,
+		isrcs,
+		track.Name,
+		track.DiscNumber,
+		track.TrackNumber,
+		track.Length,
+		track.TrackURL,
+		track.Genre,
+		track.ArtistID,
+	)
+	if err != nil {
+		return wrapErr(err)
+	}
+	track.ID = id
+	return nil
+}
+
+//UpdateTrack replaces the stored track with track
+func (s *Store) UpdateTrack(track *models.Track) error {
+	isrcs, err := toJSON(track.ISRCs)
+	if err != nil {
+		return err
+	}
+	return updated(s.q.Exec(
+		`UPDATE track SET mbid = ?, isrcs = ?, name = ?, disc_number = ?,
+		track_number = ?, length = ?, track_url = ?, genre = ?, artist_id = ?
+		WHERE id = ?`,
+		track.MBID,
+		isrcs,
+		track.Name,
+		track.DiscNumber,
+		track.TrackNumber,
+		track.Length,
+		track.TrackURL,
+		track.Genre,
+		track.ArtistID,
+		track.ID.Hex(),
+	))
+}
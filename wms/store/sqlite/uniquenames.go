@@ -0,0 +1,106 @@
+package sqlite
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/waelbendhia/music-streaming/wms/store"
+)
+
+const normalizedNamesVersion = 4
+
+type normalizedIndex struct {
+	name, table, columns string
+}
+
+var normalizedIndexes = []normalizedIndex{
+	{"artist_norm_name", "artist", "norm_name"},
+	{"release_norm_name", "release", "album_artist_id, norm_name"},
+}
+
+func (idx normalizedIndex) create(unique bool) string {
+	kind := "INDEX"
+	if unique {
+		kind = "UNIQUE INDEX"
+	}
+	return "CREATE " + kind + " " + idx.name +
+		" ON " + idx.table + " (" + idx.columns + ")"
+}
+
+//uniqueNames makes the normalized name indexes that normalizeNames left
+// non-unique unique once no names collide, and logs the names that still do
+// so they can be merged
+func (s *Store) uniqueNames() error {
+	done, err := applied(s.db)
+	if err != nil {
+		return err
+	}
+	if _, ok := done[normalizedNamesVersion]; !ok {
+		return nil
+	}
+	for _, idx := range normalizedIndexes {
+		var def string
+		err := s.db.QueryRow(
+			"SELECT sql FROM sqlite_master WHERE type = 'index' AND name = ?",
+			idx.name,
+		).Scan(&def)
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(def, "CREATE UNIQUE") {
+			continue
+		}
+		collisions, err := s.nameCollisions(idx)
+		if err != nil {
+			return err
+		}
+		for _, names := range collisions {
+			s.logger.Printf(
+				"%ss '%s' have the same normalized name, merge them to "+
+					"make names unique",
+				idx.table,
+				names,
+			)
+		}
+		if len(collisions) > 0 {
+			continue
+		}
+		s.logger.Printf("Making %s names unique", idx.table)
+		err = s.inTx(func(tx *sql.Tx) error {
+			if _, err := tx.Exec("DROP INDEX " + idx.name); err != nil {
+				return err
+			}
+			_, err := tx.Exec(idx.create(true))
+			return err
+		})
+		// A duplicate inserted since rolls back to the non-unique index, it
+		// is reported on next start
+		if err != nil && wrapErr(err) != store.ErrDuplicate {
+			return err
+		}
+	}
+	return nil
+}
+
+//nameCollisions returns the names of the rows that share idx's columns,
+// joined by "', '"
+func (s *Store) nameCollisions(idx normalizedIndex) ([]string, error) {
+	rows, err := s.db.Query(
+		"SELECT group_concat(name, ?) FROM "+idx.table+
+			" GROUP BY "+idx.columns+" HAVING count(*) > 1",
+		"', '",
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var collisions []string
+	for rows.Next() {
+		var names string
+		if err := rows.Scan(&names); err != nil {
+			return nil, err
+		}
+		collisions = append(collisions, names)
+	}
+	return collisions, rows.Err()
+}
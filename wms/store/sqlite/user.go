@@ -0,0 +1,57 @@
+package sqlite
+
+import (
+	"github.com/waelbendhia/music-streaming/wms/models"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const userColumns = "id, name, token_hash, admin, created_at"
+
+func scanUser(row scanner) (*models.User, error) {
+	var (
+		user      models.User
+		id        string
+		createdAt int64
+	)
+	err := row.Scan(&id, &user.Name, &user.TokenHash, &user.Admin, &createdAt)
+	if err != nil {
+		return nil, wrapErr(err)
+	}
+	user.ID = toID(id)
+	user.CreatedAt = fromUnix(createdAt)
+	return &user, nil
+}
+
+//UserByName returns the user with the given name
+func (s *Store) UserByName(name string) (*models.User, error) {
+	return scanUser(s.q.QueryRow(
+		"SELECT "+userColumns+" FROM user WHERE name = ?",
+		name,
+	))
+}
+
+//UserByToken returns the user whose token hashes to tokenHash
+func (s *Store) UserByToken(tokenHash string) (*models.User, error) {
+	return scanUser(s.q.QueryRow(
+		"SELECT "+userColumns+" FROM user WHERE token_hash = ?",
+		tokenHash,
+	))
+}
+
+//InsertUser stores a new user and sets its ID
+func (s *Store) InsertUser(user *models.User) error {
+	id := bson.NewObjectId()
+	_, err := s.q.Exec(
+		"INSERT INTO user ("+userColumns+") VALUES (?, ?, ?, ?, ?)",
+		id.Hex(),
+		user.Name,
+		user.TokenHash,
+		user.Admin,
+		toUnix(user.CreatedAt),
+	)
+	if err != nil {
+		return wrapErr(err)
+	}
+	user.ID = id
+	return nil
+}
@@ -0,0 +1,178 @@
+package store
+
+import (
+	"errors"
+	"time"
+
+	"github.com/waelbendhia/music-streaming/wms/models"
+	"gopkg.in/mgo.v2/bson"
+)
+
+var (
+	//ErrNotFound is returned when the requested record does not exist
+	ErrNotFound = errors.New("not found")
+	//ErrDuplicate is returned when a record would violate a uniqueness
+	// constraint, e.g. two artists with the same name
+	ErrDuplicate = errors.New("duplicate record")
+)
+
+//ArtistStore persists artists
+type ArtistStore interface {
+	//Artist returns the artist with the given ID
+	Artist(id bson.ObjectId) (*models.Artist, error)
+	//ArtistByName returns the artist whose name normalizes like name
+	ArtistByName(name string) (*models.Artist, error)
+	//ArtistByMBID returns the artist with the given MusicBrainz ID
+	ArtistByMBID(mbid string) (*models.Artist, error)
+	//InsertArtist stores a new artist and sets its ID
+	InsertArtist(artist *models.Artist) error
+	//UpdateArtist replaces the stored artist that has the same ID
+	UpdateArtist(artist *models.Artist) error
+	//ArtistsToEnrich returns up to limit artists never enriched or last
+	// enriched before staleBefore
+	ArtistsToEnrich(staleBefore time.Time, limit int) ([]models.Artist, error)
+	//Artists returns every artist
+	Artists() ([]models.Artist, error)
+	//DeleteArtist removes the artist with the given ID
+	DeleteArtist(id bson.ObjectId) error
+}
+
+//ReleaseStore persists releases
+type ReleaseStore interface {
+	//Release returns the release with the given ID
+	Release(id bson.ObjectId) (*models.Release, error)
+	//ReleaseByName returns the release of the album artist whose name
+	// normalizes like name
+	ReleaseByName(albumArtistID, name string) (*models.Release, error)
+	//ReleaseByMBID returns the release with the given MusicBrainz ID
+	ReleaseByMBID(mbid string) (*models.Release, error)
+	//SearchReleases returns the releases whose name matches query, ignoring
+	// case, backends with a full-text index match words rather than any
+	// substring
+	SearchReleases(query string) ([]models.Release, error)
+	//ReleasesByArtist returns the releases of an album artist
+	ReleasesByArtist(albumArtistID string) ([]models.Release, error)
+	//InsertRelease stores a new release and sets its ID, its artist and
+	// tracks must already be stored
+	InsertRelease(rel *models.Release) error
+	//UpsertRelease stores rel along with its album artist and tracks as a
+	// single operation, records that already exist are merged with rel
+	// instead of duplicated, see Upsert
+	UpsertRelease(rel *models.Release) error
+	//UpdateRelease replaces the stored release that has the same ID
+	UpdateRelease(rel *models.Release) error
+	//ReleasesToEnrich returns up to limit releases never enriched or last
+	// enriched before staleBefore
+	ReleasesToEnrich(staleBefore time.Time, limit int) ([]models.Release, error)
+	//Releases returns every release
+	Releases() ([]models.Release, error)
+	//DeleteRelease removes the release with the given ID, not its tracks
+	DeleteRelease(id bson.ObjectId) error
+}
+
+//TrackStore persists tracks
+type TrackStore interface {
+	//Track returns the track with the given ID
+	Track(id bson.ObjectId) (*models.Track, error)
+	//TracksByArtist returns the tracks of an artist
+	TracksByArtist(artistID string) ([]models.Track, error)
+	//InsertTrack stores a new track and sets its ID
+	InsertTrack(track *models.Track) error
+	//UpdateTrack replaces the stored track that has the same ID
+	UpdateTrack(track *models.Track) error
+	//DeleteTrack removes the track with the given ID
+	DeleteTrack(id bson.ObjectId) error
+}
+
+//TrackCount is the number of listens of a track
+type TrackCount struct {
+	TrackID string `json:"trackID" bson:"_id"`
+	Count   int    `json:"count" bson:"count"`
+}
+
+//StatStore persists listening statistics
+type StatStore interface {
+	//InsertStatistic records a listen
+	InsertStatistic(stat *models.Statistic) error
+	//TopTracks returns the limit most listened tracks since the given time
+	TopTracks(since time.Time, limit int) ([]TrackCount, error)
+	//ReassignStatistics moves the listens of a track to another
+	ReassignStatistics(fromTrackID, toTrackID string) error
+}
+
+//JobStore persists download jobs
+type JobStore interface {
+	//Job returns the job with the given ID
+	Job(id bson.ObjectId) (*models.Job, error)
+	//Jobs returns the jobs in any of the given states, or all jobs if no
+	// state is given, oldest first
+	Jobs(states ...models.JobState) ([]models.Job, error)
+	//InsertJob stores a new job and sets its ID
+	InsertJob(job *models.Job) error
+	//UpdateJob replaces the stored job that has the same ID
+	UpdateJob(job *models.Job) error
+}
+
+//UserStore persists API users
+type UserStore interface {
+	//UserByName returns the user with the given name
+	UserByName(name string) (*models.User, error)
+	//UserByToken returns the user whose token hashes to tokenHash
+	UserByToken(tokenHash string) (*models.User, error)
+	//InsertUser stores a new user and sets its ID, names are unique
+	InsertUser(user *models.User) error
+}
+
+//Alias kinds
+const (
+	ArtistAlias  = "artist"
+	ReleaseAlias = "release"
+)
+
+//AliasStore persists the aliases of merged records, see AliasKeys
+type AliasStore interface {
+	//Alias returns the ID of the record of the given kind key is an alias of
+	Alias(kind, key string) (string, error)
+	//SetAlias makes key an alias of the record with ID targetID
+	SetAlias(kind, key, targetID string) error
+	//RetargetAliases makes the aliases of a record aliases of another
+	RetargetAliases(kind, fromID, toID string) error
+}
+
+//Store is a complete storage backend
+type Store interface {
+	ArtistStore
+	ReleaseStore
+	TrackStore
+	StatStore
+	JobStore
+	AliasStore
+	UserStore
+	//Transaction calls fn with a store whose writes are committed if fn
+	// returns nil and rolled back otherwise, backends without transactions
+	// only serialize transactions with each other. The store passed to fn
+	// must not be used after it returns
+	Transaction(fn func(Store) error) error
+	//Ping returns an error if the backend can't be reached
+	Ping() error
+	//Close releases the resources held by the store
+	Close() error
+}
+
+//MigrationStatus of a schema migration
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+//Migrator is implemented by stores with a versioned schema
+type Migrator interface {
+	//MigrateUp applies pending migrations and returns how many were applied
+	MigrateUp() (int, error)
+	//MigrateDown reverts the last steps applied migrations
+	MigrateDown(steps int) error
+	//MigrationStatus lists the known migrations
+	MigrationStatus() ([]MigrationStatus, error)
+}
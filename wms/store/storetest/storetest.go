@@ -0,0 +1,585 @@
+//Package storetest is a conformance suite for store.Store implementations,
+// a backend's tests should call Run with a function opening an empty store
+package storetest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/waelbendhia/music-streaming/wms/models"
+	"github.com/waelbendhia/music-streaming/wms/store"
+	"gopkg.in/mgo.v2/bson"
+)
+
+//Opener returns an empty store, it is called once per test
+type Opener func(t *testing.T) store.Store
+
+//Run the conformance suite against the stores returned by open
+func Run(t *testing.T, open Opener) {
+	for _, test := range []struct {
+		name string
+		fn   func(*testing.T, store.Store)
+	}{
+		{"Artists", testArtists},
+		{"ArtistNotFound", testArtistNotFound},
+		{"DuplicateArtist", testDuplicateArtist},
+		{"UpsertRelease", testUpsertRelease},
+		{"UpsertReleaseTwice", testUpsertReleaseTwice},
+		{"UpsertReleaseWithoutArtist", testUpsertReleaseWithoutArtist},
+		{"UpsertNormalizedNames", testUpsertNormalizedNames},
+		{"UpsertByMBID", testUpsertByMBID},
+		{"UpsertMergesMetadata", testUpsertMergesMetadata},
+		{"UpsertTrackArtists", testUpsertTrackArtists},
+		{"SearchReleases", testSearchReleases},
+		{"FullRelease", testFullRelease},
+		{"FullArtist", testFullArtist},
+		{"Tracks", testTracks},
+		{"ToEnrich", testToEnrich},
+		{"TopTracks", testTopTracks},
+		{"Jobs", testJobs},
+		{"Delete", testDelete},
+		{"Aliases", testAliases},
+		{"UpsertResolvesAliases", testUpsertResolvesAliases},
+		{"ReassignStatistics", testReassignStatistics},
+		{"Transaction", testTransaction},
+		{"Users", testUsers},
+	} {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			s := open(t)
+			defer s.Close()
+			test.fn(t, s)
+		})
+	}
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newRelease(artist, name string, tracks ...string) *models.Release {
+	rel := &models.Release{Name: name, AlbumArtist: &models.Artist{Name: artist}}
+	for _, track := range tracks {
+		rel.Tracks = append(rel.Tracks, models.Track{Name: track})
+	}
+	return rel
+}
+
+func testArtists(t *testing.T, s store.Store) {
+	artist := models.Artist{Name: "Portishead", Genres: []string{"trip hop"}}
+	must(t, s.InsertArtist(&artist))
+	if !artist.ID.Valid() {
+		t.Fatalf("InsertArtist did not set a valid ID: %q", artist.ID)
+	}
+	got, err := s.Artist(artist.ID)
+	must(t, err)
+	if got.Name != artist.Name || len(got.Genres) != 1 {
+		t.Errorf("Artist returned %+v, want %+v", got, artist)
+	}
+	got, err = s.ArtistByName("Portishead")
+	must(t, err)
+	if got.ID != artist.ID {
+		t.Errorf("ArtistByName returned ID %v, want %v", got.ID, artist.ID)
+	}
+	got.Bio = "From Bristol"
+	must(t, s.UpdateArtist(got))
+	got, err = s.Artist(artist.ID)
+	must(t, err)
+	if got.Bio != "From Bristol" {
+		t.Errorf("UpdateArtist did not persist bio, got %q", got.Bio)
+	}
+}
+
+func testArtistNotFound(t *testing.T, s store.Store) {
+	if _, err := s.Artist(bson.NewObjectId()); err != store.ErrNotFound {
+		t.Errorf("Artist of unknown ID returned %v, want ErrNotFound", err)
+	}
+	if _, err := s.ArtistByName("nobody"); err != store.ErrNotFound {
+		t.Errorf("ArtistByName of unknown name returned %v, want ErrNotFound", err)
+	}
+	if _, err := s.Release(bson.NewObjectId()); err != store.ErrNotFound {
+		t.Errorf("Release of unknown ID returned %v, want ErrNotFound", err)
+	}
+	if _, err := s.Track(bson.NewObjectId()); err != store.ErrNotFound {
+		t.Errorf("Track of unknown ID returned %v, want ErrNotFound", err)
+	}
+}
+
+func testDuplicateArtist(t *testing.T, s store.Store) {
+	must(t, s.InsertArtist(&models.Artist{Name: "Björk"}))
+	if err := s.InsertArtist(&models.Artist{Name: "björk"}); err != store.ErrDuplicate {
+		t.Errorf("inserting an artist twice returned %v, want ErrDuplicate", err)
+	}
+}
+
+func testUpsertRelease(t *testing.T, s store.Store) {
+	rel := newRelease("Massive Attack", "Mezzanine", "Angel", "Risingson")
+	must(t, s.UpsertRelease(rel))
+	if !rel.ID.Valid() || !rel.AlbumArtist.ID.Valid() {
+		t.Fatalf("UpsertRelease did not set IDs: %+v", rel)
+	}
+	if rel.AlbumArtistID != rel.AlbumArtist.ID.Hex() {
+		t.Errorf(
+			"AlbumArtistID is %q, want %q",
+			rel.AlbumArtistID,
+			rel.AlbumArtist.ID.Hex(),
+		)
+	}
+	if len(rel.TrackIDs) != 2 {
+		t.Fatalf("UpsertRelease stored %d track IDs, want 2", len(rel.TrackIDs))
+	}
+	for i, track := range rel.Tracks {
+		if track.TrackNumber != i+1 || track.DiscNumber != 1 {
+			t.Errorf(
+				"track %d numbered %d-%d, want 1-%d",
+				i,
+				track.DiscNumber,
+				track.TrackNumber,
+				i+1,
+			)
+		}
+		if track.ArtistID != rel.AlbumArtistID {
+			t.Errorf("track %d has artist %q, want album artist", i, track.ArtistID)
+		}
+	}
+	if _, err := s.ArtistByName("Massive Attack"); err != nil {
+		t.Errorf("album artist was not stored: %v", err)
+	}
+}
+
+func testUpsertReleaseTwice(t *testing.T, s store.Store) {
+	first := newRelease("Massive Attack", "Mezzanine", "Angel")
+	must(t, s.UpsertRelease(first))
+	second := newRelease("Massive Attack", "Mezzanine", "Angel")
+	must(t, s.UpsertRelease(second))
+	if second.ID != first.ID {
+		t.Errorf("saving a release twice returned IDs %v and %v", first.ID, second.ID)
+	}
+	if len(second.Tracks) != 1 || second.Tracks[0].ID != first.Tracks[0].ID {
+		t.Errorf(
+			"saving a release twice returned tracks %+v, want %+v",
+			second.Tracks,
+			first.Tracks,
+		)
+	}
+	rels, err := s.ReleasesByArtist(first.AlbumArtistID)
+	must(t, err)
+	if len(rels) != 1 {
+		t.Errorf("artist has %d releases, want 1", len(rels))
+	}
+	tracks, err := s.TracksByArtist(first.AlbumArtistID)
+	must(t, err)
+	if len(tracks) != 1 {
+		t.Errorf("artist has %d tracks, want 1", len(tracks))
+	}
+}
+
+func testUpsertNormalizedNames(t *testing.T, s store.Store) {
+	first := newRelease("Sigur Rós", "Ágætis byrjun", "Svefn-g-englar")
+	must(t, s.UpsertRelease(first))
+	second := newRelease("sigur ros", "Agaetis  Byrjun", "svefn g englar")
+	must(t, s.UpsertRelease(second))
+	if second.AlbumArtist.ID != first.AlbumArtist.ID || second.ID != first.ID {
+		t.Errorf("names differing in case and accents were not matched")
+	}
+	if second.Name != first.Name || second.AlbumArtist.Name != "Sigur Rós" {
+		t.Errorf(
+			"upsert renamed the release to %q by %q",
+			second.Name,
+			second.AlbumArtist.Name,
+		)
+	}
+	if len(second.TrackIDs) != 1 {
+		t.Errorf("release has %d tracks, want 1", len(second.TrackIDs))
+	}
+}
+
+func testUpsertByMBID(t *testing.T, s store.Store) {
+	first := newRelease("Prince", "Purple Rain")
+	first.MBID = "5f2a8d2e-release"
+	first.AlbumArtist.MBID = "070d193a-artist"
+	must(t, s.UpsertRelease(first))
+	second := newRelease("Prince and the Revolution", "Purple Rain (OST)")
+	second.MBID = first.MBID
+	second.AlbumArtist.MBID = first.AlbumArtist.MBID
+	must(t, s.UpsertRelease(second))
+	if second.AlbumArtist.ID != first.AlbumArtist.ID || second.ID != first.ID {
+		t.Errorf("records with the same MBID were not matched")
+	}
+	if _, err := s.ArtistByName("Prince and the Revolution"); err != store.ErrNotFound {
+		t.Errorf("an artist was created for a known MBID: %v", err)
+	}
+}
+
+func testUpsertMergesMetadata(t *testing.T, s store.Store) {
+	first := newRelease("Daft Punk", "Discovery", "One More Time")
+	first.CoverURL = "http://covers/discovery.jpg"
+	must(t, s.UpsertRelease(first))
+	second := newRelease("Daft Punk", "Discovery", "One More Time", "Aerodynamic")
+	second.CoverURL = "http://other/discovery.png"
+	second.Label = "Virgin"
+	second.Cover = "2fd4e1c67a2d28fced849ee1bb76e7391b93eb12"
+	second.Tracks[0].Length = 320 * time.Second
+	second.AlbumArtist.Bio = "French duo"
+	second.AlbumArtist.Image = "de9f2c7fd25e1b3afad3e85a0bd17d9b100db4b3"
+	must(t, s.UpsertRelease(second))
+	rel, err := store.FullRelease(s, first.ID)
+	must(t, err)
+	if rel.CoverURL != first.CoverURL || rel.Label != "Virgin" ||
+		rel.Cover != second.Cover {
+		t.Errorf(
+			"release metadata was overwritten or not merged: %q %q %q",
+			rel.CoverURL,
+			rel.Label,
+			rel.Cover,
+		)
+	}
+	if len(rel.Tracks) != 2 || rel.Tracks[1].Name != "Aerodynamic" {
+		t.Fatalf("new track was not added: %+v", rel.Tracks)
+	}
+	if rel.Tracks[0].Length != 320*time.Second {
+		t.Errorf("track length was not merged: %v", rel.Tracks[0].Length)
+	}
+	if rel.AlbumArtist.Bio != "French duo" {
+		t.Errorf("artist bio was not merged: %q", rel.AlbumArtist.Bio)
+	}
+	if rel.AlbumArtist.Image != second.AlbumArtist.Image {
+		t.Errorf("artist image was not merged: %q", rel.AlbumArtist.Image)
+	}
+	if len(second.Tracks) != 2 || second.Tracks[0].ID != first.Tracks[0].ID {
+		t.Errorf("upsert did not return the canonical tracks: %+v", second.Tracks)
+	}
+}
+
+func testUpsertTrackArtists(t *testing.T, s store.Store) {
+	rel := newRelease("Gorillaz", "Demon Days", "Intro", "Feel Good Inc.")
+	must(t, s.UpsertRelease(rel))
+	if rel.Tracks[1].ArtistID != rel.AlbumArtistID {
+		t.Fatalf("uncredited track has artist %s", rel.Tracks[1].ArtistID)
+	}
+	credited := newRelease("Gorillaz", "Demon Days", "Intro", "Feel Good Inc.")
+	credited.Tracks[1].Artist = &models.Artist{Name: "De La Soul"}
+	must(t, s.UpsertRelease(credited))
+	guest, err := s.ArtistByName("De La Soul")
+	must(t, err)
+	for _, track := range credited.Tracks {
+		want := rel.AlbumArtistID
+		if track.Name == "Feel Good Inc." {
+			want = guest.ID.Hex()
+		}
+		if track.ArtistID != want {
+			t.Errorf(
+				"%s has artist %s, want %s", track.Name, track.ArtistID, want,
+			)
+		}
+	}
+	tracks, err := s.TracksByArtist(guest.ID.Hex())
+	must(t, err)
+	if len(tracks) != 1 || tracks[0].Name != "Feel Good Inc." {
+		t.Errorf("tracks of the credited artist: %+v", tracks)
+	}
+	// Upserting without the credits keeps them
+	again := newRelease("Gorillaz", "Demon Days", "Intro", "Feel Good Inc.")
+	must(t, s.UpsertRelease(again))
+	if again.Tracks[1].ArtistID != guest.ID.Hex() {
+		t.Errorf("credit lost, artist is %s", again.Tracks[1].ArtistID)
+	}
+}
+
+func testUpsertReleaseWithoutArtist(t *testing.T, s store.Store) {
+	err := s.UpsertRelease(&models.Release{Name: "Untitled"})
+	if err != models.ErrIncompleteEntity {
+		t.Errorf("saving a release without artist returned %v", err)
+	}
+}
+
+func testSearchReleases(t *testing.T, s store.Store) {
+	must(t, s.UpsertRelease(newRelease("Radiohead", "OK Computer")))
+	must(t, s.UpsertRelease(newRelease("Radiohead", "Kid A")))
+	rels, err := s.SearchReleases("computer")
+	must(t, err)
+	if len(rels) != 1 || rels[0].Name != "OK Computer" {
+		t.Errorf("SearchReleases returned %+v, want OK Computer", rels)
+	}
+	rels, err = s.SearchReleases("(")
+	must(t, err)
+	if len(rels) != 0 {
+		t.Errorf("SearchReleases with special characters returned %+v", rels)
+	}
+}
+
+func testFullRelease(t *testing.T, s store.Store) {
+	rel := newRelease("Pink Floyd", "The Wall", "In the Flesh?", "Hey You")
+	rel.Tracks[0].DiscNumber, rel.Tracks[0].TrackNumber = 2, 1
+	rel.Tracks[1].DiscNumber, rel.Tracks[1].TrackNumber = 1, 1
+	must(t, s.UpsertRelease(rel))
+	full, err := store.FullRelease(s, rel.ID)
+	must(t, err)
+	if full.AlbumArtist == nil || full.AlbumArtist.Name != "Pink Floyd" {
+		t.Errorf("FullRelease album artist is %+v", full.AlbumArtist)
+	}
+	if len(full.Tracks) != 2 || full.Tracks[0].Name != "Hey You" {
+		t.Errorf("FullRelease tracks are not ordered by disc: %+v", full.Tracks)
+	}
+}
+
+func testFullArtist(t *testing.T, s store.Store) {
+	rel := newRelease("Air", "Moon Safari")
+	must(t, s.UpsertRelease(rel))
+	related := models.Artist{Name: "Daft Punk"}
+	must(t, s.InsertArtist(&related))
+	rel.AlbumArtist.RelatedArtistIDs = []string{related.ID.Hex()}
+	must(t, s.UpdateArtist(rel.AlbumArtist))
+	full, err := store.FullArtist(s, rel.AlbumArtist.ID)
+	must(t, err)
+	if len(full.Releases) != 1 || full.Releases[0].Name != "Moon Safari" {
+		t.Errorf("FullArtist releases are %+v", full.Releases)
+	}
+	if len(full.RelatedArtists) != 1 || full.RelatedArtists[0].Name != "Daft Punk" {
+		t.Errorf("FullArtist related artists are %+v", full.RelatedArtists)
+	}
+}
+
+func testTracks(t *testing.T, s store.Store) {
+	track := models.Track{Name: "Teardrop", ArtistID: "artist"}
+	must(t, s.InsertTrack(&track))
+	track.Genre = "trip hop"
+	must(t, s.UpdateTrack(&track))
+	got, err := s.Track(track.ID)
+	must(t, err)
+	if got.Genre != "trip hop" {
+		t.Errorf("UpdateTrack did not persist genre, got %q", got.Genre)
+	}
+	tracks, err := s.TracksByArtist("artist")
+	must(t, err)
+	if len(tracks) != 1 || tracks[0].ID != track.ID {
+		t.Errorf("TracksByArtist returned %+v", tracks)
+	}
+}
+
+func testToEnrich(t *testing.T, s store.Store) {
+	fresh := models.Artist{Name: "Fresh", LastEnriched: time.Now()}
+	stale := models.Artist{Name: "Stale"}
+	must(t, s.InsertArtist(&fresh))
+	must(t, s.InsertArtist(&stale))
+	artists, err := s.ArtistsToEnrich(time.Now().Add(-time.Hour), 10)
+	must(t, err)
+	if len(artists) != 1 || artists[0].ID != stale.ID {
+		t.Errorf("ArtistsToEnrich returned %+v, want only Stale", artists)
+	}
+	rel := newRelease("Fresh", "Never enriched")
+	must(t, s.UpsertRelease(rel))
+	rels, err := s.ReleasesToEnrich(time.Now(), 10)
+	must(t, err)
+	if len(rels) != 1 || rels[0].ID != rel.ID {
+		t.Errorf("ReleasesToEnrich returned %+v", rels)
+	}
+	rels, err = s.ReleasesToEnrich(time.Now(), 0)
+	must(t, err)
+	if len(rels) > 1 {
+		t.Errorf("ReleasesToEnrich ignored its limit")
+	}
+}
+
+func testTopTracks(t *testing.T, s store.Store) {
+	now := time.Now()
+	for _, stat := range []models.Statistic{
+		{TrackID: "a", Listener: "1", TimeStamp: now},
+		{TrackID: "b", Listener: "1", TimeStamp: now},
+		{TrackID: "b", Listener: "2", TimeStamp: now},
+		{TrackID: "c", Listener: "1", TimeStamp: now.Add(-48 * time.Hour)},
+		{TrackID: "c", Listener: "2", TimeStamp: now.Add(-48 * time.Hour)},
+		{TrackID: "c", Listener: "3", TimeStamp: now.Add(-48 * time.Hour)},
+	} {
+		stat := stat
+		must(t, s.InsertStatistic(&stat))
+	}
+	top, err := s.TopTracks(now.Add(-time.Hour), 10)
+	must(t, err)
+	if len(top) != 2 || top[0].TrackID != "b" || top[0].Count != 2 {
+		t.Errorf("TopTracks returned %+v, want b then a", top)
+	}
+}
+
+func testJobs(t *testing.T, s store.Store) {
+	now := time.Now().Truncate(time.Second)
+	first := models.Job{Name: "first", State: models.JobQueued, CreatedAt: now}
+	second := models.Job{
+		Name:      "second",
+		State:     models.JobQueued,
+		CreatedAt: now.Add(time.Second),
+	}
+	must(t, s.InsertJob(&second))
+	must(t, s.InsertJob(&first))
+	first.State = models.JobDownloading
+	first.InfoHash = "c12fe1c06bba254a9dc9f519b335aa7c1367a88a"
+	first.Limits = models.JobLimits{DownloadRate: 512, MaxConns: 10}
+	first.Size, first.Dir = 1<<20, "First (2018)"
+	first.Candidates = []models.Candidate{
+		{Name: "First (2018)", Magnet: "magnet:?xt=a", Size: 1 << 20},
+		{Name: "First", Magnet: "magnet:?xt=b", Seeders: 3},
+	}
+	first.Attempts = []models.Attempt{{
+		Name:   "First (2018)",
+		Magnet: "magnet:?xt=a",
+		Reason: "no peers",
+		At:     now,
+	}}
+	first.MetaInfo = []byte("d4:infod4:name5:Firstee")
+	first.Files = []models.JobFile{
+		{Path: "First (2018)/01.flac", TrackID: "5bd0a0", Size: 100},
+		{Path: "First (2018)/cover.jpg", Extra: true, Size: 10, Complete: true},
+	}
+	must(t, s.UpdateJob(&first))
+	got, err := s.Job(first.ID)
+	must(t, err)
+	if got.State != models.JobDownloading ||
+		got.InfoHash != first.InfoHash ||
+		got.Limits != first.Limits ||
+		got.Size != first.Size ||
+		got.Dir != first.Dir ||
+		len(got.Candidates) != 2 ||
+		got.Candidates[1] != first.Candidates[1] ||
+		len(got.Attempts) != 1 ||
+		got.Attempts[0].Reason != first.Attempts[0].Reason ||
+		!got.Attempts[0].At.Equal(now) ||
+		string(got.MetaInfo) != string(first.MetaInfo) ||
+		len(got.Files) != 2 ||
+		got.Files[0] != first.Files[0] ||
+		got.Files[1] != first.Files[1] {
+		t.Errorf("UpdateJob did not persist job, got %+v", got)
+	}
+	jobs, err := s.Jobs()
+	must(t, err)
+	if len(jobs) != 2 || jobs[0].ID != first.ID {
+		t.Errorf("Jobs returned %+v, want oldest first", jobs)
+	}
+	jobs, err = s.Jobs(models.JobQueued, models.JobFailed)
+	must(t, err)
+	if len(jobs) != 1 || jobs[0].ID != second.ID {
+		t.Errorf("Jobs(queued, failed) returned %+v, want second", jobs)
+	}
+	if _, err := s.Job(bson.NewObjectId()); err != store.ErrNotFound {
+		t.Errorf("Job of unknown ID returned %v, want ErrNotFound", err)
+	}
+}
+
+func testDelete(t *testing.T, s store.Store) {
+	rel := newRelease("Muse", "Absolution", "Hysteria")
+	must(t, s.UpsertRelease(rel))
+	must(t, s.DeleteTrack(rel.Tracks[0].ID))
+	must(t, s.DeleteRelease(rel.ID))
+	must(t, s.DeleteArtist(rel.AlbumArtist.ID))
+	if _, err := s.Release(rel.ID); err != store.ErrNotFound {
+		t.Errorf("Release after DeleteRelease returned %v", err)
+	}
+	if _, err := s.Track(rel.Tracks[0].ID); err != store.ErrNotFound {
+		t.Errorf("Track after DeleteTrack returned %v", err)
+	}
+	artists, err := s.Artists()
+	must(t, err)
+	rels, err := s.Releases()
+	must(t, err)
+	if len(artists) != 0 || len(rels) != 0 {
+		t.Errorf("deleted records are still listed: %+v %+v", artists, rels)
+	}
+	if err := s.DeleteArtist(rel.AlbumArtist.ID); err != store.ErrNotFound {
+		t.Errorf("deleting an artist twice returned %v", err)
+	}
+}
+
+func testAliases(t *testing.T, s store.Store) {
+	if _, err := s.Alias(store.ArtistAlias, "beatles the"); err != store.ErrNotFound {
+		t.Errorf("unknown alias returned %v", err)
+	}
+	must(t, s.SetAlias(store.ArtistAlias, "beatles the", "a"))
+	must(t, s.SetAlias(store.ArtistAlias, "beatles the", "b"))
+	must(t, s.SetAlias(store.ReleaseAlias, "b/abbey road", "b"))
+	must(t, s.RetargetAliases(store.ArtistAlias, "b", "c"))
+	id, err := s.Alias(store.ArtistAlias, "beatles the")
+	must(t, err)
+	if id != "c" {
+		t.Errorf("artist alias targets %q, want c", id)
+	}
+	id, err = s.Alias(store.ReleaseAlias, "b/abbey road")
+	must(t, err)
+	if id != "b" {
+		t.Errorf("retargeting artist aliases changed a release alias to %q", id)
+	}
+}
+
+func testUpsertResolvesAliases(t *testing.T, s store.Store) {
+	rel := newRelease("The Beatles", "Abbey Road")
+	must(t, s.UpsertRelease(rel))
+	artistID := rel.AlbumArtist.ID.Hex()
+	must(t, s.SetAlias(store.ArtistAlias, "beatles the", artistID))
+	must(t, s.SetAlias(
+		store.ReleaseAlias,
+		artistID+"/abbey road remastered",
+		rel.ID.Hex(),
+	))
+	again := newRelease("Beatles, The", "Abbey Road (Remastered)")
+	must(t, s.UpsertRelease(again))
+	if again.AlbumArtist.ID != rel.AlbumArtist.ID || again.ID != rel.ID {
+		t.Errorf("upsert did not resolve aliases")
+	}
+	found, err := store.FindArtist(s, &models.Artist{Name: "Beatles, The"})
+	must(t, err)
+	if found.ID != rel.AlbumArtist.ID {
+		t.Errorf("FindArtist did not resolve aliases")
+	}
+}
+
+func testReassignStatistics(t *testing.T, s store.Store) {
+	now := time.Now()
+	must(t, s.InsertStatistic(&models.Statistic{TrackID: "a", TimeStamp: now}))
+	must(t, s.InsertStatistic(&models.Statistic{TrackID: "b", TimeStamp: now}))
+	must(t, s.ReassignStatistics("a", "b"))
+	top, err := s.TopTracks(now.Add(-time.Hour), 10)
+	must(t, err)
+	if len(top) != 1 || top[0].TrackID != "b" || top[0].Count != 2 {
+		t.Errorf("TopTracks after reassigning returned %+v", top)
+	}
+}
+
+func testTransaction(t *testing.T, s store.Store) {
+	artist := models.Artist{Name: "Queen"}
+	err := s.Transaction(func(tx store.Store) error {
+		return tx.InsertArtist(&artist)
+	})
+	must(t, err)
+	if _, err := s.Artist(artist.ID); err != nil {
+		t.Errorf("artist inserted in a transaction was not committed: %v", err)
+	}
+}
+
+func testUsers(t *testing.T, s store.Store) {
+	user := models.User{
+		Name:      "wael",
+		TokenHash: store.HashToken("secret"),
+		Admin:     true,
+		CreatedAt: time.Now().Truncate(time.Second),
+	}
+	must(t, s.InsertUser(&user))
+	got, err := s.UserByToken(store.HashToken("secret"))
+	must(t, err)
+	if got.ID != user.ID || !got.Admin || !got.CreatedAt.Equal(user.CreatedAt) {
+		t.Errorf("UserByToken returned %+v, want %+v", got, user)
+	}
+	got, err = s.UserByName("wael")
+	must(t, err)
+	if got.ID != user.ID {
+		t.Errorf("UserByName returned %+v, want %+v", got, user)
+	}
+	_, err = s.UserByToken(store.HashToken("guess"))
+	if err != store.ErrNotFound {
+		t.Errorf("UserByToken of unknown token returned %v", err)
+	}
+	other := models.User{Name: "wael", TokenHash: store.HashToken("other")}
+	if err := s.InsertUser(&other); err != store.ErrDuplicate {
+		t.Errorf("inserting a user with a taken name returned %v", err)
+	}
+}
@@ -0,0 +1,369 @@
+package store
+
+import (
+	"github.com/waelbendhia/music-streaming/wms/models"
+	"gopkg.in/mgo.v2/bson"
+)
+
+//Catalog is the part of a store Upsert works with
+type Catalog interface {
+	ArtistStore
+	ReleaseStore
+	TrackStore
+	AliasStore
+}
+
+//Upsert stores rel, its album artist and its tracks in c, backends
+// implement UpsertRelease by calling it inside a transaction or lock.
+//
+// The album artist is identified by MBID then by normalized name, the
+// release by MBID then by normalized name among the artist's releases and
+// tracks by MBID, normalized name then position among the release's tracks.
+// Artists and releases that match none are looked up in the aliases left by
+// merged duplicates. Tracks credited to another artist, in their Artist, are
+// attributed to that artist, which is upserted like the album artist.
+// Records that already exist get their missing metadata filled in from rel,
+// the others are inserted, so upserting the same release twice is harmless.
+//
+// On success rel holds the canonical records: the stored album artist, the
+// release's ID and all its tracks, old and new, ordered by position.
+func Upsert(c Catalog, rel *models.Release) error {
+	if rel.AlbumArtist == nil {
+		return models.ErrIncompleteEntity
+	}
+	artist, err := upsertArtist(c, rel.AlbumArtist)
+	if err != nil {
+		return err
+	}
+	rel.AlbumArtist = artist
+	rel.AlbumArtistID = artist.ID.Hex()
+	rel.NumberTracks()
+	for i := range rel.Tracks {
+		if err := resolveTrackArtist(c, &rel.Tracks[i], artist); err != nil {
+			return err
+		}
+	}
+	existing, err := findRelease(c, rel)
+	if err == ErrNotFound {
+		err = insertRelease(c, rel)
+		if err != ErrDuplicate {
+			return err
+		}
+		// Someone else inserted it first, merge into theirs
+		existing, err = findRelease(c, rel)
+	}
+	if err != nil {
+		return err
+	}
+	return mergeIntoRelease(c, existing, rel)
+}
+
+//resolveTrackArtist sets the ArtistID of a track credited to an artist, like
+// a featured or compilation artist, to that artist's, which is upserted, and
+// of the others to the album artist's
+func resolveTrackArtist(
+	c Catalog,
+	track *models.Track,
+	albumArtist *models.Artist,
+) error {
+	if track.ArtistID != "" {
+		return nil
+	}
+	if track.Artist == nil || track.Artist.Name == "" {
+		track.ArtistID = albumArtist.ID.Hex()
+		return nil
+	}
+	artist, err := upsertArtist(c, track.Artist)
+	if err != nil {
+		return err
+	}
+	track.Artist = artist
+	track.ArtistID = artist.ID.Hex()
+	return nil
+}
+
+func upsertArtist(c Catalog, artist *models.Artist) (*models.Artist, error) {
+	existing, err := FindArtist(c, artist)
+	if err == ErrNotFound {
+		inserted := *artist
+		err = c.InsertArtist(&inserted)
+		if err == nil {
+			return &inserted, nil
+		}
+		if err == ErrDuplicate {
+			existing, err = FindArtist(c, artist)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if MergeArtist(existing, artist) {
+		if err := c.UpdateArtist(existing); err != nil {
+			return nil, err
+		}
+	}
+	return existing, nil
+}
+
+//FindArtist returns the stored artist matching artist the way Upsert matches
+// album artists: by MBID, by normalized name then through aliases.
+func FindArtist(c Catalog, artist *models.Artist) (*models.Artist, error) {
+	if artist.MBID != "" {
+		found, err := c.ArtistByMBID(artist.MBID)
+		if err != ErrNotFound {
+			return found, err
+		}
+	}
+	found, err := c.ArtistByName(artist.Name)
+	if err != ErrNotFound {
+		return found, err
+	}
+	id, err := aliasTarget(c, ArtistAlias, ArtistAliasKeys(artist))
+	if err != nil {
+		return nil, err
+	}
+	return c.Artist(id)
+}
+
+func findRelease(c Catalog, rel *models.Release) (*models.Release, error) {
+	if rel.MBID != "" {
+		found, err := c.ReleaseByMBID(rel.MBID)
+		if err != ErrNotFound {
+			return found, err
+		}
+	}
+	found, err := c.ReleaseByName(rel.AlbumArtistID, rel.Name)
+	if err != ErrNotFound {
+		return found, err
+	}
+	id, err := aliasTarget(
+		c,
+		ReleaseAlias,
+		ReleaseAliasKeys(rel, rel.AlbumArtistID),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return c.Release(id)
+}
+
+//insertRelease inserts rel's tracks then rel, if that fails the tracks are
+// deleted again so a release lost to a concurrent upsert leaves no orphans
+func insertRelease(c Catalog, rel *models.Release) error {
+	rel.SortTracks()
+	rel.TrackIDs = make([]string, 0, len(rel.Tracks))
+	for i := range rel.Tracks {
+		if err := c.InsertTrack(&rel.Tracks[i]); err != nil {
+			return discardTracks(c, rel, err)
+		}
+		rel.TrackIDs = append(rel.TrackIDs, rel.Tracks[i].ID.Hex())
+	}
+	if err := c.InsertRelease(rel); err != nil {
+		return discardTracks(c, rel, err)
+	}
+	return nil
+}
+
+//discardTracks deletes the tracks insertRelease inserted before failing with
+// err, then returns err
+func discardTracks(c Catalog, rel *models.Release, err error) error {
+	for i := range rel.TrackIDs {
+		if delErr := c.DeleteTrack(rel.Tracks[i].ID); delErr != nil {
+			return delErr
+		}
+		rel.Tracks[i].ID = ""
+	}
+	rel.TrackIDs = nil
+	return err
+}
+
+//mergeIntoRelease merges rel and its tracks into the stored release existing
+// and sets rel to the result
+func mergeIntoRelease(c Catalog, existing, rel *models.Release) error {
+	tracks := make([]models.Track, 0, len(existing.TrackIDs))
+	for _, id := range existing.TrackIDs {
+		if !bson.IsObjectIdHex(id) {
+			continue
+		}
+		track, err := c.Track(bson.ObjectIdHex(id))
+		if err == ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		tracks = append(tracks, *track)
+	}
+	changed := MergeRelease(existing, rel)
+	matched := make([]bool, len(tracks))
+	for _, track := range rel.Tracks {
+		if i := MatchTrack(tracks, matched, track); i >= 0 {
+			matched[i] = true
+			// A credit replaces the album artist the track defaulted to
+			credited := track.ArtistID != rel.AlbumArtistID &&
+				track.ArtistID != existing.AlbumArtistID &&
+				tracks[i].ArtistID == existing.AlbumArtistID
+			if credited {
+				tracks[i].ArtistID = track.ArtistID
+			}
+			if MergeTrack(&tracks[i], &track) || credited {
+				if err := c.UpdateTrack(&tracks[i]); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if err := c.InsertTrack(&track); err != nil {
+			return err
+		}
+		tracks = append(tracks, track)
+		matched = append(matched, true)
+		existing.TrackIDs = append(existing.TrackIDs, track.ID.Hex())
+		changed = true
+	}
+	if changed {
+		if err := c.UpdateRelease(existing); err != nil {
+			return err
+		}
+	}
+	existing.AlbumArtist = rel.AlbumArtist
+	existing.Tracks = tracks
+	existing.SortTracks()
+	*rel = *existing
+	return nil
+}
+
+//MatchTrack returns the index of the first track in tracks that isn't
+// matched yet and is the same as track, or -1
+func MatchTrack(tracks []models.Track, matched []bool, track models.Track) int {
+	same := []func(a, b models.Track) bool{
+		func(a, b models.Track) bool {
+			return a.MBID != "" && a.MBID == b.MBID
+		},
+		func(a, b models.Track) bool {
+			return NormalizeName(a.Name) == NormalizeName(b.Name)
+		},
+		func(a, b models.Track) bool {
+			return a.TrackNumber != 0 &&
+				a.DiscNumber == b.DiscNumber &&
+				a.TrackNumber == b.TrackNumber
+		},
+	}
+	for _, fn := range same {
+		for i := range tracks {
+			if !matched[i] && fn(tracks[i], track) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+//MergeArtist fills dst's missing metadata from src and returns true if dst
+// changed, an artist merged with a non stub one is not a stub anymore
+func MergeArtist(dst, src *models.Artist) bool {
+	changed := mergeStrings(
+		[]*string{&dst.MBID, &dst.ImageURL, &dst.Image, &dst.Bio},
+		[]string{src.MBID, src.ImageURL, src.Image, src.Bio},
+	)
+	if len(dst.Genres) == 0 && len(src.Genres) > 0 {
+		dst.Genres = src.Genres
+		changed = true
+	}
+	if dst.Stub && !src.Stub {
+		dst.Stub = false
+		changed = true
+	}
+	return changed
+}
+
+//MergeRelease fills dst's missing metadata from src and returns true if dst
+// changed, tracks are not merged
+func MergeRelease(dst, src *models.Release) bool {
+	changed := mergeStrings(
+		[]*string{
+			&dst.MBID,
+			&dst.GroupMBID,
+			&dst.Edition,
+			&dst.Label,
+			&dst.CatalogNumber,
+			&dst.CoverURL,
+			&dst.Cover,
+		},
+		[]string{
+			src.MBID,
+			src.GroupMBID,
+			src.Edition,
+			src.Label,
+			src.CatalogNumber,
+			src.CoverURL,
+			src.Cover,
+		},
+	)
+	if dst.Type == "" && src.Type != "" {
+		dst.Type = src.Type
+		changed = true
+	}
+	if dst.ReleaseDate.IsZero() && !src.ReleaseDate.IsZero() {
+		dst.ReleaseDate = src.ReleaseDate
+		changed = true
+	}
+	if len(dst.Genres) == 0 && len(src.Genres) > 0 {
+		dst.Genres = src.Genres
+		changed = true
+	}
+	if len(dst.Discs) == 0 && len(src.Discs) > 0 {
+		dst.Discs = src.Discs
+		changed = true
+	}
+	return changed
+}
+
+//MergeTrack fills dst's missing metadata from src and returns true if dst
+// changed
+func MergeTrack(dst, src *models.Track) bool {
+	changed := mergeStrings(
+		[]*string{&dst.MBID, &dst.TrackURL, &dst.Genre, &dst.ArtistID},
+		[]string{src.MBID, src.TrackURL, src.Genre, src.ArtistID},
+	)
+	for _, num := range []struct{ dst, src *int }{
+		{&dst.DiscNumber, &src.DiscNumber},
+		{&dst.TrackNumber, &src.TrackNumber},
+	} {
+		if *num.dst == 0 && *num.src != 0 {
+			*num.dst = *num.src
+			changed = true
+		}
+	}
+	if dst.Length == 0 && src.Length != 0 {
+		dst.Length = src.Length
+		changed = true
+	}
+	for _, isrc := range src.ISRCs {
+		if !contains(dst.ISRCs, isrc) {
+			dst.ISRCs = append(dst.ISRCs, isrc)
+			changed = true
+		}
+	}
+	return changed
+}
+
+func mergeStrings(dst []*string, src []string) bool {
+	changed := false
+	for i := range dst {
+		if *dst[i] == "" && src[i] != "" {
+			*dst[i] = src[i]
+			changed = true
+		}
+	}
+	return changed
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+//Package tags reads the metadata tags of audio files
+package tags
+
+import (
+	"errors"
+	"os"
+
+	"github.com/dhowden/tag"
+)
+
+var (
+	//ErrNoTags is returned when a file has no tags that can be read
+	ErrNoTags = errors.New("no tags found")
+	//ErrNoPicture is returned when a file's tags embed no picture
+	ErrNoPicture = errors.New("no picture found")
+)
+
+//Tags of an audio file, the fields a file doesn't tag are empty
+type Tags struct {
+	Title       string
+	Album       string
+	Artist      string
+	AlbumArtist string
+	Track, Disc int
+	Year        int
+}
+
+//Read the tags of the audio file at path
+func Read(path string) (*Tags, error) {
+	m, err := read(path)
+	if err != nil {
+		return nil, err
+	}
+	tags := &Tags{
+		Title:       m.Title(),
+		Album:       m.Album(),
+		Artist:      m.Artist(),
+		AlbumArtist: m.AlbumArtist(),
+		Year:        m.Year(),
+	}
+	tags.Track, _ = m.Track()
+	tags.Disc, _ = m.Disc()
+	return tags, nil
+}
+
+//Picture returns the data of the picture embedded in the tags of the audio
+// file at path, usually its front cover
+func Picture(path string) ([]byte, error) {
+	m, err := read(path)
+	if err != nil {
+		return nil, err
+	}
+	picture := m.Picture()
+	if picture == nil || len(picture.Data) == 0 {
+		return nil, ErrNoPicture
+	}
+	return picture.Data, nil
+}
+
+func read(path string) (tag.Metadata, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	m, err := tag.ReadFrom(f)
+	if err == tag.ErrNoTagsFound {
+		return nil, ErrNoTags
+	}
+	return m, err
+}
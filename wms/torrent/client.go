@@ -1,88 +1,633 @@
 package torrent
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"log"
+	"os"
+	"sync"
+	"time"
 
 	"github.com/anacrolix/torrent"
 	"github.com/anacrolix/torrent/metainfo"
 	"github.com/waelbendhia/music-streaming/gopirate"
+	"golang.org/x/time/rate"
 )
 
 //ErrTorrentNotFound if torrent is not found this error is returned
 var ErrTorrentNotFound = errors.New("torrent not found")
 
-//Client is a torrent client
+//Client is a torrent client, it applies its policy to its torrents until
+// it is closed
 type Client struct {
 	*torrent.Client
-	torrents map[string]*torrent.Torrent
+	logger *log.Logger
+	//down and up limit the whole client, their limits follow the policy
+	down, up *rate.Limiter
+	stop     chan struct{}
+	done     chan struct{}
+
+	//mu guards the fields below
+	mu sync.Mutex
+	//torrents by info-hash in hex
+	torrents map[string]*handle
+	policy   Policy
+}
+
+var _ Torrents = &Client{}
+
+//handle of a torrent in the client
+type handle struct {
+	//hash is the torrent's info-hash in hex
+	hash   string
+	tor    *torrent.Torrent
+	state  *torrentState
+	events broadcaster
+	//dropped is closed once the torrent is dropped
+	dropped chan struct{}
+
+	//mu guards the fields below
+	mu sync.Mutex
+	//selected files being downloaded and the files whose completion was
+	// published, by path
+	selected, published map[string]bool
+}
+
+//Config of a torrent client
+type Config struct {
+	DownloadDir string
+	ListenAddr  string
+	//Debug logs the library's debug messages
+	Debug bool
+	//Logger logs the torrents dropped once done seeding, defaults to the
+	// standard logger's output
+	Logger *log.Logger
+	Policy Policy
 }
 
 //NewClient creates a new torrent client
-func NewClient(downloadDirectory, listenAddr string) (Client, error) {
-	cli, err := torrent.NewClient(&torrent.Config{
-		DataDir:    downloadDirectory,
-		ListenAddr: listenAddr,
+func NewClient(cfg Config) (*Client, error) {
+	if err := cfg.Policy.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = log.New(os.Stderr, "", log.LstdFlags)
+	}
+	down, up := cfg.Policy.Rates(time.Now())
+	cli := &Client{
+		logger:   cfg.Logger,
+		down:     rate.NewLimiter(limit(down), limiterBurst),
+		up:       rate.NewLimiter(limit(up), limiterBurst),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+		torrents: make(map[string]*handle),
+		policy:   cfg.Policy,
+	}
+	var err error
+	cli.Client, err = torrent.NewClient(&torrent.Config{
+		DataDir:    cfg.DownloadDir,
+		ListenAddr: cfg.ListenAddr,
 		NoUpload:   false,
-		Seed:       true,
-		Debug:      true,
+		// Completed torrents are seeded until the policy drops them
+		Seed:                       true,
+		Debug:                      cfg.Debug,
+		DownloadRateLimiter:        cli.down,
+		UploadRateLimiter:          cli.up,
+		EstablishedConnsPerTorrent: cfg.Policy.maxConns(),
 	})
-	return Client{cli, make(map[string]*torrent.Torrent)}, err
+	if err != nil {
+		return nil, err
+	}
+	go cli.run()
+	return cli, nil
+}
+
+//Policy returns the policy the client applies
+func (cli *Client) Policy() Policy {
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+	return cli.policy
+}
+
+//SetPolicy changes the policy the client applies, it takes effect within
+// a second
+func (cli *Client) SetPolicy(policy Policy) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+	cli.policy = policy
+	return nil
+}
+
+//SetLimits of the torrent with the given info-hash, they take effect
+// within a second
+func (cli *Client) SetLimits(hash string, limits Limits) error {
+	if limits.DownloadRate < 0 ||
+		limits.UploadRate < 0 ||
+		limits.MaxConns < 0 {
+		return errors.New("limits must not be negative")
+	}
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+	h, found := cli.torrents[hash]
+	if !found {
+		return ErrTorrentNotFound
+	}
+	h.state.limits = limits
+	return nil
+}
+
+//run applies the policy every enforceInterval until the client is closed
+func (cli *Client) run() {
+	defer close(cli.done)
+	ticker := time.NewTicker(enforceInterval)
+	defer ticker.Stop()
+	last := time.Now()
+	for {
+		select {
+		case <-cli.stop:
+			return
+		case now := <-ticker.C:
+			cli.enforce(now, now.Sub(last))
+			last = now
+		}
+	}
+}
+
+//enforce sets the rate limits in effect at now, throttles the torrents
+// over their own limits and drops those done seeding
+func (cli *Client) enforce(now time.Time, elapsed time.Duration) {
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+	down, up := cli.policy.Rates(now)
+	cli.down.SetLimit(limit(down))
+	cli.up.SetLimit(limit(up))
+	for hash, h := range cli.torrents {
+		tor, st := h.tor, h.state
+		st.throttle(tor.Stats(), elapsed)
+		if cli.policy.doneSeeding(now, h.complete(), tor, st) {
+			cli.logger.Printf(
+				"Done seeding %s, uploaded %d bytes",
+				tor.Name(),
+				st.written,
+			)
+			cli.drop(hash)
+			continue
+		}
+		conns := cli.policy.maxConns()
+		if st.limits.MaxConns > 0 {
+			conns = st.limits.MaxConns
+		}
+		if st.paused {
+			// The library has no per torrent rate limits, disconnecting
+			// the peers until the torrent is back within its allowances
+			// keeps its average rates within its limits
+			conns = 0
+		}
+		if conns != st.conns {
+			tor.SetMaxEstablishedConns(conns)
+			st.conns = conns
+		}
+	}
 }
 
 //AddTPBTorrent adds a magnet link to client
 func (cli *Client) AddTPBTorrent(torrent gopirate.Torrent) error {
-	tor, err := cli.AddMagnet(torrent.Link)
-	if err == nil {
-		cli.torrents[torrent.Link] = tor
-	}
+	_, err := cli.AddLink(torrent.Link)
 	return err
 }
 
-//GetTorrent if added to client
-func (cli *Client) GetTorrent(torrent gopirate.Torrent) *torrent.Torrent {
-	tor, found := cli.torrents[torrent.Link]
-	if found {
-		return tor
+//AddLink adds the torrent of a magnet link to client and returns its
+// info-hash, adding a torrent twice does nothing
+func (cli *Client) AddLink(link string) (string, error) {
+	hash, _, err := ParseMagnet(link)
+	if err != nil {
+		return "", err
 	}
-	return nil
+	return hash, cli.add(hash, func() (*torrent.Torrent, error) {
+		return cli.AddMagnet(link)
+	})
+}
+
+//AddTorrentFile adds the torrent of the .torrent file in data to client and
+// returns its info-hash, adding a torrent twice does nothing
+func (cli *Client) AddTorrentFile(data []byte) (string, error) {
+	mi, err := metainfo.Load(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	hash := mi.HashInfoBytes().HexString()
+	return hash, cli.add(hash, func() (*torrent.Torrent, error) {
+		return cli.AddTorrent(mi)
+	})
 }
 
-//GotInfo returns a channel that closes when torrent has info, or nil if torrent has not been added
-func (cli *Client) GotInfo(torrent gopirate.Torrent) <-chan struct{} {
-	if tor := cli.GetTorrent(torrent); tor != nil {
-		return tor.GotInfo()
+//add the torrent with the given info-hash using addTorrent, unless it was
+// already, and start publishing its events
+func (cli *Client) add(
+	hash string,
+	addTorrent func() (*torrent.Torrent, error),
+) error {
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+	if _, found := cli.torrents[hash]; found {
+		return nil
+	}
+	tor, err := addTorrent()
+	if err != nil {
+		return err
+	}
+	h := &handle{
+		hash:      hash,
+		tor:       tor,
+		state:     newTorrentState(tor),
+		dropped:   make(chan struct{}),
+		selected:  make(map[string]bool),
+		published: make(map[string]bool),
 	}
+	cli.torrents[hash] = h
+	go h.publish()
 	return nil
 }
 
-//GetInfo returns torrent metadata if exists
-func (cli *Client) GetInfo(torrent gopirate.Torrent) *metainfo.Info {
-	if tor := cli.GetTorrent(torrent); tor != nil {
-		return tor.Info()
+//handle returns the handle of the torrent with the given info-hash
+func (cli *Client) handle(hash string) (*handle, error) {
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+	h, found := cli.torrents[hash]
+	if !found {
+		return nil, ErrTorrentNotFound
+	}
+	return h, nil
+}
+
+//Has returns true if the torrent with the given info-hash is in client
+func (cli *Client) Has(hash string) bool {
+	_, err := cli.handle(hash)
+	return err == nil
+}
+
+//WaitForInfo waits until the info of the torrent with the given info-hash
+// is received and returns it. It returns ErrTorrentNotFound if the torrent
+// is dropped first and ctx's error if ctx is done first.
+func (cli *Client) WaitForInfo(
+	ctx context.Context,
+	hash string,
+) (*Info, error) {
+	h, err := cli.handle(hash)
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case <-h.tor.GotInfo():
+		return h.info(), nil
+	case <-h.dropped:
+		return nil, ErrTorrentNotFound
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+//Download the files of the torrent with the given info-hash at paths
+func (cli *Client) Download(hash string, paths ...string) error {
+	h, err := cli.handle(hash)
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		file, err := h.file(path)
+		if err != nil {
+			return err
+		}
+		file.Download()
+		h.mu.Lock()
+		h.selected[path] = true
+		h.mu.Unlock()
+		// Files found complete on disk are published at once
+		h.publishFile(path, file)
 	}
 	return nil
 }
 
+//FileComplete returns true if every piece of the file at path of the
+// torrent with the given info-hash is complete
+func (cli *Client) FileComplete(hash, path string) (bool, error) {
+	h, err := cli.handle(hash)
+	if err != nil {
+		return false, err
+	}
+	file, err := h.file(path)
+	if err != nil {
+		return false, err
+	}
+	return fileComplete(file), nil
+}
+
+//FileProgress returns the progress of every file of the torrent with the
+// given info-hash, in the order of its info
+func (cli *Client) FileProgress(hash string) ([]FileProgress, error) {
+	h, err := cli.handle(hash)
+	if err != nil {
+		return nil, err
+	}
+	info := h.info()
+	if info == nil {
+		return nil, ErrNoInfo
+	}
+	files := h.tor.Files()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	progress := make([]FileProgress, len(info.Files))
+	for i, file := range info.Files {
+		progress[i] = FileProgress{
+			File:      file,
+			Completed: fileCompleted(&files[i]),
+			Selected:  h.selected[file.Path],
+		}
+	}
+	return progress, nil
+}
+
 //StartAll downloads all files within given torrent
-func (cli *Client) StartAll(torrent gopirate.Torrent) error {
-	if tor := cli.GetTorrent(torrent); tor != nil {
-		tor.DownloadAll()
-		return nil
+func (cli *Client) StartAll(hash string) error {
+	h, err := cli.handle(hash)
+	if err != nil {
+		return err
 	}
-	return ErrTorrentNotFound
+	h.tor.DownloadAll()
+	return nil
 }
 
 //PrintStatus prints status of given torrent
-func (cli *Client) PrintStatus(torrent gopirate.Torrent, logger *log.Logger) {
-	logger.Println(cli.GetTorrent(torrent).Stats())
-	logger.Println("Remaining ", cli.GetTorrent(torrent).BytesMissing())
+func (cli *Client) PrintStatus(hash string, logger *log.Logger) error {
+	h, err := cli.handle(hash)
+	if err != nil {
+		return err
+	}
+	logger.Println(h.tor.Stats())
+	logger.Println("Remaining ", h.tor.BytesMissing())
+	return nil
 }
 
 //IsComplete returns true if torrent has finished downloading
-func (cli *Client) IsComplete(torrent gopirate.Torrent) bool {
-	if tor := cli.GetTorrent(torrent); tor != nil {
-		return tor.BytesMissing() > 0
+func (cli *Client) IsComplete(hash string) bool {
+	h, err := cli.handle(hash)
+	if err != nil {
+		return false
+	}
+	return h.complete()
+}
+
+//Progress returns the progress of the torrent with the given info-hash
+func (cli *Client) Progress(hash string) (Progress, error) {
+	h, err := cli.handle(hash)
+	if err != nil {
+		return Progress{}, err
+	}
+	progress := Progress{
+		Completed: h.tor.BytesCompleted(),
+		Missing:   h.tor.BytesMissing(),
+		Peers:     h.tor.Stats().ActivePeers,
+	}
+	if selected := h.selectedFiles(); len(selected) > 0 {
+		progress.Completed, progress.Missing = 0, 0
+		for _, file := range selected {
+			completed := fileCompleted(file)
+			progress.Completed += completed
+			progress.Missing += file.Length() - completed
+		}
+	}
+	return progress, nil
+}
+
+//Subscribe to the events of the torrent with the given info-hash, the
+// subscription is closed when the torrent is dropped
+func (cli *Client) Subscribe(hash string) (*Subscription, error) {
+	h, err := cli.handle(hash)
+	if err != nil {
+		return nil, err
+	}
+	return h.events.subscribe(), nil
+}
+
+//DropTorrent stops downloading and seeding the torrent with the given
+// info-hash, it does nothing if there is none
+func (cli *Client) DropTorrent(hash string) {
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+	cli.drop(hash)
+}
+
+//drop the torrent with the given info-hash, mu must be held
+func (cli *Client) drop(hash string) {
+	if h, found := cli.torrents[hash]; found {
+		h.tor.Drop()
+		close(h.dropped)
+		h.events.close()
+		delete(cli.torrents, hash)
+	}
+}
+
+//info returns the info of h's torrent, nil until it is received
+func (h *handle) info() *Info {
+	info := h.tor.Info()
+	if info == nil {
+		return nil
+	}
+	res := &Info{
+		Name:        info.Name,
+		Length:      info.TotalLength(),
+		PieceLength: info.PieceLength,
+	}
+	for _, file := range h.tor.Files() {
+		res.Files = append(res.Files, File{
+			Path:   filePath(info, &file),
+			Offset: file.Offset(),
+			Length: file.Length(),
+		})
+	}
+	return res
+}
+
+//file returns the file of h's torrent at path
+func (h *handle) file(path string) (*torrent.File, error) {
+	info := h.tor.Info()
+	if info == nil {
+		return nil, ErrNoInfo
+	}
+	files := h.tor.Files()
+	for i := range files {
+		if filePath(info, &files[i]) == path {
+			return &files[i], nil
+		}
+	}
+	return nil, ErrFileNotFound
+}
+
+//selectedFiles returns the files of h's torrent being downloaded
+func (h *handle) selectedFiles() []*torrent.File {
+	info := h.tor.Info()
+	if info == nil {
+		return nil
+	}
+	files := h.tor.Files()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var selected []*torrent.File
+	for i := range files {
+		if h.selected[filePath(info, &files[i])] {
+			selected = append(selected, &files[i])
+		}
+	}
+	return selected
+}
+
+//complete returns true if h's torrent has every piece of the files being
+// downloaded, or of all its files if none is
+func (h *handle) complete() bool {
+	if h.tor.Info() == nil {
+		return false
+	}
+	selected := h.selectedFiles()
+	if len(selected) == 0 {
+		return h.tor.BytesMissing() == 0
+	}
+	for _, file := range selected {
+		if !fileComplete(file) {
+			return false
+		}
+	}
+	return true
+}
+
+//publishFile publishes the completion of the file at path, once, if it is
+// being downloaded and complete
+func (h *handle) publishFile(path string, file *torrent.File) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.selected[path] || h.published[path] || !fileComplete(file) {
+		return
+	}
+	h.published[path] = true
+	h.events.publish(Event{
+		Type: EventFileComplete,
+		Hash: h.hash,
+		Path: path,
+	})
+}
+
+//publish the events of h's torrent once its info is received until it is
+// dropped
+func (h *handle) publish() {
+	select {
+	case <-h.tor.GotInfo():
+	case <-h.dropped:
+		return
+	}
+	sub := h.tor.SubscribePieceStateChanges()
+	defer sub.Close()
+	info, files := h.tor.Info(), h.tor.Files()
+	pieces := make(map[int]bool)
+	for {
+		var (
+			value interface{}
+			open  bool
+		)
+		select {
+		case value, open = <-sub.Values:
+			if !open {
+				return
+			}
+		case <-h.dropped:
+			return
+		}
+		change, ok := value.(torrent.PieceStateChange)
+		if !ok || !change.Complete || pieces[change.Index] {
+			continue
+		}
+		pieces[change.Index] = true
+		h.events.publish(Event{
+			Type:  EventPieceComplete,
+			Hash:  h.hash,
+			Piece: change.Index,
+		})
+		begin := int64(change.Index) * info.PieceLength
+		end := begin + info.PieceLength
+		for i := range files {
+			file := &files[i]
+			if file.Offset() < end && file.Offset()+file.Length() > begin {
+				h.publishFile(filePath(info, file), file)
+			}
+		}
+	}
+}
+
+//filePath returns the path of file, from the torrent described by info,
+// relative to the download directory
+func filePath(info *metainfo.Info, file *torrent.File) string {
+	if len(info.Files) == 0 {
+		return info.Name
+	}
+	return info.Name + "/" + file.Path()
+}
+
+//fileComplete returns true once every piece of file is complete
+func fileComplete(file *torrent.File) bool {
+	for _, piece := range file.State() {
+		if !piece.Complete {
+			return false
+		}
+	}
+	return true
+}
+
+//fileCompleted returns the number of bytes of file downloaded and verified
+func fileCompleted(file *torrent.File) int64 {
+	var completed int64
+	for _, piece := range file.State() {
+		if piece.Complete {
+			completed += piece.Bytes
+		}
+	}
+	return completed
+}
+
+//Stats are the totals of a client's torrents
+type Stats struct {
+	Torrents int
+	//BytesRead and BytesWritten are the payload bytes downloaded and
+	// uploaded by the torrents still in the client
+	BytesRead, BytesWritten int64
+	ActivePeers, TotalPeers int
+}
+
+//Stats returns the totals of the client's torrents
+func (cli *Client) Stats() Stats {
+	var stats Stats
+	cli.mu.Lock()
+	defer cli.mu.Unlock()
+	for _, h := range cli.torrents {
+		torStats := h.tor.Stats()
+		stats.Torrents++
+		stats.BytesRead += torStats.BytesReadData
+		stats.BytesWritten += torStats.BytesWrittenData
+		stats.ActivePeers += torStats.ActivePeers
+		stats.TotalPeers += torStats.TotalPeers
+	}
+	return stats
+}
+
+//Close drops every torrent and closes the client
+func (cli *Client) Close() {
+	close(cli.stop)
+	<-cli.done
+	cli.mu.Lock()
+	for hash := range cli.torrents {
+		cli.drop(hash)
 	}
-	return false
+	cli.mu.Unlock()
+	cli.Client.Close()
 }
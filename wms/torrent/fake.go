@@ -0,0 +1,546 @@
+package torrent
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+//Fake is a torrent client for tests, it connects to no peer and its
+// torrents only get their info and files when told to
+type Fake struct {
+	mu       sync.Mutex
+	torrents map[string]*fakeTorrent
+	policy   Policy
+	closed   bool
+}
+
+var _ Torrents = &Fake{}
+
+type fakeTorrent struct {
+	info    *Info
+	gotInfo chan struct{}
+	dropped chan struct{}
+	limits  Limits
+	//downloading and complete files by path
+	downloading, complete map[string]bool
+	//data downloaded of the files by path
+	data map[string][]byte
+	//changed is closed and replaced once data changes
+	changed chan struct{}
+	peers   int
+	events  broadcaster
+}
+
+//NewFake creates a fake torrent client without torrents
+func NewFake() *Fake {
+	return &Fake{torrents: make(map[string]*fakeTorrent)}
+}
+
+//AddLink adds the torrent of a magnet link and returns its info-hash
+func (f *Fake) AddLink(link string) (string, error) {
+	hash, _, err := ParseMagnet(link)
+	if err != nil {
+		return "", err
+	}
+	f.add(hash)
+	return hash, nil
+}
+
+//AddTorrentFile adds the torrent of the .torrent file in data and returns
+// its info-hash, its info must still be set
+func (f *Fake) AddTorrentFile(data []byte) (string, error) {
+	tf, err := ParseTorrentFile(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	f.add(tf.InfoHash)
+	return tf.InfoHash, nil
+}
+
+func (f *Fake) add(hash string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, found := f.torrents[hash]; found {
+		return
+	}
+	f.torrents[hash] = &fakeTorrent{
+		gotInfo:     make(chan struct{}),
+		dropped:     make(chan struct{}),
+		downloading: make(map[string]bool),
+		complete:    make(map[string]bool),
+		data:        make(map[string][]byte),
+		changed:     make(chan struct{}),
+	}
+}
+
+//torrent returns the torrent with the given info-hash, mu must be held
+func (f *Fake) torrent(hash string) (*fakeTorrent, error) {
+	t, found := f.torrents[hash]
+	if !found {
+		return nil, ErrTorrentNotFound
+	}
+	return t, nil
+}
+
+//SetInfo of the torrent with the given info-hash, which is received at
+// once
+func (f *Fake) SetInfo(hash string, info Info) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t, err := f.torrent(hash)
+	if err != nil {
+		return err
+	}
+	if t.info == nil {
+		close(t.gotInfo)
+	}
+	t.info = &info
+	return nil
+}
+
+//SetPeers sets the number of peers the torrent with the given info-hash is
+// connected to
+func (f *Fake) SetPeers(hash string, peers int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t, err := f.torrent(hash)
+	if err != nil {
+		return err
+	}
+	t.peers = peers
+	return nil
+}
+
+//CompleteFile completes the file at path of the torrent with the given
+// info-hash, it is published if it is being downloaded. Pieces are not
+// published.
+func (f *Fake) CompleteFile(hash, path string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t, err := f.torrent(hash)
+	if err != nil {
+		return err
+	}
+	if _, err := t.file(path); err != nil {
+		return err
+	}
+	if t.complete[path] {
+		return nil
+	}
+	t.complete[path] = true
+	t.notify()
+	if t.downloading[path] {
+		t.events.publish(Event{Type: EventFileComplete, Hash: hash, Path: path})
+	}
+	return nil
+}
+
+//SetFileData sets the data downloaded so far of the file at path of the
+// torrent with the given info-hash, readers block past its end until the
+// file is complete and read zeros then
+func (f *Fake) SetFileData(hash, path string, data []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t, err := f.torrent(hash)
+	if err != nil {
+		return err
+	}
+	file, err := t.file(path)
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) > file.Length {
+		data = data[:file.Length]
+	}
+	t.data[path] = append([]byte(nil), data...)
+	t.notify()
+	return nil
+}
+
+//Downloading returns the paths of the files of the torrent with the given
+// info-hash that are being downloaded
+func (f *Fake) Downloading(hash string) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t, err := f.torrent(hash)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for path := range t.downloading {
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+//Limits returns the limits of the torrent with the given info-hash
+func (f *Fake) Limits(hash string) (Limits, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t, err := f.torrent(hash)
+	if err != nil {
+		return Limits{}, err
+	}
+	return t.limits, nil
+}
+
+//Has returns true if the torrent with the given info-hash was added and not
+// dropped since
+func (f *Fake) Has(hash string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, found := f.torrents[hash]
+	return found
+}
+
+//WaitForInfo waits until the info of the torrent with the given info-hash
+// is set
+func (f *Fake) WaitForInfo(ctx context.Context, hash string) (*Info, error) {
+	f.mu.Lock()
+	t, err := f.torrent(hash)
+	f.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case <-t.gotInfo:
+	case <-t.dropped:
+		return nil, ErrTorrentNotFound
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return t.info, nil
+}
+
+//Download the files of the torrent with the given info-hash at paths
+func (f *Fake) Download(hash string, paths ...string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t, err := f.torrent(hash)
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		if _, err := t.file(path); err != nil {
+			return err
+		}
+		if t.complete[path] && !t.downloading[path] {
+			t.events.publish(Event{
+				Type: EventFileComplete,
+				Hash: hash,
+				Path: path,
+			})
+		}
+		t.downloading[path] = true
+	}
+	return nil
+}
+
+//FileComplete returns true if the file at path of the torrent with the
+// given info-hash was completed
+func (f *Fake) FileComplete(hash, path string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t, err := f.torrent(hash)
+	if err != nil {
+		return false, err
+	}
+	if _, err := t.file(path); err != nil {
+		return false, err
+	}
+	return t.complete[path], nil
+}
+
+//FileProgress returns the progress of every file of the torrent with the
+// given info-hash, incomplete files have the length of their data completed
+func (f *Fake) FileProgress(hash string) ([]FileProgress, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t, err := f.torrent(hash)
+	if err != nil {
+		return nil, err
+	}
+	if t.info == nil {
+		return nil, ErrNoInfo
+	}
+	progress := make([]FileProgress, len(t.info.Files))
+	for i, file := range t.info.Files {
+		progress[i] = FileProgress{
+			File:      file,
+			Completed: t.completed(file),
+			Selected:  t.downloading[file.Path],
+		}
+	}
+	return progress, nil
+}
+
+//IsComplete returns true if every file of the torrent with the given
+// info-hash being downloaded, or every file if none is, was completed
+func (f *Fake) IsComplete(hash string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t, err := f.torrent(hash)
+	if err != nil || t.info == nil {
+		return false
+	}
+	for _, file := range t.info.Files {
+		selected := len(t.downloading) == 0 || t.downloading[file.Path]
+		if selected && !t.complete[file.Path] {
+			return false
+		}
+	}
+	return true
+}
+
+//Progress returns the length of the completed and missing files being
+// downloaded, or of every file if none is, of the torrent with the given
+// info-hash
+func (f *Fake) Progress(hash string) (Progress, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t, err := f.torrent(hash)
+	if err != nil {
+		return Progress{}, err
+	}
+	progress := Progress{Peers: t.peers}
+	if t.info == nil {
+		return progress, nil
+	}
+	for _, file := range t.info.Files {
+		if len(t.downloading) > 0 && !t.downloading[file.Path] {
+			continue
+		}
+		completed := t.completed(file)
+		progress.Completed += completed
+		progress.Missing += file.Length - completed
+	}
+	return progress, nil
+}
+
+//NewReader returns a reader of the file at path of the torrent with the
+// given info-hash, it reads the data set and ignores the readahead
+func (f *Fake) NewReader(
+	ctx context.Context,
+	hash, path string,
+	opts ReaderOptions,
+) (FileReader, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t, err := f.torrent(hash)
+	if err != nil {
+		return nil, err
+	}
+	file, err := t.file(path)
+	if err != nil {
+		return nil, err
+	}
+	return &fakeReader{
+		ctx:     ctx,
+		f:       f,
+		t:       t,
+		file:    file,
+		timeout: opts.Timeout,
+	}, nil
+}
+
+//SetLimits of the torrent with the given info-hash
+func (f *Fake) SetLimits(hash string, limits Limits) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t, err := f.torrent(hash)
+	if err != nil {
+		return err
+	}
+	t.limits = limits
+	return nil
+}
+
+//Subscribe to the events of the torrent with the given info-hash
+func (f *Fake) Subscribe(hash string) (*Subscription, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t, err := f.torrent(hash)
+	if err != nil {
+		return nil, err
+	}
+	return t.events.subscribe(), nil
+}
+
+//DropTorrent with the given info-hash, it does nothing if there is none
+func (f *Fake) DropTorrent(hash string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.drop(hash)
+}
+
+//drop the torrent with the given info-hash, mu must be held
+func (f *Fake) drop(hash string) {
+	if t, found := f.torrents[hash]; found {
+		close(t.dropped)
+		t.notify()
+		t.events.close()
+		delete(f.torrents, hash)
+	}
+}
+
+//Policy returns the policy last set
+func (f *Fake) Policy() Policy {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.policy
+}
+
+//SetPolicy stores policy, it isn't applied
+func (f *Fake) SetPolicy(policy Policy) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.policy = policy
+	return nil
+}
+
+//ListenAddr returns a loopback address until f is closed
+func (f *Fake) ListenAddr() net.Addr {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return nil
+	}
+	return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)}
+}
+
+//Stats returns the number of torrents and their peers
+func (f *Fake) Stats() Stats {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	stats := Stats{Torrents: len(f.torrents)}
+	for _, t := range f.torrents {
+		stats.ActivePeers += t.peers
+		stats.TotalPeers += t.peers
+	}
+	return stats
+}
+
+//Close drops every torrent
+func (f *Fake) Close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for hash := range f.torrents {
+		f.drop(hash)
+	}
+	f.closed = true
+}
+
+//file returns the file of t at path
+func (t *fakeTorrent) file(path string) (File, error) {
+	if t.info == nil {
+		return File{}, ErrNoInfo
+	}
+	for _, file := range t.info.Files {
+		if file.Path == path {
+			return file, nil
+		}
+	}
+	return File{}, ErrFileNotFound
+}
+
+//completed returns the number of bytes of file downloaded
+func (t *fakeTorrent) completed(file File) int64 {
+	if t.complete[file.Path] {
+		return file.Length
+	}
+	return int64(len(t.data[file.Path]))
+}
+
+//notify the readers waiting for data that it changed, the fake's mu must be
+// held
+func (t *fakeTorrent) notify() {
+	close(t.changed)
+	t.changed = make(chan struct{})
+}
+
+//fakeReader reads a file of a fake torrent
+type fakeReader struct {
+	ctx     context.Context
+	f       *Fake
+	t       *fakeTorrent
+	file    File
+	timeout time.Duration
+	pos     int64
+}
+
+func (r *fakeReader) Read(p []byte) (int, error) {
+	var timeout <-chan time.Time
+	if r.timeout > 0 {
+		timer := time.NewTimer(r.timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	for {
+		if r.pos >= r.file.Length {
+			return 0, io.EOF
+		}
+		if left := r.file.Length - r.pos; int64(len(p)) > left {
+			p = p[:left]
+		}
+		r.f.mu.Lock()
+		data, complete := r.t.data[r.file.Path], r.t.complete[r.file.Path]
+		changed := r.t.changed
+		r.f.mu.Unlock()
+		var n int
+		switch {
+		case r.pos < int64(len(data)):
+			n = copy(p, data[r.pos:])
+		case complete:
+			for i := range p {
+				p[i] = 0
+			}
+			n = len(p)
+		}
+		if n > 0 {
+			r.pos += int64(n)
+			return n, nil
+		}
+		select {
+		case <-changed:
+		case <-r.t.dropped:
+			return 0, ErrTorrentNotFound
+		case <-timeout:
+			return 0, ErrReadTimeout
+		case <-r.ctx.Done():
+			return 0, r.ctx.Err()
+		}
+	}
+}
+
+func (r *fakeReader) Seek(offset int64, whence int) (int64, error) {
+	var pos int64
+	switch whence {
+	case io.SeekStart:
+		pos = offset
+	case io.SeekCurrent:
+		pos = r.pos + offset
+	case io.SeekEnd:
+		pos = r.file.Length + offset
+	default:
+		return r.pos, errors.New("invalid whence")
+	}
+	if pos < 0 {
+		return r.pos, errors.New("negative position")
+	}
+	r.pos = pos
+	return pos, nil
+}
+
+func (r *fakeReader) Close() error {
+	return nil
+}
@@ -0,0 +1,63 @@
+package torrent
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+//MaxTorrentFileSize is the largest .torrent file accepted
+const MaxTorrentFileSize = 4 << 20
+
+//ErrTorrentFileTooLarge is returned when a .torrent file is larger than
+// MaxTorrentFileSize
+var ErrTorrentFileTooLarge = errors.New("torrent file is too large")
+
+//ParseMagnet returns the info-hash in hex and the display name, which may
+// be empty, of a magnet link
+func ParseMagnet(link string) (hash, name string, err error) {
+	m, err := metainfo.ParseMagnetURI(link)
+	if err != nil {
+		return "", "", err
+	}
+	return m.InfoHash.HexString(), m.DisplayName, nil
+}
+
+//TorrentFile is a parsed .torrent file
+type TorrentFile struct {
+	//InfoHash in hex
+	InfoHash string
+	Name     string
+	//Length of all the torrent's files in bytes
+	Length int64
+	//Data is the file's content
+	Data []byte
+}
+
+//ParseTorrentFile reads a .torrent file from r
+func ParseTorrentFile(r io.Reader) (*TorrentFile, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(r, MaxTorrentFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > MaxTorrentFileSize {
+		return nil, ErrTorrentFileTooLarge
+	}
+	mi, err := metainfo.Load(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	info, err := mi.UnmarshalInfo()
+	if err != nil {
+		return nil, err
+	}
+	return &TorrentFile{
+		InfoHash: mi.HashInfoBytes().HexString(),
+		Name:     info.Name,
+		Length:   info.TotalLength(),
+		Data:     data,
+	}, nil
+}
@@ -0,0 +1,231 @@
+package torrent
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"golang.org/x/time/rate"
+)
+
+//DefaultMaxConns is the number of peers a torrent is connected to if the
+// policy doesn't set it
+const DefaultMaxConns = 50
+
+const (
+	//enforceInterval is how often the policy is applied to the torrents
+	enforceInterval = time.Second
+	//burstSeconds is how many seconds of a torrent's rate limit it can use
+	// at once after being idle
+	burstSeconds = 2
+	//limiterBurst is the burst of the client's limiters, it must hold the
+	// chunks the client waits for at once
+	limiterBurst = 1 << 16
+)
+
+//Policy of the client's bandwidth, connections and seeding
+type Policy struct {
+	//DownloadRate and UploadRate limits of the whole client in bytes per
+	// second, 0 is unlimited
+	DownloadRate, UploadRate int64
+	//MaxConns is the maximum number of peers a torrent is connected to, 0 is
+	// DefaultMaxConns
+	MaxConns int
+	//Seed completed torrents until they uploaded SeedRatio times their size
+	// or were seeded for SeedTime, a target of 0 is ignored. Torrents are
+	// dropped once done seeding, their files are kept.
+	Seed      bool
+	SeedRatio float64
+	SeedTime  time.Duration
+	//QuietHours replace the rate limits during part of the day
+	QuietHours QuietHours
+}
+
+//QuietHours are the part of the day during which the client is limited to
+// other rates
+type QuietHours struct {
+	//Start and End of the quiet hours in local time, they may span midnight.
+	// Quiet hours are disabled if Start and End are equal.
+	Start, End Clock
+	//DownloadRate and UploadRate during quiet hours, 0 is unlimited
+	DownloadRate, UploadRate int64
+}
+
+//Enabled returns true if q covers part of the day
+func (q QuietHours) Enabled() bool {
+	return q.Start != q.End
+}
+
+//Contains returns true if t is within the quiet hours
+func (q QuietHours) Contains(t time.Time) bool {
+	c := Clock(t.Hour()*60 + t.Minute())
+	if q.Start <= q.End {
+		return q.Start <= c && c < q.End
+	}
+	return c >= q.Start || c < q.End
+}
+
+//Validate returns an error if p has negative limits
+func (p Policy) Validate() error {
+	switch {
+	case p.DownloadRate < 0 || p.UploadRate < 0:
+		return errors.New("rate limits must not be negative")
+	case p.QuietHours.DownloadRate < 0 || p.QuietHours.UploadRate < 0:
+		return errors.New("quiet hours rate limits must not be negative")
+	case p.MaxConns < 0:
+		return errors.New("max conns must not be negative")
+	case p.SeedRatio < 0 || p.SeedTime < 0:
+		return errors.New("seeding targets must not be negative")
+	}
+	return nil
+}
+
+//Rates returns the download and upload limits in effect at t
+func (p Policy) Rates(t time.Time) (down, up int64) {
+	if p.QuietHours.Enabled() && p.QuietHours.Contains(t) {
+		return p.QuietHours.DownloadRate, p.QuietHours.UploadRate
+	}
+	return p.DownloadRate, p.UploadRate
+}
+
+func (p Policy) maxConns() int {
+	if p.MaxConns == 0 {
+		return DefaultMaxConns
+	}
+	return p.MaxConns
+}
+
+//Limits of a single torrent, they apply on top of the policy's, 0 keeps
+// the policy's
+type Limits struct {
+	//DownloadRate and UploadRate in bytes per second
+	DownloadRate, UploadRate int64
+	MaxConns                 int
+}
+
+//Clock is a time of day in minutes since midnight, written like 22:30
+type Clock int
+
+//ParseClock parses a time of day written like 22:30
+func ParseClock(s string) (Clock, error) {
+	var h, m int
+	_, err := fmt.Sscanf(s, "%d:%d", &h, &m)
+	if err != nil || h < 0 || h > 23 || m < 0 || m > 59 {
+		return 0, fmt.Errorf("invalid time of day '%s', use HH:MM", s)
+	}
+	return Clock(h*60 + m), nil
+}
+
+func (c Clock) String() string {
+	return fmt.Sprintf("%02d:%02d", int(c)/60, int(c)%60)
+}
+
+//MarshalText formats c like 22:30
+func (c Clock) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+//UnmarshalText parses a time of day written like 22:30
+func (c *Clock) UnmarshalText(text []byte) error {
+	var err error
+	*c, err = ParseClock(string(text))
+	return err
+}
+
+//torrentState is what the client tracks to apply the policy to a torrent
+type torrentState struct {
+	limits Limits
+	//read and written are the payload bytes transferred at the last tick
+	read, written int64
+	//down and up are the bytes the torrent may still transfer, it is paused
+	// while either is negative
+	down, up float64
+	paused   bool
+	//conns is the connection limit last set, -1 if none was
+	conns       int
+	completedAt time.Time
+}
+
+func newTorrentState(tor *torrent.Torrent) *torrentState {
+	stats := tor.Stats()
+	return &torrentState{
+		read:    stats.BytesReadData,
+		written: stats.BytesWrittenData,
+		conns:   -1,
+	}
+}
+
+//throttle charges the payload transferred since the last tick to the
+// torrent's allowances, the torrent is paused while one is overdrawn
+func (st *torrentState) throttle(
+	stats torrent.TorrentStats,
+	elapsed time.Duration,
+) {
+	st.down = allowance(
+		st.down,
+		st.limits.DownloadRate,
+		stats.BytesReadData-st.read,
+		elapsed,
+	)
+	st.up = allowance(
+		st.up,
+		st.limits.UploadRate,
+		stats.BytesWrittenData-st.written,
+		elapsed,
+	)
+	st.read, st.written = stats.BytesReadData, stats.BytesWrittenData
+	st.paused = st.down < 0 || st.up < 0
+}
+
+//allowance returns what is left of current once used bytes are charged and
+// elapsed time at rate is credited, up to burstSeconds of rate
+func allowance(
+	current float64,
+	rate int64,
+	used int64,
+	elapsed time.Duration,
+) float64 {
+	if rate <= 0 {
+		return 0
+	}
+	current += float64(rate)*elapsed.Seconds() - float64(used)
+	if max := float64(rate) * burstSeconds; current > max {
+		current = max
+	}
+	return current
+}
+
+//doneSeeding returns true if tor is complete, only the files downloaded
+// count, and the policy doesn't have it seeded anymore
+func (p Policy) doneSeeding(
+	now time.Time,
+	complete bool,
+	tor *torrent.Torrent,
+	st *torrentState,
+) bool {
+	if !complete {
+		return false
+	}
+	if st.completedAt.IsZero() {
+		st.completedAt = now
+	}
+	switch {
+	case !p.Seed:
+		return true
+	case p.SeedRatio > 0 &&
+		float64(st.written) >= p.SeedRatio*float64(tor.Length()):
+		return true
+	case p.SeedTime > 0 && now.Sub(st.completedAt) >= p.SeedTime:
+		return true
+	}
+	return false
+}
+
+//limit converts bytesPerSecond to a limit, 0 is unlimited
+func limit(bytesPerSecond int64) rate.Limit {
+	if bytesPerSecond <= 0 {
+		return rate.Inf
+	}
+	return rate.Limit(bytesPerSecond)
+}
@@ -0,0 +1,244 @@
+package torrent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/waelbendhia/music-streaming/wms/models"
+	"github.com/waelbendhia/music-streaming/wms/store"
+	"gopkg.in/mgo.v2/bson"
+)
+
+//ErrQueueFull is returned when a user already has as many unfinished
+// downloads as they may
+var ErrQueueFull = errors.New("download queue is full")
+
+//ErrAlreadyQueued is returned when an unfinished job already downloads the
+// torrent with the same info-hash
+var ErrAlreadyQueued = errors.New("torrent is already being downloaded")
+
+//scheduleInterval is how often the scheduler looks for stalled jobs and
+// retries the queued jobs that could not start
+const scheduleInterval = 30 * time.Second
+
+//SchedulerConfig of a scheduler
+type SchedulerConfig struct {
+	//MaxActive is the maximum number of jobs downloading at once, 0 is
+	// unlimited
+	MaxActive int
+	//MaxQueuedPerUser is the maximum number of unfinished jobs of a user, 0
+	// is unlimited. Jobs without a user count as those of a single anonymous
+	// user.
+	MaxQueuedPerUser int
+	//StallTimeout is how long a downloading job may go without progress
+	// before it is stalled, 0 is never
+	StallTimeout time.Duration
+	//Start starts downloading a queued job, it must set its state to
+	// downloading or leave it queued if it can't start yet
+	Start func(job *models.Job) error
+	//Stalled is called with a downloading job that stalled and why, it must
+	// take the job out of the downloading state
+	Stalled func(job *models.Job, reason string)
+	//Logger logs the jobs that could not start, defaults to the standard
+	// logger's output
+	Logger *log.Logger
+}
+
+//Scheduler starts the queued jobs, highest priority then oldest first,
+// while fewer than the maximum are downloading, and reports the
+// downloading ones that stall
+type Scheduler struct {
+	cfg  SchedulerConfig
+	cli  Torrents
+	jobs store.JobStore
+	wake chan struct{}
+
+	//mu serializes enqueuing and scheduling, so a user can't go over their
+	// limit and a job can't be started twice
+	mu sync.Mutex
+	//progress of the downloading jobs by ID
+	progress map[bson.ObjectId]*jobProgress
+}
+
+//jobProgress is the last progress a downloading job made
+type jobProgress struct {
+	hash      string
+	completed int64
+	at        time.Time
+	//peered is true if the torrent was connected to a peer since at
+	peered bool
+}
+
+//NewScheduler creates a scheduler of the jobs in jobs whose torrents are
+// downloaded by cli, it does nothing until it is run
+func NewScheduler(
+	cfg SchedulerConfig,
+	cli Torrents,
+	jobs store.JobStore,
+) *Scheduler {
+	if cfg.Logger == nil {
+		cfg.Logger = log.New(os.Stderr, "", log.LstdFlags)
+	}
+	return &Scheduler{
+		cfg:      cfg,
+		cli:      cli,
+		jobs:     jobs,
+		wake:     make(chan struct{}, 1),
+		progress: make(map[bson.ObjectId]*jobProgress),
+	}
+}
+
+//Enqueue stores job as queued and wakes the scheduler, it returns
+// ErrQueueFull if job's user, or anonymous users together if it has none,
+// already has too many unfinished jobs and ErrAlreadyQueued if job's
+// info-hash is known and another unfinished job has it
+func (s *Scheduler) Enqueue(job *models.Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	jobs, err := s.jobs.Jobs(models.JobQueued, models.JobDownloading)
+	if err != nil {
+		return err
+	}
+	count := 0
+	for _, other := range jobs {
+		if job.InfoHash != "" && other.InfoHash == job.InfoHash {
+			return ErrAlreadyQueued
+		}
+		if other.User == job.User {
+			count++
+		}
+	}
+	if s.cfg.MaxQueuedPerUser > 0 && count >= s.cfg.MaxQueuedPerUser {
+		return ErrQueueFull
+	}
+	job.State = models.JobQueued
+	if err := s.jobs.InsertJob(job); err != nil {
+		return err
+	}
+	s.Wake()
+	return nil
+}
+
+//Wake has the scheduler run as soon as possible, it should be called when
+// a job is queued or stops downloading
+func (s *Scheduler) Wake() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+//Run schedules the jobs when woken and every scheduleInterval until ctx is
+// done
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(scheduleInterval)
+	defer ticker.Stop()
+	for {
+		if err := s.schedule(time.Now()); err != nil {
+			s.cfg.Logger.Printf("Could not schedule downloads: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+	}
+}
+
+//schedule reports the stalled jobs then starts as many queued jobs as
+// there is room for
+func (s *Scheduler) schedule(now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	jobs, err := s.jobs.Jobs(models.JobQueued, models.JobDownloading)
+	if err != nil {
+		return err
+	}
+	var queued []*models.Job
+	downloading := make(map[bson.ObjectId]bool)
+	for i := range jobs {
+		job := &jobs[i]
+		if job.State == models.JobQueued {
+			queued = append(queued, job)
+			continue
+		}
+		if reason := s.stalled(now, job); reason != "" {
+			s.cfg.Stalled(job, reason)
+			// A job moved on to another torrent keeps its place in the queue
+			if job.State == models.JobQueued {
+				queued = append(queued, job)
+			}
+			continue
+		}
+		downloading[job.ID] = true
+	}
+	for id := range s.progress {
+		if !downloading[id] {
+			delete(s.progress, id)
+		}
+	}
+	sort.SliceStable(queued, func(i, j int) bool {
+		a, b := queued[i], queued[j]
+		if a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		}
+		return a.CreatedAt.Before(b.CreatedAt)
+	})
+	active := len(downloading)
+	for _, job := range queued {
+		if s.cfg.MaxActive > 0 && active >= s.cfg.MaxActive {
+			break
+		}
+		if err := s.cfg.Start(job); err != nil {
+			s.cfg.Logger.Printf(
+				"Could not start download %s: %v",
+				job.ID.Hex(),
+				err,
+			)
+			continue
+		}
+		if job.State == models.JobDownloading {
+			active++
+		}
+	}
+	return nil
+}
+
+//stalled returns why job stalled, or nothing if it made progress within
+// the stall timeout. Jobs whose torrent isn't in the client never stall.
+func (s *Scheduler) stalled(now time.Time, job *models.Job) string {
+	if s.cfg.StallTimeout <= 0 {
+		return ""
+	}
+	progress, err := s.cli.Progress(job.InfoHash)
+	if err != nil {
+		return ""
+	}
+	last, found := s.progress[job.ID]
+	if !found ||
+		last.hash != job.InfoHash ||
+		progress.Completed > last.completed {
+		s.progress[job.ID] = &jobProgress{
+			hash:      job.InfoHash,
+			completed: progress.Completed,
+			at:        now,
+			peered:    progress.Peers > 0,
+		}
+		return ""
+	}
+	last.peered = last.peered || progress.Peers > 0
+	switch {
+	case now.Sub(last.at) < s.cfg.StallTimeout:
+		return ""
+	case !last.peered:
+		return fmt.Sprintf("no peers for %v", s.cfg.StallTimeout)
+	}
+	return fmt.Sprintf("no progress for %v", s.cfg.StallTimeout)
+}
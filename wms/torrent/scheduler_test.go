@@ -0,0 +1,305 @@
+package torrent
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/waelbendhia/music-streaming/wms/models"
+	"github.com/waelbendhia/music-streaming/wms/store"
+	"github.com/waelbendhia/music-streaming/wms/store/memory"
+)
+
+//testScheduler is a scheduler of the jobs in a memory store whose torrents
+// are added to a fake client when they start
+type testScheduler struct {
+	*Scheduler
+	cli  *Fake
+	jobs store.JobStore
+	//started are the names of the jobs started, in order, and stalled why
+	// the jobs stalled by name
+	started []string
+	stalled map[string]string
+	//hashes is the number of info-hashes handed out
+	hashes int
+}
+
+func newTestScheduler(t *testing.T, cfg SchedulerConfig) *testScheduler {
+	s := &testScheduler{
+		cli:     NewFake(),
+		jobs:    memory.New(),
+		stalled: make(map[string]string),
+	}
+	cfg.Start = func(job *models.Job) error {
+		hash, err := s.cli.AddLink(job.Magnet)
+		if err != nil {
+			return err
+		}
+		s.started = append(s.started, job.Name)
+		job.InfoHash = hash
+		job.State = models.JobDownloading
+		return s.jobs.UpdateJob(job)
+	}
+	// Jobs move on to their next candidate like the server's, or fail
+	cfg.Stalled = func(job *models.Job, reason string) {
+		s.stalled[job.Name] = reason
+		s.cli.DropTorrent(job.InfoHash)
+		job.State = models.JobFailed
+		if len(job.Candidates) > 0 {
+			job.Magnet, job.InfoHash = job.Candidates[0].Magnet, ""
+			job.Candidates = job.Candidates[1:]
+			job.State = models.JobQueued
+		}
+		if err := s.jobs.UpdateJob(job); err != nil {
+			t.Error(err)
+		}
+	}
+	cfg.Logger = log.New(ioutil.Discard, "", 0)
+	s.Scheduler = NewScheduler(cfg, s.cli, s.jobs)
+	return s
+}
+
+//magnet returns a magnet link of a torrent no other has and its info-hash
+func (s *testScheduler) magnet() (string, string) {
+	s.hashes++
+	hash := fmt.Sprintf("%040x", s.hashes)
+	return "magnet:?xt=urn:btih:" + hash, hash
+}
+
+//insert a job named name in state, created after those inserted before.
+// The torrents of downloading jobs are added with a single file, named
+// like the job.
+func (s *testScheduler) insert(
+	t *testing.T,
+	name string,
+	state models.JobState,
+	priority int,
+) *models.Job {
+	t.Helper()
+	magnet, hash := s.magnet()
+	job := &models.Job{
+		Name:      name,
+		State:     state,
+		Priority:  priority,
+		Magnet:    magnet,
+		CreatedAt: time.Unix(int64(s.hashes), 0),
+	}
+	if state == models.JobDownloading {
+		job.InfoHash = hash
+		if _, err := s.cli.AddLink(magnet); err != nil {
+			t.Fatal(err)
+		}
+		err := s.cli.SetInfo(hash, Info{
+			Name:  name,
+			Files: []File{{Path: name, Length: 100}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.jobs.InsertJob(job); err != nil {
+		t.Fatal(err)
+	}
+	return job
+}
+
+func TestSchedule(t *testing.T) {
+	type job struct {
+		name     string
+		state    models.JobState
+		priority int
+	}
+	queued, downloading := models.JobQueued, models.JobDownloading
+	for _, test := range []struct {
+		name      string
+		maxActive int
+		jobs      []job
+		started   []string
+	}{
+		{
+			"unlimited",
+			0,
+			[]job{{"a", queued, 0}, {"b", queued, 0}},
+			[]string{"a", "b"},
+		},
+		{
+			"oldest first",
+			1,
+			[]job{{"a", queued, 0}, {"b", queued, 0}},
+			[]string{"a"},
+		},
+		{
+			"highest priority first",
+			1,
+			[]job{{"a", queued, 0}, {"b", queued, 1}},
+			[]string{"b"},
+		},
+		{
+			"priority then age",
+			2,
+			[]job{{"a", queued, 0}, {"b", queued, 1}, {"c", queued, 1}},
+			[]string{"b", "c"},
+		},
+		{
+			"downloading jobs count",
+			2,
+			[]job{{"a", downloading, 0}, {"b", queued, 0}, {"c", queued, 0}},
+			[]string{"b"},
+		},
+		{
+			"full",
+			1,
+			[]job{{"a", downloading, 0}, {"b", queued, 1}},
+			nil,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			s := newTestScheduler(t, SchedulerConfig{MaxActive: test.maxActive})
+			for _, job := range test.jobs {
+				s.insert(t, job.name, job.state, job.priority)
+			}
+			if err := s.schedule(time.Now()); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(s.started, test.started) {
+				t.Errorf("started %v, want %v", s.started, test.started)
+			}
+		})
+	}
+}
+
+func TestEnqueue(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		//user of the job enqueued, the other jobs are alice's
+		user string
+		//sameTorrent has the job enqueued download the first one's torrent
+		sameTorrent bool
+		err         error
+	}{
+		{"other user", "bob", false, nil},
+		{"too many", "alice", false, ErrQueueFull},
+		{"same torrent", "bob", true, ErrAlreadyQueued},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			s := newTestScheduler(t, SchedulerConfig{MaxQueuedPerUser: 2})
+			var first *models.Job
+			for _, name := range []string{"a", "b"} {
+				job := s.insert(t, name, models.JobDownloading, 0)
+				job.User = "alice"
+				if err := s.jobs.UpdateJob(job); err != nil {
+					t.Fatal(err)
+				}
+				if first == nil {
+					first = job
+				}
+			}
+			magnet, hash := s.magnet()
+			job := &models.Job{Name: "c", User: test.user, Magnet: magnet}
+			if test.sameTorrent {
+				job.InfoHash = first.InfoHash
+			} else {
+				job.InfoHash = hash
+			}
+			if err := s.Enqueue(job); err != test.err {
+				t.Fatalf("got %v, want %v", err, test.err)
+			}
+			if test.err == nil && job.State != models.JobQueued {
+				t.Errorf("enqueued job is %s", job.State)
+			}
+		})
+	}
+}
+
+func TestStalled(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		//data downloaded, and peers connected, before the job is checked
+		// again after elapsed
+		data    int
+		peers   int
+		elapsed time.Duration
+		//dropped drops the job's torrent before it is checked again
+		dropped bool
+		//candidate gives the job another torrent to move on to
+		candidate bool
+		stalled   string
+		started   []string
+	}{
+		{
+			name:    "within the timeout",
+			elapsed: 30 * time.Second,
+		},
+		{
+			name:    "progress",
+			data:    10,
+			elapsed: 2 * time.Minute,
+		},
+		{
+			name:    "no peers",
+			elapsed: 2 * time.Minute,
+			stalled: "no peers for 1m0s",
+		},
+		{
+			name:    "no progress",
+			peers:   3,
+			elapsed: 2 * time.Minute,
+			stalled: "no progress for 1m0s",
+		},
+		{
+			name:    "not in the client",
+			elapsed: 2 * time.Minute,
+			dropped: true,
+		},
+		{
+			name:      "next candidate",
+			elapsed:   2 * time.Minute,
+			candidate: true,
+			stalled:   "no peers for 1m0s",
+			started:   []string{"a"},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			s := newTestScheduler(t, SchedulerConfig{StallTimeout: time.Minute})
+			job := s.insert(t, "a", models.JobDownloading, 0)
+			if test.candidate {
+				magnet, _ := s.magnet()
+				job.Candidates = []models.Candidate{{Name: "b", Magnet: magnet}}
+				if err := s.jobs.UpdateJob(job); err != nil {
+					t.Fatal(err)
+				}
+			}
+			now := time.Now()
+			if err := s.schedule(now); err != nil {
+				t.Fatal(err)
+			}
+			if test.dropped {
+				s.cli.DropTorrent(job.InfoHash)
+			}
+			if test.data > 0 {
+				data := make([]byte, test.data)
+				err := s.cli.SetFileData(job.InfoHash, "a", data)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if test.peers > 0 {
+				if err := s.cli.SetPeers(job.InfoHash, test.peers); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := s.schedule(now.Add(test.elapsed)); err != nil {
+				t.Fatal(err)
+			}
+			if reason := s.stalled["a"]; reason != test.stalled {
+				t.Errorf("stalled %q, want %q", reason, test.stalled)
+			}
+			if !reflect.DeepEqual(s.started, test.started) {
+				t.Errorf("started %v, want %v", s.started, test.started)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package torrent
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+//ErrReadTimeout is returned by the reads of a FileReader whose data wasn't
+// downloaded in time
+var ErrReadTimeout = errors.New("timed out waiting for torrent data")
+
+//FileReader reads a file of a torrent while it downloads, the pieces ahead
+// of its position are downloaded first. Reads block until their data is
+// downloaded and fail with ErrReadTimeout if it takes too long.
+type FileReader interface {
+	io.ReadSeeker
+	io.Closer
+}
+
+//ReaderOptions of a FileReader
+type ReaderOptions struct {
+	//Readahead is the number of bytes ahead of the reader's position that
+	// are downloaded first
+	Readahead int64
+	//Timeout is how long a read waits for its data, 0 is unlimited
+	Timeout time.Duration
+}
+
+//NewReader returns a reader of the file at path of the torrent with the
+// given info-hash, its reads fail with ctx's error once ctx is done
+func (cli *Client) NewReader(
+	ctx context.Context,
+	hash, path string,
+	opts ReaderOptions,
+) (FileReader, error) {
+	h, err := cli.handle(hash)
+	if err != nil {
+		return nil, err
+	}
+	file, err := h.file(path)
+	if err != nil {
+		return nil, err
+	}
+	r := h.tor.NewReader()
+	// Playback can start before the pieces read are verified
+	r.SetResponsive()
+	r.SetReadahead(opts.Readahead)
+	fr := &fileReader{ctx: ctx, r: r, file: file, opts: opts}
+	if _, err := fr.Seek(0, io.SeekStart); err != nil {
+		r.Close()
+		return nil, err
+	}
+	return fr, nil
+}
+
+//fileReader reads a file through a reader of its whole torrent, positions
+// are relative to the file
+type fileReader struct {
+	ctx  context.Context
+	r    torrentReader
+	file torrentFile
+	opts ReaderOptions
+	pos  int64
+	//prioritized is the end of the region last prioritized
+	prioritized int64
+}
+
+//torrentReader is what a fileReader uses of a torrent.Reader
+type torrentReader interface {
+	io.Seeker
+	io.Closer
+	ReadContext(ctx context.Context, p []byte) (int, error)
+}
+
+//torrentFile is what a fileReader uses of a *torrent.File
+type torrentFile interface {
+	Length() int64
+	Offset() int64
+	PrioritizeRegion(off, length int64)
+}
+
+func (fr *fileReader) Read(p []byte) (int, error) {
+	left := fr.file.Length() - fr.pos
+	if left <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > left {
+		p = p[:left]
+	}
+	// The library's readahead follows the reads but the rest of the file
+	// is downloaded along with it, the region ahead is prioritized again
+	// once half of it was read
+	if fr.pos+fr.opts.Readahead/2 >= fr.prioritized {
+		fr.prioritize()
+	}
+	ctx, cancel := fr.ctx, context.CancelFunc(func() {})
+	if fr.opts.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(fr.ctx, fr.opts.Timeout)
+	}
+	defer cancel()
+	n, err := fr.r.ReadContext(ctx, p)
+	fr.pos += int64(n)
+	if err == context.DeadlineExceeded && fr.ctx.Err() == nil {
+		err = ErrReadTimeout
+	}
+	return n, err
+}
+
+func (fr *fileReader) Seek(offset int64, whence int) (int64, error) {
+	var pos int64
+	switch whence {
+	case io.SeekStart:
+		pos = offset
+	case io.SeekCurrent:
+		pos = fr.pos + offset
+	case io.SeekEnd:
+		pos = fr.file.Length() + offset
+	default:
+		return fr.pos, errors.New("invalid whence")
+	}
+	if pos < 0 {
+		return fr.pos, errors.New("negative position")
+	}
+	_, err := fr.r.Seek(fr.file.Offset()+pos, io.SeekStart)
+	if err != nil {
+		return fr.pos, err
+	}
+	fr.pos = pos
+	fr.prioritize()
+	return pos, nil
+}
+
+//prioritize the pieces of the readahead at fr's position
+func (fr *fileReader) prioritize() {
+	if fr.opts.Readahead <= 0 || fr.pos >= fr.file.Length() {
+		return
+	}
+	fr.file.PrioritizeRegion(fr.pos, fr.opts.Readahead)
+	fr.prioritized = fr.pos + fr.opts.Readahead
+}
+
+func (fr *fileReader) Close() error {
+	return fr.r.Close()
+}
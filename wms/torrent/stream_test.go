@@ -0,0 +1,122 @@
+package torrent
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+//stubTorrent is a torrent whose data is all downloaded, but for the
+// reads that stall, holding a single stubFile
+type stubTorrent struct {
+	*bytes.Reader
+	stall bool
+}
+
+func (t *stubTorrent) ReadContext(ctx context.Context, p []byte) (int, error) {
+	if t.stall {
+		<-ctx.Done()
+		return 0, ctx.Err()
+	}
+	return t.Read(p)
+}
+
+func (t *stubTorrent) Close() error {
+	return nil
+}
+
+//stubFile is a file of a stubTorrent recording the regions prioritized
+type stubFile struct {
+	offset, length int64
+	regions        [][2]int64
+}
+
+func (f *stubFile) Length() int64 {
+	return f.length
+}
+
+func (f *stubFile) Offset() int64 {
+	return f.offset
+}
+
+func (f *stubFile) PrioritizeRegion(off, length int64) {
+	f.regions = append(f.regions, [2]int64{off, length})
+}
+
+func TestFileReaderReadahead(t *testing.T) {
+	data := make([]byte, 1200)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	for _, test := range []struct {
+		name      string
+		readahead int64
+		//seek is where the reader starts, reads is the number of reads of 50
+		// bytes from there
+		seek    int64
+		reads   int
+		regions [][2]int64
+	}{
+		{"sequential", 200, 0, 5, [][2]int64{{0, 200}, {100, 200}, {200, 200}}},
+		{"small readahead", 20, 0, 2, [][2]int64{{0, 20}, {50, 20}}},
+		{"after a seek", 200, 500, 2, [][2]int64{{500, 200}}},
+		{"to the end", 200, 900, 3, [][2]int64{{900, 200}}},
+		{"at the end", 200, 1000, 1, nil},
+		{"no readahead", 0, 0, 5, nil},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			file := &stubFile{offset: 100, length: 1000}
+			fr := &fileReader{
+				ctx:  context.Background(),
+				r:    &stubTorrent{Reader: bytes.NewReader(data)},
+				file: file,
+				opts: ReaderOptions{Readahead: test.readahead},
+			}
+			if _, err := fr.Seek(test.seek, io.SeekStart); err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < test.reads; i++ {
+				pos := fr.pos
+				p := make([]byte, 50)
+				n, err := fr.Read(p)
+				if pos >= file.length {
+					if err != io.EOF {
+						t.Fatalf("read at the end: %v", err)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				want := data[file.offset+pos : file.offset+pos+50]
+				if !bytes.Equal(p[:n], want) {
+					t.Fatalf("read %v at %d, want %v", p[:n], pos, want)
+				}
+			}
+			if !reflect.DeepEqual(file.regions, test.regions) {
+				t.Errorf("prioritized %v, want %v", file.regions, test.regions)
+			}
+		})
+	}
+}
+
+func TestFileReaderTimeout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	fr := &fileReader{
+		ctx:  ctx,
+		r:    &stubTorrent{Reader: bytes.NewReader(nil), stall: true},
+		file: &stubFile{length: 10},
+		opts: ReaderOptions{Timeout: 10 * time.Millisecond},
+	}
+	if _, err := fr.Read(make([]byte, 10)); err != ErrReadTimeout {
+		t.Errorf("stalled read: got %v, want %v", err, ErrReadTimeout)
+	}
+	// Reads of requests gone aren't timeouts
+	cancel()
+	if _, err := fr.Read(make([]byte, 10)); err != context.Canceled {
+		t.Errorf("cancelled read: got %v, want %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,209 @@
+package torrent
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+)
+
+var (
+	//ErrNoInfo is returned when a torrent's info is needed before it was
+	// received
+	ErrNoInfo = errors.New("torrent info not received yet")
+	//ErrFileNotFound is returned when a torrent has no file with a path
+	ErrFileNotFound = errors.New("torrent file not found")
+)
+
+//eventBuffer is how many events a subscriber may fall behind by before the
+// newer ones are dropped
+const eventBuffer = 256
+
+//Torrents is a torrent client whose torrents are identified by their
+// info-hash in hex, it is safe for concurrent use. Client implements it and
+// Fake does for tests.
+type Torrents interface {
+	//AddLink adds the torrent of a magnet link and returns its info-hash,
+	// adding a torrent twice does nothing
+	AddLink(link string) (string, error)
+	//AddTorrentFile adds the torrent of the .torrent file in data and
+	// returns its info-hash, adding a torrent twice does nothing
+	AddTorrentFile(data []byte) (string, error)
+	//Has returns true if the torrent with the given info-hash was added and
+	// not dropped since
+	Has(hash string) bool
+	//WaitForInfo waits until the info of the torrent with the given
+	// info-hash is received and returns it. It returns ErrTorrentNotFound if
+	// the torrent is dropped first and ctx's error if ctx is done first.
+	WaitForInfo(ctx context.Context, hash string) (*Info, error)
+	//Download the files of the torrent with the given info-hash at paths,
+	// which must be the paths of its info
+	Download(hash string, paths ...string) error
+	//FileComplete returns true if every piece of the file at path of the
+	// torrent with the given info-hash is complete
+	FileComplete(hash, path string) (bool, error)
+	//FileProgress returns the progress of every file of the torrent with
+	// the given info-hash, in the order of its info
+	FileProgress(hash string) ([]FileProgress, error)
+	//NewReader returns a reader of the file at path of the torrent with the
+	// given info-hash, its reads fail with ctx's error once ctx is done
+	NewReader(
+		ctx context.Context,
+		hash, path string,
+		opts ReaderOptions,
+	) (FileReader, error)
+	//IsComplete returns true if the torrent with the given info-hash has
+	// every piece of the files being downloaded, or of all its files if
+	// none is
+	IsComplete(hash string) bool
+	//Progress returns the progress of the torrent with the given info-hash
+	Progress(hash string) (Progress, error)
+	//SetLimits of the torrent with the given info-hash
+	SetLimits(hash string, limits Limits) error
+	//Subscribe to the events of the torrent with the given info-hash, the
+	// subscription is closed when the torrent is dropped
+	Subscribe(hash string) (*Subscription, error)
+	//DropTorrent stops downloading and seeding the torrent with the given
+	// info-hash, it does nothing if there is none
+	DropTorrent(hash string)
+	Policy() Policy
+	SetPolicy(policy Policy) error
+	//ListenAddr returns the address peers connect to, nil if not listening
+	ListenAddr() net.Addr
+	Stats() Stats
+	//Close drops every torrent and closes the client
+	Close()
+}
+
+//Info of a torrent
+type Info struct {
+	Name string
+	//Length of all the files in bytes
+	Length      int64
+	PieceLength int64
+	Files       []File
+}
+
+//File of a torrent
+type File struct {
+	//Path of the file relative to the download directory, slash separated
+	Path string
+	//Offset of the file within the torrent's data in bytes
+	Offset int64
+	Length int64
+}
+
+//FileProgress of a file of a torrent
+type FileProgress struct {
+	File
+	//Completed is the number of bytes of the file downloaded and verified
+	Completed int64
+	//Selected is true if the file is being downloaded
+	Selected bool
+}
+
+//Progress of a torrent, of the files being downloaded once some are
+type Progress struct {
+	//Completed is the number of bytes downloaded and verified
+	Completed int64
+	//Missing is the number of bytes still missing
+	Missing int64
+	//Peers is the number of peers the torrent is connected to
+	Peers int
+}
+
+//EventType is what happened to a torrent
+type EventType string
+
+//Types of events
+const (
+	//EventPieceComplete is sent once a piece is downloaded and verified
+	EventPieceComplete EventType = "piece_complete"
+	//EventFileComplete is sent once every piece of a file being downloaded
+	// is complete, or as soon as it is downloaded if it already is
+	EventFileComplete EventType = "file_complete"
+)
+
+//Event of a torrent
+type Event struct {
+	Type EventType
+	//Hash is the torrent's info-hash in hex
+	Hash string
+	//Piece completed, for piece events
+	Piece int
+	//Path of the file completed, for file events
+	Path string
+}
+
+//Subscription to the events of a torrent
+type Subscription struct {
+	events chan Event
+	owner  *broadcaster
+}
+
+//Events returns the channel the events are sent on, it is closed once the
+// subscription is
+func (sub *Subscription) Events() <-chan Event {
+	return sub.events
+}
+
+//Close the subscription, closing it twice does nothing
+func (sub *Subscription) Close() {
+	sub.owner.unsubscribe(sub)
+}
+
+//broadcaster sends the events of a torrent to its subscribers, events
+// are dropped for the subscribers that fell behind by eventBuffer
+type broadcaster struct {
+	mu     sync.Mutex
+	subs   map[*Subscription]bool
+	closed bool
+}
+
+//subscribe returns a new subscription, closed already if b is
+func (b *broadcaster) subscribe() *Subscription {
+	sub := &Subscription{events: make(chan Event, eventBuffer), owner: b}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(sub.events)
+		return sub
+	}
+	if b.subs == nil {
+		b.subs = make(map[*Subscription]bool)
+	}
+	b.subs[sub] = true
+	return sub
+}
+
+func (b *broadcaster) unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.subs[sub] {
+		delete(b.subs, sub)
+		close(sub.events)
+	}
+}
+
+//publish e to the subscribers without blocking
+func (b *broadcaster) publish(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for sub := range b.subs {
+		select {
+		case sub.events <- e:
+		default:
+		}
+	}
+}
+
+//close every subscription, later ones are closed as soon as they are made
+func (b *broadcaster) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for sub := range b.subs {
+		close(sub.events)
+	}
+	b.subs = nil
+	b.closed = true
+}